@@ -0,0 +1,337 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
+	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
+)
+
+// ocReceiverType is the key of the OpenCensus receiver in the "receivers" section.
+const ocReceiverType = "opencensus"
+
+// agent holds the exporters, processors and receivers of the agent and the configuration
+// used to create them, so a reload only replaces the ones whose configuration changed.
+type agent struct {
+	logger         *zap.Logger
+	v              *viper.Viper
+	asyncErrorChan chan<- error
+
+	// The receivers send their data to the switches so the processors and exporters
+	// can be replaced without restarting the receivers.
+	traceSink   *reload.TraceSwitch
+	metricsSink *reload.MetricsSwitch
+
+	settings             reload.Settings
+	exporters            map[string]*exporterInstance
+	processorShutdownFns []func(context.Context) error
+	receivers            map[string]func() error
+	// ocReceiver is the running OpenCensus receiver, if any, so its trace config can be
+	// updated without restarting it.
+	ocReceiver *opencensusreceiver.Receiver
+	// shutdownTimeout is how long the processors and exporters have to send the data
+	// they hold when the agent stops.
+	shutdownTimeout time.Duration
+	// retirer shuts down the processors and exporters replaced by a reload.
+	retirer *reload.Retirer
+}
+
+// exporterInstance holds the exporters created for an entry of the "exporters" section.
+type exporterInstance struct {
+	traceExporters   []consumer.TraceConsumer
+	metricsExporters []consumer.MetricsConsumer
+	doneFns          []func() error
+}
+
+func (ei *exporterInstance) stop() error {
+	var errs []error
+	for _, doneFn := range ei.doneFns {
+		if err := doneFn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+func newAgent(logger *zap.Logger, v *viper.Viper, asyncErrorChan chan<- error) *agent {
+	return &agent{
+		logger:         logger,
+		v:              v,
+		asyncErrorChan: asyncErrorChan,
+		traceSink:      reload.NewTraceSwitch(multiconsumer.NewTraceProcessor(nil)),
+		metricsSink:    reload.NewMetricsSwitch(multiconsumer.NewMetricsProcessor(nil)),
+		exporters:      make(map[string]*exporterInstance),
+		receivers:      make(map[string]func() error),
+		retirer:        reload.NewRetirer(reload.RetireDelay, 0),
+	}
+}
+
+// start creates the exporters and starts the receivers of the configuration. On error
+// everything already started is stopped.
+func (a *agent) start(acfg *config.Config) error {
+	settings := reload.Settings(a.v.AllSettings())
+	if err := a.applyExporters(nil, settings, acfg); err != nil {
+		return fmt.Errorf("Config: failed to create exporters from YAML: %v", err)
+	}
+	if err := a.applyReceivers(nil, settings, acfg); err != nil {
+		a.stop(context.Background())
+		return err
+	}
+	a.settings = settings
+	a.shutdownTimeout = acfg.ShutdownTimeout()
+	a.retirer.SetTimeout(a.shutdownTimeout)
+	return nil
+}
+
+// reload re-reads the configuration file and applies it. Exporters and receivers whose
+// configuration did not change keep running. If the new configuration is invalid, or
+// any exporter fails to be created, the running components are not modified.
+func (a *agent) reload() error {
+	if err := config.ReadConfigFile(a.v, a.v.ConfigFileUsed()); err != nil {
+		return fmt.Errorf("cannot read the YAML file: %v", err)
+	}
+
+	var acfg config.Config
+	if err := a.v.Unmarshal(&acfg); err != nil {
+		return fmt.Errorf("error unmarshalling yaml config file: %v", err)
+	}
+	if err := acfg.CheckLogicalConflicts(); err != nil {
+		return fmt.Errorf("configuration logical error: %v", err)
+	}
+
+	settings := reload.Settings(a.v.AllSettings())
+	if err := a.applyExporters(a.settings, settings, &acfg); err != nil {
+		return err
+	}
+	// Receivers that fail to start are not kept, so they are started again by the next
+	// reload.
+	err := a.applyReceivers(a.settings, settings, &acfg)
+	a.settings = settings
+	a.shutdownTimeout = acfg.ShutdownTimeout()
+	a.retirer.SetTimeout(a.shutdownTimeout)
+	return err
+}
+
+// applyExporters creates the exporters whose configuration changed from oldSettings to
+// newSettings. If any exporter or processor changed, the processor chains of acfg are
+// recreated in front of all current exporters and replace the sink of the receivers.
+// The previous processors, and the exporters no longer used, are shut down after the
+// replacement.
+func (a *agent) applyExporters(oldSettings, newSettings reload.Settings, acfg *config.Config) error {
+	names := exporterNames(newSettings)
+	exporters := make(map[string]*exporterInstance, len(names))
+	var created []*exporterInstance
+	stopCreated := func() {
+		for _, ei := range created {
+			ei.stop()
+		}
+	}
+	for _, name := range names {
+		if ei, ok := a.exporters[name]; ok && !reload.Changed(oldSettings, newSettings, "exporters", name) {
+			exporters[name] = ei
+			continue
+		}
+		tes, mes, doneFns, err := config.ExporterFromViperConfig(a.logger, a.v, name)
+		if err != nil {
+			stopCreated()
+			return err
+		}
+		ei := &exporterInstance{traceExporters: tes, metricsExporters: mes, doneFns: doneFns}
+		created = append(created, ei)
+		exporters[name] = ei
+	}
+
+	if oldSettings != nil && len(created) == 0 && len(exporters) == len(a.exporters) &&
+		!reload.Changed(oldSettings, newSettings, "processors") &&
+		!reload.Changed(oldSettings, newSettings, "processor-chains") {
+		// The processors keep the data they hold, e.g.: queued batches.
+		return nil
+	}
+
+	var traceExporters []consumer.TraceConsumer
+	var metricsExporters []consumer.MetricsConsumer
+	for _, name := range names {
+		traceExporters = append(traceExporters, exporters[name].traceExporters...)
+		metricsExporters = append(metricsExporters, exporters[name].metricsExporters...)
+	}
+	traceHead, traceShutdownFns, err := config.TraceProcessorsFromViperConfig(
+		a.v, acfg.TraceProcessors(), multiconsumer.NewTraceProcessor(traceExporters))
+	if err != nil {
+		stopCreated()
+		return err
+	}
+	metricsHead, metricsShutdownFns, err := config.MetricsProcessorsFromViperConfig(
+		a.v, acfg.MetricsProcessors(), multiconsumer.NewMetricsProcessor(metricsExporters))
+	if err != nil {
+		for _, shutdownFn := range traceShutdownFns {
+			shutdownFn(context.Background())
+		}
+		stopCreated()
+		return err
+	}
+	a.traceSink.Swap(traceHead)
+	a.metricsSink.Swap(metricsHead)
+	if len(acfg.TraceProcessors()) > 0 || len(acfg.MetricsProcessors()) > 0 {
+		a.logger.Info("Processors enabled",
+			zap.Strings("traces", acfg.TraceProcessors()),
+			zap.Strings("metrics", acfg.MetricsProcessors()))
+	}
+
+	var shutdownFns []func(context.Context) error
+	for name, ei := range a.exporters {
+		if exporters[name] == ei {
+			continue
+		}
+		name, ei := name, ei
+		shutdownFns = append(shutdownFns, func(ctx context.Context) error {
+			if err := exporterhelper.StopWithin(ctx, ei.stop); err != nil {
+				return fmt.Errorf("failed to stop exporter %q: %v", name, err)
+			}
+			a.logger.Info("Exporter stopped", zap.String("exporter", name))
+			return nil
+		})
+	}
+	if len(a.processorShutdownFns) > 0 {
+		// The previous processors may still hold data, e.g.: queued batches, so they and
+		// the exporters after them are shut down later.
+		a.retire(append(a.processorShutdownFns, shutdownFns...))
+	} else {
+		a.shutdownAll(context.Background(), shutdownFns)
+	}
+	a.processorShutdownFns = append(traceShutdownFns, metricsShutdownFns...)
+	a.exporters = exporters
+	return nil
+}
+
+// retire calls the given shutdown functions after reload.RetireDelay, or on shutdown if
+// that happens first.
+func (a *agent) retire(shutdownFns []func(context.Context) error) {
+	a.retirer.Retire(func(ctx context.Context) {
+		a.shutdownAll(ctx, shutdownFns)
+	})
+}
+
+// shutdownAll calls the given shutdown functions in order, logging the data they lost.
+func (a *agent) shutdownAll(ctx context.Context, shutdownFns []func(context.Context) error) {
+	for _, shutdownFn := range shutdownFns {
+		if err := shutdownFn(ctx); err != nil {
+			a.logger.Warn("Failed to shut down", zap.Error(err))
+		}
+	}
+}
+
+// applyReceivers restarts the receivers whose configuration changed from oldSettings to
+// newSettings, stops the ones no longer configured, and starts the new ones.
+func (a *agent) applyReceivers(oldSettings, newSettings reload.Settings, acfg *config.Config) error {
+	var errs []error
+
+	// The OpenCensus receiver always runs, with its defaults if not configured. A change
+	// of its trace config only is pushed to the libraries connected to it, without
+	// restarting it.
+	oldOCSettings, newOCSettings := ocReceiverSettings(oldSettings), ocReceiverSettings(newSettings)
+	if doneFn, ok := a.receivers[ocReceiverType]; !ok || reload.ChangedExcept(oldOCSettings, newOCSettings, "trace_config") {
+		if ok {
+			doneFn()
+			delete(a.receivers, ocReceiverType)
+			a.ocReceiver = nil
+		}
+		ocr, err := runOCReceiver(a.logger, acfg, a.traceSink, a.metricsSink, a.asyncErrorChan)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			a.receivers[ocReceiverType] = ocr.Stop
+			a.ocReceiver = ocr
+		}
+	} else if reload.Changed(oldOCSettings, newOCSettings, "trace_config") {
+		if defaultConfig, rules, err := acfg.OpenCensusReceiverTraceConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("OpenCensus receiver trace config: %v", err))
+		} else {
+			a.ocReceiver.SetTraceConfig(defaultConfig, rules)
+			a.logger.Info("OpenCensus receiver trace config updated")
+		}
+	}
+
+	for _, receiverType := range receiverTypes() {
+		if doneFn, ok := a.receivers[receiverType]; ok {
+			if !reload.Changed(oldSettings, newSettings, "receivers", receiverType) {
+				continue
+			}
+			doneFn()
+			delete(a.receivers, receiverType)
+			log.Printf("Stopped %s receiver", receiverType)
+		}
+		doneFn, err := runReceiver(a.logger, a.v, receiverType, a.traceSink, a.metricsSink, a.asyncErrorChan)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if doneFn != nil {
+			a.receivers[receiverType] = doneFn
+		}
+	}
+
+	return internal.CombineErrors(errs)
+}
+
+// stop stops all receivers, then all processors and then all exporters, so data already
+// received has a chance to be exported. The processors and exporters have until ctx is
+// done to send the data they hold.
+func (a *agent) stop(ctx context.Context) {
+	for _, doneFn := range a.receivers {
+		doneFn()
+	}
+	a.receivers = make(map[string]func() error)
+	a.ocReceiver = nil
+	a.shutdownAll(ctx, a.processorShutdownFns)
+	a.processorShutdownFns = nil
+	a.retirer.Shutdown(ctx)
+	for name, ei := range a.exporters {
+		if err := exporterhelper.StopWithin(ctx, ei.stop); err != nil {
+			a.logger.Warn("Failed to stop exporter", zap.String("exporter", name), zap.Error(err))
+		}
+	}
+	a.exporters = make(map[string]*exporterInstance)
+}
+
+// ocReceiverSettings returns the settings of the OpenCensus receiver, nil if not configured.
+func ocReceiverSettings(settings reload.Settings) reload.Settings {
+	ocSettings, _ := settings.Lookup("receivers", ocReceiverType).(map[string]interface{})
+	return ocSettings
+}
+
+// exporterNames returns the sorted names of the entries of the "exporters" section.
+func exporterNames(settings reload.Settings) []string {
+	exporters, _ := settings.Lookup("exporters").(map[string]interface{})
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
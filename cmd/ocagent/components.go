@@ -0,0 +1,46 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// The components linked in the agent, each package registers the factories of its
+// components from an init function. In-house components can be added to a build of the
+// agent by importing their packages here.
+import (
+	// Receivers, the trace receivers are registered in receivers.go.
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/prometheus"
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/vmmetrics"
+
+	// Processors
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/processor/headsampling"
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/processor/memorylimiter"
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
+	_ "github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+	_ "github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
+
+	// Exporters
+	_ "github.com/census-instrumentation/opencensus-service/exporter/awsexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/datadogexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/failoverexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/honeycombexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/jaegerexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/kafkaexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/loadbalancingexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/opencensusexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/prometheusexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/stackdriverexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/wavefrontexporter"
+	_ "github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
+)
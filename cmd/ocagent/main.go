@@ -34,16 +34,12 @@ import (
 	"github.com/census-instrumentation/opencensus-service/internal/config"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 	"github.com/census-instrumentation/opencensus-service/internal/pprofserver"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
 	"github.com/census-instrumentation/opencensus-service/internal/version"
 	"github.com/census-instrumentation/opencensus-service/internal/zpagesserver"
 	"github.com/census-instrumentation/opencensus-service/observability"
-	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
-	"github.com/census-instrumentation/opencensus-service/receiver/jaegerreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
-	"github.com/census-instrumentation/opencensus-service/receiver/prometheusreceiver"
-	"github.com/census-instrumentation/opencensus-service/receiver/vmmetricsreceiver"
-	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver"
-	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver/zipkinscribereceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver/octrace"
 )
 
 var rootCmd = &cobra.Command{
@@ -66,10 +62,10 @@ func init() {
 			fmt.Print(version.Info())
 		},
 	}
-	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(versionCmd, newValidateCommand(), newPrintConfigCommand())
 	rootCmd.PersistentFlags().StringVarP(&configYAMLFile, "config", "c", "config.yaml", "The YAML file with the configurations for the agent and various exporters")
 
-	viperutils.AddFlags(viperCfg, rootCmd, pprofserver.AddFlags)
+	viperutils.AddFlags(viperCfg, rootCmd, pprofserver.AddFlags, reload.AddFlags)
 }
 
 func main() {
@@ -79,8 +75,7 @@ func main() {
 }
 
 func runOCAgent() {
-	viperCfg.SetConfigFile(configYAMLFile)
-	err := viperCfg.ReadInConfig()
+	err := config.ReadConfigFile(viperCfg, configYAMLFile)
 	if err != nil {
 		log.Fatalf("Cannot read the YAML file %v error: %v", configYAMLFile, err)
 	}
@@ -112,21 +107,14 @@ func runOCAgent() {
 		log.Fatalf("Failed to start net/http/pprof: %v", err)
 	}
 
-	traceExporters, metricsExporters, closeFns, err := config.ExportersFromViperConfig(logger, viperCfg)
-	if err != nil {
-		log.Fatalf("Config: failed to create exporters from YAML: %v", err)
-	}
-
-	commonSpanSink := multiconsumer.NewTraceProcessor(traceExporters)
-	commonMetricsSink := multiconsumer.NewMetricsProcessor(metricsExporters)
-
-	// Add other receivers here as they are implemented
-	ocReceiverDoneFn, err := runOCReceiver(logger, &agentConfig, commonSpanSink, commonMetricsSink, asyncErrorChan)
-	if err != nil {
+	// Create the exporters and run the OpenCensus receiver and all the other receivers
+	// enabled in the configuration.
+	a := newAgent(logger, viperCfg, asyncErrorChan)
+	if err := a.start(&agentConfig); err != nil {
 		log.Fatal(err)
 	}
-	closeFns = append(closeFns, ocReceiverDoneFn)
 
+	var closeFns []func() error
 	// If zPages are enabled, run them
 	zPagesPort, zPagesEnabled := agentConfig.ZPagesPort()
 	if zPagesEnabled {
@@ -138,54 +126,17 @@ func runOCAgent() {
 		closeFns = append(closeFns, zCloseFn)
 	}
 
-	// TODO: Generalize the startup of these receivers when unifying them w/ collector
-	// If the Zipkin receiver is enabled, then run it
-	if agentConfig.ZipkinReceiverEnabled() {
-		zipkinReceiverAddr := agentConfig.ZipkinReceiverAddress()
-		zipkinReceiverDoneFn, err := runZipkinReceiver(zipkinReceiverAddr, commonSpanSink, asyncErrorChan)
-		if err != nil {
-			log.Fatal(err)
-		}
-		closeFns = append(closeFns, zipkinReceiverDoneFn)
-	}
-
-	if agentConfig.ZipkinScribeReceiverEnabled() {
-		zipkinScribeDoneFn, err := runZipkinScribeReceiver(agentConfig.ZipkinScribeConfig(), commonSpanSink, asyncErrorChan)
-		if err != nil {
-			log.Fatal(err)
-		}
-		closeFns = append(closeFns, zipkinScribeDoneFn)
-	}
-
-	if agentConfig.JaegerReceiverEnabled() {
-		collectorHTTPPort, collectorThriftPort := agentConfig.JaegerReceiverPorts()
-		jaegerDoneFn, err := runJaegerReceiver(collectorThriftPort, collectorHTTPPort, commonSpanSink, asyncErrorChan)
-		if err != nil {
-			log.Fatal(err)
-		}
-		closeFns = append(closeFns, jaegerDoneFn)
-	}
-
-	// If the Prometheus receiver is enabled, then run it.
-	if agentConfig.PrometheusReceiverEnabled() {
-		promDoneFn, err := runPrometheusReceiver(viperCfg, commonMetricsSink, asyncErrorChan)
-		if err != nil {
-			log.Fatal(err)
-		}
-		closeFns = append(closeFns, promDoneFn)
-	}
-
-	// If the VMMetrics receiver is enabled, then run it.
-	if agentConfig.VMMetricsReceiverEnabled() {
-		vmmDoneFn, err := runVMMetricsReceiver(viperCfg, commonMetricsSink, asyncErrorChan)
-		if err != nil {
-			log.Fatal(err)
-		}
-		closeFns = append(closeFns, vmmDoneFn)
+	reloadRequests, stopReload, err := reload.Listen(asyncErrorChan, viperCfg, logger)
+	if err != nil {
+		log.Fatal(err)
 	}
+	closeFns = append(closeFns, stopReload)
 
 	// Always cleanup finally
 	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+		a.stop(ctx)
+		cancel()
 		for _, closeFn := range closeFns {
 			if closeFn != nil {
 				closeFn()
@@ -196,26 +147,43 @@ func runOCAgent() {
 	signalsChan := make(chan os.Signal, 1)
 	signal.Notify(signalsChan, os.Interrupt, syscall.SIGTERM)
 
-	select {
-	case err = <-asyncErrorChan:
-		log.Fatalf("Asynchronous error %q, terminating process", err)
-	case s := <-signalsChan:
-		log.Printf("Received %q signal from OS, terminating process", s)
+	for {
+		select {
+		case err = <-asyncErrorChan:
+			log.Fatalf("Asynchronous error %q, terminating process", err)
+		case s := <-signalsChan:
+			log.Printf("Received %q signal from OS, terminating process", s)
+			return
+		case req := <-reloadRequests:
+			log.Printf("Reloading configuration, requested by %s", req.Source)
+			err := a.reload()
+			if err != nil {
+				log.Printf("Failed to reload configuration: %v", err)
+			} else {
+				log.Printf("Configuration reloaded")
+			}
+			req.Done(err)
+		}
 	}
 }
 
-func runOCReceiver(logger *zap.Logger, acfg *config.Config, tc consumer.TraceConsumer, mc consumer.MetricsConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
+func runOCReceiver(logger *zap.Logger, acfg *config.Config, tc consumer.TraceConsumer, mc consumer.MetricsConsumer, asyncErrorChan chan<- error) (ocr *opencensusreceiver.Receiver, err error) {
 	tlsCredsOption, hasTLSCreds, err := acfg.OpenCensusReceiverTLSCredentialsServerOption()
 	if err != nil {
 		return nil, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
 	}
+	defaultTraceConfig, traceConfigRules, err := acfg.OpenCensusReceiverTraceConfig()
+	if err != nil {
+		return nil, fmt.Errorf("OpenCensus receiver trace config: %v", err)
+	}
 	addr := acfg.OpenCensusReceiverAddress()
 	corsOrigins := acfg.OpenCensusReceiverCorsAllowedOrigins()
-	ocr, err := opencensusreceiver.New(addr,
+	ocr, err = opencensusreceiver.New(addr,
 		tc,
 		mc,
 		tlsCredsOption,
-		opencensusreceiver.WithCorsOrigins(corsOrigins))
+		opencensusreceiver.WithCorsOrigins(corsOrigins),
+		opencensusreceiver.WithTraceReceiverOptions(octrace.WithTraceConfig(defaultTraceConfig, traceConfigRules)))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the OpenCensus receiver on address %q: error %v", addr, err)
@@ -259,91 +227,5 @@ func runOCReceiver(logger *zap.Logger, acfg *config.Config, tc consumer.TraceCon
 			zap.String("key_file", tlsCreds.KeyFile))
 	}
 
-	doneFn = ocr.Stop
-	return doneFn, nil
-}
-
-func runJaegerReceiver(collectorThriftPort, collectorHTTPPort int, next consumer.TraceConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	config := &jaegerreceiver.Configuration{
-		CollectorThriftPort: collectorThriftPort,
-		CollectorHTTPPort:   collectorHTTPPort,
-
-		// TODO: (@odeke-em, @pjanotti) send a change
-		// to dynamically retrieve the Jaeger Agent's ports
-		// and not use their defaults of 5778, 6831, 6832
-	}
-	jtr, err := jaegerreceiver.New(context.Background(), config, next)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create new Jaeger receiver: %v", err)
-	}
-	if err := jtr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("failed to start Jaeger receiver: %v", err)
-	}
-	doneFn = func() error {
-		return jtr.StopTraceReception(context.Background())
-	}
-	log.Printf("Running Jaeger receiver with CollectorThriftPort %d CollectHTTPPort %d", collectorThriftPort, collectorHTTPPort)
-	return doneFn, nil
-}
-
-func runZipkinReceiver(addr string, next consumer.TraceConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	zi, err := zipkinreceiver.New(addr, next)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create the Zipkin receiver: %v", err)
-	}
-
-	if err := zi.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("cannot start Zipkin receiver with address %q: %v", addr, err)
-	}
-	doneFn = func() error {
-		return zi.StopTraceReception(context.Background())
-	}
-	log.Printf("Running Zipkin receiver with address %q", addr)
-	return doneFn, nil
-}
-
-func runZipkinScribeReceiver(config *config.ScribeReceiverConfig, next consumer.TraceConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	zs, err := zipkinscribereceiver.NewReceiver(config.Address, config.Port, config.Category, next)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create the Zipkin Scribe receiver: %v", err)
-	}
-
-	if err := zs.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("cannot start Zipkin Scribe receiver with %v: %v", config, err)
-	}
-	doneFn = func() error {
-		return zs.StopTraceReception(context.Background())
-	}
-	log.Printf("Running Zipkin Scribe receiver with %+v", *config)
-	return doneFn, nil
-}
-
-func runPrometheusReceiver(v *viper.Viper, next consumer.MetricsConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	pmr, err := prometheusreceiver.New(v.Sub("receivers.prometheus"), next)
-	if err != nil {
-		return nil, err
-	}
-	if err := pmr.StartMetricsReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, err
-	}
-	doneFn = func() error {
-		return pmr.StopMetricsReception(context.Background())
-	}
-	log.Print("Running Prometheus receiver")
-	return doneFn, nil
-}
-
-func runVMMetricsReceiver(v *viper.Viper, next consumer.MetricsConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	vmr, err := vmmetricsreceiver.New(v.Sub("receivers.vmmetrics"), next)
-	if err != nil {
-		return nil, err
-	}
-	if err := vmr.StartMetricsReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, err
-	}
-	doneFn = func() error {
-		return vmr.StopMetricsReception(context.Background())
-	}
-	log.Print("Running VMMetrics receiver")
-	return doneFn, nil
+	return ocr, nil
 }
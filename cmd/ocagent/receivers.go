@@ -0,0 +1,176 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
+	"github.com/census-instrumentation/opencensus-service/receiver/jaegerreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver/zipkinscribereceiver"
+)
+
+// The trace receivers of the agent use the configuration settings of the agent, that
+// differ from the ones used by the collector, so their factories are registered here.
+func init() {
+	receiver.RegisterTraceReceiverFactory(newJaegerReceiverFactory())
+	receiver.RegisterTraceReceiverFactory(newZipkinReceiverFactory())
+	receiver.RegisterTraceReceiverFactory(newZipkinScribeReceiverFactory())
+}
+
+func newJaegerReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		"jaeger",
+		func() interface{} { return &config.ReceiverConfig{} },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rCfg := cfg.(*config.ReceiverConfig)
+			jCfg := &jaegerreceiver.Configuration{
+				CollectorThriftPort: rCfg.CollectorThriftPort,
+				CollectorHTTPPort:   rCfg.CollectorHTTPPort,
+
+				SamplingStrategiesFile:  rCfg.SamplingStrategiesFile,
+				BaggageRestrictionsFile: rCfg.BaggageRestrictionsFile,
+
+				// TODO: (@odeke-em, @pjanotti) send a change
+				// to dynamically retrieve the Jaeger Agent's ports
+				// and not use their defaults of 5778, 6831, 6832
+			}
+			return jaegerreceiver.New(context.Background(), jCfg, next)
+		})
+	return f
+}
+
+func newZipkinReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		"zipkin",
+		func() interface{} {
+			return &config.ReceiverConfig{Address: zipkinexporter.DefaultZipkinEndpointHostPort}
+		},
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			// Use the agent configuration helper so the default address is applied.
+			acfg := &config.Config{Receivers: &config.Receivers{Zipkin: cfg.(*config.ReceiverConfig)}}
+			return zipkinreceiver.New(acfg.ZipkinReceiverAddress(), next)
+		})
+	return f
+}
+
+func newZipkinScribeReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		"zipkin-scribe",
+		func() interface{} { return &config.ScribeReceiverConfig{} },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			// Use the agent configuration helper so the default port and category are applied.
+			acfg := &config.Config{Receivers: &config.Receivers{Scribe: cfg.(*config.ScribeReceiverConfig)}}
+			sCfg := acfg.ZipkinScribeConfig()
+			return zipkinscribereceiver.NewReceiver(sCfg.Address, sCfg.Port, sCfg.Category, next)
+		})
+	return f
+}
+
+// receiverTypes returns the types of all registered receivers, sorted. The OpenCensus
+// receiver is not included since it is run by runOCReceiver.
+func receiverTypes() []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, factory := range receiver.TraceReceiverFactories() {
+		if !seen[factory.Type()] {
+			seen[factory.Type()] = true
+			types = append(types, factory.Type())
+		}
+	}
+	for _, factory := range receiver.MetricsReceiverFactories() {
+		if !seen[factory.Type()] {
+			seen[factory.Type()] = true
+			types = append(types, factory.Type())
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// runReceiver creates and starts the receivers of the given type if it has a configuration
+// under the "receivers" section, otherwise it returns a nil doneFn.
+func runReceiver(
+	logger *zap.Logger,
+	v *viper.Viper,
+	receiverType string,
+	tc consumer.TraceConsumer,
+	mc consumer.MetricsConsumer,
+	asyncErrorChan chan<- error,
+) (doneFn func() error, err error) {
+	rv := v.Sub("receivers." + receiverType)
+	if rv == nil {
+		return nil, nil
+	}
+
+	var doneFns []func() error
+	// Stop the trace receiver if the metrics one fails to start.
+	defer func() {
+		if err != nil {
+			for _, doneFn := range doneFns {
+				doneFn()
+			}
+		}
+	}()
+
+	if factory := receiver.GetTraceReceiverFactory(receiverType); factory != nil {
+		tr, err := factory.NewFromViper(rv, tc, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the %s receiver: %v", receiverType, err)
+		}
+		if err := tr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+			return nil, fmt.Errorf("cannot start the %s receiver: %v", receiverType, err)
+		}
+		doneFns = append(doneFns, func() error {
+			return tr.StopTraceReception(context.Background())
+		})
+	}
+
+	if factory := receiver.GetMetricsReceiverFactory(receiverType); factory != nil {
+		mr, err := factory.NewFromViper(rv, mc, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the %s receiver: %v", receiverType, err)
+		}
+		if err := mr.StartMetricsReception(context.Background(), asyncErrorChan); err != nil {
+			return nil, fmt.Errorf("cannot start the %s receiver: %v", receiverType, err)
+		}
+		doneFns = append(doneFns, func() error {
+			return mr.StopMetricsReception(context.Background())
+		})
+	}
+
+	log.Printf("Running %s receiver", receiverType)
+	return func() error {
+		var errs []error
+		for _, doneFn := range doneFns {
+			if err := doneFn(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return internal.CombineErrors(errs)
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+)
+
+func newValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "validate",
+		Short:        "Validate the configuration without running ocagent",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v, err := readConfigFile(configYAMLFile)
+			if err != nil {
+				return err
+			}
+			if err := validateConfig(v); err != nil {
+				return fmt.Errorf("invalid configuration: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration %q is valid\n", configYAMLFile)
+			return nil
+		},
+	}
+}
+
+func newPrintConfigCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "print-config",
+		Short:        "Print the configuration with the defaults of its components",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The references to environment variables and files are printed as
+			// written, so secrets are not disclosed.
+			v := viper.New()
+			v.SetConfigFile(configYAMLFile)
+			if err := v.ReadInConfig(); err != nil {
+				return fmt.Errorf("cannot read the YAML file %v error: %v", configYAMLFile, err)
+			}
+			out, err := yaml.Marshal(config.EffectiveSettings(v))
+			if err != nil {
+				return fmt.Errorf("failed to marshal the configuration: %v", err)
+			}
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+}
+
+func readConfigFile(file string) (*viper.Viper, error) {
+	v := viper.New()
+	if err := config.ReadConfigFile(v, file); err != nil {
+		return nil, fmt.Errorf("cannot read the YAML file %v error: %v", file, err)
+	}
+	return v, nil
+}
+
+// validateConfig checks the configuration in v as done by runOCAgent, and the
+// configuration of all receivers and exporters, without binding any port or
+// connecting to any backend.
+func validateConfig(v *viper.Viper) error {
+	var agentConfig config.Config
+	if err := v.Unmarshal(&agentConfig); err != nil {
+		return fmt.Errorf("error unmarshalling yaml config: %v", err)
+	}
+	if err := agentConfig.CheckLogicalConflicts(); err != nil {
+		return fmt.Errorf("configuration logical error: %v", err)
+	}
+
+	var errs []error
+	if err := agentConfig.OpenCensusReceiverTLSServerCredentials().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err))
+	}
+	if _, _, err := agentConfig.OpenCensusReceiverTraceConfig(); err != nil {
+		errs = append(errs, fmt.Errorf("OpenCensus receiver trace config: %v", err))
+	}
+
+	knownTypes := map[string]bool{ocReceiverType: true}
+	for _, receiverType := range receiverTypes() {
+		knownTypes[receiverType] = true
+		// Like runReceiver, only the receivers with a configuration are validated.
+		rv := v.Sub("receivers." + receiverType)
+		if rv == nil {
+			continue
+		}
+		if err := config.ValidateReceiverConfig(receiverType, rv); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for receiverType := range v.GetStringMap("receivers") {
+		if !knownTypes[receiverType] {
+			errs = append(errs, fmt.Errorf("unknown receiver type for %q", receiverType))
+		}
+	}
+
+	if err := config.ValidateExportersConfig(v); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.ValidateProcessorChains(v, agentConfig.TraceProcessors(), agentConfig.MetricsProcessors()); err != nil {
+		errs = append(errs, err)
+	}
+	return internal.CombineErrors(errs)
+}
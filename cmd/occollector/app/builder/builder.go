@@ -27,7 +27,9 @@ const (
 	receiversRoot     = "receivers"
 	jaegerEntry       = "jaeger"
 	opencensusEntry   = "opencensus"
+	prometheusEntry   = "prometheus"
 	shopifyEntry      = "shopify"
+	vmMetricsEntry    = "vmmetrics"
 	zipkinEntry       = "zipkin"
 	zipkinScribeEntry = "zipkin-scribe"
 
@@ -36,6 +38,7 @@ const (
 	jaegerReceiverFlg           = "receive-jaeger"
 	ocReceiverFlg               = "receive-oc-trace"
 	shopifyReceiverFlg          = "receive-shopify"
+	vmMetricsReceiverFlg        = "receive-vmmetrics"
 	zipkinReceiverFlg           = "receive-zipkin"
 	zipkinScribeReceiverFlg     = "receive-zipkin-scribe"
 	loggingExporterFlg          = "logging-exporter"
@@ -53,6 +56,7 @@ func Flags(flags *flag.FlagSet) {
 		fmt.Sprintf("Flag to run the Zipkin receiver, default settings: %+v", *NewDefaultZipkinReceiverCfg()))
 	flags.Bool(zipkinScribeReceiverFlg, false,
 		fmt.Sprintf("Flag to run the Zipkin Scribe receiver, default settings: %+v", *NewDefaultZipkinScribeReceiverCfg()))
+	flags.Bool(vmMetricsReceiverFlg, false, "Flag to run the VM metrics receiver with its default settings")
 	flags.Bool(loggingExporterFlg, false, "Flag to add a logging exporter (combine with log level DEBUG to log incoming spans)")
 	flags.Bool(useTailSamplingAlwaysSample, false, "Flag to use a tail-based sampling processor with an always sample policy, "+
 		"unless tail sampling setting is present on configuration file.")
@@ -79,6 +83,10 @@ type JaegerReceiverCfg struct {
 	ThriftTChannelPort int `mapstructure:"jaeger-thrift-tchannel-port"`
 	// ThriftHTTPPort is the port that the relay receives on for jaeger thrift http requests
 	ThriftHTTPPort int `mapstructure:"jaeger-thrift-http-port"`
+	// SamplingStrategiesFile is the file with the sampling strategies served to Jaeger clients
+	SamplingStrategiesFile string `mapstructure:"sampling-strategies-file"`
+	// BaggageRestrictionsFile is the file with the baggage restrictions served to Jaeger clients
+	BaggageRestrictionsFile string `mapstructure:"baggage-restrictions-file"`
 }
 
 // JaegerReceiverEnabled checks if the Jaeger receiver is enabled, via a command-line flag, environment
@@ -108,6 +116,11 @@ type OpenCensusReceiverCfg struct {
 
 	// TLSCredentials is a (cert_file, key_file) configuration.
 	TLSCredentials *config.TLSCredentials `mapstructure:"tls_credentials"`
+
+	// DisableTracing disables the reception of traces by the receiver.
+	DisableTracing bool `mapstructure:"disable-tracing"`
+	// DisableMetrics disables the reception of metrics by the receiver.
+	DisableMetrics bool `mapstructure:"disable-metrics"`
 }
 
 // OpenCensusReceiverEnabled checks if the OpenCensus receiver is enabled, via a command-line flag, environment
@@ -129,6 +142,40 @@ func (cfg *OpenCensusReceiverCfg) InitFromViper(v *viper.Viper) (*OpenCensusRece
 	return cfg, initFromViper(cfg, v, receiversRoot, opencensusEntry)
 }
 
+// Validate checks the settings that can be verified without starting the receiver,
+// i.e.: the TLS credentials.
+func (cfg *OpenCensusReceiverCfg) Validate() error {
+	return cfg.TLSCredentials.Validate()
+}
+
+// PrometheusReceiverEnabled checks if the Prometheus receiver is enabled. Since the receiver
+// requires scrape configurations it can only be enabled via the configuration file.
+func PrometheusReceiverEnabled(v *viper.Viper) bool {
+	return getViperSub(v, receiversRoot, prometheusEntry) != nil
+}
+
+// PrometheusReceiverViper returns the viper configuration of the Prometheus receiver, or
+// nil if it is not present.
+func PrometheusReceiverViper(v *viper.Viper) *viper.Viper {
+	return getViperSub(v, receiversRoot, prometheusEntry)
+}
+
+// VMMetricsReceiverEnabled checks if the VM metrics receiver is enabled, via a command-line flag,
+// environment variable, or configuration file.
+func VMMetricsReceiverEnabled(v *viper.Viper) bool {
+	return featureEnabled(v, vmMetricsReceiverFlg, receiversRoot, vmMetricsEntry)
+}
+
+// VMMetricsReceiverViper returns the viper configuration of the VM metrics receiver. If the
+// receiver was enabled only via command-line flag an empty configuration is returned so
+// the receiver defaults are used.
+func VMMetricsReceiverViper(v *viper.Viper) *viper.Viper {
+	if vmv := getViperSub(v, receiversRoot, vmMetricsEntry); vmv != nil {
+		return vmv
+	}
+	return viper.New()
+}
+
 // ShopifyReceiverCfg holds configuration for Shopify receiver.
 type ShopifyReceiverCfg struct {
 	// Port is the port that the receiver will use
@@ -214,6 +261,26 @@ func (cfg *ScribeReceiverCfg) InitFromViper(v *viper.Viper) (*ScribeReceiverCfg,
 	return cfg, initFromViper(cfg, v, receiversRoot, zipkinEntry)
 }
 
+// receiverFlags maps the types of the receivers that can be enabled via command-line
+// flag to the respective flag.
+var receiverFlags = map[string]string{
+	jaegerEntry:       jaegerReceiverFlg,
+	opencensusEntry:   ocReceiverFlg,
+	shopifyEntry:      shopifyReceiverFlg,
+	vmMetricsEntry:    vmMetricsReceiverFlg,
+	zipkinEntry:       zipkinReceiverFlg,
+	zipkinScribeEntry: zipkinScribeReceiverFlg,
+}
+
+// ReceiverEnabled checks if the receiver of the given type is enabled, via a command-line
+// flag, environment variable, or configuration file.
+func ReceiverEnabled(v *viper.Viper, receiverType string) bool {
+	if flg, ok := receiverFlags[receiverType]; ok && v.GetBool(flg) {
+		return true
+	}
+	return getViperSub(v, receiversRoot, receiverType) != nil
+}
+
 // Helper functions
 
 func initFromViper(cfg interface{}, v *viper.Viper, labels ...string) error {
@@ -22,6 +22,8 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 )
 
 func TestReceiversEnabledByPresenceWithDefaultSettings(t *testing.T) {
@@ -60,6 +62,13 @@ func TestReceiversEnabledByPresenceWithDefaultSettings(t *testing.T) {
 		t.Errorf("Incorrect config for Zipkin receiver, want %v got %v", wz, gz)
 	}
 
+	if !VMMetricsReceiverEnabled(v) {
+		t.Errorf("VM metrics receiver was expected to be enabled")
+	}
+	if PrometheusReceiverEnabled(v) {
+		t.Errorf("Prometheus receiver was not expected to be enabled")
+	}
+
 	wscrb := NewDefaultZipkinScribeReceiverCfg()
 	gscrb, err := wscrb.InitFromViper(v)
 	if err != nil {
@@ -82,6 +91,25 @@ func TestReceiversDisabledByPresenceWithDefaultSettings(t *testing.T) {
 	}
 }
 
+func TestReceiverEnabled(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/receivers_enabled.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+	v.Set(shopifyReceiverFlg, true)
+
+	for _, receiverType := range []string{"jaeger", "opencensus", "shopify", "vmmetrics", "zipkin", "zipkin-scribe"} {
+		if !ReceiverEnabled(v, receiverType) {
+			t.Errorf("ReceiverEnabled(%q) = false, want true", receiverType)
+		}
+	}
+	for _, receiverType := range []string{"prometheus", "in-house"} {
+		if ReceiverEnabled(v, receiverType) {
+			t.Errorf("ReceiverEnabled(%q) = true, want false", receiverType)
+		}
+	}
+}
+
 func TestMultiAndQueuedSpanProcessorConfig(t *testing.T) {
 	v, err := loadViperFromFile("./testdata/queued_exporters.yaml")
 	if err != nil {
@@ -98,11 +126,17 @@ func TestMultiAndQueuedSpanProcessorConfig(t *testing.T) {
 		DiscoveryMinPeers:         7,
 		DiscoveryConnCheckTimeout: time.Second * 7,
 	}
+	fst.Persistence = &queued.PersistenceConfig{
+		Directory:  "/var/lib/occollector/proc-tchannel",
+		MaxSizeMiB: 512,
+		Sync:       queued.SyncAlways,
+	}
 	fst.RawConfig = v.Sub(queuedExportersConfigKey).Sub("proc-tchannel")
 	snd := NewDefaultQueuedSpanProcessorCfg()
 	snd.Name = "proc-http"
 	snd.RetryOnFailure = false
 	snd.BackoffDelay = 3 * time.Second
+	snd.MaxElapsedTime = 10 * time.Minute
 	snd.SenderType = ThriftHTTPSenderType
 	snd.SenderConfig = &JaegerThriftHTTPSenderCfg{
 		CollectorEndpoint: "https://somedomain.com/api/traces",
@@ -130,6 +164,90 @@ func TestMultiAndQueuedSpanProcessorConfig(t *testing.T) {
 	}
 }
 
+func TestQueuedMetricsProcessorConfig(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/metrics_queue.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	wCfg := &QueuedMetricsProcessorCfg{
+		NumWorkers:      4,
+		QueueSize:       250,
+		RetryOnFailure:  false,
+		BackoffDelay:    2 * time.Second,
+		MaxBackoffDelay: 30 * time.Second,
+		MaxRetries:      5,
+	}
+
+	gCfg := NewDefaultQueuedMetricsProcessorCfg().InitFromViper(v)
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		t.Fatalf("Wanted %+v but got %+v", *wCfg, *gCfg)
+	}
+
+	dCfg := NewDefaultQueuedMetricsProcessorCfg().InitFromViper(viper.New())
+	if !reflect.DeepEqual(dCfg, NewDefaultQueuedMetricsProcessorCfg()) {
+		t.Fatalf("Wanted default config but got %+v", *dCfg)
+	}
+}
+
+func TestPipelinesConfig(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/pipelines.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	if !PipelinesEnabled(v) {
+		t.Fatalf("Pipelines should be enabled")
+	}
+
+	wCfg := &PipelinesCfg{
+		Pipelines: []*PipelineCfg{
+			{
+				Name:      "oc-traffic",
+				Receivers: []string{"opencensus"},
+				Exporters: []string{"opencensus"},
+			},
+			{
+				Name:       "zipkin-traffic",
+				Receivers:  []string{"zipkin"},
+				Processors: []string{"attribute-key/scrub-pii"},
+				Exporters:  []string{"zipkin"},
+			},
+		},
+	}
+
+	gCfg, err := NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to InitFromViper for pipelines: %v", err)
+	}
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		gj, _ := json.MarshalIndent(gCfg, "", "  ")
+		wj, _ := json.MarshalIndent(wCfg, "", "  ")
+		t.Fatalf("Wanted %s but got %s", wj, gj)
+	}
+
+	if ProcessorViper(v, "attribute-key/scrub-pii") == nil {
+		t.Errorf("Expected configuration for processor %q", "attribute-key/scrub-pii")
+	}
+	if got := ComponentType("attribute-key/scrub-pii"); got != "attribute-key" {
+		t.Errorf("ComponentType() = %q, want %q", got, "attribute-key")
+	}
+	if got := ComponentType("zipkin"); got != "zipkin" {
+		t.Errorf("ComponentType() = %q, want %q", got, "zipkin")
+	}
+}
+
+func TestPipelinesConfigWithoutExporters(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/pipelines_no_exporters.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	if _, err := NewDefaultPipelinesCfg().InitFromViper(v); err == nil {
+		t.Fatalf("Expected error for pipeline without exporters")
+	}
+}
+
 func TestTailSamplingPoliciesConfiguration(t *testing.T) {
 	v, err := loadViperFromFile("./testdata/sampling_config.yaml")
 	if err != nil {
@@ -196,6 +314,120 @@ func TestTailSamplingPoliciesConfiguration(t *testing.T) {
 	}
 }
 
+func TestLatencyAndTraceShapePoliciesConfiguration(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/sampling_latency_shape.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	wCfg := &SamplingCfg{
+		Mode: TailSampling,
+		Policies: []*PolicyCfg{
+			{
+				Name:      "fan-out",
+				Type:      TraceShape,
+				Exporters: []string{"zipkin"},
+				Configuration: &TraceShapeCfg{
+					MinSpans:    100,
+					MinDepth:    8,
+					MinServices: 5,
+					SpanKinds:   []string{"producer", "consumer"},
+				},
+			},
+			{
+				Name:      "slow",
+				Type:      Latency,
+				Exporters: []string{"jaeger"},
+				Configuration: &LatencyCfg{
+					TraceThreshold: 2 * time.Second,
+					SpanThreshold:  500 * time.Millisecond,
+					OperationThresholds: []OperationThresholdCfg{
+						{Operation: "/checkout.Pay", Threshold: time.Second},
+					},
+				},
+			},
+		},
+	}
+
+	gCfg := NewDefaultSamplingCfg().InitFromViper(v)
+	sort.Slice(gCfg.Policies, func(i, j int) bool {
+		return gCfg.Policies[i].Name < gCfg.Policies[j].Name
+	})
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		gb, _ := json.MarshalIndent(gCfg, "", " ")
+		t.Fatalf("Wanted %+v but got %+v\ngot json:\n%s", *wCfg, *gCfg, string(gb))
+	}
+}
+
+func TestCompositePolicyConfiguration(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/sampling_composite.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	wCfg := &SamplingCfg{
+		Mode: TailSampling,
+		Policies: []*PolicyCfg{
+			{
+				Name:      "to-backend",
+				Type:      Composite,
+				Exporters: []string{"jaeger"},
+				Configuration: &CompositeCfg{
+					SpansPerSecond: 1000,
+					SubPolicies: []*WeightedPolicyCfg{
+						{
+							Policy: &PolicyCfg{
+								Name:          "errors",
+								Type:          ErrorStatus,
+								Configuration: NewDefaultErrorStatusCfg(),
+							},
+							Weight: 50,
+						},
+						{
+							Policy: &PolicyCfg{
+								Name: "slow-checkout",
+								Type: And,
+								Configuration: &BooleanCfg{
+									SubPolicies: []*PolicyCfg{
+										{
+											Name:          "slow-checkout/0",
+											Type:          Latency,
+											Configuration: &LatencyCfg{TraceThreshold: 2 * time.Second},
+										},
+										{
+											Name: "slow-checkout/1",
+											Type: StringAttributeFilter,
+											Configuration: &StringAttributeFilterCfg{
+												Key:    "component",
+												Values: []string{"checkout"},
+											},
+										},
+									},
+								},
+							},
+							Weight: 30,
+						},
+						{
+							Policy: &PolicyCfg{
+								Name:          "to-backend/2",
+								Type:          Probabilistic,
+								Configuration: &ProbabilisticCfg{SamplingPercentage: 1},
+							},
+							Weight: 20,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	gCfg := NewDefaultSamplingCfg().InitFromViper(v)
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		gb, _ := json.MarshalIndent(gCfg, "", " ")
+		t.Fatalf("Wanted %+v but got %+v\ngot json:\n%s", *wCfg, *gCfg, string(gb))
+	}
+}
+
 func TestTailSamplingConfig(t *testing.T) {
 	v, err := loadViperFromFile("./testdata/sampling_config.yaml")
 	if err != nil {
@@ -205,6 +437,10 @@ func TestTailSamplingConfig(t *testing.T) {
 	wCfg := NewDefaultTailBasedCfg()
 	wCfg.DecisionWait = 31 * time.Second
 	wCfg.NumTraces = 20001
+	wCfg.DecisionCacheSize = 100000
+	wCfg.DecisionCacheTTL = 10 * time.Minute
+	wCfg.EarlyDecision = true
+	wCfg.EarlyDecisionIdleTimeout = 3 * time.Second
 
 	gCfg := NewDefaultTailBasedCfg().InitFromViper(v)
 	if !reflect.DeepEqual(gCfg, wCfg) {
@@ -212,6 +448,32 @@ func TestTailSamplingConfig(t *testing.T) {
 	}
 }
 
+func TestHeadSamplingConfig(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/head_sampling_config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	wCfg := NewDefaultHeadBasedCfg()
+	wCfg.SamplingPercentage = 12.5
+	wCfg.HashSeed = 42
+	wCfg.ServiceOverrides = map[string]float64{
+		"checkout":    100,
+		"healthcheck": 0,
+	}
+
+	gCfg := NewDefaultHeadBasedCfg().InitFromViper(v)
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		t.Fatalf("Wanted %+v but got %+v", *wCfg, *gCfg)
+	}
+
+	// The tail-based settings are ignored in head mode.
+	tCfg := NewDefaultTailBasedCfg().InitFromViper(v)
+	if !reflect.DeepEqual(tCfg, NewDefaultTailBasedCfg()) {
+		t.Fatalf("Wanted the default tail-based settings but got %+v", *tCfg)
+	}
+}
+
 func loadViperFromFile(file string) (*viper.Viper, error) {
 	v := viper.New()
 	v.SetConfigFile(file)
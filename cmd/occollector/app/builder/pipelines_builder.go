@@ -0,0 +1,120 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	pipelinesRoot  = "pipelines"
+	processorsRoot = "processors"
+	exportersRoot  = "exporters"
+
+	// componentNameSeparator separates the type of a component from the name of
+	// the instance, e.g.: "attributes/scrub-pii".
+	componentNameSeparator = "/"
+)
+
+// PipelineCfg holds the configuration of a named pipeline: the receivers feeding
+// it, the ordered chain of processors applied to the data, and the exporters that
+// get the resulting data.
+type PipelineCfg struct {
+	// Name of the pipeline, used to identify it in logs.
+	Name string
+	// Receivers lists the receivers that send their data to the pipeline.
+	Receivers []string `mapstructure:"receivers"`
+	// Processors lists, in order, the processors the data goes through before
+	// reaching the exporters.
+	Processors []string `mapstructure:"processors"`
+	// Exporters lists the exporters that get the data after going through the
+	// processors.
+	Exporters []string `mapstructure:"exporters"`
+}
+
+// PipelinesCfg holds the configuration of all pipelines of the collector.
+type PipelinesCfg struct {
+	Pipelines []*PipelineCfg
+}
+
+// PipelinesEnabled checks if the configuration defines any pipeline. When pipelines are
+// defined only the components referenced by them are created.
+func PipelinesEnabled(v *viper.Viper) bool {
+	return v.Sub(pipelinesRoot) != nil
+}
+
+// NewDefaultPipelinesCfg returns an instance of PipelinesCfg with default values.
+func NewDefaultPipelinesCfg() *PipelinesCfg {
+	return &PipelinesCfg{}
+}
+
+// InitFromViper initializes PipelinesCfg with properties from viper. Pipelines are
+// sorted by name so the construction order is deterministic.
+func (pCfg *PipelinesCfg) InitFromViper(v *viper.Viper) (*PipelinesCfg, error) {
+	pv := v.Sub(pipelinesRoot)
+	if pv == nil {
+		return pCfg, nil
+	}
+
+	for pipelineName := range v.GetStringMap(pipelinesRoot) {
+		pipelineCfg := &PipelineCfg{Name: pipelineName}
+		if psv := pv.Sub(pipelineName); psv != nil {
+			if err := psv.Unmarshal(pipelineCfg); err != nil {
+				return nil, fmt.Errorf("Failed to read configuration for pipeline %q: %v", pipelineName, err)
+			}
+		}
+		if len(pipelineCfg.Receivers) == 0 {
+			return nil, fmt.Errorf("pipeline %q must have at least one receiver", pipelineName)
+		}
+		if len(pipelineCfg.Exporters) == 0 {
+			return nil, fmt.Errorf("pipeline %q must have at least one exporter", pipelineName)
+		}
+		pCfg.Pipelines = append(pCfg.Pipelines, pipelineCfg)
+	}
+
+	sort.Slice(pCfg.Pipelines, func(i, j int) bool {
+		return pCfg.Pipelines[i].Name < pCfg.Pipelines[j].Name
+	})
+	return pCfg, nil
+}
+
+// ComponentType returns the type of the component with the given name. Components can
+// be named either just by their type, e.g.: "zipkin", or by their type followed by the
+// name of the instance, e.g.: "zipkin/primary".
+func ComponentType(name string) string {
+	return strings.SplitN(name, componentNameSeparator, 2)[0]
+}
+
+// ReceiverViper returns the viper configuration of the named receiver, or nil if it is
+// not present.
+func ReceiverViper(v *viper.Viper, name string) *viper.Viper {
+	return getViperSub(v, receiversRoot, name)
+}
+
+// ProcessorViper returns the viper configuration of the named processor, or nil if it is
+// not present.
+func ProcessorViper(v *viper.Viper, name string) *viper.Viper {
+	return getViperSub(v, processorsRoot, name)
+}
+
+// ExporterViper returns the viper configuration of the named exporter, or nil if it is
+// not present.
+func ExporterViper(v *viper.Viper, name string) *viper.Viper {
+	return getViperSub(v, exportersRoot, name)
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
 )
 
@@ -38,6 +39,8 @@ const (
 
 const (
 	queuedExportersConfigKey = "queued-exporters"
+	metricsQueueConfigKey    = "metrics-queue"
+	memoryLimiterConfigKey   = "memory-limiter"
 )
 
 // JaegerThriftTChannelSenderCfg holds configuration for Jaeger Thrift Tchannel sender
@@ -115,14 +118,24 @@ type QueuedSpanProcessorCfg struct {
 	QueueSize int `mapstructure:"queue-size"`
 	// Retry indicates whether queue processor should retry span batches in case of processing failure
 	RetryOnFailure bool `mapstructure:"retry-on-failure"`
-	// BackoffDelay is the amount of time a worker waits after a failed send before retrying
+	// BackoffDelay is the amount of time a failed batch waits before its first retry, it
+	// doubles on each retry up to MaxBackoffDelay
 	BackoffDelay time.Duration `mapstructure:"backoff-delay"`
+	// MaxBackoffDelay is the maximum amount of time a failed batch waits before a retry
+	MaxBackoffDelay time.Duration `mapstructure:"max-backoff-delay"`
+	// MaxRetries is the number of retries after which a batch is dropped, zero means no limit
+	MaxRetries int `mapstructure:"max-retries"`
+	// MaxElapsedTime is the time after the first failure of a batch after which it is
+	// dropped, zero means no limit
+	MaxElapsedTime time.Duration `mapstructure:"max-elapsed-time"`
 	// SenderType indicates the type of sender to instantiate
 	SenderType   SenderType `mapstructure:"sender-type"`
 	SenderConfig interface{}
 	// BatchingConfig sets config parameters related to batching
 	BatchingConfig BatchingConfig `mapstructure:"batching"`
-	RawConfig      *viper.Viper
+	// Persistence keeps the queue on disk instead of in memory when set
+	Persistence *queued.PersistenceConfig `mapstructure:"persistence"`
+	RawConfig   *viper.Viper
 }
 
 // AttributesCfg holds configuration for attributes that can be added to all spans
@@ -141,12 +154,13 @@ type GlobalProcessorCfg struct {
 // NewDefaultQueuedSpanProcessorCfg returns an instance of QueuedSpanProcessorCfg with default values
 func NewDefaultQueuedSpanProcessorCfg() *QueuedSpanProcessorCfg {
 	opts := &QueuedSpanProcessorCfg{
-		Name:           "default-queued-jaeger-sender",
-		NumWorkers:     10,
-		QueueSize:      5000,
-		RetryOnFailure: true,
-		SenderType:     InvalidSenderType,
-		BackoffDelay:   5 * time.Second,
+		Name:            "default-queued-jaeger-sender",
+		NumWorkers:      10,
+		QueueSize:       5000,
+		RetryOnFailure:  true,
+		SenderType:      InvalidSenderType,
+		BackoffDelay:    5 * time.Second,
+		MaxBackoffDelay: queued.DefaultMaxBackoffDelay,
 	}
 	return opts
 }
@@ -182,6 +196,53 @@ func (qOpts *QueuedSpanProcessorCfg) InitFromViper(v *viper.Viper) *QueuedSpanPr
 	return qOpts
 }
 
+// QueuedMetricsProcessorCfg holds configuration for the queued metrics processors
+// placed in front of each metrics exporter.
+type QueuedMetricsProcessorCfg struct {
+	// NumWorkers is the number of queue workers that dequeue batches and send them out
+	NumWorkers int `mapstructure:"num-workers"`
+	// QueueSize is the maximum number of batches allowed in queue at a given time
+	QueueSize int `mapstructure:"queue-size"`
+	// Retry indicates whether queue processor should retry metrics batches in case of processing failure
+	RetryOnFailure bool `mapstructure:"retry-on-failure"`
+	// BackoffDelay is the amount of time a failed batch waits before its first retry, it
+	// doubles on each retry up to MaxBackoffDelay
+	BackoffDelay time.Duration `mapstructure:"backoff-delay"`
+	// MaxBackoffDelay is the maximum amount of time a failed batch waits before a retry
+	MaxBackoffDelay time.Duration `mapstructure:"max-backoff-delay"`
+	// MaxRetries is the number of retries after which a batch is dropped, zero means no limit
+	MaxRetries int `mapstructure:"max-retries"`
+	// MaxElapsedTime is the time after the first failure of a batch after which it is
+	// dropped, zero means no limit
+	MaxElapsedTime time.Duration `mapstructure:"max-elapsed-time"`
+}
+
+// NewDefaultQueuedMetricsProcessorCfg returns an instance of QueuedMetricsProcessorCfg with default values
+func NewDefaultQueuedMetricsProcessorCfg() *QueuedMetricsProcessorCfg {
+	opts := &QueuedMetricsProcessorCfg{
+		NumWorkers:      2,
+		QueueSize:       1000,
+		RetryOnFailure:  true,
+		BackoffDelay:    5 * time.Second,
+		MaxBackoffDelay: queued.DefaultMaxBackoffDelay,
+	}
+	return opts
+}
+
+// InitFromViper initializes QueuedMetricsProcessorCfg with properties from viper
+func (qOpts *QueuedMetricsProcessorCfg) InitFromViper(v *viper.Viper) *QueuedMetricsProcessorCfg {
+	if mqv := v.Sub(metricsQueueConfigKey); mqv != nil {
+		mqv.Unmarshal(qOpts)
+	}
+	return qOpts
+}
+
+// MemoryLimiterViper returns the configuration of the memory limiter placed in front of
+// all other processors, or nil if the "memory-limiter" section is not present.
+func MemoryLimiterViper(v *viper.Viper) *viper.Viper {
+	return v.Sub(memoryLimiterConfigKey)
+}
+
 // MultiSpanProcessorCfg holds configuration for all the span processors
 type MultiSpanProcessorCfg struct {
 	Processors []*QueuedSpanProcessorCfg
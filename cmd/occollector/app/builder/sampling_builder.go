@@ -15,9 +15,13 @@
 package builder
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 )
 
 const (
@@ -36,6 +40,9 @@ const (
 	// TailSampling is the mode in which trace data is temporarily retained until an evaluation
 	// if the trace should be sampled is performed.
 	TailSampling Mode = "tail"
+	// HeadSampling is the mode in which a percentage of the traces is kept, deciding by
+	// their trace IDs as soon as their spans arrive.
+	HeadSampling Mode = "head"
 )
 
 // PolicyType indicates the type of sampling policy.
@@ -52,6 +59,32 @@ const (
 	StringAttributeFilter PolicyType = "string-attribute-filter"
 	// RateLimiting allows all traces until the specified limits are satisfied.
 	RateLimiting PolicyType = "rate-limiting"
+	// KeyedRateLimiting allows traces until the limit of their service, or of the value of
+	// an attribute, is satisfied.
+	KeyedRateLimiting PolicyType = "keyed-rate-limiting"
+	// Latency samples traces that are slower than a threshold, end-to-end or in any of
+	// their spans.
+	Latency PolicyType = "latency"
+	// TraceShape samples traces with many spans, deep chains of spans, many services or
+	// spans of specific kinds.
+	TraceShape PolicyType = "trace-shape"
+	// ErrorStatus samples traces with a span that failed, according to its status, HTTP
+	// status code, "error" attribute or annotations.
+	ErrorStatus PolicyType = "error-status"
+	// Adaptive samples a target number of traces per second, adjusting the probability of
+	// sampling each service and operation to their traffic.
+	Adaptive PolicyType = "adaptive"
+	// Probabilistic samples a percentage of the traces by hashing their trace IDs.
+	Probabilistic PolicyType = "probabilistic"
+	// And samples traces sampled by all of its sub-policies.
+	And PolicyType = "and"
+	// Or samples traces sampled by any of its sub-policies.
+	Or PolicyType = "or"
+	// Not samples traces not sampled by its single sub-policy.
+	Not PolicyType = "not"
+	// Composite samples traces sampled by any of its sub-policies, limiting the spans per
+	// second sampled by each sub-policy to its share, by weight, of a global limit.
+	Composite PolicyType = "composite"
 )
 
 // PolicyCfg holds the common configuration to all policies.
@@ -94,6 +127,144 @@ type RateLimitingCfg struct {
 	SpansPerSecond int64 `mapstructure:"spans-per-second"`
 }
 
+// KeyedRateLimitingCfg holds the configurable settings to create a keyed rate limiting
+// sampling policy evaluator.
+type KeyedRateLimitingCfg struct {
+	// Key is the attribute whose values have their own limits, the service of the root
+	// span of the traces is used if empty.
+	Key string `mapstructure:"key"`
+	// SpansPerSecond is the limit of spans per second of each value without an override.
+	SpansPerSecond int64 `mapstructure:"spans-per-second"`
+	// Overrides are the limits of specific values.
+	Overrides []KeyedRateLimitCfg `mapstructure:"overrides"`
+}
+
+// KeyedRateLimitCfg holds the limit of spans per second of a value of a keyed rate
+// limiting policy.
+type KeyedRateLimitCfg struct {
+	// Value is the service name, or the attribute value, with the limit.
+	Value string `mapstructure:"value"`
+	// SpansPerSecond is the limit of spans per second of the value.
+	SpansPerSecond int64 `mapstructure:"spans-per-second"`
+}
+
+// LatencyCfg holds the configurable settings to create a latency sampling policy
+// evaluator. Zero thresholds are not checked.
+type LatencyCfg struct {
+	// TraceThreshold is the duration, from the start of the first span to the end of the
+	// last span, above which a trace is sampled.
+	TraceThreshold time.Duration `mapstructure:"trace-threshold"`
+	// SpanThreshold is the duration above which a span, of an operation without its own
+	// threshold, causes its trace to be sampled.
+	SpanThreshold time.Duration `mapstructure:"span-threshold"`
+	// OperationThresholds are the thresholds of the spans of specific operations.
+	OperationThresholds []OperationThresholdCfg `mapstructure:"operation-thresholds"`
+}
+
+// OperationThresholdCfg holds the latency threshold of the spans of an operation.
+type OperationThresholdCfg struct {
+	// Operation is the name of the spans.
+	Operation string `mapstructure:"operation"`
+	// Threshold is the duration above which a span causes its trace to be sampled.
+	Threshold time.Duration `mapstructure:"threshold"`
+}
+
+// TraceShapeCfg holds the configurable settings to create a trace shape sampling policy
+// evaluator. A trace is sampled if it meets any of the settings, zero values are not checked.
+type TraceShapeCfg struct {
+	// MinSpans is the minimum number of spans of a sampled trace.
+	MinSpans int64 `mapstructure:"min-spans"`
+	// MinDepth is the minimum length of a chain of spans, from parent to child.
+	MinDepth int `mapstructure:"min-depth"`
+	// MinServices is the minimum number of distinct services that sent spans of the trace.
+	MinServices int `mapstructure:"min-services"`
+	// SpanKinds are the kinds, e.g.: "SERVER" or "CLIENT", of spans that cause their trace
+	// to be sampled.
+	SpanKinds []string `mapstructure:"span-kinds"`
+}
+
+// ErrorStatusCfg holds the configurable settings to create an error status sampling policy
+// evaluator.
+type ErrorStatusCfg struct {
+	// StatusCodes are the names of the canonical status codes, e.g.: "INTERNAL", of failed
+	// spans. All codes but "OK" are used if empty.
+	StatusCodes []string `mapstructure:"status-codes"`
+	// HTTPStatusCodes are the values of the "http.status_code" attribute of failed spans,
+	// either a code, e.g.: "429", or a class of codes, e.g.: "5xx". "5xx" is used if empty.
+	HTTPStatusCodes []string `mapstructure:"http-status-codes"`
+	// ErrorTag indicates that the spans with the "error" attribute set are failed spans.
+	ErrorTag bool `mapstructure:"error-tag"`
+	// ErrorAnnotations indicates that the spans with an "error" annotation are failed spans.
+	ErrorAnnotations bool `mapstructure:"error-annotations"`
+}
+
+// NewDefaultErrorStatusCfg creates an ErrorStatusCfg with the default values.
+func NewDefaultErrorStatusCfg() *ErrorStatusCfg {
+	return &ErrorStatusCfg{
+		ErrorTag:         true,
+		ErrorAnnotations: true,
+	}
+}
+
+// ProbabilisticCfg holds the configurable settings to create a probabilistic sampling
+// policy evaluator.
+type ProbabilisticCfg struct {
+	// SamplingPercentage is the percentage, between 0 and 100, of the traces sampled.
+	SamplingPercentage float64 `mapstructure:"sampling-percentage"`
+	// HashSeed is the seed of the hash of the trace IDs, the same traces are sampled by the
+	// head sampling mode with the same seed.
+	HashSeed uint32 `mapstructure:"hash-seed"`
+}
+
+// AdaptiveCfg holds the configurable settings to create an adaptive sampling policy
+// evaluator.
+type AdaptiveCfg struct {
+	// TracesPerSecond is the target of traces per second sampled.
+	TracesPerSecond float64 `mapstructure:"traces-per-second"`
+	// AdjustInterval is how often the probabilities of the services and operations are
+	// adjusted.
+	AdjustInterval time.Duration `mapstructure:"adjust-interval"`
+	// StateFile is the file where the probabilities are saved to be used after a restart,
+	// they are not saved if empty.
+	StateFile string `mapstructure:"state-file"`
+	// HashSeed is the seed of the hash of the trace IDs, by default it differs from the
+	// seed of head sampling and of the probabilistic policy.
+	HashSeed uint32 `mapstructure:"hash-seed"`
+}
+
+// NewDefaultAdaptiveCfg creates an AdaptiveCfg with the default values.
+func NewDefaultAdaptiveCfg() *AdaptiveCfg {
+	return &AdaptiveCfg{
+		AdjustInterval: 10 * time.Second,
+		HashSeed:       sampling.DefaultAdaptiveHashSeed,
+	}
+}
+
+// BooleanCfg holds the sub-policies of the "and", "or" and "not" sampling policies.
+type BooleanCfg struct {
+	// SubPolicies are the policies combined, their exporters are ignored.
+	SubPolicies []*PolicyCfg
+}
+
+// CompositeCfg holds the configurable settings to create a composite sampling policy
+// evaluator.
+type CompositeCfg struct {
+	// SpansPerSecond is the limit of spans per second sampled by all the sub-policies.
+	SpansPerSecond int64
+	// SubPolicies are evaluated in order, each one can sample up to its share of
+	// SpansPerSecond.
+	SubPolicies []*WeightedPolicyCfg
+}
+
+// WeightedPolicyCfg holds a sub-policy of a composite policy.
+type WeightedPolicyCfg struct {
+	// Policy is the configuration of the sub-policy, its exporters are ignored.
+	Policy *PolicyCfg
+	// Weight is the share of the spans per second of the composite policy that the
+	// sub-policy can sample, relative to the sum of the weights of all sub-policies.
+	Weight float64
+}
+
 // SamplingCfg holds the sampling configuration.
 type SamplingCfg struct {
 	// Mode specifies the sampling mode to be used.
@@ -124,34 +295,86 @@ func (sCfg *SamplingCfg) InitFromViper(v *viper.Viper) *SamplingCfg {
 	}
 
 	for policyName := range sv.GetStringMap(policiesTag) {
-		polSub := pv.Sub(policyName)
-		polCfg := &PolicyCfg{}
-		polCfg.Name = policyName
-		polCfg.Type = PolicyType(polSub.GetString("policy"))
-		polCfg.Exporters = polSub.GetStringSlice("exporters")
-
-		cfgSub := polSub.Sub("configuration")
-		if cfgSub != nil {
-			// As the number of polices grow this likely should be in a map.
-			var cfg interface{}
-			switch polCfg.Type {
-			case NumericAttributeFilter:
-				numAttributeFilterCfg := &NumericAttributeFilterCfg{}
-				cfg = numAttributeFilterCfg
-			case StringAttributeFilter:
-				strAttributeFilterCfg := &StringAttributeFilterCfg{}
-				cfg = strAttributeFilterCfg
-			case RateLimiting:
-				rateLimitingCfg := &RateLimitingCfg{}
-				cfg = rateLimitingCfg
+		sCfg.Policies = append(sCfg.Policies, policyCfgFromViper(policyName, pv.Sub(policyName)))
+	}
+	return sCfg
+}
+
+func policyCfgFromViper(policyName string, polSub *viper.Viper) *PolicyCfg {
+	polCfg := &PolicyCfg{}
+	polCfg.Name = policyName
+	polCfg.Type = PolicyType(polSub.GetString("policy"))
+	polCfg.Exporters = polSub.GetStringSlice("exporters")
+
+	if polCfg.Type == ErrorStatus {
+		// The error status policy can be used without configuration.
+		polCfg.Configuration = NewDefaultErrorStatusCfg()
+	}
+
+	cfgSub := polSub.Sub("configuration")
+	if cfgSub != nil {
+		// As the number of polices grow this likely should be in a map.
+		var cfg interface{}
+		switch polCfg.Type {
+		case NumericAttributeFilter:
+			numAttributeFilterCfg := &NumericAttributeFilterCfg{}
+			cfg = numAttributeFilterCfg
+		case StringAttributeFilter:
+			strAttributeFilterCfg := &StringAttributeFilterCfg{}
+			cfg = strAttributeFilterCfg
+		case RateLimiting:
+			rateLimitingCfg := &RateLimitingCfg{}
+			cfg = rateLimitingCfg
+		case KeyedRateLimiting:
+			cfg = &KeyedRateLimitingCfg{}
+		case Latency:
+			cfg = &LatencyCfg{}
+		case TraceShape:
+			cfg = &TraceShapeCfg{}
+		case ErrorStatus:
+			cfg = NewDefaultErrorStatusCfg()
+		case Adaptive:
+			cfg = NewDefaultAdaptiveCfg()
+		case Probabilistic:
+			cfg = &ProbabilisticCfg{}
+		case And, Or, Not:
+			booleanCfg := &BooleanCfg{}
+			for _, subPolCfg := range subPolicyCfgsFromViper(policyName, cfgSub) {
+				booleanCfg.SubPolicies = append(booleanCfg.SubPolicies, subPolCfg.Policy)
+			}
+			polCfg.Configuration = booleanCfg
+			return polCfg
+		case Composite:
+			polCfg.Configuration = &CompositeCfg{
+				SpansPerSecond: cfgSub.GetInt64("spans-per-second"),
+				SubPolicies:    subPolicyCfgsFromViper(policyName, cfgSub),
 			}
-			cfgSub.Unmarshal(cfg)
-			polCfg.Configuration = cfg
+			return polCfg
 		}
+		cfgSub.Unmarshal(cfg)
+		polCfg.Configuration = cfg
+	}
+	return polCfg
+}
 
-		sCfg.Policies = append(sCfg.Policies, polCfg)
+// subPolicyCfgsFromViper reads the "sub-policies" list of a policy configuration, the
+// sub-policies without a "name" are named after their parent and position.
+func subPolicyCfgsFromViper(parentName string, cfgSub *viper.Viper) []*WeightedPolicyCfg {
+	items, _ := cfgSub.Get("sub-policies").([]interface{})
+	subPolCfgs := make([]*WeightedPolicyCfg, 0, len(items))
+	for i, item := range items {
+		itemSub := viper.New()
+		itemSub.MergeConfigMap(cast.ToStringMap(item))
+		name := itemSub.GetString("name")
+		if name == "" {
+			name = fmt.Sprintf("%s/%d", parentName, i)
+		}
+		subPolCfgs = append(subPolCfgs, &WeightedPolicyCfg{
+			Policy: policyCfgFromViper(name, itemSub),
+			Weight: itemSub.GetFloat64("weight"),
+		})
 	}
-	return sCfg
+	return subPolCfgs
 }
 
 // TailBasedCfg holds the configuration for tail-based sampling.
@@ -162,13 +385,28 @@ type TailBasedCfg struct {
 	// NumTraces is the number of traces kept on memory. Typically most of the data
 	// of a trace is released after a sampling decision is taken.
 	NumTraces uint64 `mapstructure:"num-traces"`
+	// DecisionCacheSize is the number of traces whose decisions are kept after they
+	// are removed from memory, so their late spans get the same decisions. Zero
+	// disables it.
+	DecisionCacheSize int `mapstructure:"decision-cache-size"`
+	// DecisionCacheTTL is how long the decisions of a trace are kept after it is
+	// removed from memory.
+	DecisionCacheTTL time.Duration `mapstructure:"decision-cache-ttl"`
+	// EarlyDecision evaluates the traces as soon as they look complete, i.e.: their
+	// root span and all the parent spans referenced were received, instead of always
+	// waiting for DecisionWait.
+	EarlyDecision bool `mapstructure:"early-decision"`
+	// EarlyDecisionIdleTimeout also evaluates, when EarlyDecision is enabled, the traces
+	// that did not receive new spans for this long. Zero disables it.
+	EarlyDecisionIdleTimeout time.Duration `mapstructure:"early-decision-idle-timeout"`
 }
 
 // NewDefaultTailBasedCfg creates a TailBasedCfg with the default values.
 func NewDefaultTailBasedCfg() *TailBasedCfg {
 	return &TailBasedCfg{
-		DecisionWait: 30 * time.Second,
-		NumTraces:    50000,
+		DecisionWait:     30 * time.Second,
+		NumTraces:        50000,
+		DecisionCacheTTL: 5 * time.Minute,
 	}
 }
 
@@ -185,3 +423,33 @@ func (tCfg *TailBasedCfg) InitFromViper(v *viper.Viper) *TailBasedCfg {
 	tv.Unmarshal(tCfg)
 	return tCfg
 }
+
+// HeadBasedCfg holds the configuration for head-based sampling.
+type HeadBasedCfg struct {
+	// SamplingPercentage is the percentage, between 0 and 100, of the traces kept.
+	SamplingPercentage float64 `mapstructure:"sampling-percentage"`
+	// HashSeed is the seed of the hash of the trace IDs, collectors with the same seed
+	// keep the same traces.
+	HashSeed uint32 `mapstructure:"hash-seed"`
+	// ServiceOverrides are the sampling percentages of specific services, by the name
+	// of the service in the node of the spans.
+	ServiceOverrides map[string]float64 `mapstructure:"service-overrides"`
+}
+
+// NewDefaultHeadBasedCfg creates a HeadBasedCfg with the default values.
+func NewDefaultHeadBasedCfg() *HeadBasedCfg {
+	return &HeadBasedCfg{
+		SamplingPercentage: 100,
+	}
+}
+
+// InitFromViper initializes HeadBasedCfg with properties from viper.
+func (hCfg *HeadBasedCfg) InitFromViper(v *viper.Viper) *HeadBasedCfg {
+	hv := v.Sub(samplingTag)
+	if hv == nil || hv.GetString(modeTag) != string(HeadSampling) {
+		return hCfg
+	}
+
+	hv.Unmarshal(hCfg)
+	return hCfg
+}
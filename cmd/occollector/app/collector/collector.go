@@ -29,11 +29,12 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
-	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/pipeline"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 	"github.com/census-instrumentation/opencensus-service/internal/pprofserver"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
 	"github.com/census-instrumentation/opencensus-service/internal/zpagesserver"
-	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
 var (
@@ -46,8 +47,20 @@ type Application struct {
 	v           *viper.Viper
 	logger      *zap.Logger
 	healthCheck *healthcheck.HealthCheck
-	processor   consumer.TraceConsumer
-	receivers   []receiver.TraceReceiver
+
+	// When no pipelines are configured the receivers send their data to these switches,
+	// so the processors and exporters can be replaced on a configuration reload.
+	processor            *reload.TraceSwitch
+	metricsProcessor     *reload.MetricsSwitch
+	processorShutdownFns []func(context.Context) error
+	// retirer shuts down the processors and exporters replaced by a reload.
+	retirer   *reload.Retirer
+	receivers map[string]*typeReceivers
+	// appliedSettings is the configuration the running components were created from.
+	appliedSettings reload.Settings
+
+	pipelines *pipeline.Pipelines
+
 	// stopTestChan is used to terminate the application in end to end tests.
 	stopTestChan chan struct{}
 	// readyChan is used in tests to indicate that the application is ready.
@@ -64,8 +77,7 @@ func newApp() *Application {
 func (app *Application) init() {
 	var err error
 	if file := builder.GetConfigFile(app.v); file != "" {
-		app.v.SetConfigFile(file)
-		err := app.v.ReadInConfig()
+		err := config.ReadConfigFile(app.v, file)
 		if err != nil {
 			log.Fatalf("Error loading config file %q: %v", file, err)
 			return
@@ -92,8 +104,20 @@ func (app *Application) execute() {
 		log.Fatalf("Failed to start healthcheck server: %v", err)
 	}
 
+	// When pipelines are configured they define all receivers, processors and exporters
+	// of the collector, otherwise all enabled receivers feed all enabled exporters.
+	pipelinesEnabled := builder.PipelinesEnabled(app.v)
+
+	app.retirer = reload.NewRetirer(reload.RetireDelay, app.v.GetDuration(shutdownTimeoutCfg))
 	var closeFns []func()
-	app.processor, closeFns = startProcessor(app.v, app.logger)
+	if pipelinesEnabled {
+		app.pipelines = startPipelines(app.v, app.logger, asyncErrorChannel)
+	} else {
+		tp, mp, processorShutdownFns := startProcessor(app.v, app.logger)
+		app.processor = reload.NewTraceSwitch(tp)
+		app.metricsProcessor = reload.NewMetricsSwitch(mp)
+		app.processorShutdownFns = processorShutdownFns
+	}
 
 	zpagesPort := app.v.GetInt(zpagesserver.ZPagesHTTPPort)
 	if zpagesPort > 0 {
@@ -109,7 +133,9 @@ func (app *Application) execute() {
 		closeFns = append(closeFns, closeFn)
 	}
 
-	app.receivers = createReceivers(app.v, app.logger, app.processor, asyncErrorChannel)
+	if !pipelinesEnabled {
+		app.receivers = createReceivers(app.v, app.logger, app.processor, app.metricsProcessor, asyncErrorChannel)
+	}
 
 	err = initTelemetry(asyncErrorChannel, app.v, app.logger)
 	if err != nil {
@@ -117,6 +143,13 @@ func (app *Application) execute() {
 		os.Exit(1)
 	}
 
+	reloadRequests, stopReload, err := reload.Listen(asyncErrorChannel, app.v, app.logger)
+	if err != nil {
+		app.logger.Error("Failed to listen for reload requests", zap.Error(err))
+		os.Exit(1)
+	}
+	app.appliedSettings = app.v.AllSettings()
+
 	signalsChannel := make(chan os.Signal, 1)
 	signal.Notify(signalsChannel, os.Interrupt, syscall.SIGTERM)
 
@@ -128,25 +161,52 @@ func (app *Application) execute() {
 	// notify tests that it is ready.
 	close(app.readyChan)
 
-	select {
-	case err = <-asyncErrorChannel:
-		app.logger.Error("Asynchronous error received, terminating process", zap.Error(err))
-	case s := <-signalsChannel:
-		app.logger.Info("Received signal from OS", zap.String("signal", s.String()))
-	case <-app.stopTestChan:
-		app.logger.Info("Received stop test request")
+run:
+	for {
+		select {
+		case err = <-asyncErrorChannel:
+			app.logger.Error("Asynchronous error received, terminating process", zap.Error(err))
+			break run
+		case s := <-signalsChannel:
+			app.logger.Info("Received signal from OS", zap.String("signal", s.String()))
+			break run
+		case <-app.stopTestChan:
+			app.logger.Info("Received stop test request")
+			break run
+		case req := <-reloadRequests:
+			app.logger.Info("Reloading configuration", zap.String("source", req.Source))
+			err := app.reload(asyncErrorChannel)
+			if err != nil {
+				app.logger.Error("Failed to reload configuration", zap.Error(err))
+			} else {
+				app.logger.Info("Configuration reloaded")
+			}
+			req.Done(err)
+		}
 	}
 
 	app.healthCheck.Set(healthcheck.Unavailable)
 	app.logger.Info("Starting shutdown...")
+	stopReload()
 
-	// TODO: orderly shutdown: first receivers, then flushing pipelines giving
-	// senders a chance to send all their data. This may take time, the allowed
-	// time should be part of configuration.
-	for _, receiver := range app.receivers {
-		receiver.StopTraceReception(context.Background())
+	// Orderly shutdown: first the receivers, then the processors and exporters, giving
+	// them until the shutdown timeout to send the data they hold.
+	for _, receiverType := range receiverTypes() {
+		if tr, ok := app.receivers[receiverType]; ok {
+			tr.stop()
+		}
 	}
-
+	// The components replaced by a reload go first, as they may send their data to
+	// exporters still used by the running pipelines.
+	ctx, cancel := shutdownContext(app.v)
+	defer cancel()
+	app.retirer.Shutdown(ctx)
+	if app.pipelines != nil {
+		if err := app.pipelines.Shutdown(ctx); err != nil {
+			app.logger.Warn("Failed to shut down the pipelines", zap.Error(err))
+		}
+	}
+	shutdownAll(ctx, app.logger, app.processorShutdownFns)
 	for _, closeFn := range closeFns {
 		closeFn()
 	}
@@ -170,9 +230,12 @@ func (app *Application) Start() error {
 		builder.Flags,
 		healthCheckFlags,
 		loggerFlags,
+		shutdownFlags,
 		pprofserver.AddFlags,
 		zpagesserver.AddFlags,
+		reload.AddFlags,
 	)
+	rootCmd.AddCommand(newValidateCommand(), newPrintConfigCommand())
 
 	return rootCmd.Execute()
 }
@@ -0,0 +1,82 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/pipeline"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// pipelineFactories returns the factories of the components that can be used in the
+// "pipelines" section of the configuration, i.e.: all registered trace components.
+func pipelineFactories() pipeline.Factories {
+	factories := pipeline.Factories{
+		Receivers:  make(map[string]receiver.TraceReceiverFactory),
+		Processors: make(map[string]processor.TraceProcessorFactory),
+		Exporters:  make(map[string]exporter.TraceExporterFactory),
+	}
+	for _, f := range receiver.TraceReceiverFactories() {
+		factories.Receivers[f.Type()] = f
+	}
+	for _, f := range processor.TraceProcessorFactories() {
+		factories.Processors[f.Type()] = f
+	}
+	for _, f := range exporter.TraceExporterFactories() {
+		factories.Exporters[f.Type()] = f
+	}
+	return factories
+}
+
+// startPipelines creates and starts all pipelines of the configuration.
+func startPipelines(v *viper.Viper, logger *zap.Logger, asyncErrorChan chan<- error) *pipeline.Pipelines {
+	cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		logger.Error("Invalid pipelines configuration", zap.Error(err))
+		os.Exit(1)
+	}
+
+	pipelines, err := pipeline.Build(logger, v, cfg, pipelineFactories())
+	if err != nil {
+		logger.Error("Failed to build pipelines", zap.Error(err))
+		os.Exit(1)
+	}
+
+	if err := pipelines.Start(asyncErrorChan); err != nil {
+		logger.Error("Failed to start pipelines", zap.Error(err))
+		os.Exit(1)
+	}
+
+	return pipelines
+}
+
+// reloadPipelines applies the pipelines of the configuration in v to the running ones,
+// see pipeline.Pipelines.Reload.
+func reloadPipelines(pipelines *pipeline.Pipelines, v *viper.Viper, asyncErrorChan chan<- error, retirer *reload.Retirer) error {
+	cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		return fmt.Errorf("invalid pipelines configuration: %v", err)
+	}
+	return pipelines.Reload(v, cfg, pipelineFactories(), asyncErrorChan, retirer)
+}
@@ -15,10 +15,16 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	tchReporter "github.com/jaegertracing/jaeger/cmd/agent/app/reporter/tchannel"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -26,41 +32,51 @@ import (
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/sender"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
 	"github.com/census-instrumentation/opencensus-service/exporter/loggingexporter"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/headsampling"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/memorylimiter"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/tailsampling"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/secrets"
+	"github.com/census-instrumentation/opencensus-service/processor"
 	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
 )
 
-func createExporters(v *viper.Viper, logger *zap.Logger) ([]func(), []consumer.TraceConsumer, []consumer.MetricsConsumer) {
+func createExporters(v *viper.Viper, logger *zap.Logger) ([]func(context.Context) error, []consumer.TraceConsumer, []consumer.MetricsConsumer, error) {
 	// TODO: (@pjanotti) this is slightly modified from agent but in the end duplication, need to consolidate style and visibility.
 	traceExporters, metricsExporters, doneFns, err := config.ExportersFromViperConfig(logger, v)
 	if err != nil {
-		logger.Fatal("Failed to create config for exporters", zap.Error(err))
+		return nil, nil, nil, fmt.Errorf("failed to create config for exporters: %v", err)
 	}
 
-	wrappedDoneFns := make([]func(), 0, len(doneFns))
+	shutdownFns := make([]func(context.Context) error, 0, len(doneFns))
 	for _, doneFn := range doneFns {
-		wrapperFn := func() {
-			if err := doneFn(); err != nil {
-				logger.Warn("Error when closing exporters", zap.Error(err))
+		doneFn := doneFn
+		shutdownFn := func(ctx context.Context) error {
+			if err := exporterhelper.StopWithin(ctx, doneFn); err != nil {
+				return fmt.Errorf("error when closing exporters: %v", err)
 			}
+			return nil
 		}
 
-		wrappedDoneFns = append(wrappedDoneFns, wrapperFn)
+		shutdownFns = append(shutdownFns, shutdownFn)
 	}
 
-	return wrappedDoneFns, traceExporters, metricsExporters
+	return shutdownFns, traceExporters, metricsExporters, nil
 }
 
+// buildQueuedSpanProcessor builds the queued processor, and its senders and exporters,
+// described by opts. The returned functions shut down the queues and then the exporters.
 func buildQueuedSpanProcessor(
 	logger *zap.Logger, opts *builder.QueuedSpanProcessorCfg,
-) (closeFns []func(), queuedSpanProcessor consumer.TraceConsumer, err error) {
+) (shutdownFns []func(context.Context) error, queuedSpanProcessor consumer.TraceConsumer, err error) {
 	logger.Info("Constructing queue processor with name", zap.String("name", opts.Name))
 
 	// build span batch sender from configured options
@@ -76,8 +92,7 @@ func buildQueuedSpanProcessor(
 		}
 		tchreporter, err := tchrepbuilder.CreateReporter(logger)
 		if err != nil {
-			logger.Fatal("Cannot create tchannel reporter.", zap.Error(err))
-			return nil, nil, err
+			return nil, nil, fmt.Errorf("cannot create tchannel reporter: %v", err)
 		}
 		spanSender = sender.NewJaegerThriftTChannelSender(tchreporter, logger)
 	case builder.ThriftHTTPSenderType:
@@ -99,13 +114,17 @@ func buildQueuedSpanProcessor(
 			logger,
 		)
 	}
-	doneFns, traceExporters, _ := createExporters(opts.RawConfig, logger)
+	exporterShutdownFns, traceExporters, _, err := createExporters(opts.RawConfig, logger)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if spanSender == nil && len(traceExporters) == 0 {
+		shutdownAll(context.Background(), logger, exporterShutdownFns)
 		if opts.SenderType != "" {
-			logger.Fatal("Unrecognized sender type", zap.String("SenderType", string(opts.SenderType)))
+			return nil, nil, fmt.Errorf("unrecognized sender type %q", opts.SenderType)
 		}
-		logger.Fatal("No senders or exporters configured.")
+		return nil, nil, fmt.Errorf("no senders or exporters configured for %q", opts.Name)
 	}
 
 	allSendersAndExporters := make([]consumer.TraceConsumer, 0, 1+len(traceExporters))
@@ -145,27 +164,96 @@ func buildQueuedSpanProcessor(
 	}
 
 	queuedConsumers := make([]consumer.TraceConsumer, 0, len(allSendersAndExporters))
-	for _, senderOrExporter := range allSendersAndExporters {
+	for i, senderOrExporter := range allSendersAndExporters {
+		persistence := opts.Persistence
+		if persistence != nil && len(allSendersAndExporters) > 1 {
+			// Each queue needs its own directory, numbered in the order of the sender and
+			// the exporters.
+			cfg := *persistence
+			cfg.Directory = filepath.Join(cfg.Directory, strconv.Itoa(i))
+			persistence = &cfg
+		}
 		// build queued span processor with underlying sender
+		queuedConsumer, err := queued.NewQueuedSpanProcessor(
+			senderOrExporter,
+			queued.Options.WithLogger(logger),
+			queued.Options.WithName(opts.Name),
+			queued.Options.WithNumWorkers(opts.NumWorkers),
+			queued.Options.WithQueueSize(opts.QueueSize),
+			queued.Options.WithRetryOnProcessingFailures(opts.RetryOnFailure),
+			queued.Options.WithBackoffDelay(opts.BackoffDelay),
+			queued.Options.WithMaxBackoffDelay(opts.MaxBackoffDelay),
+			queued.Options.WithMaxRetries(opts.MaxRetries),
+			queued.Options.WithMaxElapsedTime(opts.MaxElapsedTime),
+			queued.Options.WithBatching(opts.BatchingConfig.Enable),
+			queued.Options.WithBatchingOptions(batchingOptions...),
+			queued.Options.WithPersistence(persistence),
+		)
+		if err != nil {
+			shutdownAll(context.Background(), logger, append(shutdownFns, exporterShutdownFns...))
+			return nil, nil, fmt.Errorf("failed to create the queue of %q: %v", opts.Name, err)
+		}
+		queuedConsumers = append(queuedConsumers, queuedConsumer)
+		shutdownFns = append(shutdownFns, processor.ShutdownFunc(queuedConsumer))
+	}
+	return append(shutdownFns, exporterShutdownFns...), multiconsumer.NewTraceProcessor(queuedConsumers), nil
+}
+
+func buildQueuedMetricsProcessors(
+	logger *zap.Logger, opts *builder.QueuedMetricsProcessorCfg, metricsExporters []consumer.MetricsConsumer,
+) []consumer.MetricsConsumer {
+	queuedConsumers := make([]consumer.MetricsConsumer, 0, len(metricsExporters))
+	for i, metricsExporter := range metricsExporters {
+		name := fmt.Sprintf("metrics-exporter-%d", i)
+		if me, ok := metricsExporter.(exporter.MetricsExporter); ok {
+			name = me.MetricsExportFormat()
+		}
+		logger.Info("Constructing queued metrics processor with name", zap.String("name", name))
 		queuedConsumers = append(
 			queuedConsumers,
-			queued.NewQueuedSpanProcessor(
-				senderOrExporter,
+			queued.NewQueuedMetricsProcessor(
+				metricsExporter,
 				queued.Options.WithLogger(logger),
-				queued.Options.WithName(opts.Name),
+				queued.Options.WithName(name),
 				queued.Options.WithNumWorkers(opts.NumWorkers),
 				queued.Options.WithQueueSize(opts.QueueSize),
 				queued.Options.WithRetryOnProcessingFailures(opts.RetryOnFailure),
 				queued.Options.WithBackoffDelay(opts.BackoffDelay),
-				queued.Options.WithBatching(opts.BatchingConfig.Enable),
-				queued.Options.WithBatchingOptions(batchingOptions...),
+				queued.Options.WithMaxBackoffDelay(opts.MaxBackoffDelay),
+				queued.Options.WithMaxRetries(opts.MaxRetries),
+				queued.Options.WithMaxElapsedTime(opts.MaxElapsedTime),
 			),
 		)
 	}
-	return doneFns, multiconsumer.NewTraceProcessor(queuedConsumers), nil
+	return queuedConsumers
 }
 
 func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[string]consumer.TraceConsumer, v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, error) {
+	policies, err := buildSamplingPolicies(cfg, nameToTraceConsumer)
+	if err != nil {
+		return nil, err
+	}
+
+	tailCfg := builder.NewDefaultTailBasedCfg().InitFromViper(v)
+	opts := []tailsampling.Option{
+		tailsampling.WithDecisionCache(tailCfg.DecisionCacheSize, tailCfg.DecisionCacheTTL),
+	}
+	if tailCfg.EarlyDecision {
+		opts = append(opts, tailsampling.WithEarlyDecision(tailCfg.EarlyDecisionIdleTimeout))
+	}
+	tailSamplingProcessor, err := tailsampling.NewTailSamplingSpanProcessor(
+		policies,
+		tailCfg.NumTraces,
+		128,
+		tailCfg.DecisionWait,
+		logger,
+		opts...)
+	return tailSamplingProcessor, err
+}
+
+// buildSamplingPolicies creates the policies of the sampling configuration, sending the
+// sampled traces to the consumers named by the exporters of each policy.
+func buildSamplingPolicies(cfg *builder.SamplingCfg, nameToTraceConsumer map[string]consumer.TraceConsumer) ([]*tailsampling.Policy, error) {
 	var policies []*tailsampling.Policy
 	seenExporter := make(map[string]bool)
 	for _, polCfg := range cfg.Policies {
@@ -173,22 +261,11 @@ func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[st
 			Name: string(polCfg.Name),
 		}
 
-		// As the number of sampling policies grow this should be changed to a map.
-		switch polCfg.Type {
-		case builder.AlwaysSample:
-			policy.Evaluator = sampling.NewAlwaysSample()
-		case builder.NumericAttributeFilter:
-			numAttributeFilterCfg := polCfg.Configuration.(*builder.NumericAttributeFilterCfg)
-			policy.Evaluator = sampling.NewNumericAttributeFilter(numAttributeFilterCfg.Key, numAttributeFilterCfg.MinValue, numAttributeFilterCfg.MaxValue)
-		case builder.StringAttributeFilter:
-			strAttributeFilterCfg := polCfg.Configuration.(*builder.StringAttributeFilterCfg)
-			policy.Evaluator = sampling.NewStringAttributeFilter(strAttributeFilterCfg.Key, strAttributeFilterCfg.Values)
-		case builder.RateLimiting:
-			rateLimitingCfg := polCfg.Configuration.(*builder.RateLimitingCfg)
-			policy.Evaluator = sampling.NewRateLimiting(rateLimitingCfg.SpansPerSecond)
-		default:
-			return nil, fmt.Errorf("unknown sampling policy %s", polCfg.Name)
+		evaluator, err := buildPolicyEvaluator(polCfg)
+		if err != nil {
+			return nil, err
 		}
+		policy.Evaluator = evaluator
 
 		var policyProcessors []consumer.TraceConsumer
 		for _, exporter := range polCfg.Exporters {
@@ -221,25 +298,222 @@ func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[st
 	if len(policies) < 1 {
 		return nil, fmt.Errorf("no sampling policies were configured")
 	}
+	return policies, nil
+}
 
-	tailCfg := builder.NewDefaultTailBasedCfg().InitFromViper(v)
-	tailSamplingProcessor, err := tailsampling.NewTailSamplingSpanProcessor(
-		policies,
-		tailCfg.NumTraces,
-		128,
-		tailCfg.DecisionWait,
-		logger)
-	return tailSamplingProcessor, err
+// buildPolicyEvaluator creates the evaluator of a sampling policy, along with the evaluators
+// of its sub-policies.
+func buildPolicyEvaluator(polCfg *builder.PolicyCfg) (sampling.PolicyEvaluator, error) {
+	var evaluator sampling.PolicyEvaluator
+	// As the number of sampling policies grow this should be changed to a map.
+	switch polCfg.Type {
+	case builder.AlwaysSample:
+		evaluator = sampling.NewAlwaysSample()
+	case builder.NumericAttributeFilter:
+		numAttributeFilterCfg, ok := polCfg.Configuration.(*builder.NumericAttributeFilterCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		evaluator = sampling.NewNumericAttributeFilter(numAttributeFilterCfg.Key, numAttributeFilterCfg.MinValue, numAttributeFilterCfg.MaxValue)
+	case builder.StringAttributeFilter:
+		strAttributeFilterCfg, ok := polCfg.Configuration.(*builder.StringAttributeFilterCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		evaluator = sampling.NewStringAttributeFilter(strAttributeFilterCfg.Key, strAttributeFilterCfg.Values)
+	case builder.RateLimiting:
+		rateLimitingCfg, ok := polCfg.Configuration.(*builder.RateLimitingCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		evaluator = sampling.NewRateLimiting(rateLimitingCfg.SpansPerSecond)
+	case builder.KeyedRateLimiting:
+		keyedRateLimitingCfg, ok := polCfg.Configuration.(*builder.KeyedRateLimitingCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		overrides := make(map[string]int64, len(keyedRateLimitingCfg.Overrides))
+		for _, override := range keyedRateLimitingCfg.Overrides {
+			overrides[override.Value] = override.SpansPerSecond
+		}
+		evaluator = sampling.NewKeyedRateLimiting(polCfg.Name, keyedRateLimitingCfg.Key, keyedRateLimitingCfg.SpansPerSecond, overrides)
+	case builder.Latency:
+		latencyCfg, ok := polCfg.Configuration.(*builder.LatencyCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		operationThresholds := make(map[string]time.Duration, len(latencyCfg.OperationThresholds))
+		for _, opCfg := range latencyCfg.OperationThresholds {
+			operationThresholds[opCfg.Operation] = opCfg.Threshold
+		}
+		evaluator = sampling.NewLatency(latencyCfg.TraceThreshold, latencyCfg.SpanThreshold, operationThresholds)
+	case builder.TraceShape:
+		traceShapeCfg, ok := polCfg.Configuration.(*builder.TraceShapeCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		spanKinds := make([]tracepb.Span_SpanKind, 0, len(traceShapeCfg.SpanKinds))
+		for _, kind := range traceShapeCfg.SpanKinds {
+			value, ok := tracepb.Span_SpanKind_value[strings.ToUpper(kind)]
+			if !ok {
+				return nil, fmt.Errorf("unknown span kind %q for sampling policy %q", kind, polCfg.Name)
+			}
+			spanKinds = append(spanKinds, tracepb.Span_SpanKind(value))
+		}
+		evaluator = sampling.NewTraceShape(traceShapeCfg.MinSpans, traceShapeCfg.MinDepth, traceShapeCfg.MinServices, spanKinds)
+	case builder.ErrorStatus:
+		errorStatusCfg, ok := polCfg.Configuration.(*builder.ErrorStatusCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		var err error
+		if evaluator, err = buildErrorStatusPolicy(errorStatusCfg); err != nil {
+			return nil, fmt.Errorf("invalid configuration for sampling policy %q: %v", polCfg.Name, err)
+		}
+	case builder.Adaptive:
+		adaptiveCfg, ok := polCfg.Configuration.(*builder.AdaptiveCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		if adaptiveCfg.TracesPerSecond <= 0 || adaptiveCfg.AdjustInterval <= 0 {
+			return nil, fmt.Errorf("traces per second and adjust interval of sampling policy %q must be positive", polCfg.Name)
+		}
+		var err error
+		evaluator, err = sampling.NewAdaptive(adaptiveCfg.TracesPerSecond, adaptiveCfg.AdjustInterval, adaptiveCfg.StateFile, adaptiveCfg.HashSeed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sampling policy %q: %v", polCfg.Name, err)
+		}
+	case builder.Probabilistic:
+		probabilisticCfg, ok := polCfg.Configuration.(*builder.ProbabilisticCfg)
+		if !ok {
+			return nil, fmt.Errorf("missing configuration for sampling policy %q", polCfg.Name)
+		}
+		if probabilisticCfg.SamplingPercentage < 0 || probabilisticCfg.SamplingPercentage > 100 {
+			return nil, fmt.Errorf("sampling percentage of sampling policy %q must be between 0 and 100", polCfg.Name)
+		}
+		evaluator = sampling.NewProbabilistic(probabilisticCfg.SamplingPercentage, probabilisticCfg.HashSeed)
+	case builder.And, builder.Or, builder.Not:
+		booleanCfg, ok := polCfg.Configuration.(*builder.BooleanCfg)
+		if !ok || len(booleanCfg.SubPolicies) == 0 {
+			return nil, fmt.Errorf("no sub-policies for sampling policy %q", polCfg.Name)
+		}
+		subEvaluators := make([]sampling.PolicyEvaluator, 0, len(booleanCfg.SubPolicies))
+		for _, subPolCfg := range booleanCfg.SubPolicies {
+			subEvaluator, err := buildPolicyEvaluator(subPolCfg)
+			if err != nil {
+				return nil, err
+			}
+			subEvaluators = append(subEvaluators, subEvaluator)
+		}
+		switch polCfg.Type {
+		case builder.And:
+			evaluator = sampling.NewAnd(subEvaluators...)
+		case builder.Or:
+			evaluator = sampling.NewOr(subEvaluators...)
+		default:
+			if len(subEvaluators) != 1 {
+				return nil, fmt.Errorf("sampling policy %q must have a single sub-policy", polCfg.Name)
+			}
+			evaluator = sampling.NewNot(subEvaluators[0])
+		}
+	case builder.Composite:
+		compositeCfg, ok := polCfg.Configuration.(*builder.CompositeCfg)
+		if !ok || len(compositeCfg.SubPolicies) == 0 {
+			return nil, fmt.Errorf("no sub-policies for sampling policy %q", polCfg.Name)
+		}
+		if compositeCfg.SpansPerSecond <= 0 {
+			return nil, fmt.Errorf("spans per second of sampling policy %q must be positive", polCfg.Name)
+		}
+		subPolicies := make([]sampling.WeightedPolicy, 0, len(compositeCfg.SubPolicies))
+		for _, subPolCfg := range compositeCfg.SubPolicies {
+			if subPolCfg.Weight <= 0 {
+				return nil, fmt.Errorf("weight of sampling policy %q must be positive", subPolCfg.Policy.Name)
+			}
+			subEvaluator, err := buildPolicyEvaluator(subPolCfg.Policy)
+			if err != nil {
+				return nil, err
+			}
+			subPolicies = append(subPolicies, sampling.WeightedPolicy{Evaluator: subEvaluator, Weight: subPolCfg.Weight})
+		}
+		evaluator = sampling.NewComposite(compositeCfg.SpansPerSecond, subPolicies)
+	default:
+		return nil, fmt.Errorf("unknown sampling policy %s", polCfg.Name)
+	}
+	return evaluator, nil
 }
 
-func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, []func()) {
+func buildErrorStatusPolicy(cfg *builder.ErrorStatusCfg) (sampling.PolicyEvaluator, error) {
+	var statusCodes []int32
+	for _, name := range cfg.StatusCodes {
+		code, ok := sampling.ParseStatusCode(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown status code %q", name)
+		}
+		statusCodes = append(statusCodes, code)
+	}
+
+	httpStatusCodes := cfg.HTTPStatusCodes
+	if len(httpStatusCodes) == 0 {
+		httpStatusCodes = []string{"5xx"}
+	}
+	httpStatusRanges := make([]sampling.HTTPStatusRange, 0, len(httpStatusCodes))
+	for _, code := range httpStatusCodes {
+		r, err := parseHTTPStatusRange(code)
+		if err != nil {
+			return nil, err
+		}
+		httpStatusRanges = append(httpStatusRanges, r)
+	}
+
+	return sampling.NewErrorStatus(statusCodes, httpStatusRanges, cfg.ErrorTag, cfg.ErrorAnnotations), nil
+}
+
+// parseHTTPStatusRange parses either an HTTP status code, e.g.: "429", or a class of codes,
+// e.g.: "5xx".
+func parseHTTPStatusRange(code string) (sampling.HTTPStatusRange, error) {
+	lower := strings.ToLower(code)
+	if len(lower) == 3 && strings.HasSuffix(lower, "xx") && lower[0] >= '1' && lower[0] <= '5' {
+		class := int64(lower[0]-'0') * 100
+		return sampling.HTTPStatusRange{Min: class, Max: class + 99}, nil
+	}
+	status, err := strconv.ParseInt(code, 10, 64)
+	if err != nil || status < 100 || status > 599 {
+		return sampling.HTTPStatusRange{}, fmt.Errorf("invalid HTTP status code %q", code)
+	}
+	return sampling.HTTPStatusRange{Min: status, Max: status}, nil
+}
+
+func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, consumer.MetricsConsumer, []func(context.Context) error) {
+	tp, mp, shutdownFns, err := buildProcessor(v, logger)
+	if err != nil {
+		logger.Error("Failed to build the processors", zap.Error(err))
+		os.Exit(1)
+	}
+	return tp, mp, shutdownFns
+}
+
+// buildProcessor builds the processors and exporters that receive the data of all
+// enabled receivers when no pipelines are configured. The returned functions shut down
+// the processors, starting with the closest to the receivers, and then the exporters.
+// On error the processors and exporters already created are shut down.
+func buildProcessor(v *viper.Viper, logger *zap.Logger) (_ consumer.TraceConsumer, _ consumer.MetricsConsumer, shutdownFns []func(context.Context) error, err error) {
+	var processorShutdownFns, exporterShutdownFns []func(context.Context) error
+	defer func() {
+		shutdownFns = append(processorShutdownFns, exporterShutdownFns...)
+		if err != nil {
+			shutdownAll(context.Background(), logger, shutdownFns)
+			shutdownFns = nil
+		}
+	}()
+
 	// Build pipeline from its end: 1st exporters, the OC-proto queue processor, and
 	// finally the receivers.
-	var closeFns []func()
 	var traceConsumers []consumer.TraceConsumer
 	nameToTraceConsumer := make(map[string]consumer.TraceConsumer)
-	exportersCloseFns, traceExporters, metricsExporters := createExporters(v, logger)
-	closeFns = append(closeFns, exportersCloseFns...)
+	exporterShutdownFns, traceExporters, metricsExporters, err := createExporters(v, logger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	if len(traceExporters) > 0 {
 		// Exporters need an extra hop from OC-proto to span data: to workaround that for now
 		// we will use a special processor that transforms the data to a format that they can consume.
@@ -251,42 +525,46 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 		traceConsumers = append(traceConsumers, traceExpProc)
 	}
 
-	// TODO: (@pjanotti) make use of metrics exporters
-	_ = metricsExporters
+	// Metrics exporters are each placed behind their own queue so a slow or failing
+	// backend does not block the receivers or the other exporters.
+	metricsQueueCfg := builder.NewDefaultQueuedMetricsProcessorCfg().InitFromViper(v)
+	metricsConsumers := buildQueuedMetricsProcessors(logger, metricsQueueCfg, metricsExporters)
+	for _, metricsConsumer := range metricsConsumers {
+		processorShutdownFns = append(processorShutdownFns, processor.ShutdownFunc(metricsConsumer))
+	}
 
 	if builder.LoggingExporterEnabled(v) {
 		dbgProc, _ := loggingexporter.NewTraceExporter(logger)
 		// TODO: Add this to the exporters list and avoid treating it specially. Don't know all the implications.
 		nameToTraceConsumer["debug"] = dbgProc
 		traceConsumers = append(traceConsumers, dbgProc)
+
+		dbgMetricsProc, _ := loggingexporter.NewMetricsExporter(logger)
+		metricsConsumers = append(metricsConsumers, dbgMetricsProc)
 	}
 
 	multiProcessorCfg := builder.NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
 	for _, queuedJaegerProcessorCfg := range multiProcessorCfg.Processors {
 		logger.Info("Queued Jaeger Sender Enabled")
-		doneFns, queuedJaegerProcessor, err := buildQueuedSpanProcessor(logger, queuedJaegerProcessorCfg)
+		queuedShutdownFns, queuedJaegerProcessor, err := buildQueuedSpanProcessor(logger, queuedJaegerProcessorCfg)
 		if err != nil {
-			logger.Error("Failed to build the queued span processor", zap.Error(err))
-			os.Exit(1)
+			return nil, nil, nil, fmt.Errorf("failed to build the queued span processor: %v", err)
 		}
 		nameToTraceConsumer[queuedJaegerProcessorCfg.Name] = queuedJaegerProcessor
 		traceConsumers = append(traceConsumers, queuedJaegerProcessor)
-		closeFns = append(closeFns, doneFns...)
+		processorShutdownFns = append(processorShutdownFns, queuedShutdownFns...)
 	}
 
-	if len(traceConsumers) == 0 {
-		logger.Warn("Nothing to do: no processor was enabled. Shutting down.")
-		os.Exit(1)
+	if len(traceConsumers) == 0 && len(metricsConsumers) == 0 {
+		return nil, nil, nil, errors.New("nothing to do: no processor was enabled")
 	}
 
 	var tailSamplingProcessor consumer.TraceConsumer
 	samplingProcessorCfg := builder.NewDefaultSamplingCfg().InitFromViper(v)
 	if samplingProcessorCfg.Mode == builder.TailSampling {
-		var err error
 		tailSamplingProcessor, err = buildSamplingProcessor(samplingProcessorCfg, nameToTraceConsumer, v, logger)
 		if err != nil {
-			logger.Error("Falied to build the sampling processor", zap.Error(err))
-			os.Exit(1)
+			return nil, nil, nil, fmt.Errorf("failed to build the sampling processor: %v", err)
 		}
 	} else if builder.DebugTailSamplingEnabled(v) {
 		policy := []*tailsampling.Policy{
@@ -296,27 +574,36 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 				Destination: multiconsumer.NewTraceProcessor(traceConsumers),
 			},
 		}
-		var err error
 		tailSamplingProcessor, err = tailsampling.NewTailSamplingSpanProcessor(policy, 50000, 128, 10*time.Second, logger)
 		if err != nil {
-			logger.Error("Falied to build the debug tail-sampling processor", zap.Error(err))
-			os.Exit(1)
+			return nil, nil, nil, fmt.Errorf("failed to build the debug tail-sampling processor: %v", err)
 		}
 		logger.Info("Debugging tail-sampling with always sample policy (num_traces: 50000; decision_wait: 10s)")
 	}
 
 	if tailSamplingProcessor != nil {
-		// SpanProcessors are going to go all via the tail sampling processor.
+		// SpanProcessors are going to go all via the tail sampling processor, so it
+		// is the first to be shut down.
 		traceConsumers = []consumer.TraceConsumer{tailSamplingProcessor}
+		processorShutdownFns = append(
+			[]func(context.Context) error{processor.ShutdownFunc(tailSamplingProcessor)}, processorShutdownFns...)
 	}
 
 	// Wraps processors in a single one to be connected to all enabled receivers.
 	tp := multiconsumer.NewTraceProcessor(traceConsumers)
+	if samplingProcessorCfg.Mode == builder.HeadSampling {
+		headSamplingCfg := builder.NewDefaultHeadBasedCfg().InitFromViper(v)
+		tp, err = newHeadSamplingProcessor(tp, headSamplingCfg)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to build the head sampling processor: %v", err)
+		}
+		logger.Info("Head sampling enabled", zap.Float64("sampling-percentage", headSamplingCfg.SamplingPercentage))
+	}
 	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.Attributes != nil {
 		logger.Info(
 			"Found global attributes config",
 			zap.Bool("overwrite", multiProcessorCfg.Global.Attributes.Overwrite),
-			zap.Any("values", multiProcessorCfg.Global.Attributes.Values),
+			zap.Any("values", secrets.RedactValue(multiProcessorCfg.Global.Attributes.Values)),
 			zap.Any("key-mapping", multiProcessorCfg.Global.Attributes.KeyReplacements),
 		)
 
@@ -331,5 +618,54 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 			tp, _ = attributekeyprocessor.NewTraceProcessor(tp, multiProcessorCfg.Global.Attributes.KeyReplacements...)
 		}
 	}
-	return tp, closeFns
+
+	mp := multiconsumer.NewMetricsProcessor(metricsConsumers)
+	if memoryLimiterCfg := builder.MemoryLimiterViper(v); memoryLimiterCfg != nil {
+		// The memory limiter goes in front of all other processors, including the
+		// tail-sampling one, so the data is refused before it is held in memory.
+		var limiterShutdownFns []func(context.Context) error
+		tp, mp, limiterShutdownFns, err = buildMemoryLimiters(memoryLimiterCfg, tp, mp)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to build the memory limiter: %v", err)
+		}
+		processorShutdownFns = append(limiterShutdownFns, processorShutdownFns...)
+		logger.Info("Memory limiter enabled",
+			zap.Int64("soft-limit-mib", memoryLimiterCfg.GetInt64("soft-limit-mib")),
+			zap.Int64("hard-limit-mib", memoryLimiterCfg.GetInt64("hard-limit-mib")))
+	}
+	return tp, mp, nil, nil
+}
+
+// buildMemoryLimiters places a memory limiter in front of the trace and metrics processors,
+// the returned functions stop the memory checks.
+func buildMemoryLimiters(
+	cfg *viper.Viper,
+	tp consumer.TraceConsumer,
+	mp consumer.MetricsConsumer,
+) (consumer.TraceConsumer, consumer.MetricsConsumer, []func(context.Context) error, error) {
+	traceLimiter, err := memorylimiter.NewTraceProcessorFactory().NewFromViper(cfg, tp)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	metricsLimiter, err := memorylimiter.NewMetricsProcessorFactory().NewFromViper(cfg, mp)
+	if err != nil {
+		processor.ShutdownFunc(traceLimiter)(context.Background())
+		return nil, nil, nil, err
+	}
+	shutdownFns := []func(context.Context) error{
+		processor.ShutdownFunc(traceLimiter),
+		processor.ShutdownFunc(metricsLimiter),
+	}
+	return traceLimiter, metricsLimiter, shutdownFns, nil
+}
+
+// newHeadSamplingProcessor creates the head sampling processor of the "sampling" section,
+// it is used by validate too so both check the same settings.
+func newHeadSamplingProcessor(next consumer.TraceConsumer, cfg *builder.HeadBasedCfg) (consumer.TraceConsumer, error) {
+	return headsampling.NewTraceProcessor(
+		next,
+		headsampling.WithSamplingPercentage(cfg.SamplingPercentage),
+		headsampling.WithHashSeed(cfg.HashSeed),
+		headsampling.WithServicePercentages(cfg.ServiceOverrides),
+	)
 }
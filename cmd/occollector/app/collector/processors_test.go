@@ -15,12 +15,16 @@
 package collector
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/memorylimiter"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+	"github.com/census-instrumentation/opencensus-service/processor"
 	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
@@ -85,21 +89,69 @@ func Test_startProcessor(t *testing.T) {
 				return attributeKeyProcessor
 			},
 		},
+		{
+			name: "memory_limiter",
+			setupViperCfg: func() *viper.Viper {
+				v := viper.New()
+				v.Set("logging-exporter", true)
+				v.Set("global.attributes.values", map[string]interface{}{"foo": "bar"})
+				v.Set("memory-limiter.soft-limit-mib", 1<<20)
+				return v
+			},
+			wantExamplar: func(t *testing.T) interface{} {
+				nopProcessor := processortest.NewNopTraceProcessor(nil)
+				memoryLimiter, err := memorylimiter.NewTraceProcessor(nopProcessor, memorylimiter.WithSoftLimitMiB(1<<20))
+				if err != nil {
+					t.Fatalf("memorylimiter.NewTraceProcessor() = %v", err)
+				}
+				processor.ShutdownFunc(memoryLimiter)(context.Background())
+				return memoryLimiter
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			consumer, closeFns := startProcessor(tt.setupViperCfg(), zap.NewNop())
+			consumer, metricsConsumer, shutdownFns := startProcessor(tt.setupViperCfg(), zap.NewNop())
 			if consumer == nil {
 				t.Errorf("startProcessor() got nil consumer")
 			}
+			if metricsConsumer == nil {
+				t.Errorf("startProcessor() got nil metrics consumer")
+			}
 			consumerExamplar := tt.wantExamplar(t)
 			if reflect.TypeOf(consumer) != reflect.TypeOf(consumerExamplar) {
 				t.Errorf("startProcessor() got consumer type %q want %q",
 					reflect.TypeOf(consumer),
 					reflect.TypeOf(consumerExamplar))
 			}
-			for _, closeFn := range closeFns {
-				closeFn()
+			for _, shutdownFn := range shutdownFns {
+				shutdownFn(context.Background())
+			}
+		})
+	}
+}
+
+func Test_parseHTTPStatusRange(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    sampling.HTTPStatusRange
+		wantErr bool
+	}{
+		{code: "5xx", want: sampling.HTTPStatusRange{Min: 500, Max: 599}},
+		{code: "4XX", want: sampling.HTTPStatusRange{Min: 400, Max: 499}},
+		{code: "429", want: sampling.HTTPStatusRange{Min: 429, Max: 429}},
+		{code: "6xx", wantErr: true},
+		{code: "42", wantErr: true},
+		{code: "teapot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := parseHTTPStatusRange(tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHTTPStatusRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHTTPStatusRange() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
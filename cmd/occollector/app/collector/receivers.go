@@ -16,6 +16,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/spf13/viper"
@@ -23,47 +24,137 @@ import (
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/consumer"
-	jaegerreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/jaeger"
 	ocreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/opencensus"
-	shopifyreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/shopify"
-	zipkinreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/zipkin"
-	zipkinscribereceiver "github.com/census-instrumentation/opencensus-service/internal/collector/zipkin/scribe"
 	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
-func createReceivers(v *viper.Viper, logger *zap.Logger, traceConsumers consumer.TraceConsumer, asyncErrorChan chan<- error) []receiver.TraceReceiver {
-	var someReceiverEnabled bool
-	receivers := []struct {
-		runFn   func(*zap.Logger, *viper.Viper, consumer.TraceConsumer, chan<- error) (receiver.TraceReceiver, error)
-		enabled bool
-	}{
-		{jaegerreceiver.Start, builder.JaegerReceiverEnabled(v)},
-		{ocreceiver.Start, builder.OpenCensusReceiverEnabled(v)},
-		{shopifyreceiver.Start, builder.ShopifyReceiverEnabled(v)},
-		{zipkinreceiver.Start, builder.ZipkinReceiverEnabled(v)},
-		{zipkinscribereceiver.Start, builder.ZipkinScribeReceiverEnabled(v)},
+// typeReceivers holds the receivers started for a receiver type, a type can have a
+// trace receiver, a metrics receiver, or both.
+type typeReceivers struct {
+	trace   receiver.TraceReceiver
+	metrics receiver.MetricsReceiver
+}
+
+func (tr *typeReceivers) stop() {
+	if tr.trace != nil {
+		tr.trace.StopTraceReception(context.Background())
 	}
+	if tr.metrics != nil {
+		tr.metrics.StopMetricsReception(context.Background())
+	}
+}
 
-	var startedTraceReceivers []receiver.TraceReceiver
-	for _, receiver := range receivers {
-		if receiver.enabled {
-			rec, err := receiver.runFn(logger, v, traceConsumers, asyncErrorChan)
-			if err != nil {
-				// TODO: (@pjanotti) better shutdown, for now just try to stop any started receiver before terminating.
-				for _, startedTraceReceiver := range startedTraceReceivers {
-					startedTraceReceiver.StopTraceReception(context.Background())
-				}
-				logger.Fatal("Cannot run receiver for "+rec.TraceSource(), zap.Error(err))
+// createReceivers creates and starts the enabled receivers, keyed by their type. The
+// receivers are taken from the factories registered in the receiver package, see
+// components.go.
+func createReceivers(
+	v *viper.Viper,
+	logger *zap.Logger,
+	traceConsumers consumer.TraceConsumer,
+	metricsConsumers consumer.MetricsConsumer,
+	asyncErrorChan chan<- error,
+) map[string]*typeReceivers {
+	startedReceivers := make(map[string]*typeReceivers)
+	for _, receiverType := range receiverTypes() {
+		if !receiverEnabled(v, receiverType) {
+			continue
+		}
+		tr, err := startReceiver(v, logger, receiverType, traceConsumers, metricsConsumers, asyncErrorChan)
+		if err != nil {
+			// TODO: (@pjanotti) better shutdown, for now just try to stop any started receiver before terminating.
+			for _, startedReceiver := range startedReceivers {
+				startedReceiver.stop()
 			}
-			startedTraceReceivers = append(startedTraceReceivers, rec)
-			someReceiverEnabled = true
+			logger.Fatal("Cannot run receiver", zap.String("receiver", receiverType), zap.Error(err))
 		}
+		startedReceivers[receiverType] = tr
 	}
 
-	if !someReceiverEnabled {
+	if len(startedReceivers) == 0 {
 		logger.Warn("Nothing to do: no receiver was enabled. Shutting down.")
 		os.Exit(1)
 	}
 
-	return startedTraceReceivers
+	return startedReceivers
+}
+
+// receiverTypes returns the types of all registered receivers, starting with the
+// OpenCensus receiver that handles both traces and metrics on the same endpoint.
+func receiverTypes() []string {
+	types := []string{ocreceiver.TypeStr}
+	seen := map[string]bool{ocreceiver.TypeStr: true}
+	for _, factory := range receiver.TraceReceiverFactories() {
+		if !seen[factory.Type()] {
+			seen[factory.Type()] = true
+			types = append(types, factory.Type())
+		}
+	}
+	for _, factory := range receiver.MetricsReceiverFactories() {
+		if !seen[factory.Type()] {
+			seen[factory.Type()] = true
+			types = append(types, factory.Type())
+		}
+	}
+	return types
+}
+
+func receiverEnabled(v *viper.Viper, receiverType string) bool {
+	if receiverType == ocreceiver.TypeStr {
+		return builder.OpenCensusReceiverEnabled(v)
+	}
+	return builder.ReceiverEnabled(v, receiverType)
+}
+
+// startReceiver creates and starts the receivers of the given type. If any of them
+// fails to start the ones already started are stopped.
+func startReceiver(
+	v *viper.Viper,
+	logger *zap.Logger,
+	receiverType string,
+	traceConsumers consumer.TraceConsumer,
+	metricsConsumers consumer.MetricsConsumer,
+	asyncErrorChan chan<- error,
+) (*typeReceivers, error) {
+	if receiverType == ocreceiver.TypeStr {
+		tr, mr, err := ocreceiver.Start(logger, v, traceConsumers, metricsConsumers, asyncErrorChan)
+		if err != nil {
+			return nil, err
+		}
+		return &typeReceivers{trace: tr, metrics: mr}, nil
+	}
+
+	started := &typeReceivers{}
+	if factory := receiver.GetTraceReceiverFactory(receiverType); factory != nil {
+		rec, err := factory.NewFromViper(receiverViper(v, receiverType), traceConsumers, logger)
+		if err == nil {
+			err = rec.StartTraceReception(context.Background(), asyncErrorChan)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot run trace receiver: %v", err)
+		}
+		logger.Info("Trace receiver is running.", zap.String("receiver", receiverType))
+		started.trace = rec
+	}
+	if factory := receiver.GetMetricsReceiverFactory(receiverType); factory != nil {
+		rec, err := factory.NewFromViper(receiverViper(v, receiverType), metricsConsumers, logger)
+		if err == nil {
+			err = rec.StartMetricsReception(context.Background(), asyncErrorChan)
+		}
+		if err != nil {
+			started.stop()
+			return nil, fmt.Errorf("cannot run metrics receiver: %v", err)
+		}
+		logger.Info("Metrics receiver is running.", zap.String("receiver", receiverType))
+		started.metrics = rec
+	}
+	return started, nil
+}
+
+// receiverViper returns the configuration of the receiver of the given type. Receivers
+// enabled only via command-line flag get an empty configuration so their defaults are used.
+func receiverViper(v *viper.Viper, receiverType string) *viper.Viper {
+	if rv := builder.ReceiverViper(v, receiverType); rv != nil {
+		return rv
+	}
+	return viper.New()
 }
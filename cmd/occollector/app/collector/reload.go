@@ -0,0 +1,104 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
+)
+
+// reload re-reads the configuration file and applies it, recreating only the
+// components whose configuration changed.
+func (app *Application) reload(asyncErrorChannel chan<- error) error {
+	file := builder.GetConfigFile(app.v)
+	if file == "" {
+		return errors.New("no configuration file to reload")
+	}
+	if err := config.ReadConfigFile(app.v, file); err != nil {
+		return fmt.Errorf("error loading config file %q: %v", file, err)
+	}
+	app.retirer.SetTimeout(app.v.GetDuration(shutdownTimeoutCfg))
+
+	if builder.PipelinesEnabled(app.v) != (app.pipelines != nil) {
+		return errors.New("switching between a configuration with pipelines and one without them requires a restart")
+	}
+	if app.pipelines != nil {
+		return reloadPipelines(app.pipelines, app.v, asyncErrorChannel, app.retirer)
+	}
+	return app.reloadProcessorAndReceivers(asyncErrorChannel)
+}
+
+// reloadProcessorAndReceivers applies the configuration when no pipelines are configured.
+// The processors and exporters are rebuilt if anything other than the receivers changed,
+// and only the receivers whose configuration changed are restarted.
+func (app *Application) reloadProcessorAndReceivers(asyncErrorChannel chan<- error) error {
+	newSettings := reload.Settings(app.v.AllSettings())
+
+	if reload.ChangedExcept(app.appliedSettings, newSettings, "receivers") {
+		tp, mp, shutdownFns, err := buildProcessor(app.v, app.logger)
+		if err != nil {
+			return err
+		}
+		app.processor.Swap(tp)
+		app.metricsProcessor.Swap(mp)
+		app.retire(app.processorShutdownFns)
+		app.processorShutdownFns = shutdownFns
+		app.logger.Info("Processors and exporters rebuilt")
+	}
+
+	// The receivers send their data to the switches, so the ones whose configuration
+	// did not change keep running untouched. Receivers that failed to start on a previous
+	// reload are not in app.receivers and are started again.
+	var errs []error
+	for _, receiverType := range receiverTypes() {
+		enabled := receiverEnabled(app.v, receiverType)
+		if tr, ok := app.receivers[receiverType]; ok {
+			if enabled && !reload.Changed(app.appliedSettings, newSettings, "receivers", receiverType) {
+				continue
+			}
+			tr.stop()
+			delete(app.receivers, receiverType)
+			app.logger.Info("Receiver stopped", zap.String("receiver", receiverType))
+		}
+		if !enabled {
+			continue
+		}
+		tr, err := startReceiver(app.v, app.logger, receiverType, app.processor, app.metricsProcessor, asyncErrorChannel)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot run receiver %q: %v", receiverType, err))
+			continue
+		}
+		app.receivers[receiverType] = tr
+	}
+
+	app.appliedSettings = newSettings
+	return internal.CombineErrors(errs)
+}
+
+// retire shuts down the given processors and exporters after reload.RetireDelay, or on
+// shutdown if that happens first.
+func (app *Application) retire(shutdownFns []func(context.Context) error) {
+	app.retirer.Retire(func(ctx context.Context) {
+		shutdownAll(ctx, app.logger, shutdownFns)
+	})
+}
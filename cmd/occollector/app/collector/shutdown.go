@@ -0,0 +1,48 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	shutdownTimeoutCfg = "shutdown-timeout"
+)
+
+func shutdownFlags(flags *flag.FlagSet) {
+	flags.Duration(shutdownTimeoutCfg, 10*time.Second,
+		"Time the processors and exporters have to send the data they hold, e.g.: queued spans, when the collector exits.")
+}
+
+// shutdownContext returns the context bounding the time spent shutting down the
+// processors and exporters.
+func shutdownContext(v *viper.Viper) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), v.GetDuration(shutdownTimeoutCfg))
+}
+
+// shutdownAll calls the given shutdown functions in order, logging the data they lost.
+func shutdownAll(ctx context.Context, logger *zap.Logger, shutdownFns []func(context.Context) error) {
+	for _, shutdownFn := range shutdownFns {
+		if err := shutdownFn(ctx); err != nil {
+			logger.Warn("Failed to shut down", zap.Error(err))
+		}
+	}
+}
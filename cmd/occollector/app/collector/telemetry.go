@@ -25,10 +25,15 @@ import (
 	"go.opencensus.io/stats/view"
 	"go.uber.org/zap"
 
+	"github.com/census-instrumentation/opencensus-service/exporter/failoverexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/loadbalancingexporter"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/headsampling"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/memorylimiter"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/tailsampling"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
 	"github.com/census-instrumentation/opencensus-service/observability"
 )
@@ -59,8 +64,13 @@ func initTelemetry(asyncErrorChannel chan<- error, v *viper.Viper, logger *zap.L
 	views := processor.MetricViews(level)
 	views = append(views, queued.MetricViews(level)...)
 	views = append(views, nodebatcher.MetricViews(level)...)
+	views = append(views, memorylimiter.MetricViews(level)...)
+	views = append(views, headsampling.MetricViews(level)...)
+	views = append(views, loadbalancingexporter.MetricViews(level)...)
+	views = append(views, failoverexporter.MetricViews(level)...)
 	views = append(views, observability.AllViews...)
 	views = append(views, tailsampling.SamplingProcessorMetricViews(level)...)
+	views = append(views, sampling.MetricViews(level)...)
 	processMetricsViews := telemetry.NewProcessMetricsViews()
 	views = append(views, processMetricsViews.Views()...)
 	if err := view.Register(views...); err != nil {
@@ -0,0 +1,220 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/pipeline"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/memorylimiter"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// newValidateCommand creates the "validate" command, it checks the configuration
+// without binding any port or connecting to any backend.
+func newValidateCommand() *cobra.Command {
+	v := viper.New()
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration without running the collector",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := readConfigFile(v, builder.GetConfigFile(v)); err != nil {
+				return err
+			}
+			if err := validateConfig(v); err != nil {
+				return fmt.Errorf("invalid configuration: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration %q is valid\n", builder.GetConfigFile(v))
+			return nil
+		},
+	}
+	addConfigFlags(v, cmd)
+	return cmd
+}
+
+// newPrintConfigCommand creates the "print-config" command, it prints the configuration
+// file with the defaults of the configured components filled in.
+func newPrintConfigCommand() *cobra.Command {
+	v := viper.New()
+	cmd := &cobra.Command{
+		Use:   "print-config",
+		Short: "Print the configuration with the defaults of its components",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The settings of the flags are left out of the output, so the file is
+			// read into its own viper. The references to environment variables and
+			// files are printed as written, so secrets are not disclosed.
+			file := builder.GetConfigFile(v)
+			if file == "" {
+				return fmt.Errorf("missing the configuration file, use --config")
+			}
+			fv := viper.New()
+			fv.SetConfigFile(file)
+			if err := fv.ReadInConfig(); err != nil {
+				return fmt.Errorf("error loading config file %q: %v", file, err)
+			}
+			out, err := yaml.Marshal(config.EffectiveSettings(fv))
+			if err != nil {
+				return fmt.Errorf("failed to marshal the configuration: %v", err)
+			}
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+	addConfigFlags(v, cmd)
+	return cmd
+}
+
+// addConfigFlags adds the flags that select the components of the collector, with "-c"
+// as a shorthand for "--config".
+func addConfigFlags(v *viper.Viper, cmd *cobra.Command) {
+	// The flag is defined before the ones of builder.Flags, so it takes the place of
+	// the "config" flag without a shorthand defined there.
+	cmd.Flags().StringP("config", "c", "", "Path to the config file")
+	viperutils.AddFlags(v, cmd, builder.Flags)
+	cmd.SilenceUsage = true
+}
+
+func readConfigFile(v *viper.Viper, file string) error {
+	if file == "" {
+		return fmt.Errorf("missing the configuration file, use --config")
+	}
+	if err := config.ReadConfigFile(v, file); err != nil {
+		return fmt.Errorf("error loading config file %q: %v", file, err)
+	}
+	return nil
+}
+
+// validateConfig checks the configuration of all components that would be created by
+// the collector for the configuration in v, see pipeline.Validate and buildProcessor.
+func validateConfig(v *viper.Viper) error {
+	if builder.PipelinesEnabled(v) {
+		cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+		if err != nil {
+			return err
+		}
+		return pipeline.Validate(v, cfg, pipelineFactories())
+	}
+
+	var errs []error
+	if err := validateReceivers(v); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.ValidateExportersConfig(v); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Nop consumers stand for the processors and exporters that can be referenced
+	// by the sampling policies.
+	nameToTraceConsumer := make(map[string]consumer.TraceConsumer)
+	if hasTraceExporters(v) {
+		nameToTraceConsumer["exporters"] = exportertest.NewNopTraceExporter()
+	}
+	if builder.LoggingExporterEnabled(v) {
+		nameToTraceConsumer["debug"] = exportertest.NewNopTraceExporter()
+	}
+	multiProcessorCfg := builder.NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	for _, processorCfg := range multiProcessorCfg.Processors {
+		if err := validateQueuedSpanProcessor(processorCfg); err != nil {
+			errs = append(errs, err)
+		}
+		nameToTraceConsumer[processorCfg.Name] = exportertest.NewNopTraceExporter()
+	}
+
+	if memoryLimiterCfg := builder.MemoryLimiterViper(v); memoryLimiterCfg != nil {
+		validator := memorylimiter.NewTraceProcessorFactory().(processor.ConfigValidator)
+		if err := validator.ValidateConfig(memoryLimiterCfg); err != nil {
+			errs = append(errs, fmt.Errorf("invalid memory limiter configuration: %v", err))
+		}
+	}
+
+	samplingProcessorCfg := builder.NewDefaultSamplingCfg().InitFromViper(v)
+	if samplingProcessorCfg.Mode == builder.TailSampling {
+		if _, err := buildSamplingPolicies(samplingProcessorCfg, nameToTraceConsumer); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sampling configuration: %v", err))
+		}
+	}
+	if samplingProcessorCfg.Mode == builder.HeadSampling {
+		headSamplingCfg := builder.NewDefaultHeadBasedCfg().InitFromViper(v)
+		if _, err := newHeadSamplingProcessor(exportertest.NewNopTraceExporter(), headSamplingCfg); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sampling configuration: %v", err))
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// validateReceivers checks the configuration of the enabled receivers, any entry of the
+// "receivers" section that is not of a known type is reported as an error.
+func validateReceivers(v *viper.Viper) error {
+	var errs []error
+	knownTypes := make(map[string]bool)
+	for _, receiverType := range receiverTypes() {
+		knownTypes[receiverType] = true
+		if !receiverEnabled(v, receiverType) {
+			continue
+		}
+		if err := config.ValidateReceiverConfig(receiverType, receiverViper(v, receiverType)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for receiverType := range v.GetStringMap("receivers") {
+		if !knownTypes[receiverType] {
+			errs = append(errs, fmt.Errorf("unknown receiver type for %q", receiverType))
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// validateQueuedSpanProcessor checks the configuration of a queued span processor,
+// see buildQueuedSpanProcessor.
+func validateQueuedSpanProcessor(cfg *builder.QueuedSpanProcessorCfg) error {
+	switch cfg.SenderType {
+	case builder.ThriftTChannelSenderType, builder.ThriftHTTPSenderType, builder.ProtoGRPCSenderType:
+		return config.ValidateExportersConfig(cfg.RawConfig)
+	case builder.InvalidSenderType:
+		if !hasTraceExporters(cfg.RawConfig) {
+			return fmt.Errorf("no senders or exporters configured for %q", cfg.Name)
+		}
+		return config.ValidateExportersConfig(cfg.RawConfig)
+	default:
+		return fmt.Errorf("unrecognized sender type %q for %q", cfg.SenderType, cfg.Name)
+	}
+}
+
+// hasTraceExporters checks if the "exporters" section of v has any exporter of a type
+// that can export traces.
+func hasTraceExporters(v *viper.Viper) bool {
+	if v == nil {
+		return false
+	}
+	for name := range v.GetStringMap("exporters") {
+		if exporter.GetTraceExporterFactory(builder.ComponentType(name)) != nil {
+			return true
+		}
+	}
+	return false
+}
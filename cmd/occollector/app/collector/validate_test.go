@@ -0,0 +1,139 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+func Test_validateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "sampling_config",
+			file: "../builder/testdata/sampling_config.yaml",
+		},
+		{
+			name: "pipelines",
+			file: "../builder/testdata/pipelines.yaml",
+		},
+		{
+			name: "sampling_policy_unknown_exporter",
+			yaml: `
+queued-exporters:
+  jaeger1:
+    sender-type: jaeger-thrift-http
+    jaeger-thrift-http:
+      collector_endpoint: "http://localhost:14268/api/traces"
+sampling:
+  mode: tail
+  policies:
+    always:
+      policy: always-sample
+      exporters: [jaeger2]
+`,
+			wantErr: true,
+		},
+		{
+			name: "sampling_policy_missing_configuration",
+			yaml: `
+logging-exporter: true
+sampling:
+  mode: tail
+  policies:
+    errors:
+      policy: numeric-attribute-filter
+      exporters: [debug]
+`,
+			wantErr: true,
+		},
+		{
+			name: "memory_limiter_without_soft_limit",
+			yaml: `
+logging-exporter: true
+memory-limiter:
+  hard-limit-mib: 2000
+`,
+			wantErr: true,
+		},
+		{
+			name: "unrecognized_sender_type",
+			yaml: `
+queued-exporters:
+  jaeger1:
+    sender-type: jaeger-thrift-udp
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_receiver",
+			yaml: `
+receivers:
+  unknown:
+    port: 1234
+`,
+			wantErr: true,
+		},
+		{
+			name: "missing_tls_files",
+			yaml: `
+receivers:
+  opencensus:
+    tls_credentials:
+      cert_file: "testdata/missing.crt"
+      key_file: "testdata/missing.key"
+`,
+			wantErr: true,
+		},
+		{
+			name: "pipeline_unknown_exporter",
+			yaml: `
+receivers:
+  opencensus:
+pipelines:
+  traces:
+    receivers: [opencensus]
+    exporters: [unknown]
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			if tt.file != "" {
+				v.SetConfigFile(tt.file)
+				if err := v.ReadInConfig(); err != nil {
+					t.Fatalf("Failed to read %q: %v", tt.file, err)
+				}
+			} else if err := viperutils.LoadYAMLBytes(v, []byte(tt.yaml)); err != nil {
+				t.Fatalf("Unexpected YAML parse error: %v", err)
+			}
+
+			err := validateConfig(v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -19,10 +19,13 @@ import (
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	jaegerproto "github.com/jaegertracing/jaeger/proto-gen/api_v2"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	jaegertranslator "github.com/census-instrumentation/opencensus-service/translator/trace/jaeger"
 )
@@ -55,12 +58,15 @@ func (s *JaegerProtoGRPCSender) ConsumeTraceData(ctx context.Context, td data.Tr
 	protoBatch, err := jaegertranslator.OCProtoToJaegerProto(td)
 	if err != nil {
 		s.logger.Warn("Error translating OC proto batch to Jaeger proto", zap.Error(err))
-		return err
+		return consumererror.Permanent(err)
 	}
 
 	_, err = s.client.PostSpans(context.Background(), &jaegerproto.PostSpansRequest{Batch: *protoBatch})
 	if err != nil {
 		s.logger.Warn("Error sending grpc batch", zap.Error(err))
+		if status.Code(err) == codes.InvalidArgument {
+			return consumererror.Permanent(err)
+		}
 		return err
 	}
 
@@ -27,6 +27,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	jaegertranslator "github.com/census-instrumentation/opencensus-service/translator/trace/jaeger"
 )
@@ -88,12 +89,12 @@ func (s *JaegerThriftHTTPSender) ConsumeTraceData(ctx context.Context, td data.T
 	// TODO: (@pjanotti) In case of failure the translation to Jaeger Thrift is going to be remade, cache it somehow.
 	tBatch, err := jaegertranslator.OCProtoToJaegerThrift(td)
 	if err != nil {
-		return err
+		return consumererror.Permanent(err)
 	}
 
 	body, err := serializeThrift(tBatch)
 	if err != nil {
-		return err
+		return consumererror.Permanent(err)
 	}
 	req, err := http.NewRequest("POST", s.url, body)
 	if err != nil {
@@ -110,11 +111,24 @@ func (s *JaegerThriftHTTPSender) ConsumeTraceData(ctx context.Context, td data.T
 	io.Copy(ioutil.Discard, resp.Body)
 	resp.Body.Close()
 	if resp.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("Jaeger Thirft HTTP sender error: %d", resp.StatusCode)
+		err := fmt.Errorf("Jaeger Thirft HTTP sender error: %d", resp.StatusCode)
+		if isPermanentHTTPStatus(resp.StatusCode) {
+			return consumererror.Permanent(err)
+		}
+		return err
 	}
 	return nil
 }
 
+// isPermanentHTTPStatus reports whether the request failed with status code because it
+// was rejected, so it fails again if retried. Timeouts and throttling are retryable.
+func isPermanentHTTPStatus(code int) bool {
+	if code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return false
+	}
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError
+}
+
 func serializeThrift(obj thrift.TStruct) (*bytes.Buffer, error) {
 	t := thrift.NewTMemoryBuffer()
 	p := thrift.NewTBinaryProtocolTransport(t)
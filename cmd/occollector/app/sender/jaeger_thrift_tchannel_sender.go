@@ -22,6 +22,7 @@ import (
 	reporter "github.com/jaegertracing/jaeger/cmd/agent/app/reporter"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	jaegertranslator "github.com/census-instrumentation/opencensus-service/translator/trace/jaeger"
 )
@@ -51,7 +52,7 @@ func (s *JaegerThriftTChannelSender) ConsumeTraceData(ctx context.Context, td da
 	// TODO: (@pjanotti) In case of failure the translation to Jaeger Thrift is going to be remade, cache it somehow.
 	tBatch, err := jaegertranslator.OCProtoToJaegerThrift(td)
 	if err != nil {
-		return err
+		return consumererror.Permanent(err)
 	}
 
 	if err := s.reporter.EmitBatch(tBatch); err != nil {
@@ -35,3 +35,13 @@ type MetricsConsumer interface {
 type TraceConsumer interface {
 	ConsumeTraceData(ctx context.Context, td data.TraceData) error
 }
+
+// Shutdowner is implemented by the consumers that hold data in memory, e.g.: queues,
+// batches or traces waiting for a sampling decision, and by the exporters that must
+// flush data before exiting.
+//
+// Shutdown stops the consumer after sending the data it holds to its destination. It
+// gives up when ctx is done, returning an error that reports the data that was lost.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
@@ -0,0 +1,153 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consumererror provides the errors that consumers return to tell the
+// components sending them data how to handle a failure.
+package consumererror
+
+import (
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// permanentError is an error that will fail again if the same data is retried.
+type permanentError struct {
+	err error
+}
+
+// Permanent wraps err to indicate that sending the same data again will fail with the
+// same error, e.g.: the backend rejected it as malformed. Queues drop such data instead
+// of retrying it.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func (p permanentError) Error() string {
+	return "Permanent error: " + p.err.Error()
+}
+
+// IsPermanent reports whether err was returned by Permanent. Errors that are not
+// permanent are retryable.
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := unwrapPartial(err).(permanentError)
+	return ok
+}
+
+// resourceExhaustedError is an error that will go away once the consumer has freed
+// resources.
+type resourceExhaustedError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+// ResourceExhausted wraps err to indicate that the data was refused because the consumer
+// is overloaded, e.g.: its queue is full or the memory usage is too high. The data was
+// not accepted and should be sent again after retryAfter, or after a delay chosen by the
+// sender if retryAfter is zero. Receivers report these errors to their clients, so that
+// they can retry instead of losing the data.
+func ResourceExhausted(err error, retryAfter time.Duration) error {
+	return resourceExhaustedError{err: err, retryAfter: retryAfter}
+}
+
+func (r resourceExhaustedError) Error() string {
+	return "Resource exhausted: " + r.err.Error()
+}
+
+// IsResourceExhausted reports whether err was returned by ResourceExhausted.
+func IsResourceExhausted(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := unwrapPartial(err).(resourceExhaustedError)
+	return ok
+}
+
+// RetryAfter returns the delay after which the data refused with err can be sent again.
+// It returns zero if err was not returned by ResourceExhausted or has no delay.
+func RetryAfter(err error) time.Duration {
+	if r, ok := unwrapPartial(err).(resourceExhaustedError); ok {
+		return r.retryAfter
+	}
+	return 0
+}
+
+// partialError is an error for which the consumer accepted all the data but the failed
+// spans.
+type partialError struct {
+	err    error
+	failed data.TraceData
+}
+
+// PartialTraces wraps err to indicate that only the spans of failed were not accepted,
+// e.g.: the consumer sent the data to several destinations and some of them failed.
+// Queues retry only the failed spans, so that the accepted ones are not sent twice. The
+// kind of err, e.g.: Permanent, is kept.
+func PartialTraces(err error, failed data.TraceData) error {
+	return partialError{err: err, failed: failed}
+}
+
+func (p partialError) Error() string {
+	return p.err.Error()
+}
+
+// FailedTraceData returns the spans that were not accepted if err was returned by
+// PartialTraces.
+func FailedTraceData(err error) (data.TraceData, bool) {
+	p, ok := err.(partialError)
+	return p.failed, ok
+}
+
+func unwrapPartial(err error) error {
+	if p, ok := err.(partialError); ok {
+		return p.err
+	}
+	return err
+}
+
+// CombineErrors converts the errors returned by several consumers into one error. The
+// result is a ResourceExhausted error, with the longest delay, if all the consumers
+// refused the data so that the sender retries it, and a Permanent error if all the
+// consumers failed permanently.
+func CombineErrors(errs []error) error {
+	if len(errs) <= 1 {
+		return internal.CombineErrors(errs)
+	}
+	numPermanent := 0
+	numExhausted := 0
+	var retryAfter time.Duration
+	for _, err := range errs {
+		if IsPermanent(err) {
+			numPermanent++
+		}
+		if IsResourceExhausted(err) {
+			numExhausted++
+			if d := RetryAfter(err); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+	err := internal.CombineErrors(errs)
+	switch {
+	case numExhausted == len(errs):
+		return ResourceExhausted(err, retryAfter)
+	case numPermanent == len(errs):
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumererror
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestPermanent(t *testing.T) {
+	err := errors.New("rejected")
+	if IsPermanent(err) {
+		t.Errorf("IsPermanent(%v) = true, want false", err)
+	}
+	if IsPermanent(nil) {
+		t.Error("IsPermanent(nil) = true, want false")
+	}
+
+	perr := Permanent(err)
+	if !IsPermanent(perr) {
+		t.Errorf("IsPermanent(%v) = false, want true", perr)
+	}
+	if perr.Error() != "Permanent error: rejected" {
+		t.Errorf("Error() = %q", perr.Error())
+	}
+}
+
+func TestResourceExhausted(t *testing.T) {
+	err := errors.New("queue is full")
+	if IsResourceExhausted(err) {
+		t.Errorf("IsResourceExhausted(%v) = true, want false", err)
+	}
+	if IsResourceExhausted(nil) {
+		t.Error("IsResourceExhausted(nil) = true, want false")
+	}
+	if d := RetryAfter(err); d != 0 {
+		t.Errorf("RetryAfter(%v) = %v, want 0", err, d)
+	}
+
+	rerr := ResourceExhausted(err, 5*time.Second)
+	if !IsResourceExhausted(rerr) {
+		t.Errorf("IsResourceExhausted(%v) = false, want true", rerr)
+	}
+	if IsPermanent(rerr) {
+		t.Errorf("IsPermanent(%v) = true, want false", rerr)
+	}
+	if d := RetryAfter(rerr); d != 5*time.Second {
+		t.Errorf("RetryAfter(%v) = %v, want 5s", rerr, d)
+	}
+	if rerr.Error() != "Resource exhausted: queue is full" {
+		t.Errorf("Error() = %q", rerr.Error())
+	}
+}
+
+func TestPartialTraces(t *testing.T) {
+	err := errors.New("unavailable")
+	if _, ok := FailedTraceData(err); ok {
+		t.Errorf("FailedTraceData(%v) ok, want not ok", err)
+	}
+
+	failed := data.TraceData{Spans: []*tracepb.Span{{TraceId: []byte{1}}}}
+	perr := PartialTraces(ResourceExhausted(err, time.Second), failed)
+	got, ok := FailedTraceData(perr)
+	if !ok || !reflect.DeepEqual(got, failed) {
+		t.Errorf("FailedTraceData(%v) = (%v, %t), want (%v, true)", perr, got, ok, failed)
+	}
+	if !IsResourceExhausted(perr) || RetryAfter(perr) != time.Second {
+		t.Errorf("PartialTraces() lost the ResourceExhausted error: %v", perr)
+	}
+	if !IsPermanent(PartialTraces(Permanent(err), failed)) {
+		t.Error("PartialTraces() lost the Permanent error")
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	failed := errors.New("failed")
+	rejected := Permanent(errors.New("rejected"))
+	tests := []struct {
+		name          string
+		errs          []error
+		wantNil       bool
+		wantExhausted bool
+		wantPermanent bool
+		wantDelay     time.Duration
+	}{
+		{name: "none", wantNil: true},
+		{name: "single", errs: []error{rejected}, wantPermanent: true},
+		{name: "mixed", errs: []error{failed, rejected}},
+		{name: "all_permanent", errs: []error{rejected, rejected}, wantPermanent: true},
+		{
+			name: "one_exhausted",
+			errs: []error{
+				failed,
+				ResourceExhausted(failed, time.Second),
+			},
+		},
+		{
+			name: "all_exhausted",
+			errs: []error{
+				ResourceExhausted(failed, time.Second),
+				ResourceExhausted(failed, 3*time.Second),
+			},
+			wantExhausted: true,
+			wantDelay:     3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CombineErrors(tt.errs)
+			if (err == nil) != tt.wantNil {
+				t.Fatalf("CombineErrors() = %v, want nil: %v", err, tt.wantNil)
+			}
+			if IsResourceExhausted(err) != tt.wantExhausted {
+				t.Errorf("IsResourceExhausted(%v) = %v, want %v", err, !tt.wantExhausted, tt.wantExhausted)
+			}
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("IsPermanent(%v) = %v, want %v", err, !tt.wantPermanent, tt.wantPermanent)
+			}
+			if d := RetryAfter(err); d != tt.wantDelay {
+				t.Errorf("RetryAfter(%v) = %v, want %v", err, d, tt.wantDelay)
+			}
+		})
+	}
+}
@@ -61,13 +61,10 @@ type awsXRayExporter struct {
 // AWSXRayTraceExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
 // AWS X-Ray according to the configuration settings.
 func AWSXRayTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		AWSXRay *awsXRayConfig `mapstructure:"aws-xray"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	xc, err := awsXRayConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-	xc := cfg.AWSXRay
 	if xc == nil {
 		return nil, nil, nil, nil
 	}
@@ -101,6 +98,18 @@ func AWSXRayTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 	return
 }
 
+// awsXRayConfigFromViper returns the configuration of the AWS X-Ray exporter, or nil if there
+// is none.
+func awsXRayConfigFromViper(v *viper.Viper) (*awsXRayConfig, error) {
+	var cfg struct {
+		AWSXRay *awsXRayConfig `mapstructure:"aws-xray"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.AWSXRay, nil
+}
+
 // Flush invokes .Flush() for every one of its underlying exporters.
 func (axe *awsXRayExporter) Flush() {
 	axe.mu.RLock()
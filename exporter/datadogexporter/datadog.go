@@ -44,14 +44,10 @@ type datadogConfig struct {
 // DatadogTraceExportersFromViper unmarshals the viper and returns an exporter.TraceExporter targeting
 // Datadog according to the configuration settings.
 func DatadogTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Datadog *datadogConfig `mapstructure:"datadog,omitempty"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	dc, err := datadogConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	dc := cfg.Datadog
 	if dc == nil {
 		return nil, nil, nil, nil
 	}
@@ -86,3 +82,15 @@ func DatadogTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 
 	return
 }
+
+// datadogConfigFromViper returns the configuration of the Datadog exporter, or nil if there
+// is none.
+func datadogConfigFromViper(v *viper.Viper) (*datadogConfig, error) {
+	var cfg struct {
+		Datadog *datadogConfig `mapstructure:"datadog,omitempty"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Datadog, nil
+}
@@ -0,0 +1,384 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+// ExportersFromViper is the function used by the exporters to create their instances
+// from the "exporters" section of the configuration. The function expects the
+// configuration of the exporter under the key of its type, e.g.: "zipkin".
+type ExportersFromViper func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error)
+
+// ErrExporterNotEnabled is returned by the factories of this package when the given
+// configuration does not enable the exporter for the requested signal, e.g.: a
+// Stackdriver exporter with "enable_tracing: false" and a trace exporter factory.
+var ErrExporterNotEnabled = errors.New("exporter not enabled by its configuration")
+
+// Stopper is implemented by the exporters created by the factories of this package,
+// it releases any resources held by the exporter, flushing pending data if possible.
+// The exporters also implement consumer.Shutdowner to bound the time spent flushing.
+type Stopper interface {
+	Stop() error
+}
+
+// StopWithin calls stop and waits for it to return until ctx is done, it is used to
+// bound the time taken by exporters that flush their data when stopped.
+func StopWithin(ctx context.Context, stop func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- stop()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("exporter did not stop before the deadline: %v", ctx.Err())
+	}
+}
+
+// FactoryOption applies changes to the factories created by NewTraceExporterFactory
+// and NewMetricsExporterFactory.
+type FactoryOption func(*factoryOptions)
+
+type factoryOptions struct {
+	defaults   map[string]interface{}
+	validate   func(v *viper.Viper) error
+	sendErrors bool
+}
+
+// WithDefaultConfig sets the settings returned by DefaultConfig. They document the
+// values used by the exporter for the settings not in its configuration, the exporter
+// is still responsible for applying them.
+func WithDefaultConfig(defaults map[string]interface{}) FactoryOption {
+	return func(o *factoryOptions) {
+		o.defaults = defaults
+	}
+}
+
+// WithConfigValidator sets the function used by ValidateConfig to check a configuration
+// without creating the exporter. Like ExportersFromViper, the function expects the
+// configuration of the exporter under the key of its type.
+func WithConfigValidator(validate func(v *viper.Viper) error) FactoryOption {
+	return func(o *factoryOptions) {
+		o.validate = validate
+	}
+}
+
+// WithSendErrors declares that the trace exporters return the errors of sending the data
+// to the backend, see exporter.SendErrorReporter.
+func WithSendErrors() FactoryOption {
+	return func(o *factoryOptions) {
+		o.sendErrors = true
+	}
+}
+
+func newFactoryOptions(options []FactoryOption) factoryOptions {
+	var opts factoryOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+func (o *factoryOptions) defaultConfig() *viper.Viper {
+	v := viper.New()
+	for key, value := range o.defaults {
+		v.Set(key, value)
+	}
+	return v
+}
+
+func (o *factoryOptions) validateConfig(exporterType string, cfg *viper.Viper) error {
+	if o.validate == nil {
+		return nil
+	}
+	v := viper.New()
+	if cfg != nil {
+		v.Set(exporterType, cfg.AllSettings())
+	}
+	return o.validate(v)
+}
+
+type traceExporterFactory struct {
+	exporterType string
+	fromViper    ExportersFromViper
+	options      factoryOptions
+}
+
+var _ (exporter.TraceExporterFactory) = (*traceExporterFactory)(nil)
+var _ (exporter.ConfigValidator) = (*traceExporterFactory)(nil)
+var _ (exporter.SendErrorReporter) = (*traceExporterFactory)(nil)
+
+// NewTraceExporterFactory creates a factory for the given exporter "type" that uses
+// fromViper to create the exporter. The configuration passed to NewFromViper is the
+// configuration of a single exporter, i.e.: without the exporter type as the root key.
+func NewTraceExporterFactory(exporterType string, fromViper ExportersFromViper, options ...FactoryOption) exporter.TraceExporterFactory {
+	return &traceExporterFactory{
+		exporterType: exporterType,
+		fromViper:    fromViper,
+		options:      newFactoryOptions(options),
+	}
+}
+
+// Type gets the type of the exporter created by this factory.
+func (f *traceExporterFactory) Type() string {
+	return f.exporterType
+}
+
+// DefaultConfig returns the settings given via WithDefaultConfig, or an empty
+// configuration. The defaults are applied by the exporter itself.
+func (f *traceExporterFactory) DefaultConfig() *viper.Viper {
+	return f.options.defaultConfig()
+}
+
+// ValidateConfig checks cfg with the function given via WithConfigValidator, if any.
+func (f *traceExporterFactory) ValidateConfig(cfg *viper.Viper) error {
+	return f.options.validateConfig(f.exporterType, cfg)
+}
+
+// ReportsSendErrors reports whether the factory was created with WithSendErrors.
+func (f *traceExporterFactory) ReportsSendErrors() bool {
+	return f.options.sendErrors
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceExporter. The
+// returned exporter also implements Stopper.
+func (f *traceExporterFactory) NewFromViper(cfg *viper.Viper) (exporter.TraceExporter, error) {
+	tps, _, doneFns, err := exportersFromConfig(f.exporterType, f.fromViper, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(tps) == 0 || tps[0] == nil {
+		stopAll(doneFns)
+		return nil, ErrExporterNotEnabled
+	}
+
+	return &stoppableTraceExporter{
+		TraceConsumer: tps[0],
+		exportFormat:  f.exporterType,
+		doneFns:       doneFns,
+	}, nil
+}
+
+type metricsExporterFactory struct {
+	exporterType string
+	fromViper    ExportersFromViper
+	options      factoryOptions
+}
+
+var _ (exporter.MetricsExporterFactory) = (*metricsExporterFactory)(nil)
+var _ (exporter.ConfigValidator) = (*metricsExporterFactory)(nil)
+
+// NewMetricsExporterFactory creates a factory for the given exporter "type" that uses
+// fromViper to create the exporter. The configuration passed to NewFromViper is the
+// configuration of a single exporter, i.e.: without the exporter type as the root key.
+func NewMetricsExporterFactory(exporterType string, fromViper ExportersFromViper, options ...FactoryOption) exporter.MetricsExporterFactory {
+	return &metricsExporterFactory{
+		exporterType: exporterType,
+		fromViper:    fromViper,
+		options:      newFactoryOptions(options),
+	}
+}
+
+// Type gets the type of the exporter created by this factory.
+func (f *metricsExporterFactory) Type() string {
+	return f.exporterType
+}
+
+// DefaultConfig returns the settings given via WithDefaultConfig, or an empty
+// configuration. The defaults are applied by the exporter itself.
+func (f *metricsExporterFactory) DefaultConfig() *viper.Viper {
+	return f.options.defaultConfig()
+}
+
+// ValidateConfig checks cfg with the function given via WithConfigValidator, if any.
+func (f *metricsExporterFactory) ValidateConfig(cfg *viper.Viper) error {
+	return f.options.validateConfig(f.exporterType, cfg)
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsExporter. The
+// returned exporter also implements Stopper.
+func (f *metricsExporterFactory) NewFromViper(cfg *viper.Viper) (exporter.MetricsExporter, error) {
+	_, mps, doneFns, err := exportersFromConfig(f.exporterType, f.fromViper, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(mps) == 0 || mps[0] == nil {
+		stopAll(doneFns)
+		return nil, ErrExporterNotEnabled
+	}
+
+	return &stoppableMetricsExporter{
+		MetricsConsumer: mps[0],
+		exportFormat:    f.exporterType,
+		doneFns:         doneFns,
+	}, nil
+}
+
+// exportersFromConfig calls fromViper placing cfg under the exporter type, as
+// expected by the ExportersFromViper functions.
+func exportersFromConfig(
+	exporterType string,
+	fromViper ExportersFromViper,
+	cfg *viper.Viper,
+) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+	v := viper.New()
+	if cfg != nil {
+		v.Set(exporterType, cfg.AllSettings())
+	}
+	return fromViper(v)
+}
+
+func stopAll(doneFns []func() error) error {
+	var errs []error
+	for _, doneFn := range doneFns {
+		if err := doneFn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// stoppableTraceExporter wraps the consumers created by ExportersFromViper functions
+// as a TraceExporter that can be stopped.
+type stoppableTraceExporter struct {
+	consumer.TraceConsumer
+	exportFormat string
+	doneFns      []func() error
+}
+
+var _ (exporter.TraceExporter) = (*stoppableTraceExporter)(nil)
+var _ Stopper = (*stoppableTraceExporter)(nil)
+var _ consumer.Shutdowner = (*stoppableTraceExporter)(nil)
+
+func (ste *stoppableTraceExporter) TraceExportFormat() string {
+	if te, ok := ste.TraceConsumer.(exporter.TraceExporter); ok {
+		return te.TraceExportFormat()
+	}
+	return ste.exportFormat
+}
+
+func (ste *stoppableTraceExporter) Stop() error {
+	return stopAll(ste.doneFns)
+}
+
+func (ste *stoppableTraceExporter) Shutdown(ctx context.Context) error {
+	return StopWithin(ctx, ste.Stop)
+}
+
+// stoppableMetricsExporter wraps the consumers created by ExportersFromViper functions
+// as a MetricsExporter that can be stopped.
+type stoppableMetricsExporter struct {
+	consumer.MetricsConsumer
+	exportFormat string
+	doneFns      []func() error
+}
+
+var _ (exporter.MetricsExporter) = (*stoppableMetricsExporter)(nil)
+var _ Stopper = (*stoppableMetricsExporter)(nil)
+var _ consumer.Shutdowner = (*stoppableMetricsExporter)(nil)
+
+func (sme *stoppableMetricsExporter) MetricsExportFormat() string {
+	if me, ok := sme.MetricsConsumer.(exporter.MetricsExporter); ok {
+		return me.MetricsExportFormat()
+	}
+	return sme.exportFormat
+}
+
+func (sme *stoppableMetricsExporter) Stop() error {
+	return stopAll(sme.doneFns)
+}
+
+func (sme *stoppableMetricsExporter) Shutdown(ctx context.Context) error {
+	return StopWithin(ctx, sme.Stop)
+}
+
+// NewNamedTraceExporter wraps te so the observability metrics of the data it exports are
+// tagged with name (e.g. "zipkin/primary") instead of the exporter type. It is used to tell
+// apart multiple instances of the same type of exporter. The returned exporter implements
+// Stopper and consumer.Shutdowner, stopping te if it implements them too.
+func NewNamedTraceExporter(name string, te exporter.TraceExporter) exporter.TraceExporter {
+	return &namedTraceExporter{TraceExporter: te, name: name}
+}
+
+type namedTraceExporter struct {
+	exporter.TraceExporter
+	name string
+}
+
+var _ Stopper = (*namedTraceExporter)(nil)
+var _ consumer.Shutdowner = (*namedTraceExporter)(nil)
+
+func (nte *namedTraceExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return nte.TraceExporter.ConsumeTraceData(observability.ContextWithExporterName(ctx, nte.name), td)
+}
+
+func (nte *namedTraceExporter) Stop() error {
+	if stopper, ok := nte.TraceExporter.(Stopper); ok {
+		return stopper.Stop()
+	}
+	return nil
+}
+
+func (nte *namedTraceExporter) Shutdown(ctx context.Context) error {
+	if shutdowner, ok := nte.TraceExporter.(consumer.Shutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return StopWithin(ctx, nte.Stop)
+}
+
+// NewNamedMetricsExporter wraps me so the observability metrics of the data it exports are
+// tagged with name instead of the exporter type, see NewNamedTraceExporter.
+func NewNamedMetricsExporter(name string, me exporter.MetricsExporter) exporter.MetricsExporter {
+	return &namedMetricsExporter{MetricsExporter: me, name: name}
+}
+
+type namedMetricsExporter struct {
+	exporter.MetricsExporter
+	name string
+}
+
+var _ Stopper = (*namedMetricsExporter)(nil)
+var _ consumer.Shutdowner = (*namedMetricsExporter)(nil)
+
+func (nme *namedMetricsExporter) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	return nme.MetricsExporter.ConsumeMetricsData(observability.ContextWithExporterName(ctx, nme.name), md)
+}
+
+func (nme *namedMetricsExporter) Stop() error {
+	if stopper, ok := nme.MetricsExporter.(Stopper); ok {
+		return stopper.Stop()
+	}
+	return nil
+}
+
+func (nme *namedMetricsExporter) Shutdown(ctx context.Context) error {
+	if shutdowner, ok := nme.MetricsExporter.(consumer.Shutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return StopWithin(ctx, nme.Stop)
+}
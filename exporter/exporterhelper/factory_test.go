@@ -0,0 +1,227 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/observability/observabilitytest"
+)
+
+func TestTraceExporterFactory(t *testing.T) {
+	var gotEndpoint string
+	stopped := false
+	fromViper := func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+		var cfg struct {
+			Fake *struct {
+				Endpoint string `mapstructure:"endpoint"`
+			} `mapstructure:"fake"`
+		}
+		if err := v.Unmarshal(&cfg); err != nil || cfg.Fake == nil {
+			return nil, nil, nil, err
+		}
+		gotEndpoint = cfg.Fake.Endpoint
+		te, err := NewTraceExporter(fakeExporterName, newPushTraceData(0, nil))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stop := func() error {
+			stopped = true
+			return nil
+		}
+		return []consumer.TraceConsumer{te}, nil, []func() error{stop}, nil
+	}
+
+	f := NewTraceExporterFactory("fake", fromViper)
+	if f.Type() != "fake" {
+		t.Fatalf("Type() = %q, want %q", f.Type(), "fake")
+	}
+
+	if _, err := f.NewFromViper(f.DefaultConfig()); err != ErrExporterNotEnabled {
+		t.Fatalf("NewFromViper() with empty configuration: want %v got %v", ErrExporterNotEnabled, err)
+	}
+
+	cfg := viper.New()
+	cfg.Set("endpoint", "localhost:1234")
+	te, err := f.NewFromViper(cfg)
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	if gotEndpoint != "localhost:1234" {
+		t.Errorf("endpoint = %q, want %q", gotEndpoint, "localhost:1234")
+	}
+	if g, w := te.TraceExportFormat(), fakeExporterName; g != w {
+		t.Errorf("TraceExportFormat() = %q, want %q", g, w)
+	}
+	if err := te.ConsumeTraceData(context.Background(), data.TraceData{}); err != nil {
+		t.Errorf("ConsumeTraceData() = %v", err)
+	}
+
+	stopper, ok := te.(Stopper)
+	if !ok {
+		t.Fatalf("exporter created by the factory should implement Stopper")
+	}
+	if err := stopper.Stop(); err != nil || !stopped {
+		t.Errorf("Stop() = %v, stopped = %v", err, stopped)
+	}
+}
+
+func TestMetricsExporterFactory(t *testing.T) {
+	fromViper := func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+		if v.Sub("fake") == nil {
+			return nil, nil, nil, nil
+		}
+		me, err := NewMetricsExporter(fakeExporterName, newPushMetricsData(0, nil))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, []consumer.MetricsConsumer{me}, nil, nil
+	}
+
+	f := NewMetricsExporterFactory("fake", fromViper)
+	if f.Type() != "fake" {
+		t.Fatalf("Type() = %q, want %q", f.Type(), "fake")
+	}
+
+	cfg := viper.New()
+	cfg.Set("endpoint", "localhost:1234")
+	me, err := f.NewFromViper(cfg)
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	if g, w := me.MetricsExportFormat(), fakeExporterName; g != w {
+		t.Errorf("MetricsExportFormat() = %q, want %q", g, w)
+	}
+	if err := me.ConsumeMetricsData(context.Background(), data.MetricsData{}); err != nil {
+		t.Errorf("ConsumeMetricsData() = %v", err)
+	}
+	if err := me.(Stopper).Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+
+	// A trace exporter factory using the same function is not enabled.
+	if _, err := NewTraceExporterFactory("fake", fromViper).NewFromViper(cfg); err != ErrExporterNotEnabled {
+		t.Errorf("NewFromViper() for traces: want %v got %v", ErrExporterNotEnabled, err)
+	}
+}
+
+func TestExporterFactoryOptions(t *testing.T) {
+	fromViper := func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+		return nil, nil, nil, nil
+	}
+	errMissingEndpoint := errors.New("missing endpoint")
+	validate := func(v *viper.Viper) error {
+		if v.GetString("fake.endpoint") == "" {
+			return errMissingEndpoint
+		}
+		return nil
+	}
+
+	f := NewTraceExporterFactory(
+		"fake",
+		fromViper,
+		WithDefaultConfig(map[string]interface{}{"num_workers": 2}),
+		WithConfigValidator(validate),
+		WithSendErrors())
+	if got := f.DefaultConfig().GetInt("num_workers"); got != 2 {
+		t.Errorf("DefaultConfig() num_workers = %d, want 2", got)
+	}
+
+	validator, ok := f.(exporter.ConfigValidator)
+	if !ok {
+		t.Fatalf("factory should implement exporter.ConfigValidator")
+	}
+	if err := validator.ValidateConfig(viper.New()); err != errMissingEndpoint {
+		t.Errorf("ValidateConfig() with empty configuration: want %v got %v", errMissingEndpoint, err)
+	}
+	cfg := viper.New()
+	cfg.Set("endpoint", "localhost:1234")
+	if err := validator.ValidateConfig(cfg); err != nil {
+		t.Errorf("ValidateConfig() = %v", err)
+	}
+
+	if !f.(exporter.SendErrorReporter).ReportsSendErrors() {
+		t.Errorf("ReportsSendErrors() = false, want true")
+	}
+	if NewTraceExporterFactory("fake", fromViper).(exporter.SendErrorReporter).ReportsSendErrors() {
+		t.Errorf("ReportsSendErrors() without WithSendErrors = true, want false")
+	}
+
+	// Without a validator any configuration is accepted.
+	mf := NewMetricsExporterFactory("fake", fromViper)
+	if err := mf.(exporter.ConfigValidator).ValidateConfig(viper.New()); err != nil {
+		t.Errorf("ValidateConfig() without validator = %v", err)
+	}
+	if len(mf.DefaultConfig().AllSettings()) != 0 {
+		t.Errorf("DefaultConfig() without defaults = %v, want empty", mf.DefaultConfig().AllSettings())
+	}
+}
+
+func TestNamedTraceExporter(t *testing.T) {
+	doneFn := observabilitytest.SetupRecordedMetricsTest()
+	defer doneFn()
+
+	te, err := NewTraceExporter(fakeExporterName, newPushTraceData(0, nil), WithRecordMetrics(true))
+	if err != nil {
+		t.Fatalf("NewTraceExporter() = %v", err)
+	}
+	const instanceName = fakeExporterName + "/primary"
+	nte := NewNamedTraceExporter(instanceName, te)
+	if g, w := nte.TraceExportFormat(), fakeExporterName; g != w {
+		t.Errorf("TraceExportFormat() = %q, want %q", g, w)
+	}
+
+	td := data.TraceData{Spans: make([]*tracepb.Span, 3)}
+	ctx := observability.ContextWithReceiverName(context.Background(), fakeReceiverName)
+	if err := nte.ConsumeTraceData(ctx, td); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+	if err := observabilitytest.CheckValueViewExporterReceivedSpans(fakeReceiverName, instanceName, len(td.Spans)); err != nil {
+		t.Fatalf("CheckValueViewExporterReceivedSpans: Want nil Got %v", err)
+	}
+
+	if err := nte.(Stopper).Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+}
+
+func TestStopWithin(t *testing.T) {
+	stopErr := errors.New("stop error")
+	if err := StopWithin(context.Background(), func() error { return stopErr }); err != stopErr {
+		t.Errorf("StopWithin() = %v, want %v", err, stopErr)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := StopWithin(ctx, func() error {
+		<-release
+		return nil
+	})
+	if err == nil {
+		t.Errorf("StopWithin() = nil, want an error for a stop that did not finish")
+	}
+}
@@ -40,7 +40,7 @@ func (me *metricsExporter) MetricsExportFormat() string {
 }
 
 func (me *metricsExporter) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
-	exporterCtx := observability.ContextWithExporterName(ctx, me.exporterFormat)
+	exporterCtx := observability.ContextWithDefaultExporterName(ctx, me.exporterFormat)
 	_, err := me.pushMetricsData(exporterCtx, md)
 	return err
 }
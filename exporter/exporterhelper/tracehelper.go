@@ -36,7 +36,7 @@ type traceExporter struct {
 var _ (exporter.TraceExporter) = (*traceExporter)(nil)
 
 func (te *traceExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
-	exporterCtx := observability.ContextWithExporterName(ctx, te.exporterFormat)
+	exporterCtx := observability.ContextWithDefaultExporterName(ctx, te.exporterFormat)
 	_, err := te.pushTraceData(exporterCtx, td)
 	return err
 }
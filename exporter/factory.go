@@ -41,3 +41,21 @@ type MetricsExporterFactory interface {
 	// created by this factory.
 	DefaultConfig() *viper.Viper
 }
+
+// ConfigValidator is implemented by the exporter factories that can check a
+// configuration without creating an exporter, i.e.: without connecting to the
+// backend. It is used to validate a configuration file before running it.
+type ConfigValidator interface {
+	// ValidateConfig checks the given configuration, in the same format passed to
+	// NewFromViper, returning an error describing any problem found.
+	ValidateConfig(cfg *viper.Viper) error
+}
+
+// SendErrorReporter is implemented by the trace exporter factories whose exporters return
+// the errors of sending the data to the backend from ConsumeTraceData. Other exporters may
+// send the data asynchronously and return nil while the backend is down.
+type SendErrorReporter interface {
+	// ReportsSendErrors reports whether the exporters created by the factory return
+	// the errors of sending the data to the backend.
+	ReportsSendErrors() bool
+}
@@ -0,0 +1,71 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failoverexporter
+
+import "time"
+
+// circuitBreaker tracks the health of a destination. It opens after failureThreshold
+// consecutive failures, so that the data is sent to the next destination, and then lets
+// one probe through every probeInterval to find out whether the destination is back.
+type circuitBreaker struct {
+	failureThreshold int
+	probeInterval    time.Duration
+
+	failures int
+	open     bool
+	probing  bool
+	openedAt time.Time
+}
+
+// allow reports whether data can be sent to the destination. While the breaker is open
+// only one probe is allowed every probeInterval.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	if !cb.open {
+		return true
+	}
+	if cb.probing || now.Sub(cb.openedAt) < cb.probeInterval {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+// onSuccess closes the breaker. It returns true if the breaker was open, i.e.: a probe
+// succeeded.
+func (cb *circuitBreaker) onSuccess() bool {
+	wasOpen := cb.open
+	cb.failures = 0
+	cb.open = false
+	cb.probing = false
+	return wasOpen
+}
+
+// onFailure counts a failure. It returns whether the breaker is open, and whether it was
+// just tripped by this failure.
+func (cb *circuitBreaker) onFailure(now time.Time) (open, tripped bool) {
+	if cb.open {
+		// A failed probe waits for another interval.
+		cb.probing = false
+		cb.openedAt = now
+		return true, false
+	}
+	cb.failures++
+	if cb.failures < cb.failureThreshold {
+		return false, false
+	}
+	cb.open = true
+	cb.openedAt = now
+	return true, true
+}
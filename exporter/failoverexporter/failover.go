@@ -0,0 +1,269 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failoverexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type failoverConfig struct {
+	// Destinations are the names of the exporters, configured under "exporters", in order
+	// of preference.
+	Destinations     []string      `mapstructure:"destinations"`
+	FailureThreshold int           `mapstructure:"failure-threshold"`
+	ProbeInterval    time.Duration `mapstructure:"probe-interval"`
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultProbeInterval    = 30 * time.Second
+
+	// exporterNameSeparator separates the type of an exporter from the name of the
+	// instance, as in the "exporters" section of the configuration.
+	exporterNameSeparator = "/"
+)
+
+var (
+	// ErrDestinationsRequired indicates that this exporter was not provided with any destination.
+	ErrDestinationsRequired = errors.New("failover exporter config requires at least one destination")
+
+	errAllDestinationsDown = errors.New("all failover destinations are down")
+)
+
+// FailoverTraceExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// that sends the traces to the first healthy destination. The destinations are other
+// exporters, configured under the "exporters" key of the failover exporter.
+func FailoverTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	fc, exportersViper, err := failoverConfigFromViper(v)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if fc == nil {
+		return nil, nil, nil, nil
+	}
+
+	fo := &failover{now: time.Now}
+	for _, name := range fc.Destinations {
+		te, err := newDestinationExporter(exportersViper, name)
+		if err != nil {
+			fo.stop()
+			return nil, nil, nil, err
+		}
+		fo.destinations = append(fo.destinations, &destination{
+			name:     name,
+			exporter: te,
+			breaker: circuitBreaker{
+				failureThreshold: fc.FailureThreshold,
+				probeInterval:    fc.ProbeInterval,
+			},
+		})
+	}
+
+	foe, err := exporterhelper.NewTraceExporter(
+		"failover",
+		fo.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Failover.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true))
+	if err != nil {
+		fo.stop()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, foe)
+	doneFns = append(doneFns, fo.stop)
+	return
+}
+
+// failoverConfigFromViper returns the configuration of the failover exporter and the
+// configuration of its destinations, or nil if there is none.
+func failoverConfigFromViper(v *viper.Viper) (*failoverConfig, *viper.Viper, error) {
+	fv := v.Sub(TypeStr)
+	if fv == nil {
+		return nil, nil, nil
+	}
+	fc := &failoverConfig{
+		FailureThreshold: defaultFailureThreshold,
+		ProbeInterval:    defaultProbeInterval,
+	}
+	if err := fv.Unmarshal(fc); err != nil {
+		return nil, nil, err
+	}
+	if len(fc.Destinations) == 0 {
+		return nil, nil, ErrDestinationsRequired
+	}
+	if fc.FailureThreshold <= 0 {
+		return nil, nil, fmt.Errorf("failover failure-threshold must be positive, got %d", fc.FailureThreshold)
+	}
+	if fc.ProbeInterval <= 0 {
+		return nil, nil, fmt.Errorf("failover probe-interval must be positive, got %v", fc.ProbeInterval)
+	}
+
+	exportersViper := fv.Sub("exporters")
+	seen := make(map[string]bool)
+	for i, name := range fc.Destinations {
+		// The keys of the configuration are not case sensitive.
+		name = strings.ToLower(name)
+		fc.Destinations[i] = name
+		if seen[name] {
+			return nil, nil, fmt.Errorf("failover destination %q is listed more than once", name)
+		}
+		seen[name] = true
+		if exportersViper == nil || exportersViper.Sub(name) == nil {
+			return nil, nil, fmt.Errorf("failover destination %q is not configured under exporters", name)
+		}
+		factory := exporter.GetTraceExporterFactory(destinationType(name))
+		if factory == nil {
+			return nil, nil, fmt.Errorf("failover destination %q has an unknown trace exporter type", name)
+		}
+		// The breaker of a destination trips on the errors of its exporter, only the last
+		// destination can have an exporter that does not report them.
+		reporter, ok := factory.(exporter.SendErrorReporter)
+		if i < len(fc.Destinations)-1 && (!ok || !reporter.ReportsSendErrors()) {
+			return nil, nil, fmt.Errorf("failover destination %q must be the last one, its exporter does not report send errors", name)
+		}
+	}
+	return fc, exportersViper, nil
+}
+
+// validateFailoverConfig checks the configuration of the failover exporter, including
+// the configurations of its destinations, without creating them.
+func validateFailoverConfig(v *viper.Viper) error {
+	fc, exportersViper, err := failoverConfigFromViper(v)
+	if err != nil || fc == nil {
+		return err
+	}
+	for _, name := range fc.Destinations {
+		factory := exporter.GetTraceExporterFactory(destinationType(name))
+		if validator, ok := factory.(exporter.ConfigValidator); ok {
+			if err := validator.ValidateConfig(exportersViper.Sub(name)); err != nil {
+				return fmt.Errorf("failover destination %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func destinationType(name string) string {
+	return strings.SplitN(name, exporterNameSeparator, 2)[0]
+}
+
+// newDestinationExporter creates the exporter of a destination from its configuration.
+func newDestinationExporter(exportersViper *viper.Viper, name string) (exporter.TraceExporter, error) {
+	exporterType := destinationType(name)
+	te, err := exporter.GetTraceExporterFactory(exporterType).NewFromViper(exportersViper.Sub(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create failover destination %q: %v", name, err)
+	}
+	if name != exporterType {
+		te = exporterhelper.NewNamedTraceExporter(name, te)
+	}
+	return te, nil
+}
+
+// destination is one of the exporters of the failover exporter.
+type destination struct {
+	name     string
+	exporter consumer.TraceConsumer
+	breaker  circuitBreaker
+}
+
+// failover sends the data to the first destination whose circuit breaker is closed.
+type failover struct {
+	// mu protects the circuit breakers of the destinations.
+	mu           sync.Mutex
+	destinations []*destination
+	now          func() time.Time
+}
+
+// pushTraceData sends the data to the first destination that accepts it. A failure is
+// returned as is, so that the data is retried, until the destination trips its breaker:
+// the data is then sent to the next destination.
+func (fo *failover) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	for _, dest := range fo.destinations {
+		fo.mu.Lock()
+		ok := dest.breaker.allow(fo.now())
+		fo.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		err := dest.exporter.ConsumeTraceData(ctx, td)
+		if err == nil || consumererror.IsPermanent(err) {
+			// A permanent error is about the data, the destination is reachable.
+			fo.mu.Lock()
+			recovered := dest.breaker.onSuccess()
+			fo.mu.Unlock()
+			if recovered {
+				fo.record(dest, statRecoveries)
+			}
+			if err != nil {
+				return len(td.Spans), err
+			}
+			return 0, nil
+		}
+
+		fo.mu.Lock()
+		open, tripped := dest.breaker.onFailure(fo.now())
+		fo.mu.Unlock()
+		if tripped {
+			fo.record(dest, statTrips)
+		}
+		if !open {
+			return len(td.Spans), err
+		}
+		errs = append(errs, fmt.Errorf("%s: %v", dest.name, err))
+	}
+
+	if len(errs) == 0 {
+		return len(td.Spans), errAllDestinationsDown
+	}
+	return len(td.Spans), internal.CombineErrors(errs)
+}
+
+func (fo *failover) record(dest *destination, measure *stats.Int64Measure) {
+	stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(tagDestinationKey, dest.name)},
+		measure.M(1))
+}
+
+// stop stops the exporters of all the destinations.
+func (fo *failover) stop() error {
+	var errs []error
+	for _, dest := range fo.destinations {
+		if stopper, ok := dest.exporter.(exporterhelper.Stopper); ok {
+			if err := stopper.Stop(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return internal.CombineErrors(errs)
+}
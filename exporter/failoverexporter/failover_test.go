@@ -0,0 +1,294 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failoverexporter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+)
+
+const (
+	testExporterType = "failovertest"
+	// testAsyncExporterType is the type of an exporter that does not report send errors.
+	testAsyncExporterType = "failovertestasync"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+func init() {
+	exporter.RegisterTraceExporterFactory(exporterhelper.NewTraceExporterFactory(
+		testExporterType,
+		func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+			if v.GetString(testExporterType+".url") == "" {
+				return nil, nil, nil, errors.New("url is required")
+			}
+			return []consumer.TraceConsumer{&testDestination{}}, nil, nil, nil
+		},
+		exporterhelper.WithConfigValidator(func(v *viper.Viper) error {
+			if v.GetString(testExporterType+".url") == "" {
+				return errors.New("url is required")
+			}
+			return nil
+		}),
+		exporterhelper.WithSendErrors(),
+	))
+	exporter.RegisterTraceExporterFactory(exporterhelper.NewTraceExporterFactory(
+		testAsyncExporterType,
+		func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+			return []consumer.TraceConsumer{&testDestination{}}, nil, nil, nil
+		},
+	))
+}
+
+// testDestination fails while down is set and counts the spans it received.
+type testDestination struct {
+	down     int32
+	numSpans int32
+}
+
+func (d *testDestination) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if atomic.LoadInt32(&d.down) != 0 {
+		return errUnavailable
+	}
+	atomic.AddInt32(&d.numSpans, int32(len(td.Spans)))
+	return nil
+}
+
+func (d *testDestination) setDown(down bool) {
+	v := int32(0)
+	if down {
+		v = 1
+	}
+	atomic.StoreInt32(&d.down, v)
+}
+
+func newTestFailover(now *time.Time, dests ...*testDestination) *failover {
+	fo := &failover{now: func() time.Time { return *now }}
+	for i, d := range dests {
+		fo.destinations = append(fo.destinations, &destination{
+			name:     []string{"primary", "secondary", "tertiary"}[i],
+			exporter: d,
+			breaker:  circuitBreaker{failureThreshold: 2, probeInterval: time.Minute},
+		})
+	}
+	return fo
+}
+
+func TestFailover_TripsAndFailsBack(t *testing.T) {
+	now := time.Unix(1000, 0)
+	primary, secondary := &testDestination{}, &testDestination{}
+	fo := newTestFailover(&now, primary, secondary)
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() = %v", err)
+	}
+
+	// The first failure is returned so that the data is retried on the primary.
+	primary.setDown(true)
+	if _, err := fo.pushTraceData(context.Background(), td); err != errUnavailable {
+		t.Fatalf("pushTraceData() = %v, want %v", err, errUnavailable)
+	}
+	// The second one trips the breaker, the data goes to the secondary.
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() after tripping = %v", err)
+	}
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() on the secondary = %v", err)
+	}
+	if got := atomic.LoadInt32(&secondary.numSpans); got != 2 {
+		t.Errorf("got %d spans on the secondary, want 2", got)
+	}
+
+	// A failed probe keeps the data on the secondary.
+	now = now.Add(time.Minute)
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() with a failed probe = %v", err)
+	}
+	if got := atomic.LoadInt32(&secondary.numSpans); got != 3 {
+		t.Errorf("got %d spans on the secondary, want 3", got)
+	}
+
+	// A successful probe fails back to the primary.
+	primary.setDown(false)
+	now = now.Add(30 * time.Second)
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() before the next probe = %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() with a successful probe = %v", err)
+	}
+	if _, err := fo.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("pushTraceData() after failing back = %v", err)
+	}
+	if got := atomic.LoadInt32(&primary.numSpans); got != 3 {
+		t.Errorf("got %d spans on the primary, want 3", got)
+	}
+	if got := atomic.LoadInt32(&secondary.numSpans); got != 4 {
+		t.Errorf("got %d spans on the secondary, want 4", got)
+	}
+}
+
+func TestFailover_AllDestinationsDown(t *testing.T) {
+	now := time.Unix(1000, 0)
+	primary, secondary := &testDestination{}, &testDestination{}
+	primary.setDown(true)
+	secondary.setDown(true)
+	fo := newTestFailover(&now, primary, secondary)
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+
+	for i := 0; i < 4; i++ {
+		fo.pushTraceData(context.Background(), td)
+	}
+	dropped, err := fo.pushTraceData(context.Background(), td)
+	if err != errAllDestinationsDown || dropped != 1 {
+		t.Errorf("pushTraceData() = (%d, %v), want (1, %v)", dropped, err, errAllDestinationsDown)
+	}
+}
+
+func TestFailover_PermanentErrorDoesNotTrip(t *testing.T) {
+	now := time.Unix(1000, 0)
+	secondary := &testDestination{}
+	fo := newTestFailover(&now, &testDestination{}, secondary)
+	fo.destinations[0].exporter = consumerFunc(func(ctx context.Context, td data.TraceData) error {
+		return consumererror.Permanent(errors.New("malformed"))
+	})
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := fo.pushTraceData(context.Background(), td); !consumererror.IsPermanent(err) {
+			t.Fatalf("pushTraceData() = %v, want a permanent error", err)
+		}
+	}
+	if got := atomic.LoadInt32(&secondary.numSpans); got != 0 {
+		t.Errorf("got %d spans on the secondary, want 0", got)
+	}
+}
+
+type consumerFunc func(ctx context.Context, td data.TraceData) error
+
+func (f consumerFunc) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return f(ctx, td)
+}
+
+func TestFailoverTraceExportersFromViper(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			cfg: map[string]interface{}{
+				"destinations": []string{testExporterType + "/primary", testExporterType + "/dr"},
+				"exporters": map[string]interface{}{
+					testExporterType + "/primary": map[string]interface{}{"url": "http://primary"},
+					testExporterType + "/dr":      map[string]interface{}{"url": "http://dr"},
+				},
+			},
+		},
+		{
+			name: "async_last",
+			cfg: map[string]interface{}{
+				"destinations": []string{testExporterType, testAsyncExporterType},
+				"exporters": map[string]interface{}{
+					testExporterType:      map[string]interface{}{"url": "http://primary"},
+					testAsyncExporterType: map[string]interface{}{"url": "http://dr"},
+				},
+			},
+		},
+		{
+			name: "async_primary",
+			cfg: map[string]interface{}{
+				"destinations": []string{testAsyncExporterType, testExporterType},
+				"exporters": map[string]interface{}{
+					testAsyncExporterType: map[string]interface{}{"url": "http://primary"},
+					testExporterType:      map[string]interface{}{"url": "http://dr"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no_destinations",
+			cfg:     map[string]interface{}{"probe-interval": "10s"},
+			wantErr: true,
+		},
+		{
+			name: "not_configured",
+			cfg: map[string]interface{}{
+				"destinations": []string{testExporterType + "/primary"},
+				"exporters": map[string]interface{}{
+					testExporterType + "/dr": map[string]interface{}{"url": "http://dr"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown_type",
+			cfg: map[string]interface{}{
+				"destinations": []string{"unknown"},
+				"exporters": map[string]interface{}{
+					"unknown": map[string]interface{}{"url": "http://primary"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid_destination",
+			cfg: map[string]interface{}{
+				"destinations": []string{testExporterType},
+				"exporters": map[string]interface{}{
+					testExporterType: map[string]interface{}{"timeout": "5s"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set(TypeStr, tt.cfg)
+			if err := validateFailoverConfig(v); (err != nil) != tt.wantErr {
+				t.Errorf("validateFailoverConfig() = %v, want error: %v", err, tt.wantErr)
+			}
+			tps, _, doneFns, err := FailoverTraceExportersFromViper(v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FailoverTraceExportersFromViper() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(tps) != 1 || len(doneFns) != 1 {
+				t.Fatalf("got %d exporters and %d done functions, want 1 and 1", len(tps), len(doneFns))
+			}
+			if err := doneFns[0](); err != nil {
+				t.Errorf("done() = %v", err)
+			}
+		})
+	}
+}
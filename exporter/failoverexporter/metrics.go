@@ -0,0 +1,57 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failoverexporter
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var (
+	tagDestinationKey, _ = tag.NewKey("destination")
+
+	statTrips      = stats.Int64("failover_trips", "Number of times a destination was failed over after consecutive failures", stats.UnitDimensionless)
+	statRecoveries = stats.Int64("failover_recoveries", "Number of times a destination was failed back to after a successful probe", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metrics views related to the failover exporter.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	tagKeys := []tag.Key{tagDestinationKey}
+
+	tripsView := &view.View{
+		Name:        statTrips.Name(),
+		Measure:     statTrips,
+		Description: statTrips.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	recoveriesView := &view.View{
+		Name:        statRecoveries.Name(),
+		Measure:     statRecoveries,
+		Description: statRecoveries.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{tripsView, recoveriesView}
+}
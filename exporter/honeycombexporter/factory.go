@@ -0,0 +1,41 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+)
+
+// TypeStr is the type of the Honeycomb exporter, it is also the key of its
+// configuration in the "exporters" section.
+const TypeStr = "honeycomb"
+
+func init() {
+	exporter.RegisterTraceExporterFactory(exporterhelper.NewTraceExporterFactory(
+		TypeStr,
+		HoneycombTraceExportersFromViper,
+		exporterhelper.WithConfigValidator(validateConfig),
+	))
+}
+
+// validateConfig checks that the configuration can be parsed, as done when creating
+// the exporter.
+func validateConfig(v *viper.Viper) error {
+	_, err := honeycombConfigFromViper(v)
+	return err
+}
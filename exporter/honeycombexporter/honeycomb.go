@@ -33,14 +33,10 @@ type honeycombConfig struct {
 // HoneycombTraceExportersFromViper unmarshals the viper and returns an exporter.TraceExporter
 // targeting Honeycomb according to the configuration settings.
 func HoneycombTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Honeycomb *honeycombConfig `mapstructure:"honeycomb"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	hc, err := honeycombConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	hc := cfg.Honeycomb
 	if hc == nil {
 		return nil, nil, nil, nil
 	}
@@ -59,3 +55,15 @@ func HoneycombTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsu
 	})
 	return
 }
+
+// honeycombConfigFromViper returns the configuration of the Honeycomb exporter, or nil if there
+// is none.
+func honeycombConfigFromViper(v *viper.Viper) (*honeycombConfig, error) {
+	var cfg struct {
+		Honeycomb *honeycombConfig `mapstructure:"honeycomb"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Honeycomb, nil
+}
@@ -15,6 +15,8 @@
 package jaegerexporter
 
 import (
+	"errors"
+
 	"github.com/spf13/viper"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
@@ -23,6 +25,10 @@ import (
 	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
 )
 
+// errCollectorEndpointRequired is returned, as by jaeger.NewExporter, when the
+// configuration does not have an endpoint.
+var errCollectorEndpointRequired = errors.New("missing endpoint for Jaeger exporter")
+
 // Slight modified version of go/src/contrib.go.opencensus.io/exporter/jaeger/jaeger.go
 type jaegerConfig struct {
 	CollectorEndpoint string `mapstructure:"collector_endpoint,omitempty"`
@@ -34,13 +40,10 @@ type jaegerConfig struct {
 // JaegerExportersFromViper unmarshals the viper and returns exporter.TraceExporters targeting
 // Jaeger according to the configuration settings.
 func JaegerExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Jaeger *jaegerConfig `mapstructure:"jaeger"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	jc, err := jaegerConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-	jc := cfg.Jaeger
 	if jc == nil {
 		return nil, nil, nil, nil
 	}
@@ -73,3 +76,18 @@ func JaegerExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 	tps = append(tps, jte)
 	return
 }
+
+// jaegerConfigFromViper returns the configuration of the Jaeger exporter, or nil if there
+// is none.
+func jaegerConfigFromViper(v *viper.Viper) (*jaegerConfig, error) {
+	var cfg struct {
+		Jaeger *jaegerConfig `mapstructure:"jaeger"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Jaeger != nil && cfg.Jaeger.CollectorEndpoint == "" {
+		return nil, errCollectorEndpointRequired
+	}
+	return cfg.Jaeger, nil
+}
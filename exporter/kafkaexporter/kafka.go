@@ -15,6 +15,7 @@
 package kafkaexporter
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/viper"
@@ -24,6 +25,8 @@ import (
 	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
 )
 
+var errBrokersRequired = errors.New("Kafka exporter config requires at least one broker")
+
 type kafkaConfig struct {
 	Brokers []string `mapstructure:"brokers,omitempty"`
 	Topic   string   `mapstructure:"topic,omitempty"`
@@ -32,14 +35,10 @@ type kafkaConfig struct {
 // KafkaExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
 // Kafka according to the configuration settings.
 func KafkaExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Kafka *kafkaConfig `mapstructure:"kafka"`
-	}
-
-	if err := v.Unmarshal(&cfg); err != nil {
+	kc, err := kafkaConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-	kc := cfg.Kafka
 	if kc == nil {
 		return nil, nil, nil, nil
 	}
@@ -65,3 +64,18 @@ func KafkaExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 	})
 	return
 }
+
+// kafkaConfigFromViper returns the configuration of the Kafka exporter, or nil if there
+// is none.
+func kafkaConfigFromViper(v *viper.Viper) (*kafkaConfig, error) {
+	var cfg struct {
+		Kafka *kafkaConfig `mapstructure:"kafka"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Kafka != nil && len(cfg.Kafka.Brokers) == 0 {
+		return nil, errBrokersRequired
+	}
+	return cfg.Kafka, nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+	"go.opencensus.io/stats"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/exporter/opencensusexporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/compression"
+	"github.com/census-instrumentation/opencensus-service/internal/compression/grpc"
+)
+
+type loadBalancingConfig struct {
+	// Endpoints is a static list of OpenCensus endpoints.
+	Endpoints []string `mapstructure:"endpoints,omitempty"`
+	// DNSName is a "host:port" whose host resolves to the addresses of the endpoints,
+	// e.g.: a headless Kubernetes service.
+	DNSName         string        `mapstructure:"dns-name,omitempty"`
+	ResolveInterval time.Duration `mapstructure:"resolve-interval,omitempty"`
+
+	// The settings of the OpenCensus exporters sending to each endpoint.
+	Compression string            `mapstructure:"compression,omitempty"`
+	Headers     map[string]string `mapstructure:"headers,omitempty"`
+	CertPemFile string            `mapstructure:"cert-pem-file,omitempty"`
+}
+
+const defaultResolveInterval = 30 * time.Second
+
+var (
+	// ErrEndpointsRequired indicates that this exporter was provided with neither a list of endpoints nor a DNS name.
+	ErrEndpointsRequired = errors.New("load balancing exporter config requires either endpoints or a dns-name")
+	// ErrBothEndpointsAndDNSName indicates that this exporter was provided with both a list of endpoints and a DNS name.
+	ErrBothEndpointsAndDNSName = errors.New("load balancing exporter config cannot have both endpoints and a dns-name")
+	// ErrUnsupportedCompressionType indicates that this exporter was provided with a compression protocol it does not support.
+	ErrUnsupportedCompressionType = errors.New("load balancing exporter unsupported compression type")
+	// ErrUnableToGetTLSCreds indicates that this exporter could not read the provided TLS credentials.
+	ErrUnableToGetTLSCreds = errors.New("load balancing exporter unable to read TLS credentials")
+
+	errNoEndpoints = errors.New("no endpoints to send the traces to")
+)
+
+// LoadBalancingTraceExportersFromViper unmarshals the viper and returns a
+// consumer.TraceConsumer that sends all the spans of a trace to the same OpenCensus
+// endpoint, chosen by consistently hashing the trace ID.
+func LoadBalancingTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	lbc, err := loadBalancingConfigFromViper(v)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if lbc == nil {
+		return nil, nil, nil, nil
+	}
+
+	var res resolver
+	if lbc.DNSName != "" {
+		interval := lbc.ResolveInterval
+		if interval <= 0 {
+			interval = defaultResolveInterval
+		}
+		if res, err = newDNSResolver(lbc.DNSName, interval); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid load balancing dns-name %q: %v", lbc.DNSName, err)
+		}
+	} else {
+		res = &staticResolver{endpoints: lbc.Endpoints}
+	}
+
+	lb := newLoadBalancer(res, func(endpoint string) (consumer.TraceConsumer, func() error, error) {
+		return newOpenCensusExporter(lbc, endpoint)
+	})
+	if err := lb.start(); err != nil {
+		lb.stop()
+		return nil, nil, nil, err
+	}
+
+	lbe, err := exporterhelper.NewTraceExporter(
+		"loadbalancing",
+		lb.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.LoadBalancing.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true))
+	if err != nil {
+		lb.stop()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, lbe)
+	doneFns = append(doneFns, lb.stop)
+	return
+}
+
+// loadBalancingConfigFromViper returns the configuration of the load balancing exporter,
+// or nil if there is none. It checks the settings that do not require reading files.
+func loadBalancingConfigFromViper(v *viper.Viper) (*loadBalancingConfig, error) {
+	var cfg struct {
+		LoadBalancing *loadBalancingConfig `mapstructure:"loadbalancing"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	lbc := cfg.LoadBalancing
+	if lbc == nil {
+		return nil, nil
+	}
+	if len(lbc.Endpoints) == 0 && lbc.DNSName == "" {
+		return nil, ErrEndpointsRequired
+	}
+	if len(lbc.Endpoints) > 0 && lbc.DNSName != "" {
+		return nil, ErrBothEndpointsAndDNSName
+	}
+	if lbc.Compression != "" && grpc.GetGRPCCompressionKey(lbc.Compression) == compression.Unsupported {
+		return nil, ErrUnsupportedCompressionType
+	}
+	return lbc, nil
+}
+
+// validateLoadBalancingConfig checks the configuration of the load balancing exporter,
+// including that its TLS credentials can be read, without resolving or connecting to
+// the endpoints.
+func validateLoadBalancingConfig(v *viper.Viper) error {
+	lbc, err := loadBalancingConfigFromViper(v)
+	if err != nil || lbc == nil {
+		return err
+	}
+	if lbc.CertPemFile != "" {
+		if _, err := credentials.NewClientTLSFromFile(lbc.CertPemFile, ""); err != nil {
+			return ErrUnableToGetTLSCreds
+		}
+	}
+	return nil
+}
+
+// newOpenCensusExporter creates the OpenCensus exporter sending the traces of an endpoint.
+func newOpenCensusExporter(lbc *loadBalancingConfig, endpoint string) (consumer.TraceConsumer, func() error, error) {
+	v := viper.New()
+	v.Set(opencensusexporter.TypeStr, map[string]interface{}{
+		"endpoint":      endpoint,
+		"compression":   lbc.Compression,
+		"headers":       lbc.Headers,
+		"cert-pem-file": lbc.CertPemFile,
+		// The exporters are already one per endpoint.
+		"num-workers": 1,
+	})
+	tps, _, doneFns, err := opencensusexporter.OpenCensusTraceExportersFromViper(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := func() error {
+		var errs []error
+		for _, done := range doneFns {
+			if err := done(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return internal.CombineErrors(errs)
+	}
+	return tps[0], stop, nil
+}
+
+// endpointExporter sends the traces of one endpoint.
+type endpointExporter struct {
+	consumer.TraceConsumer
+	stop func() error
+}
+
+// loadBalancer splits the incoming traces by trace ID across the endpoints found by its
+// resolver.
+type loadBalancer struct {
+	resolver    resolver
+	newExporter func(endpoint string) (consumer.TraceConsumer, func() error, error)
+
+	// mu protects ring and exporters, it is held for reading while sending so that the
+	// exporters of removed endpoints are only stopped once they are no longer used.
+	mu        sync.RWMutex
+	ring      *hashRing
+	exporters map[string]endpointExporter
+}
+
+func newLoadBalancer(res resolver, newExporter func(endpoint string) (consumer.TraceConsumer, func() error, error)) *loadBalancer {
+	return &loadBalancer{
+		resolver:    res,
+		newExporter: newExporter,
+		ring:        newHashRing(nil),
+		exporters:   make(map[string]endpointExporter),
+	}
+}
+
+func (lb *loadBalancer) start() error {
+	return lb.resolver.start(lb.updateEndpoints)
+}
+
+// stop stops resolving the endpoints and stops all the exporters, flushing their data.
+func (lb *loadBalancer) stop() error {
+	lb.resolver.stop()
+	return lb.updateEndpoints(nil)
+}
+
+// updateEndpoints rebalances the traces across endpoints. The exporters of the endpoints
+// that are kept are reused, and those of the removed ones are stopped.
+func (lb *loadBalancer) updateEndpoints(endpoints []string) error {
+	var errs []error
+	lb.mu.Lock()
+	exporters := make(map[string]endpointExporter, len(endpoints))
+	available := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if exp, ok := lb.exporters[endpoint]; ok {
+			exporters[endpoint] = exp
+			available = append(available, endpoint)
+			continue
+		}
+		tc, stop, err := lb.newExporter(endpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot create the exporter for endpoint %q: %v", endpoint, err))
+			continue
+		}
+		exporters[endpoint] = endpointExporter{TraceConsumer: tc, stop: stop}
+		available = append(available, endpoint)
+	}
+	var removed []endpointExporter
+	for endpoint, exp := range lb.exporters {
+		if _, ok := exporters[endpoint]; !ok {
+			removed = append(removed, exp)
+		}
+	}
+	lb.exporters = exporters
+	lb.ring = newHashRing(available)
+	lb.mu.Unlock()
+
+	stats.Record(context.Background(), statNumEndpoints.M(int64(len(available))))
+	for _, exp := range removed {
+		if err := exp.stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// pushTraceData sends the spans of each trace to the endpoint of its trace ID. If only
+// some endpoints fail, the error carries their spans so that only those are retried. The
+// OpenCensus exporters report their send errors, so an endpoint fails when its spans could
+// not be written to its stream.
+func (lb *loadBalancer) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	if len(lb.exporters) == 0 {
+		return len(td.Spans), errNoEndpoints
+	}
+
+	var failedSpans []*tracepb.Span
+	var errs []error
+	for endpoint, spans := range splitByEndpoint(lb.ring, td.Spans) {
+		err := lb.exporters[endpoint].ConsumeTraceData(ctx, data.TraceData{
+			Node:         td.Node,
+			Resource:     td.Resource,
+			Spans:        spans,
+			SourceFormat: td.SourceFormat,
+		})
+		if err != nil {
+			failedSpans = append(failedSpans, spans...)
+			errs = append(errs, err)
+		}
+	}
+	err := consumererror.CombineErrors(errs)
+	if err != nil && len(failedSpans) < len(td.Spans) {
+		err = consumererror.PartialTraces(err, data.TraceData{
+			Node:         td.Node,
+			Resource:     td.Resource,
+			Spans:        failedSpans,
+			SourceFormat: td.SourceFormat,
+		})
+	}
+	return len(failedSpans), err
+}
+
+// splitByEndpoint groups the spans by the endpoint of their trace IDs.
+func splitByEndpoint(ring *hashRing, spans []*tracepb.Span) map[string][]*tracepb.Span {
+	batches := make(map[string][]*tracepb.Span)
+	for _, span := range spans {
+		var traceID []byte
+		if span != nil {
+			traceID = span.TraceId
+		}
+		endpoint := ring.endpointFor(traceID)
+		batches[endpoint] = append(batches[endpoint], span)
+	}
+	return batches
+}
@@ -0,0 +1,332 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
+)
+
+func TestLoadBalancingTraceExportersFromViper(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr error
+	}{
+		{name: "no_endpoints", cfg: map[string]interface{}{"compression": "gzip"}, wantErr: ErrEndpointsRequired},
+		{
+			name: "both",
+			cfg: map[string]interface{}{
+				"endpoints": []string{"127.0.0.1:55678"},
+				"dns-name":  "collectors:55678",
+			},
+			wantErr: ErrBothEndpointsAndDNSName,
+		},
+		{
+			name: "compression",
+			cfg: map[string]interface{}{
+				"endpoints":   []string{"127.0.0.1:55678"},
+				"compression": "random-compression",
+			},
+			wantErr: ErrUnsupportedCompressionType,
+		},
+		{
+			name: "tls",
+			cfg: map[string]interface{}{
+				"endpoints":     []string{"127.0.0.1:55678"},
+				"cert-pem-file": "dummy_file.pem",
+			},
+			wantErr: ErrUnableToGetTLSCreds,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set(TypeStr, tt.cfg)
+			if err := validateLoadBalancingConfig(v); err != tt.wantErr {
+				t.Errorf("validateLoadBalancingConfig() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	v := viper.New()
+	v.Set(TypeStr, map[string]interface{}{
+		"endpoints":   []string{"127.0.0.1:55678", "127.0.0.1:55679"},
+		"compression": "gzip",
+	})
+	tps, _, doneFns, err := LoadBalancingTraceExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("LoadBalancingTraceExportersFromViper() = %v", err)
+	}
+	if len(tps) != 1 || len(doneFns) != 1 {
+		t.Fatalf("got %d exporters and %d done functions, want 1 and 1", len(tps), len(doneFns))
+	}
+	if err := doneFns[0](); err != nil {
+		t.Errorf("done() = %v", err)
+	}
+}
+
+// fakeExporters creates sink exporters for the endpoints and records which ones were
+// stopped.
+type fakeExporters struct {
+	mu      sync.Mutex
+	sinks   map[string]*exportertest.SinkTraceExporter
+	stopped map[string]bool
+}
+
+func newFakeExporters() *fakeExporters {
+	return &fakeExporters{
+		sinks:   make(map[string]*exportertest.SinkTraceExporter),
+		stopped: make(map[string]bool),
+	}
+}
+
+func (f *fakeExporters) newExporter(endpoint string) (consumer.TraceConsumer, func() error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sink := &exportertest.SinkTraceExporter{}
+	f.sinks[endpoint] = sink
+	return sink, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.stopped[endpoint] = true
+		return nil
+	}, nil
+}
+
+func (f *fakeExporters) isStopped(endpoint string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stopped[endpoint]
+}
+
+func TestLoadBalancer_SplitsByTraceID(t *testing.T) {
+	endpoints := []string{"10.0.0.1:55678", "10.0.0.2:55678", "10.0.0.3:55678"}
+	fakes := newFakeExporters()
+	lb := newLoadBalancer(&staticResolver{endpoints: endpoints}, fakes.newExporter)
+	if err := lb.start(); err != nil {
+		t.Fatalf("start() = %v", err)
+	}
+
+	var spans []*tracepb.Span
+	for _, traceID := range randomTraceIDs(100) {
+		// Two spans per trace.
+		spans = append(spans, &tracepb.Span{TraceId: traceID}, &tracepb.Span{TraceId: traceID})
+	}
+	if _, err := lb.pushTraceData(context.Background(), data.TraceData{Spans: spans, SourceFormat: "test"}); err != nil {
+		t.Fatalf("pushTraceData() = %v", err)
+	}
+
+	traceEndpoints := make(map[string]string)
+	numSpans := 0
+	for endpoint, sink := range fakes.sinks {
+		for _, td := range sink.AllTraces() {
+			if td.SourceFormat != "test" {
+				t.Errorf("got source format %q, want \"test\"", td.SourceFormat)
+			}
+			for _, span := range td.Spans {
+				numSpans++
+				key := string(span.TraceId)
+				if prev, ok := traceEndpoints[key]; ok && prev != endpoint {
+					t.Errorf("trace sent to %s and %s", prev, endpoint)
+				}
+				traceEndpoints[key] = endpoint
+			}
+		}
+	}
+	if numSpans != len(spans) {
+		t.Errorf("got %d spans sent, want %d", numSpans, len(spans))
+	}
+	if len(fakes.sinks) != len(endpoints) {
+		t.Errorf("got %d endpoints used, want %d", len(fakes.sinks), len(endpoints))
+	}
+
+	if err := lb.stop(); err != nil {
+		t.Fatalf("stop() = %v", err)
+	}
+	for _, endpoint := range endpoints {
+		if !fakes.isStopped(endpoint) {
+			t.Errorf("exporter of %s not stopped", endpoint)
+		}
+	}
+}
+
+func TestLoadBalancer_NoEndpoints(t *testing.T) {
+	lb := newLoadBalancer(&staticResolver{}, newFakeExporters().newExporter)
+	if err := lb.start(); err != nil {
+		t.Fatalf("start() = %v", err)
+	}
+	td := data.TraceData{Spans: []*tracepb.Span{{TraceId: []byte{1}}}}
+	if dropped, err := lb.pushTraceData(context.Background(), td); err != errNoEndpoints || dropped != 1 {
+		t.Errorf("pushTraceData() = (%d, %v), want (1, %v)", dropped, err, errNoEndpoints)
+	}
+}
+
+func TestLoadBalancer_RetriesOnlyFailedEndpoints(t *testing.T) {
+	healthy, failing := "10.0.0.1:55678", "10.0.0.2:55678"
+	sinks := map[string]*exportertest.SinkTraceExporter{
+		healthy: {},
+		failing: {},
+	}
+	var failOnce sync.Once
+	lb := newLoadBalancer(&staticResolver{endpoints: []string{healthy, failing}},
+		func(endpoint string) (consumer.TraceConsumer, func() error, error) {
+			sink := sinks[endpoint]
+			if endpoint == healthy {
+				return sink, func() error { return nil }, nil
+			}
+			return consumerFunc(func(ctx context.Context, td data.TraceData) error {
+				var err error
+				failOnce.Do(func() { err = errors.New("unavailable") })
+				if err != nil {
+					return err
+				}
+				return sink.ConsumeTraceData(ctx, td)
+			}), func() error { return nil }, nil
+		})
+	if err := lb.start(); err != nil {
+		t.Fatalf("start() = %v", err)
+	}
+	defer lb.stop()
+	lbe, err := exporterhelper.NewTraceExporter("loadbalancing", lb.pushTraceData)
+	if err != nil {
+		t.Fatalf("NewTraceExporter() = %v", err)
+	}
+	// The queued processor retries the failed batches.
+	qp, err := queued.NewQueuedSpanProcessor(lbe,
+		queued.Options.WithNumWorkers(1),
+		queued.Options.WithRetryOnProcessingFailures(true),
+		queued.Options.WithBackoffDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+
+	var spans []*tracepb.Span
+	for _, traceID := range randomTraceIDs(100) {
+		spans = append(spans, &tracepb.Span{TraceId: traceID})
+	}
+	if err := qp.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := qp.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	// Each span is received once by the endpoint of its trace, the healthy one included.
+	received := make(map[string]int)
+	for endpoint, sink := range sinks {
+		for _, td := range sink.AllTraces() {
+			for _, span := range td.Spans {
+				if got := lb.ring.endpointFor(span.TraceId); got != endpoint {
+					t.Errorf("span sent to %s, want %s", endpoint, got)
+				}
+				received[string(span.TraceId)]++
+			}
+		}
+	}
+	for _, span := range spans {
+		if n := received[string(span.TraceId)]; n != 1 {
+			t.Errorf("span received %d times, want once", n)
+		}
+	}
+	if len(sinks[healthy].AllTraces()) == 0 || len(sinks[failing].AllTraces()) == 0 {
+		t.Error("want spans sent to both endpoints")
+	}
+}
+
+type consumerFunc func(ctx context.Context, td data.TraceData) error
+
+func (f consumerFunc) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return f(ctx, td)
+}
+
+func TestDNSResolver_UpdatesEndpoints(t *testing.T) {
+	fakes := newFakeExporters()
+	res, err := newDNSResolver("collectors:55678", time.Millisecond)
+	if err != nil {
+		t.Fatalf("newDNSResolver() = %v", err)
+	}
+
+	var mu sync.Mutex
+	addrs := []string{"10.0.0.2", "10.0.0.1"}
+	var lookupErr error
+	res.lookup = func(ctx context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if host != "collectors" {
+			t.Errorf("lookup(%q), want \"collectors\"", host)
+		}
+		return addrs, lookupErr
+	}
+
+	lb := newLoadBalancer(res, fakes.newExporter)
+	if err := lb.start(); err != nil {
+		t.Fatalf("start() = %v", err)
+	}
+	defer lb.stop()
+
+	waitForEndpoints(t, lb, []string{"10.0.0.1:55678", "10.0.0.2:55678"})
+
+	// A failed resolution keeps the endpoints.
+	mu.Lock()
+	lookupErr = errors.New("no such host")
+	mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	waitForEndpoints(t, lb, []string{"10.0.0.1:55678", "10.0.0.2:55678"})
+
+	mu.Lock()
+	addrs = []string{"10.0.0.2", "10.0.0.3"}
+	lookupErr = nil
+	mu.Unlock()
+	waitForEndpoints(t, lb, []string{"10.0.0.2:55678", "10.0.0.3:55678"})
+	if !fakes.isStopped("10.0.0.1:55678") {
+		t.Error("exporter of the removed endpoint not stopped")
+	}
+	if fakes.isStopped("10.0.0.2:55678") {
+		t.Error("exporter of a kept endpoint stopped")
+	}
+}
+
+func waitForEndpoints(t *testing.T, lb *loadBalancer, want []string) {
+	t.Helper()
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		lb.mu.RLock()
+		got = got[:0]
+		for endpoint := range lb.exporters {
+			got = append(got, endpoint)
+		}
+		lb.mu.RUnlock()
+		if reflect.DeepEqual(sortedEndpoints(got), want) {
+			return
+		}
+	}
+	t.Fatalf("got endpoints %v, want %v", got, want)
+}
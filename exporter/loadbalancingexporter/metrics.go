@@ -0,0 +1,50 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var (
+	statNumEndpoints    = stats.Int64("loadbalancer_num_endpoints", "Number of endpoints the traces are balanced across", stats.UnitDimensionless)
+	statResolveFailures = stats.Int64("loadbalancer_resolve_failures", "Number of failures to resolve or connect to the endpoints", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metrics views related to the load balancing exporter.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	numEndpointsView := &view.View{
+		Name:        statNumEndpoints.Name(),
+		Measure:     statNumEndpoints,
+		Description: statNumEndpoints.Description(),
+		Aggregation: view.LastValue(),
+	}
+
+	resolveFailuresView := &view.View{
+		Name:        statResolveFailures.Name(),
+		Measure:     statResolveFailures,
+		Description: statResolveFailures.Description(),
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{numEndpointsView, resolveFailuresView}
+}
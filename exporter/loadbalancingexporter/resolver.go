@@ -0,0 +1,146 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+)
+
+// resolver finds the endpoints that the traces are balanced across.
+type resolver interface {
+	// start resolves the endpoints and calls onChange with them, then again each time
+	// they change until stop is called.
+	start(onChange func(endpoints []string) error) error
+	stop()
+}
+
+// staticResolver resolves to a fixed list of endpoints.
+type staticResolver struct {
+	endpoints []string
+}
+
+var _ resolver = (*staticResolver)(nil)
+
+func (r *staticResolver) start(onChange func(endpoints []string) error) error {
+	return onChange(sortedEndpoints(r.endpoints))
+}
+
+func (r *staticResolver) stop() {}
+
+// dnsResolver periodically resolves a host name to the endpoints formed by each of its
+// addresses and a port.
+type dnsResolver struct {
+	host     string
+	port     string
+	interval time.Duration
+	// lookup returns the addresses of a host, it is net.DefaultResolver.LookupHost
+	// unless replaced by tests.
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	onChange  func(endpoints []string) error
+	endpoints []string
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+var _ resolver = (*dnsResolver)(nil)
+
+func newDNSResolver(hostPort string, interval time.Duration) (*dnsResolver, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	return &dnsResolver{
+		host:     host,
+		port:     port,
+		interval: interval,
+		lookup:   net.DefaultResolver.LookupHost,
+		stopCh:   make(chan struct{}),
+	}, nil
+}
+
+// start resolves the host name and keeps resolving it on the interval. A failed
+// resolution keeps the previous endpoints, so it does not prevent the exporter from
+// starting: the traces are refused until the name is resolved.
+func (r *dnsResolver) start(onChange func(endpoints []string) error) error {
+	r.onChange = onChange
+	r.resolve()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.resolve()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *dnsResolver) resolve() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+	addrs, err := r.lookup(ctx, r.host)
+	if err != nil {
+		stats.Record(context.Background(), statResolveFailures.M(1))
+		return
+	}
+
+	endpoints := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, net.JoinHostPort(addr, r.port))
+	}
+	endpoints = sortedEndpoints(endpoints)
+	if reflect.DeepEqual(endpoints, r.endpoints) {
+		return
+	}
+	if err := r.onChange(endpoints); err != nil {
+		stats.Record(context.Background(), statResolveFailures.M(1))
+		return
+	}
+	r.endpoints = endpoints
+}
+
+func (r *dnsResolver) stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// sortedEndpoints returns a sorted copy of endpoints without duplicates.
+func sortedEndpoints(endpoints []string) []string {
+	seen := make(map[string]bool, len(endpoints))
+	sorted := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			sorted = append(sorted, endpoint)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodesPerEndpoint is the number of points of each endpoint on the ring. More points
+// spread the traces more evenly across the endpoints.
+const virtualNodesPerEndpoint = 128
+
+// hashRing consistently maps trace IDs to endpoints: when an endpoint is added or removed
+// only the traces mapped to it, or that will be, change their endpoint.
+type hashRing struct {
+	points []ringPoint
+}
+
+type ringPoint struct {
+	hash     uint64
+	endpoint string
+}
+
+func newHashRing(endpoints []string) *hashRing {
+	points := make([]ringPoint, 0, len(endpoints)*virtualNodesPerEndpoint)
+	for _, endpoint := range endpoints {
+		for i := 0; i < virtualNodesPerEndpoint; i++ {
+			points = append(points, ringPoint{
+				hash:     hashBytes([]byte(endpoint + "#" + strconv.Itoa(i))),
+				endpoint: endpoint,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			// Break ties so that the ring only depends on the set of endpoints.
+			return points[i].endpoint < points[j].endpoint
+		}
+		return points[i].hash < points[j].hash
+	})
+	return &hashRing{points: points}
+}
+
+// endpointFor returns the endpoint of the given trace ID, or an empty string if the ring
+// has no endpoints.
+func (r *hashRing) endpointFor(traceID []byte) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := hashBytes(traceID)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].endpoint
+}
+
+// hashBytes returns the FNV-1a hash of b, mixed with the finalizer of MurmurHash3 since
+// FNV-1a alone spreads similar inputs, e.g.: the points of an endpoint, poorly.
+func hashBytes(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,82 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancingexporter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomTraceIDs(n int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	traceIDs := make([][]byte, n)
+	for i := range traceIDs {
+		traceID := make([]byte, 16)
+		binary.BigEndian.PutUint64(traceID, r.Uint64())
+		binary.BigEndian.PutUint64(traceID[8:], r.Uint64())
+		traceIDs[i] = traceID
+	}
+	return traceIDs
+}
+
+func TestHashRing_Empty(t *testing.T) {
+	if got := newHashRing(nil).endpointFor([]byte{1}); got != "" {
+		t.Errorf("endpointFor() on an empty ring = %q, want \"\"", got)
+	}
+}
+
+func TestHashRing_Distribution(t *testing.T) {
+	endpoints := []string{"10.0.0.1:55678", "10.0.0.2:55678", "10.0.0.3:55678", "10.0.0.4:55678"}
+	ring := newHashRing(endpoints)
+	traceIDs := randomTraceIDs(10000)
+
+	counts := make(map[string]int)
+	for _, traceID := range traceIDs {
+		counts[ring.endpointFor(traceID)]++
+	}
+	for _, endpoint := range endpoints {
+		// Each endpoint should get about a quarter of the traces.
+		if counts[endpoint] < 1500 || counts[endpoint] > 3500 {
+			t.Errorf("endpoint %s got %d of %d traces", endpoint, counts[endpoint], len(traceIDs))
+		}
+	}
+}
+
+func TestHashRing_MinimalRebalancing(t *testing.T) {
+	var endpoints []string
+	for i := 1; i <= 5; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("10.0.0.%d:55678", i))
+	}
+	before := newHashRing(endpoints)
+	after := newHashRing(append(endpoints, "10.0.0.6:55678"))
+	traceIDs := randomTraceIDs(10000)
+
+	moved := 0
+	for _, traceID := range traceIDs {
+		from, to := before.endpointFor(traceID), after.endpointFor(traceID)
+		if from != to {
+			moved++
+			if to != "10.0.0.6:55678" {
+				t.Fatalf("trace moved from %s to %s instead of the new endpoint", from, to)
+			}
+		}
+	}
+	// About a sixth of the traces should move to the new endpoint.
+	if moved < 1000 || moved > 2500 {
+		t.Errorf("%d of %d traces moved to the new endpoint", moved, len(traceIDs))
+	}
+}
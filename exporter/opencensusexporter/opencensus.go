@@ -63,28 +63,17 @@ var (
 // OpenCensusTraceExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
 // OpenCensus Agent/Collector according to the configuration settings.
 func OpenCensusTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		OpenCensus *opencensusConfig `mapstructure:"opencensus"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	ocac, err := opencensusConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-	ocac := cfg.OpenCensus
 	if ocac == nil {
 		return nil, nil, nil, nil
 	}
 
-	if ocac.Endpoint == "" {
-		return nil, nil, nil, ErrEndpointRequired
-	}
-
 	opts := []ocagent.ExporterOption{ocagent.WithAddress(ocac.Endpoint)}
 	if ocac.Compression != "" {
-		if compressionKey := grpc.GetGRPCCompressionKey(ocac.Compression); compressionKey != compression.Unsupported {
-			opts = append(opts, ocagent.UseCompressor(compressionKey))
-		} else {
-			return nil, nil, nil, ErrUnsupportedCompressionType
-		}
+		opts = append(opts, ocagent.UseCompressor(grpc.GetGRPCCompressionKey(ocac.Compression)))
 	}
 	if ocac.CertPemFile != "" {
 		creds, err := credentials.NewClientTLSFromFile(ocac.CertPemFile, "")
@@ -135,6 +124,43 @@ func OpenCensusTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceCons
 	return
 }
 
+// opencensusConfigFromViper returns the configuration of the OpenCensus exporter, or nil if there
+// is none. It checks the settings that do not require reading files.
+func opencensusConfigFromViper(v *viper.Viper) (*opencensusConfig, error) {
+	var cfg struct {
+		OpenCensus *opencensusConfig `mapstructure:"opencensus"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	ocac := cfg.OpenCensus
+	if ocac == nil {
+		return nil, nil
+	}
+	if ocac.Endpoint == "" {
+		return nil, ErrEndpointRequired
+	}
+	if ocac.Compression != "" && grpc.GetGRPCCompressionKey(ocac.Compression) == compression.Unsupported {
+		return nil, ErrUnsupportedCompressionType
+	}
+	return ocac, nil
+}
+
+// validateOpenCensusConfig checks the configuration of the OpenCensus exporter, including
+// that its TLS credentials can be read, without connecting to the endpoint.
+func validateOpenCensusConfig(v *viper.Viper) error {
+	ocac, err := opencensusConfigFromViper(v)
+	if err != nil || ocac == nil {
+		return err
+	}
+	if ocac.CertPemFile != "" {
+		if _, err := credentials.NewClientTLSFromFile(ocac.CertPemFile, ""); err != nil {
+			return ErrUnableToGetTLSCreds
+		}
+	}
+	return nil
+}
+
 func (oce *ocagentExporter) PushTraceData(ctx context.Context, td data.TraceData) (int, error) {
 	// Get an exporter worker round-robin
 	exporter := oce.exporters[atomic.AddUint32(&oce.counter, 1)%uint32(len(oce.exporters))]
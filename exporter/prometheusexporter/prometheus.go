@@ -49,22 +49,11 @@ var errBlankPrometheusAddress = errors.New("expecting a non-blank address to run
 // targeting Prometheus according to the configuration settings.
 // It allows HTTP clients to scrape it on endpoint path "/metrics".
 func PrometheusExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Prometheus *prometheusConfig `mapstructure:"prometheus"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	pcfg, err := prometheusConfigFromViper(v)
+	if err != nil || pcfg == nil {
 		return nil, nil, nil, err
 	}
-	if cfg.Prometheus == nil {
-		return nil, nil, nil, nil
-	}
-
-	pcfg := cfg.Prometheus
 	addr := strings.TrimSpace(pcfg.Address)
-	if addr == "" {
-		err = errBlankPrometheusAddress
-		return
-	}
 
 	opts := prometheus.Options{
 		Namespace:   pcfg.Namespace,
@@ -97,6 +86,21 @@ func PrometheusExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer,
 	return
 }
 
+// prometheusConfigFromViper returns the configuration of the Prometheus exporter, or nil
+// if there is none.
+func prometheusConfigFromViper(v *viper.Viper) (*prometheusConfig, error) {
+	var cfg struct {
+		Prometheus *prometheusConfig `mapstructure:"prometheus"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Prometheus != nil && strings.TrimSpace(cfg.Prometheus.Address) == "" {
+		return nil, errBlankPrometheusAddress
+	}
+	return cfg.Prometheus, nil
+}
+
 type prometheusExporter struct {
 	exporter *prometheus.Exporter
 }
@@ -0,0 +1,110 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The registries hold the factories of all exporters linked in the binary. Exporter
+// packages are expected to register their factories from an init function, so a
+// binary only needs to import an exporter package to make it available.
+var (
+	registryMu               sync.RWMutex
+	traceExporterFactories   = make(map[string]TraceExporterFactory)
+	metricsExporterFactories = make(map[string]MetricsExporterFactory)
+)
+
+// RegisterTraceExporterFactory makes a TraceExporterFactory available by its type.
+// If RegisterTraceExporterFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterTraceExporterFactory(factory TraceExporterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("exporter: RegisterTraceExporterFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := traceExporterFactories[typeStr]; dup {
+		panic(fmt.Sprintf("exporter: RegisterTraceExporterFactory called twice for type %q", typeStr))
+	}
+	traceExporterFactories[typeStr] = factory
+}
+
+// RegisterMetricsExporterFactory makes a MetricsExporterFactory available by its type.
+// If RegisterMetricsExporterFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterMetricsExporterFactory(factory MetricsExporterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("exporter: RegisterMetricsExporterFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := metricsExporterFactories[typeStr]; dup {
+		panic(fmt.Sprintf("exporter: RegisterMetricsExporterFactory called twice for type %q", typeStr))
+	}
+	metricsExporterFactories[typeStr] = factory
+}
+
+// GetTraceExporterFactory returns the registered TraceExporterFactory for the given
+// type, or nil if there is none.
+func GetTraceExporterFactory(typeStr string) TraceExporterFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return traceExporterFactories[typeStr]
+}
+
+// GetMetricsExporterFactory returns the registered MetricsExporterFactory for the given
+// type, or nil if there is none.
+func GetMetricsExporterFactory(typeStr string) MetricsExporterFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return metricsExporterFactories[typeStr]
+}
+
+// TraceExporterFactories returns all registered TraceExporterFactory sorted by type.
+func TraceExporterFactories() []TraceExporterFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]TraceExporterFactory, 0, len(traceExporterFactories))
+	for _, factory := range traceExporterFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
+
+// MetricsExporterFactories returns all registered MetricsExporterFactory sorted by type.
+func MetricsExporterFactories() []MetricsExporterFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]MetricsExporterFactory, 0, len(metricsExporterFactories))
+	for _, factory := range metricsExporterFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
@@ -0,0 +1,121 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestTraceExporterFactoryRegistry(t *testing.T) {
+	defer resetRegistries()
+
+	RegisterTraceExporterFactory(&fakeTraceExporterFactory{typeStr: "b"})
+	RegisterTraceExporterFactory(&fakeTraceExporterFactory{typeStr: "a"})
+
+	if f := GetTraceExporterFactory("a"); f == nil || f.Type() != "a" {
+		t.Fatalf("GetTraceExporterFactory(\"a\") = %v", f)
+	}
+	if f := GetTraceExporterFactory("c"); f != nil {
+		t.Fatalf("GetTraceExporterFactory(\"c\") = %v, want nil", f)
+	}
+
+	factories := TraceExporterFactories()
+	if len(factories) != 2 || factories[0].Type() != "a" || factories[1].Type() != "b" {
+		t.Fatalf("TraceExporterFactories() = %v, want factories sorted by type", factories)
+	}
+}
+
+func TestTraceExporterFactoryRegistryPanics(t *testing.T) {
+	defer resetRegistries()
+
+	RegisterTraceExporterFactory(&fakeTraceExporterFactory{typeStr: "a"})
+	tests := []struct {
+		name    string
+		factory TraceExporterFactory
+	}{
+		{name: "nil", factory: nil},
+		{name: "duplicate", factory: &fakeTraceExporterFactory{typeStr: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("RegisterTraceExporterFactory should panic")
+				}
+			}()
+			RegisterTraceExporterFactory(tt.factory)
+		})
+	}
+}
+
+func TestMetricsExporterFactoryRegistry(t *testing.T) {
+	defer resetRegistries()
+
+	RegisterMetricsExporterFactory(&fakeMetricsExporterFactory{typeStr: "a"})
+	if f := GetMetricsExporterFactory("a"); f == nil || f.Type() != "a" {
+		t.Fatalf("GetMetricsExporterFactory(\"a\") = %v", f)
+	}
+	if factories := MetricsExporterFactories(); len(factories) != 1 {
+		t.Fatalf("MetricsExporterFactories() = %v, want 1 factory", factories)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("RegisterMetricsExporterFactory should panic on duplicate type")
+		}
+	}()
+	RegisterMetricsExporterFactory(&fakeMetricsExporterFactory{typeStr: "a"})
+}
+
+func resetRegistries() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	traceExporterFactories = make(map[string]TraceExporterFactory)
+	metricsExporterFactories = make(map[string]MetricsExporterFactory)
+}
+
+type fakeTraceExporterFactory struct {
+	typeStr string
+}
+
+func (f *fakeTraceExporterFactory) Type() string {
+	return f.typeStr
+}
+
+func (f *fakeTraceExporterFactory) NewFromViper(cfg *viper.Viper) (TraceExporter, error) {
+	return nil, nil
+}
+
+func (f *fakeTraceExporterFactory) DefaultConfig() *viper.Viper {
+	return viper.New()
+}
+
+type fakeMetricsExporterFactory struct {
+	typeStr string
+}
+
+func (f *fakeMetricsExporterFactory) Type() string {
+	return f.typeStr
+}
+
+func (f *fakeMetricsExporterFactory) NewFromViper(cfg *viper.Viper) (MetricsExporter, error) {
+	return nil, nil
+}
+
+func (f *fakeMetricsExporterFactory) DefaultConfig() *viper.Viper {
+	return viper.New()
+}
@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stackdriverexporter
+
+import (
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+)
+
+// TypeStr is the type of the Stackdriver exporter, it is also the key of its
+// configuration in the "exporters" section.
+const TypeStr = "stackdriver"
+
+func init() {
+	exporter.RegisterTraceExporterFactory(exporterhelper.NewTraceExporterFactory(
+		TypeStr,
+		sharedExportersFromViper,
+		exporterhelper.WithConfigValidator(validateConfig),
+	))
+	exporter.RegisterMetricsExporterFactory(exporterhelper.NewMetricsExporterFactory(
+		TypeStr,
+		sharedExportersFromViper,
+		exporterhelper.WithConfigValidator(validateConfig),
+	))
+}
+
+// validateConfig checks that the configuration can be parsed, as done when creating
+// the exporter.
+func validateConfig(v *viper.Viper) error {
+	_, err := stackdriverConfigFromViper(v)
+	return err
+}
+
+// sharedExporters holds the exporters created by the trace and the metrics factories
+// for each configuration, so that a configuration enabling both signals creates a
+// single Stackdriver exporter.
+var sharedExporters = struct {
+	sync.Mutex
+	byConfig map[stackdriverConfig]*sharedExporter
+}{byConfig: make(map[stackdriverConfig]*sharedExporter)}
+
+type sharedExporter struct {
+	tps     []consumer.TraceConsumer
+	mps     []consumer.MetricsConsumer
+	doneFns []func() error
+	refs    int
+}
+
+// sharedExportersFromViper is like StackdriverTraceExportersFromViper but returns the
+// exporter already created for the same configuration, if any. The exporter is stopped
+// once the returned done function was called by all its users.
+func sharedExportersFromViper(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+	sc, err := stackdriverConfigFromViper(v)
+	if err != nil || sc == nil {
+		return StackdriverTraceExportersFromViper(v)
+	}
+
+	sharedExporters.Lock()
+	defer sharedExporters.Unlock()
+	se, ok := sharedExporters.byConfig[*sc]
+	if !ok {
+		tps, mps, doneFns, err := StackdriverTraceExportersFromViper(v)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		se = &sharedExporter{tps: tps, mps: mps, doneFns: doneFns}
+		sharedExporters.byConfig[*sc] = se
+	}
+	se.refs++
+	key := *sc
+	var releaseOnce sync.Once
+	release := func() (err error) {
+		releaseOnce.Do(func() {
+			err = releaseSharedExporter(key, se)
+		})
+		return err
+	}
+	return se.tps, se.mps, []func() error{release}, nil
+}
+
+func releaseSharedExporter(key stackdriverConfig, se *sharedExporter) error {
+	sharedExporters.Lock()
+	se.refs--
+	if se.refs > 0 {
+		sharedExporters.Unlock()
+		return nil
+	}
+	delete(sharedExporters.byConfig, key)
+	sharedExporters.Unlock()
+
+	var err error
+	for _, doneFn := range se.doneFns {
+		if derr := doneFn(); derr != nil && err == nil {
+			err = derr
+		}
+	}
+	return err
+}
@@ -46,13 +46,10 @@ var _ consumer.MetricsConsumer = (*stackdriverExporter)(nil)
 // StackdriverTraceExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
 // Stackdriver according to the configuration settings.
 func StackdriverTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Stackdriver *stackdriverConfig `mapstructure:"stackdriver"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	sc, err := stackdriverConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-	sc := cfg.Stackdriver
 	if sc == nil {
 		return nil, nil, nil, nil
 	}
@@ -111,6 +108,18 @@ func StackdriverTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceCon
 	return
 }
 
+// stackdriverConfigFromViper returns the configuration of the Stackdriver exporter, or nil if there
+// is none.
+func stackdriverConfigFromViper(v *viper.Viper) (*stackdriverConfig, error) {
+	var cfg struct {
+		Stackdriver *stackdriverConfig `mapstructure:"stackdriver"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Stackdriver, nil
+}
+
 func (sde *stackdriverExporter) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
 	ctx, span := trace.StartSpan(ctx,
 		"opencensus.service.exporter.stackdriver.ExportMetricsData",
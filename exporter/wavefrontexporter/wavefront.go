@@ -37,14 +37,10 @@ type wavefrontConfig struct {
 
 // WavefrontTraceExportersFromViper unmarshals the viper and returns trace and metric consumers.
 func WavefrontTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Wavefront *wavefrontConfig `mapstructure:"wavefront,omitempty"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	wc, err := wavefrontConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	wc := cfg.Wavefront
 	if wc == nil {
 		return nil, nil, nil, nil
 	}
@@ -89,3 +85,15 @@ func WavefrontTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsu
 
 	return
 }
+
+// wavefrontConfigFromViper returns the configuration of the Wavefront exporter, or nil if there
+// is none.
+func wavefrontConfigFromViper(v *viper.Viper) (*wavefrontConfig, error) {
+	var cfg struct {
+		Wavefront *wavefrontConfig `mapstructure:"wavefront,omitempty"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Wavefront, nil
+}
@@ -80,14 +80,10 @@ func (zc *ZipkinConfig) EndpointURL() string {
 // ZipkinExportersFromViper unmarshals the viper and returns an exporter.TraceExporter targeting
 // Zipkin according to the configuration settings.
 func ZipkinExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
-	var cfg struct {
-		Zipkin *ZipkinConfig `mapstructure:"zipkin"`
-	}
-	if err := v.Unmarshal(&cfg); err != nil {
+	zc, err := zipkinConfigFromViper(v)
+	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	zc := cfg.Zipkin
 	if zc == nil {
 		return nil, nil, nil, nil
 	}
@@ -114,6 +110,18 @@ func ZipkinExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 	return
 }
 
+// zipkinConfigFromViper returns the configuration of the Zipkin exporter, or nil if there
+// is none.
+func zipkinConfigFromViper(v *viper.Viper) (*ZipkinConfig, error) {
+	var cfg struct {
+		Zipkin *ZipkinConfig `mapstructure:"zipkin"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Zipkin, nil
+}
+
 func newZipkinExporter(finalEndpointURI, defaultServiceName, defaultLocalEndpointURI string, uploadPeriod time.Duration) (*zipkinExporter, error) {
 	var opts []zipkinhttp.ReporterOption
 	if uploadPeriod > 0 {
@@ -222,7 +230,7 @@ func (ze *zipkinExporter) ConsumeTraceData(ctx context.Context, td data.TraceDat
 	}
 
 	// And finally record metrics on the number of exported spans.
-	observability.RecordTraceExporterMetrics(observability.ContextWithExporterName(ctx, "zipkin"), len(td.Spans), len(td.Spans)-goodSpans)
+	observability.RecordTraceExporterMetrics(observability.ContextWithDefaultExporterName(ctx, "zipkin"), len(td.Spans), len(td.Spans)-goodSpans)
 
 	return nil
 }
@@ -25,39 +25,39 @@ package jaegerreceiver
 import (
 	"context"
 
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
 	"github.com/census-instrumentation/opencensus-service/receiver/jaegerreceiver"
 )
 
-// Start starts the Jaeger receiver endpoint.
-func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
-	rOpts, err := builder.NewDefaultJaegerReceiverCfg().InitFromViper(v)
-	if err != nil {
-		return nil, err
-	}
-
-	ctx := context.Background()
-	config := &jaegerreceiver.Configuration{
-		CollectorThriftPort: rOpts.ThriftTChannelPort,
-		CollectorHTTPPort:   rOpts.ThriftHTTPPort,
-	}
-	jtr, err := jaegerreceiver.New(ctx, config, traceConsumer)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := jtr.StartTraceReception(ctx, asyncErrorChan); err != nil {
-		return nil, err
-	}
-
-	logger.Info("Jaeger receiver is running.",
-		zap.Int("thrift-tchannel-port", rOpts.ThriftTChannelPort),
-		zap.Int("thrift-http-port", rOpts.ThriftHTTPPort))
-
-	return jtr, nil
+// TypeStr is the type of the Jaeger receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "jaeger"
+
+func init() {
+	receiver.RegisterTraceReceiverFactory(NewTraceReceiverFactory())
+}
+
+// NewTraceReceiverFactory creates a factory for Jaeger receivers using the same
+// configuration settings of the "receivers" section of the collector.
+func NewTraceReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		TypeStr,
+		func() interface{} { return builder.NewDefaultJaegerReceiverCfg() },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rOpts := cfg.(*builder.JaegerReceiverCfg)
+			config := &jaegerreceiver.Configuration{
+				CollectorThriftPort: rOpts.ThriftTChannelPort,
+				CollectorHTTPPort:   rOpts.ThriftHTTPPort,
+
+				SamplingStrategiesFile:  rOpts.SamplingStrategiesFile,
+				BaggageRestrictionsFile: rOpts.BaggageRestrictionsFile,
+			}
+			return jaegerreceiver.New(context.Background(), config, next)
+		})
+	return f
 }
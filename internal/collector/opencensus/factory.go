@@ -0,0 +1,54 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocreceiver
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
+)
+
+// TypeStr is the type of the OpenCensus receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "opencensus"
+
+func init() {
+	receiver.RegisterTraceReceiverFactory(NewTraceReceiverFactory())
+}
+
+// NewTraceReceiverFactory creates a factory for OpenCensus trace receivers using the
+// same configuration settings of the "receivers" section of the collector.
+func NewTraceReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		TypeStr,
+		func() interface{} { return builder.NewDefaultOpenCensusReceiverCfg() },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rOpts := cfg.(*builder.OpenCensusReceiverCfg)
+			tlsCredsOption, _, err := rOpts.TLSCredentials.ToOpenCensusReceiverServerOption()
+			if err != nil {
+				return nil, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
+			}
+			addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
+			return opencensusreceiver.New(addr, next, nil, tlsCredsOption)
+		})
+	return f
+}
@@ -30,37 +30,65 @@ import (
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
 )
 
-// Start starts the OpenCensus receiver endpoint.
-func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
+// Start starts the OpenCensus receiver endpoint. Traces and metrics are received on the
+// same endpoint, either of them can be disabled via configuration in which case the
+// corresponding returned receiver is nil.
+func Start(
+	logger *zap.Logger,
+	v *viper.Viper,
+	traceConsumer consumer.TraceConsumer,
+	metricsConsumer consumer.MetricsConsumer,
+	asyncErrorChan chan<- error,
+) (receiver.TraceReceiver, receiver.MetricsReceiver, error) {
 	rOpts, err := builder.NewDefaultOpenCensusReceiverCfg().InitFromViper(v)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if rOpts.DisableTracing && rOpts.DisableMetrics {
+		return nil, nil, fmt.Errorf("OpenCensus receiver has both tracing and metrics disabled")
 	}
 
 	tlsCredsOption, hasTLSCreds, err := rOpts.TLSCredentials.ToOpenCensusReceiverServerOption()
 	if err != nil {
-		return nil, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
+		return nil, nil, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
 	}
 
 	addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
-	ocr, err := opencensusreceiver.New(addr, traceConsumer, nil, tlsCredsOption)
+	ocr, err := opencensusreceiver.New(addr, traceConsumer, metricsConsumer, tlsCredsOption)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create the OpenCensus trace receiver: %v", err)
+		return nil, nil, fmt.Errorf("Failed to create the OpenCensus receiver: %v", err)
+	}
+
+	var traceReceiver receiver.TraceReceiver
+	if !rOpts.DisableTracing {
+		if err := ocr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+			return nil, nil, fmt.Errorf("Cannot bind Opencensus receiver to address %q: %v", addr, err)
+		}
+		traceReceiver = ocr
 	}
 
-	if err := ocr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("Cannot bind Opencensus receiver to address %q: %v", addr, err)
+	var metricsReceiver receiver.MetricsReceiver
+	if !rOpts.DisableMetrics {
+		if err := ocr.StartMetricsReception(context.Background(), asyncErrorChan); err != nil {
+			return nil, nil, fmt.Errorf("Cannot bind Opencensus receiver to address %q: %v", addr, err)
+		}
+		metricsReceiver = ocr
 	}
 
 	if hasTLSCreds {
 		tlsCreds := rOpts.TLSCredentials
 		logger.Info("OpenCensus receiver is running.",
 			zap.Int("port", rOpts.Port),
+			zap.Bool("traces", traceReceiver != nil),
+			zap.Bool("metrics", metricsReceiver != nil),
 			zap.String("cert_file", tlsCreds.CertFile),
 			zap.String("key_file", tlsCreds.KeyFile))
 	} else {
-		logger.Info("OpenCensus receiver is running.", zap.Int("port", rOpts.Port))
+		logger.Info("OpenCensus receiver is running.",
+			zap.Int("port", rOpts.Port),
+			zap.Bool("traces", traceReceiver != nil),
+			zap.Bool("metrics", metricsReceiver != nil))
 	}
 
-	return ocr, nil
+	return traceReceiver, metricsReceiver, nil
 }
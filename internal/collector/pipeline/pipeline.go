@@ -0,0 +1,475 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline builds the named pipelines of the collector, connecting
+// receivers to an ordered chain of processors and to exporters, using the
+// factories of each type of component.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// Factories holds the factories used to create the components referenced by the
+// pipelines, keyed by the type of the component.
+type Factories struct {
+	Receivers  map[string]receiver.TraceReceiverFactory
+	Processors map[string]processor.TraceProcessorFactory
+	Exporters  map[string]exporter.TraceExporterFactory
+}
+
+// Pipelines holds the components created for all configured pipelines.
+type Pipelines struct {
+	logger *zap.Logger
+
+	// The names are kept in creation order so components are started and stopped
+	// in a deterministic order.
+	receiverNames []string
+	receivers     map[string]*receiverInstance
+	exporterNames []string
+	exporters     map[string]*exporterInstance
+	pipelines     map[string]*pipelineInstance
+}
+
+// receiverInstance holds a receiver and the settings used to create it. The receiver
+// sends its data to a switch so the pipelines after it can be replaced on a reload
+// without restarting the receiver.
+type receiverInstance struct {
+	receiver receiver.TraceReceiver
+	settings reload.Settings
+	next     *reload.TraceSwitch
+	consumer consumer.TraceConsumer
+	running  bool
+}
+
+// exporterInstance holds an exporter and the settings used to create it.
+type exporterInstance struct {
+	exporter exporter.TraceExporter
+	settings reload.Settings
+}
+
+// pipelineInstance holds the consumer at the head of a pipeline and everything used
+// to create it, so it can be kept on a reload if none of that changed.
+type pipelineInstance struct {
+	cfg               *builder.PipelineCfg
+	processorSettings []reload.Settings
+	exporters         []*exporterInstance
+	head              consumer.TraceConsumer
+	// shutdownFns shut down the processors of the pipeline that hold data or goroutines,
+	// e.g.: queues and batchers, in the order they must be called.
+	shutdownFns []func(context.Context) error
+}
+
+// Build creates all components referenced by the pipelines in cfg. Receivers and
+// exporters are created only once even if referenced by multiple pipelines: a
+// receiver fans out its data to all pipelines listing it and an exporter gets the
+// data of all pipelines listing it. Processors are created per pipeline. The
+// receivers are not started, use Start for that.
+func Build(logger *zap.Logger, v *viper.Viper, cfg *builder.PipelinesCfg, factories Factories) (*Pipelines, error) {
+	return build(logger, v, cfg, factories, &Pipelines{})
+}
+
+// build creates the components for cfg, reusing the components of previous whose
+// configuration did not change. On error the components created by build are stopped
+// and previous is left untouched.
+func build(logger *zap.Logger, v *viper.Viper, cfg *builder.PipelinesCfg, factories Factories, previous *Pipelines) (*Pipelines, error) {
+	p := &Pipelines{
+		logger:    logger,
+		receivers: make(map[string]*receiverInstance),
+		exporters: make(map[string]*exporterInstance),
+		pipelines: make(map[string]*pipelineInstance),
+	}
+
+	var receiverNames []string
+	receiverConsumers := make(map[string][]consumer.TraceConsumer)
+	for _, pipelineCfg := range cfg.Pipelines {
+		pi, err := p.buildPipeline(v, pipelineCfg, factories, previous)
+		if err != nil {
+			p.discard(previous)
+			return nil, err
+		}
+		p.pipelines[pipelineCfg.Name] = pi
+		for _, receiverName := range pipelineCfg.Receivers {
+			if _, ok := receiverConsumers[receiverName]; !ok {
+				receiverNames = append(receiverNames, receiverName)
+			}
+			receiverConsumers[receiverName] = append(receiverConsumers[receiverName], pi.head)
+		}
+	}
+
+	for _, receiverName := range receiverNames {
+		var next consumer.TraceConsumer
+		if consumers := receiverConsumers[receiverName]; len(consumers) == 1 {
+			next = consumers[0]
+		} else {
+			next = multiconsumer.NewTraceProcessor(consumers)
+		}
+
+		rv := builder.ReceiverViper(v, receiverName)
+		settings := settingsOf(rv)
+		if prev, ok := previous.receivers[receiverName]; ok && reflect.DeepEqual(prev.settings, settings) {
+			// Keep the receiver, it is connected to the new pipelines when the reload
+			// is applied.
+			p.receiverNames = append(p.receiverNames, receiverName)
+			p.receivers[receiverName] = &receiverInstance{
+				receiver: prev.receiver,
+				settings: settings,
+				next:     prev.next,
+				consumer: next,
+				running:  prev.running,
+			}
+			continue
+		}
+
+		factory, ok := factories.Receivers[builder.ComponentType(receiverName)]
+		if !ok {
+			p.discard(previous)
+			return nil, fmt.Errorf("unknown receiver type for %q", receiverName)
+		}
+
+		if rv == nil {
+			rv = viper.New()
+		}
+		ts := reload.NewTraceSwitch(next)
+		r, err := factory.NewFromViper(rv, ts, logger)
+		if err != nil {
+			p.discard(previous)
+			return nil, fmt.Errorf("failed to create receiver %q: %v", receiverName, err)
+		}
+		p.receiverNames = append(p.receiverNames, receiverName)
+		p.receivers[receiverName] = &receiverInstance{
+			receiver: r,
+			settings: settings,
+			next:     ts,
+			consumer: next,
+		}
+	}
+
+	return p, nil
+}
+
+// buildPipeline creates the processors and exporters of the pipeline, returning the
+// consumer that should receive the data of the pipeline receivers. If the pipeline,
+// its processors and its exporters are unchanged from previous its head is reused.
+func (p *Pipelines) buildPipeline(
+	v *viper.Viper,
+	cfg *builder.PipelineCfg,
+	factories Factories,
+	previous *Pipelines,
+) (*pipelineInstance, error) {
+	pi := &pipelineInstance{
+		cfg:       cfg,
+		exporters: make([]*exporterInstance, 0, len(cfg.Exporters)),
+	}
+	for _, exporterName := range cfg.Exporters {
+		ei, err := p.getOrCreateExporter(v, exporterName, factories, previous)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %q: %v", cfg.Name, err)
+		}
+		pi.exporters = append(pi.exporters, ei)
+	}
+	for _, processorName := range cfg.Processors {
+		pi.processorSettings = append(pi.processorSettings, settingsOf(builder.ProcessorViper(v, processorName)))
+	}
+
+	if prev, ok := previous.pipelines[cfg.Name]; ok && prev.sameChain(pi) {
+		pi.head = prev.head
+		pi.shutdownFns = prev.shutdownFns
+		p.logger.Info("Pipeline unchanged", zap.String("pipeline", cfg.Name))
+		return pi, nil
+	}
+
+	exporters := make([]consumer.TraceConsumer, 0, len(pi.exporters))
+	for _, ei := range pi.exporters {
+		exporters = append(exporters, ei.exporter)
+	}
+
+	var next processor.TraceProcessor
+	if len(exporters) == 1 {
+		next = exporters[0]
+	} else {
+		next = multiconsumer.NewTraceProcessor(exporters)
+	}
+
+	// Processors are chained starting from the last one so the data goes through them
+	// in the order they are listed.
+	for i := len(cfg.Processors) - 1; i >= 0; i-- {
+		processorName := cfg.Processors[i]
+		factory, ok := factories.Processors[builder.ComponentType(processorName)]
+		if !ok {
+			pi.shutdownProcessors(context.Background())
+			return nil, fmt.Errorf("pipeline %q: unknown processor type for %q", cfg.Name, processorName)
+		}
+
+		pv := builder.ProcessorViper(v, processorName)
+		if pv == nil {
+			pv = factory.DefaultConfig()
+		}
+		tp, err := factory.NewFromViper(pv, next)
+		if err != nil {
+			pi.shutdownProcessors(context.Background())
+			return nil, fmt.Errorf("pipeline %q: failed to create processor %q: %v", cfg.Name, processorName, err)
+		}
+		if shutdownFn := processor.ShutdownFunc(tp); shutdownFn != nil {
+			// The processors closer to the receivers are shut down first, so the data
+			// they flush reaches the ones after them.
+			pi.shutdownFns = append([]func(context.Context) error{shutdownFn}, pi.shutdownFns...)
+		}
+		next = tp
+	}
+
+	p.logger.Info("Pipeline created",
+		zap.String("pipeline", cfg.Name),
+		zap.Strings("receivers", cfg.Receivers),
+		zap.Strings("processors", cfg.Processors),
+		zap.Strings("exporters", cfg.Exporters))
+	pi.head = next
+	return pi, nil
+}
+
+// shutdownProcessors shuts down the processors of the pipeline in order, returning the
+// errors reporting the data they lost.
+func (pi *pipelineInstance) shutdownProcessors(ctx context.Context) []error {
+	var errs []error
+	for _, shutdownFn := range pi.shutdownFns {
+		if err := shutdownFn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %q: %v", pi.cfg.Name, err))
+		}
+	}
+	return errs
+}
+
+// sameChain checks if other has the same processors, with the same settings, and
+// the very same exporter instances as pi.
+func (pi *pipelineInstance) sameChain(other *pipelineInstance) bool {
+	if !reflect.DeepEqual(pi.cfg.Processors, other.cfg.Processors) ||
+		!reflect.DeepEqual(pi.processorSettings, other.processorSettings) ||
+		len(pi.exporters) != len(other.exporters) {
+		return false
+	}
+	for i := range pi.exporters {
+		if pi.exporters[i] != other.exporters[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pipelines) getOrCreateExporter(
+	v *viper.Viper,
+	exporterName string,
+	factories Factories,
+	previous *Pipelines,
+) (*exporterInstance, error) {
+	if ei, ok := p.exporters[exporterName]; ok {
+		return ei, nil
+	}
+
+	ev := builder.ExporterViper(v, exporterName)
+	settings := settingsOf(ev)
+	if prev, ok := previous.exporters[exporterName]; ok && reflect.DeepEqual(prev.settings, settings) {
+		p.exporterNames = append(p.exporterNames, exporterName)
+		p.exporters[exporterName] = prev
+		return prev, nil
+	}
+
+	factory, ok := factories.Exporters[builder.ComponentType(exporterName)]
+	if !ok {
+		return nil, fmt.Errorf("unknown exporter type for %q", exporterName)
+	}
+
+	if ev == nil {
+		ev = factory.DefaultConfig()
+	}
+	te, err := factory.NewFromViper(ev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter %q: %v", exporterName, err)
+	}
+	if exporterName != factory.Type() {
+		te = exporterhelper.NewNamedTraceExporter(exporterName, te)
+	}
+
+	ei := &exporterInstance{exporter: te, settings: settings}
+	p.exporterNames = append(p.exporterNames, exporterName)
+	p.exporters[exporterName] = ei
+	p.logger.Info("Trace Exporter enabled", zap.String("exporter", exporterName))
+	return ei, nil
+}
+
+// Start starts all receivers of the pipelines. If any receiver fails to start the
+// ones already started are stopped.
+func (p *Pipelines) Start(asyncErrorChan chan<- error) error {
+	var started []*receiverInstance
+	for _, receiverName := range p.receiverNames {
+		ri := p.receivers[receiverName]
+		if err := ri.receiver.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+			for _, sri := range started {
+				sri.receiver.StopTraceReception(context.Background())
+				sri.running = false
+			}
+			return fmt.Errorf("cannot start receiver %q: %v", receiverName, err)
+		}
+		p.logger.Info("Receiver is running.", zap.String("receiver", receiverName))
+		ri.running = true
+		started = append(started, ri)
+	}
+	return nil
+}
+
+// Reload applies cfg to the running pipelines. Only the components whose configuration
+// changed are recreated: receivers with unchanged settings keep running and are
+// connected to the new pipelines, pipelines whose processors and exporters did not
+// change are kept as they are, and so are exporters with unchanged settings. If any
+// component fails to be created the running pipelines are not modified. The replaced
+// pipelines are shut down by retirer.
+func (p *Pipelines) Reload(v *viper.Viper, cfg *builder.PipelinesCfg, factories Factories, asyncErrorChan chan<- error, retirer *reload.Retirer) error {
+	next, err := build(p.logger, v, cfg, factories, p)
+	if err != nil {
+		return err
+	}
+
+	for _, receiverName := range p.receiverNames {
+		ri := p.receivers[receiverName]
+		if nri, ok := next.receivers[receiverName]; ok && nri.receiver == ri.receiver {
+			nri.next.Swap(nri.consumer)
+			continue
+		}
+		if ri.running {
+			ri.receiver.StopTraceReception(context.Background())
+			p.logger.Info("Receiver stopped.", zap.String("receiver", receiverName))
+		}
+	}
+
+	var startErr error
+	for _, receiverName := range next.receiverNames {
+		ri := next.receivers[receiverName]
+		if ri.running {
+			continue
+		}
+		if err := ri.receiver.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+			if startErr == nil {
+				startErr = fmt.Errorf("cannot start receiver %q: %v", receiverName, err)
+			}
+			continue
+		}
+		p.logger.Info("Receiver is running.", zap.String("receiver", receiverName))
+		ri.running = true
+	}
+
+	// The replaced pipelines may still hold data, so they and the exporters after them
+	// are shut down later.
+	var retired []*pipelineInstance
+	for name, pi := range p.pipelines {
+		if npi, ok := next.pipelines[name]; !ok || npi.head != pi.head {
+			retired = append(retired, pi)
+		}
+	}
+	if len(retired) > 0 {
+		old := *p
+		retirer.Retire(func(ctx context.Context) {
+			var errs []error
+			for _, pi := range retired {
+				errs = append(errs, pi.shutdownProcessors(ctx)...)
+			}
+			errs = append(errs, old.shutdownExportersNotIn(ctx, next)...)
+			old.logErrors(errs)
+		})
+	} else {
+		p.logErrors(p.shutdownExportersNotIn(context.Background(), next))
+	}
+	*p = *next
+	return startErr
+}
+
+// Shutdown stops all receivers, then shuts down the processors of each pipeline and
+// finally all exporters, so data already in the pipelines has a chance to be flushed.
+// The processors and exporters have until ctx is done to send the data they hold, the
+// returned error reports the data lost.
+func (p *Pipelines) Shutdown(ctx context.Context) error {
+	for _, receiverName := range p.receiverNames {
+		ri := p.receivers[receiverName]
+		ri.receiver.StopTraceReception(context.Background())
+		ri.running = false
+	}
+	var errs []error
+	for _, pi := range p.pipelines {
+		errs = append(errs, pi.shutdownProcessors(ctx)...)
+	}
+	errs = append(errs, p.shutdownExportersNotIn(ctx, &Pipelines{})...)
+	return internal.CombineErrors(errs)
+}
+
+// discard shuts down the processors and exporters created for p that are not used by
+// previous, it is called when p fails to be built.
+func (p *Pipelines) discard(previous *Pipelines) {
+	var errs []error
+	for name, pi := range p.pipelines {
+		if ppi, ok := previous.pipelines[name]; !ok || ppi.head != pi.head {
+			errs = append(errs, pi.shutdownProcessors(context.Background())...)
+		}
+	}
+	errs = append(errs, p.shutdownExportersNotIn(context.Background(), previous)...)
+	p.logErrors(errs)
+}
+
+// shutdownExportersNotIn shuts down the exporters of p that are not used by other.
+func (p *Pipelines) shutdownExportersNotIn(ctx context.Context, other *Pipelines) []error {
+	var errs []error
+	for _, exporterName := range p.exporterNames {
+		ei := p.exporters[exporterName]
+		if other.exporters[exporterName] == ei {
+			continue
+		}
+		var err error
+		switch e := ei.exporter.(type) {
+		case consumer.Shutdowner:
+			err = e.Shutdown(ctx)
+		case exporterhelper.Stopper:
+			err = exporterhelper.StopWithin(ctx, e.Stop)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop exporter %q: %v", exporterName, err))
+		}
+	}
+	return errs
+}
+
+func (p *Pipelines) logErrors(errs []error) {
+	for _, err := range errs {
+		p.logger.Warn("Failed to shut down", zap.Error(err))
+	}
+}
+
+// settingsOf returns the settings of v, or nil if there is no configuration.
+func settingsOf(v *viper.Viper) reload.Settings {
+	if v == nil {
+		return nil
+	}
+	return v.AllSettings()
+}
@@ -0,0 +1,481 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/reload"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+const pipelinesConfig = `
+receivers:
+  fake/zipkin:
+    id: zipkin
+  fake/oc:
+    id: oc
+processors:
+  add-attributes/pii:
+    values:
+      scrubbed: true
+exporters:
+  sink/primary:
+    id: primary
+  sink/secondary:
+    id: secondary
+pipelines:
+  zipkin:
+    receivers: [fake/zipkin]
+    processors: [add-attributes/pii]
+    exporters: [sink/primary]
+  oc:
+    receivers: [fake/oc, fake/zipkin]
+    exporters: [sink/secondary]
+`
+
+func TestBuild(t *testing.T) {
+	v := loadConfig(t, pipelinesConfig)
+	cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+
+	rf := &fakeReceiverFactory{receivers: make(map[string]*fakeReceiver)}
+	ef := &sinkExporterFactory{exporters: make(map[string]*exportertest.SinkTraceExporter)}
+	factories := Factories{
+		Receivers:  map[string]receiver.TraceReceiverFactory{"fake": rf},
+		Processors: map[string]processor.TraceProcessorFactory{addattributesprocessor.TypeStr: addattributesprocessor.NewTraceProcessorFactory()},
+		Exporters:  map[string]exporter.TraceExporterFactory{"sink": ef},
+	}
+
+	p, err := Build(zap.NewNop(), v, cfg, factories)
+	if err != nil {
+		t.Fatalf("Build() = %v", err)
+	}
+	if len(p.receivers) != 2 {
+		t.Fatalf("got %d receivers, want 2", len(p.receivers))
+	}
+	if len(p.exporters) != 2 {
+		t.Fatalf("got %d exporters, want 2", len(p.exporters))
+	}
+
+	if err := p.Start(make(chan error)); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	for name, r := range rf.receivers {
+		if !r.started {
+			t.Errorf("receiver %q was not started", name)
+		}
+	}
+
+	// Data from "fake/zipkin" goes to both pipelines, data from "fake/oc" only to the
+	// "oc" pipeline.
+	rf.receivers["zipkin"].send(t)
+	rf.receivers["oc"].send(t)
+
+	primary := ef.exporters["primary"].AllTraces()
+	if len(primary) != 1 {
+		t.Fatalf("primary exporter got %d batches, want 1", len(primary))
+	}
+	if attrs := primary[0].Spans[0].Attributes; attrs == nil || attrs.AttributeMap["scrubbed"] == nil {
+		t.Errorf("primary exporter got spans without the attribute added by the processor")
+	}
+	if secondary := ef.exporters["secondary"].AllTraces(); len(secondary) != 2 {
+		t.Fatalf("secondary exporter got %d batches, want 2", len(secondary))
+	}
+
+	p.Shutdown(context.Background())
+	for name, r := range rf.receivers {
+		if !r.stopped {
+			t.Errorf("receiver %q was not stopped", name)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	v := loadConfig(t, pipelinesConfig)
+	cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+
+	rf := &fakeReceiverFactory{receivers: make(map[string]*fakeReceiver)}
+	ef := &sinkExporterFactory{exporters: make(map[string]*exportertest.SinkTraceExporter)}
+	factories := Factories{
+		Receivers:  map[string]receiver.TraceReceiverFactory{"fake": rf},
+		Processors: map[string]processor.TraceProcessorFactory{addattributesprocessor.TypeStr: addattributesprocessor.NewTraceProcessorFactory()},
+		Exporters:  map[string]exporter.TraceExporterFactory{"sink": ef},
+	}
+
+	p, err := Build(zap.NewNop(), v, cfg, factories)
+	if err != nil {
+		t.Fatalf("Build() = %v", err)
+	}
+	if err := p.Start(make(chan error)); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	primary := ef.exporters["primary"]
+
+	// Change the settings of "fake/oc" and "sink/secondary", everything else is kept.
+	const reloadedConfig = `
+receivers:
+  fake/zipkin:
+    id: zipkin
+  fake/oc:
+    id: oc-reloaded
+processors:
+  add-attributes/pii:
+    values:
+      scrubbed: true
+exporters:
+  sink/primary:
+    id: primary
+  sink/secondary:
+    id: secondary-reloaded
+pipelines:
+  zipkin:
+    receivers: [fake/zipkin]
+    processors: [add-attributes/pii]
+    exporters: [sink/primary]
+  oc:
+    receivers: [fake/oc, fake/zipkin]
+    exporters: [sink/secondary]
+`
+	v = loadConfig(t, reloadedConfig)
+	cfg, err = builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+	if err := p.Reload(v, cfg, factories, make(chan error), reload.NewRetirer(reload.RetireDelay, time.Second)); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+
+	if len(rf.receivers) != 3 {
+		t.Fatalf("got %d receivers created, want 3", len(rf.receivers))
+	}
+	if rf.receivers["zipkin"].stopped {
+		t.Errorf("unchanged receiver was stopped")
+	}
+	if !rf.receivers["oc"].stopped {
+		t.Errorf("changed receiver was not stopped")
+	}
+	if !rf.receivers["oc-reloaded"].started {
+		t.Errorf("new receiver was not started")
+	}
+	if ef.exporters["primary"] != primary {
+		t.Errorf("unchanged exporter was recreated")
+	}
+
+	rf.receivers["zipkin"].send(t)
+	rf.receivers["oc-reloaded"].send(t)
+
+	if got := len(primary.AllTraces()); got != 1 {
+		t.Errorf("primary exporter got %d batches, want 1", got)
+	}
+	if got := len(ef.exporters["secondary"].AllTraces()); got != 0 {
+		t.Errorf("replaced exporter got %d batches, want 0", got)
+	}
+	if got := len(ef.exporters["secondary-reloaded"].AllTraces()); got != 2 {
+		t.Errorf("new exporter got %d batches, want 2", got)
+	}
+
+	// A configuration that fails to build leaves the running pipelines untouched.
+	v = loadConfig(t, `
+pipelines:
+  traces:
+    receivers: [fake/zipkin]
+    exporters: [unknown]
+`)
+	cfg, err = builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+	if err := p.Reload(v, cfg, factories, make(chan error), reload.NewRetirer(reload.RetireDelay, time.Second)); err == nil {
+		t.Fatalf("Reload() should fail")
+	}
+	rf.receivers["zipkin"].send(t)
+	if got := len(primary.AllTraces()); got != 2 {
+		t.Errorf("primary exporter got %d batches, want 2", got)
+	}
+
+	p.Shutdown(context.Background())
+	for name, r := range rf.receivers {
+		if !r.stopped {
+			t.Errorf("receiver %q was not stopped", name)
+		}
+	}
+}
+
+func TestBuildUnknownComponents(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{
+			name: "unknown_receiver",
+			config: `
+pipelines:
+  traces:
+    receivers: [unknown]
+    exporters: [sink]
+`,
+		},
+		{
+			name: "unknown_processor",
+			config: `
+pipelines:
+  traces:
+    receivers: [fake]
+    processors: [unknown]
+    exporters: [sink]
+`,
+		},
+		{
+			name: "unknown_exporter",
+			config: `
+pipelines:
+  traces:
+    receivers: [fake]
+    exporters: [unknown]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := loadConfig(t, tt.config)
+			cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+			if err != nil {
+				t.Fatalf("Failed to load pipelines configuration: %v", err)
+			}
+			factories := Factories{
+				Receivers: map[string]receiver.TraceReceiverFactory{
+					"fake": &fakeReceiverFactory{receivers: make(map[string]*fakeReceiver)},
+				},
+				Exporters: map[string]exporter.TraceExporterFactory{
+					"sink": &sinkExporterFactory{exporters: make(map[string]*exportertest.SinkTraceExporter)},
+				},
+			}
+			if _, err := Build(zap.NewNop(), v, cfg, factories); err == nil {
+				t.Fatalf("Build() should fail")
+			}
+		})
+	}
+}
+
+func loadConfig(t *testing.T, config string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBufferString(config)); err != nil {
+		t.Fatalf("Failed to read configuration: %v", err)
+	}
+	return v
+}
+
+// fakeReceiverFactory keeps the created receivers by the "id" of their configuration.
+type fakeReceiverFactory struct {
+	receivers map[string]*fakeReceiver
+}
+
+var _ receiver.TraceReceiverFactory = (*fakeReceiverFactory)(nil)
+
+func (f *fakeReceiverFactory) Type() string {
+	return "fake"
+}
+
+func (f *fakeReceiverFactory) NewFromViper(v *viper.Viper, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+	r := &fakeReceiver{next: next}
+	f.receivers[v.GetString("id")] = r
+	return r, nil
+}
+
+func (f *fakeReceiverFactory) DefaultConfig() interface{} {
+	return nil
+}
+
+type fakeReceiver struct {
+	next    consumer.TraceConsumer
+	started bool
+	stopped bool
+}
+
+var _ receiver.TraceReceiver = (*fakeReceiver)(nil)
+
+func (r *fakeReceiver) TraceSource() string {
+	return "fake"
+}
+
+func (r *fakeReceiver) StartTraceReception(ctx context.Context, asyncErrorChannel chan<- error) error {
+	r.started = true
+	return nil
+}
+
+func (r *fakeReceiver) StopTraceReception(ctx context.Context) error {
+	r.stopped = true
+	return nil
+}
+
+func (r *fakeReceiver) send(t *testing.T) {
+	td := data.TraceData{
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "span"}}},
+	}
+	if err := r.next.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+}
+
+// sinkExporterFactory keeps the created exporters by the "id" of their configuration.
+type sinkExporterFactory struct {
+	exporters map[string]*exportertest.SinkTraceExporter
+}
+
+var _ exporter.TraceExporterFactory = (*sinkExporterFactory)(nil)
+
+func (f *sinkExporterFactory) Type() string {
+	return "sink"
+}
+
+func (f *sinkExporterFactory) NewFromViper(cfg *viper.Viper) (exporter.TraceExporter, error) {
+	te := &exportertest.SinkTraceExporter{}
+	f.exporters[cfg.GetString("id")] = te
+	return te, nil
+}
+
+func (f *sinkExporterFactory) DefaultConfig() *viper.Viper {
+	return viper.New()
+}
+
+func TestReloadStopsProcessors(t *testing.T) {
+	const config = `
+receivers:
+  fake/zipkin:
+    id: zipkin
+processors:
+  stoppable:
+    id: %s
+exporters:
+  sink:
+    id: sink
+pipelines:
+  traces:
+    receivers: [fake/zipkin]
+    processors: [stoppable]
+    exporters: [sink]
+`
+	rf := &fakeReceiverFactory{receivers: make(map[string]*fakeReceiver)}
+	pf := &stoppableProcessorFactory{processors: make(map[string]*stoppableProcessor)}
+	factories := Factories{
+		Receivers:  map[string]receiver.TraceReceiverFactory{"fake": rf},
+		Processors: map[string]processor.TraceProcessorFactory{"stoppable": pf},
+		Exporters:  map[string]exporter.TraceExporterFactory{"sink": &sinkExporterFactory{exporters: make(map[string]*exportertest.SinkTraceExporter)}},
+	}
+
+	v := loadConfig(t, fmt.Sprintf(config, "first"))
+	cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+	p, err := Build(zap.NewNop(), v, cfg, factories)
+	if err != nil {
+		t.Fatalf("Build() = %v", err)
+	}
+
+	v = loadConfig(t, fmt.Sprintf(config, "second"))
+	cfg, err = builder.NewDefaultPipelinesCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to load pipelines configuration: %v", err)
+	}
+	retirer := reload.NewRetirer(time.Hour, time.Second)
+	if err := p.Reload(v, cfg, factories, make(chan error), retirer); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+
+	first := pf.processors["first"]
+	if first.isStopped() {
+		t.Errorf("replaced processor was stopped before the retire delay")
+	}
+	retirer.Shutdown(context.Background())
+	if !first.isStopped() {
+		t.Errorf("replaced processor was not stopped by the retirer Shutdown")
+	}
+	if pf.processors["second"].isStopped() {
+		t.Errorf("new processor was stopped by Reload")
+	}
+
+	p.Shutdown(context.Background())
+	if !pf.processors["second"].isStopped() {
+		t.Errorf("processor was not stopped by Shutdown")
+	}
+}
+
+// stoppableProcessorFactory keeps the created processors by the "id" of their configuration.
+type stoppableProcessorFactory struct {
+	processors map[string]*stoppableProcessor
+}
+
+var _ processor.TraceProcessorFactory = (*stoppableProcessorFactory)(nil)
+
+func (f *stoppableProcessorFactory) Type() string {
+	return "stoppable"
+}
+
+func (f *stoppableProcessorFactory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	sp := &stoppableProcessor{next: next}
+	f.processors[cfg.GetString("id")] = sp
+	return sp, nil
+}
+
+func (f *stoppableProcessorFactory) DefaultConfig() *viper.Viper {
+	return viper.New()
+}
+
+type stoppableProcessor struct {
+	next consumer.TraceConsumer
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (sp *stoppableProcessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return sp.next.ConsumeTraceData(ctx, td)
+}
+
+func (sp *stoppableProcessor) Stop() {
+	sp.mu.Lock()
+	sp.stopped = true
+	sp.mu.Unlock()
+}
+
+func (sp *stoppableProcessor) isStopped() bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.stopped
+}
@@ -0,0 +1,120 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// Validate checks that all components referenced by the pipelines have a factory and
+// a valid configuration. No component is created, since receivers and exporters can
+// bind ports or connect to their backends and processors can start goroutines or open
+// their on-disk queues: only the factories implementing a ConfigValidator interface
+// check the configuration of their components.
+func Validate(v *viper.Viper, cfg *builder.PipelinesCfg, factories Factories) error {
+	var errs []error
+	checked := make(map[string]bool)
+	for _, pipelineCfg := range cfg.Pipelines {
+		for _, receiverName := range pipelineCfg.Receivers {
+			if checked[receiverName] {
+				continue
+			}
+			checked[receiverName] = true
+			if err := validateReceiver(v, receiverName, factories); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, exporterName := range pipelineCfg.Exporters {
+			if checked[exporterName] {
+				continue
+			}
+			checked[exporterName] = true
+			if err := validateExporter(v, exporterName, factories); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, processorName := range pipelineCfg.Processors {
+			if err := validateProcessor(v, processorName, factories); err != nil {
+				errs = append(errs, fmt.Errorf("pipeline %q: %v", pipelineCfg.Name, err))
+			}
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+func validateReceiver(v *viper.Viper, receiverName string, factories Factories) error {
+	factory, ok := factories.Receivers[builder.ComponentType(receiverName)]
+	if !ok {
+		return fmt.Errorf("unknown receiver type for %q", receiverName)
+	}
+	validator, ok := factory.(receiver.ConfigValidator)
+	if !ok {
+		return nil
+	}
+	rv := builder.ReceiverViper(v, receiverName)
+	if rv == nil {
+		rv = viper.New()
+	}
+	if err := validator.ValidateConfig(rv); err != nil {
+		return fmt.Errorf("invalid config for receiver %q: %v", receiverName, err)
+	}
+	return nil
+}
+
+func validateExporter(v *viper.Viper, exporterName string, factories Factories) error {
+	factory, ok := factories.Exporters[builder.ComponentType(exporterName)]
+	if !ok {
+		return fmt.Errorf("unknown exporter type for %q", exporterName)
+	}
+	validator, ok := factory.(exporter.ConfigValidator)
+	if !ok {
+		return nil
+	}
+	ev := builder.ExporterViper(v, exporterName)
+	if ev == nil {
+		ev = factory.DefaultConfig()
+	}
+	if err := validator.ValidateConfig(ev); err != nil {
+		return fmt.Errorf("invalid config for exporter %q: %v", exporterName, err)
+	}
+	return nil
+}
+
+func validateProcessor(v *viper.Viper, processorName string, factories Factories) error {
+	factory, ok := factories.Processors[builder.ComponentType(processorName)]
+	if !ok {
+		return fmt.Errorf("unknown processor type for %q", processorName)
+	}
+	validator, ok := factory.(processor.ConfigValidator)
+	if !ok {
+		return nil
+	}
+	pv := builder.ProcessorViper(v, processorName)
+	if pv == nil {
+		pv = factory.DefaultConfig()
+	}
+	if err := validator.ValidateConfig(pv); err != nil {
+		return fmt.Errorf("invalid config for processor %q: %v", processorName, err)
+	}
+	return nil
+}
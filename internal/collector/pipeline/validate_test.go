@@ -0,0 +1,142 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			config: pipelinesConfig,
+		},
+		{
+			name: "invalid_exporter_config",
+			config: `
+exporters:
+  sink/primary:
+    id: ""
+pipelines:
+  traces:
+    receivers: [fake]
+    exporters: [sink/primary]
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid_processor_config",
+			config: `
+processors:
+  add-attributes:
+    values:
+      key: [1, 2]
+pipelines:
+  traces:
+    receivers: [fake]
+    processors: [add-attributes]
+    exporters: [sink]
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_receiver",
+			config: `
+pipelines:
+  traces:
+    receivers: [unknown]
+    exporters: [sink]
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_processor",
+			config: `
+pipelines:
+  traces:
+    receivers: [fake]
+    processors: [unknown]
+    exporters: [sink]
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_exporter",
+			config: `
+pipelines:
+  traces:
+    receivers: [fake]
+    exporters: [unknown]
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := loadConfig(t, tt.config)
+			cfg, err := builder.NewDefaultPipelinesCfg().InitFromViper(v)
+			if err != nil {
+				t.Fatalf("Failed to load pipelines configuration: %v", err)
+			}
+
+			rf := &fakeReceiverFactory{receivers: make(map[string]*fakeReceiver)}
+			ef := &validatingExporterFactory{
+				sinkExporterFactory: sinkExporterFactory{exporters: make(map[string]*exportertest.SinkTraceExporter)},
+			}
+			factories := Factories{
+				Receivers:  map[string]receiver.TraceReceiverFactory{"fake": rf},
+				Processors: map[string]processor.TraceProcessorFactory{addattributesprocessor.TypeStr: addattributesprocessor.NewTraceProcessorFactory()},
+				Exporters:  map[string]exporter.TraceExporterFactory{"sink": ef},
+			}
+
+			err = Validate(v, cfg, factories)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rf.receivers) != 0 || len(ef.exporters) != 0 {
+				t.Errorf("Validate() created %d receivers and %d exporters, want none", len(rf.receivers), len(ef.exporters))
+			}
+		})
+	}
+}
+
+// validatingExporterFactory rejects configurations with an empty "id".
+type validatingExporterFactory struct {
+	sinkExporterFactory
+}
+
+var _ exporter.ConfigValidator = (*validatingExporterFactory)(nil)
+
+func (f *validatingExporterFactory) ValidateConfig(cfg *viper.Viper) error {
+	if cfg.IsSet("id") && cfg.GetString("id") == "" {
+		return errors.New("empty id")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headsampling
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TypeStr is the type of the processors created by the factory.
+const TypeStr = "head-sampling"
+
+// Config holds the configuration of the processors created by the factory.
+type Config struct {
+	// SamplingPercentage is the percentage, between 0 and 100, of the traces kept.
+	SamplingPercentage float64 `mapstructure:"sampling-percentage"`
+	// HashSeed is the seed of the hash of the trace IDs.
+	HashSeed uint32 `mapstructure:"hash-seed"`
+	// ServiceOverrides are the sampling percentages of specific services.
+	ServiceOverrides map[string]float64 `mapstructure:"service-overrides"`
+}
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+}
+
+// NewTraceProcessorFactory creates a factory for processors that keep a percentage of
+// the traces, deciding by their trace IDs.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewTraceProcessor(next, opts...)
+}
+
+// ValidateConfig checks the sampling percentages of the configuration.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	pCfg, err := configFromViper(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := newTraceIDSampler(pCfg.SamplingPercentage); err != nil {
+		return err
+	}
+	for service, percentage := range pCfg.ServiceOverrides {
+		if _, err := newTraceIDSampler(percentage); err != nil {
+			return fmt.Errorf("service %q: %v", service, err)
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory.
+func (f *factory) DefaultConfig() *viper.Viper {
+	v := viper.New()
+	v.SetDefault("sampling-percentage", 100)
+	v.SetDefault("hash-seed", 0)
+	return v
+}
+
+func configFromViper(cfg *viper.Viper) (Config, error) {
+	pCfg := Config{SamplingPercentage: 100}
+	if cfg != nil {
+		if err := cfg.Unmarshal(&pCfg); err != nil {
+			return pCfg, err
+		}
+	}
+	return pCfg, nil
+}
+
+func optionsFromViper(cfg *viper.Viper) ([]Option, error) {
+	pCfg, err := configFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []Option{
+		WithSamplingPercentage(pCfg.SamplingPercentage),
+		WithHashSeed(pCfg.HashSeed),
+		WithServicePercentages(pCfg.ServiceOverrides),
+	}, nil
+}
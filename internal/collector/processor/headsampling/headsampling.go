@@ -0,0 +1,144 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package headsampling contains a processor that keeps a percentage of the traces,
+// deciding from the trace ID alone so that all the agents and collectors keep the same
+// traces without any coordination.
+package headsampling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+)
+
+// ProbabilityAttributeKey is the attribute added to the sampled spans with the
+// probability, between 0 and 1, with which they were sampled.
+const ProbabilityAttributeKey = "sampling.probability"
+
+// traceIDSampler keeps the traces whose trace ID hashes to a bucket below threshold.
+type traceIDSampler struct {
+	threshold   uint32
+	probability float64
+}
+
+func newTraceIDSampler(percentage float64) (traceIDSampler, error) {
+	if percentage < 0 || percentage > 100 || math.IsNaN(percentage) {
+		return traceIDSampler{}, fmt.Errorf("sampling percentage must be between 0 and 100, got %v", percentage)
+	}
+	return traceIDSampler{
+		threshold:   sampling.TraceIDThreshold(percentage),
+		probability: percentage / 100,
+	}, nil
+}
+
+func (s traceIDSampler) sample(seed uint32, traceID []byte) bool {
+	return sampling.TraceIDSampled(seed, traceID, s.threshold)
+}
+
+type headSampler struct {
+	name            string
+	next            consumer.TraceConsumer
+	seed            uint32
+	percentage      float64
+	servicePercents map[string]float64
+
+	sampler         traceIDSampler
+	serviceSamplers map[string]traceIDSampler
+}
+
+var _ consumer.TraceConsumer = (*headSampler)(nil)
+
+// NewTraceProcessor returns a processor that sends to next only the spans of the traces
+// sampled by hashing their trace IDs. The sampled spans get the ProbabilityAttributeKey
+// attribute.
+func NewTraceProcessor(next consumer.TraceConsumer, opts ...Option) (consumer.TraceConsumer, error) {
+	if next == nil {
+		return nil, errors.New("next consumer is nil")
+	}
+	hs := &headSampler{
+		name:       TypeStr,
+		next:       next,
+		percentage: 100,
+	}
+	for _, opt := range opts {
+		opt(hs)
+	}
+
+	var err error
+	if hs.sampler, err = newTraceIDSampler(hs.percentage); err != nil {
+		return nil, err
+	}
+	hs.serviceSamplers = make(map[string]traceIDSampler, len(hs.servicePercents))
+	for service, percentage := range hs.servicePercents {
+		s, err := newTraceIDSampler(percentage)
+		if err != nil {
+			return nil, fmt.Errorf("service %q: %v", service, err)
+		}
+		hs.serviceSamplers[strings.ToLower(service)] = s
+	}
+	return hs, nil
+}
+
+// ConsumeTraceData sends the sampled spans of td to the next consumer.
+func (hs *headSampler) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	sampler := hs.sampler
+	if td.Node != nil && td.Node.ServiceInfo != nil {
+		if s, ok := hs.serviceSamplers[strings.ToLower(td.Node.ServiceInfo.Name)]; ok {
+			sampler = s
+		}
+	}
+
+	sampled := make([]*tracepb.Span, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		if span == nil || !sampler.sample(hs.seed, span.TraceId) {
+			continue
+		}
+		setProbabilityAttribute(span, sampler.probability)
+		sampled = append(sampled, span)
+	}
+
+	stats.RecordWithTags(context.Background(),
+		processor.StatsTagsForBatch(hs.name, processor.ServiceNameForNode(td.Node), td.SourceFormat),
+		statSampledSpans.M(int64(len(sampled))),
+		statNotSampledSpans.M(int64(len(td.Spans)-len(sampled))))
+
+	if len(sampled) == 0 {
+		return nil
+	}
+	td.Spans = sampled
+	return hs.next.ConsumeTraceData(ctx, td)
+}
+
+func setProbabilityAttribute(span *tracepb.Span, probability float64) {
+	if span.Attributes == nil {
+		span.Attributes = &tracepb.Span_Attributes{}
+	}
+	if span.Attributes.AttributeMap == nil {
+		span.Attributes.AttributeMap = make(map[string]*tracepb.AttributeValue, 1)
+	}
+	span.Attributes.AttributeMap[ProbabilityAttributeKey] = &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: probability},
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headsampling
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func traceIDs(n int) [][]byte {
+	ids := make([][]byte, n)
+	for i := range ids {
+		ids[i] = make([]byte, 16)
+		binary.BigEndian.PutUint64(ids[i][8:], uint64(i))
+	}
+	return ids
+}
+
+func spansForTraces(ids [][]byte) []*tracepb.Span {
+	spans := make([]*tracepb.Span, 0, 2*len(ids))
+	for _, id := range ids {
+		spans = append(spans, &tracepb.Span{TraceId: id}, &tracepb.Span{TraceId: id})
+	}
+	return spans
+}
+
+func sampledTraceIDs(sink *exportertest.SinkTraceExporter) map[string]int {
+	ids := make(map[string]int)
+	for _, td := range sink.AllTraces() {
+		for _, span := range td.Spans {
+			ids[string(span.TraceId)]++
+		}
+	}
+	return ids
+}
+
+func TestHeadSamplingPercentage(t *testing.T) {
+	tests := []struct {
+		percentage float64
+		delta      float64
+	}{
+		{percentage: 0},
+		{percentage: 1, delta: 0.2},
+		{percentage: 12.5, delta: 0.5},
+		{percentage: 50, delta: 1},
+		{percentage: 100},
+	}
+	ids := traceIDs(20000)
+	for _, tt := range tests {
+		sink := &exportertest.SinkTraceExporter{}
+		hs, err := NewTraceProcessor(sink, WithSamplingPercentage(tt.percentage))
+		if err != nil {
+			t.Fatalf("NewTraceProcessor(%v%%) = %v", tt.percentage, err)
+		}
+		if err := hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansForTraces(ids)}); err != nil {
+			t.Fatalf("ConsumeTraceData() = %v", err)
+		}
+
+		sampled := sampledTraceIDs(sink)
+		for id, count := range sampled {
+			if count != 2 {
+				t.Errorf("%v%%: got %d spans of trace %x, want all of its 2 spans", tt.percentage, count, id)
+			}
+		}
+		got := 100 * float64(len(sampled)) / float64(len(ids))
+		if math.Abs(got-tt.percentage) > tt.delta {
+			t.Errorf("got %.2f%% of the traces sampled, want %v%% ± %v", got, tt.percentage, tt.delta)
+		}
+	}
+}
+
+func TestHeadSamplingIsDeterministic(t *testing.T) {
+	ids := traceIDs(1000)
+	sampleWith := func(opts ...Option) map[string]int {
+		sink := &exportertest.SinkTraceExporter{}
+		hs, err := NewTraceProcessor(sink, opts...)
+		if err != nil {
+			t.Fatalf("NewTraceProcessor() = %v", err)
+		}
+		hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansForTraces(ids)})
+		return sampledTraceIDs(sink)
+	}
+
+	first := sampleWith(WithSamplingPercentage(30), WithHashSeed(7))
+	second := sampleWith(WithSamplingPercentage(30), WithHashSeed(7))
+	if len(first) != len(second) {
+		t.Fatalf("got %d and %d traces sampled with the same seed, want the same traces", len(first), len(second))
+	}
+	for id := range first {
+		if _, ok := second[id]; !ok {
+			t.Fatalf("trace %x sampled only once with the same seed", id)
+		}
+	}
+
+	otherSeed := sampleWith(WithSamplingPercentage(30), WithHashSeed(8))
+	common := 0
+	for id := range otherSeed {
+		if _, ok := first[id]; ok {
+			common++
+		}
+	}
+	if common == len(first) {
+		t.Errorf("got the same traces sampled with different seeds")
+	}
+}
+
+func TestHeadSamplingServiceOverrides(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	hs, err := NewTraceProcessor(sink,
+		WithSamplingPercentage(0),
+		WithServicePercentages(map[string]float64{"checkout": 100}))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() = %v", err)
+	}
+
+	ids := traceIDs(10)
+	nodeFor := func(service string) *commonpb.Node {
+		return &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: service}}
+	}
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Node: nodeFor("frontend"), Spans: spansForTraces(ids)})
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Node: nodeFor("Checkout"), Spans: spansForTraces(ids)})
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansForTraces(ids)})
+
+	tds := sink.AllTraces()
+	if len(tds) != 1 {
+		t.Fatalf("got %d batches sent, want only the batch of the overridden service", len(tds))
+	}
+	if got := tds[0].Node.ServiceInfo.Name; got != "Checkout" {
+		t.Errorf("got batch of service %q sent, want %q", got, "Checkout")
+	}
+	for _, span := range tds[0].Spans {
+		attr := span.Attributes.AttributeMap[ProbabilityAttributeKey]
+		if got := attr.GetDoubleValue(); got != 1 {
+			t.Errorf("got %s = %v, want 1", ProbabilityAttributeKey, got)
+		}
+	}
+}
+
+func TestHeadSamplingSetsProbability(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	hs, err := NewTraceProcessor(sink, WithSamplingPercentage(25))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() = %v", err)
+	}
+	spans := spansForTraces(traceIDs(100))
+	spans[0].Attributes = &tracepb.Span_Attributes{
+		AttributeMap: map[string]*tracepb.AttributeValue{
+			"http.method": {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "GET"}}},
+		},
+	}
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans})
+
+	for _, td := range sink.AllTraces() {
+		for _, span := range td.Spans {
+			attr, ok := span.Attributes.AttributeMap[ProbabilityAttributeKey]
+			if !ok {
+				t.Fatalf("sampled span without the %s attribute", ProbabilityAttributeKey)
+			}
+			if got := attr.GetDoubleValue(); got != 0.25 {
+				t.Errorf("got %s = %v, want 0.25", ProbabilityAttributeKey, got)
+			}
+		}
+	}
+	if spans[0].Attributes.AttributeMap["http.method"] == nil {
+		t.Errorf("existing attributes were dropped")
+	}
+}
+
+func TestNewTraceProcessorInvalidPercentage(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	if _, err := NewTraceProcessor(sink, WithSamplingPercentage(101)); err == nil {
+		t.Errorf("NewTraceProcessor() with 101%% = nil error, want an error")
+	}
+	if _, err := NewTraceProcessor(sink, WithServicePercentages(map[string]float64{"checkout": -1})); err == nil {
+		t.Errorf("NewTraceProcessor() with a -1%% override = nil error, want an error")
+	}
+	if _, err := NewTraceProcessor(nil); err == nil {
+		t.Errorf("NewTraceProcessor() with nil next = nil error, want an error")
+	}
+}
+
+func TestFactoryNewFromViper(t *testing.T) {
+	v := viper.New()
+	v.Set("sampling-percentage", 0)
+	v.Set("service-overrides", map[string]interface{}{"checkout": 100})
+	sink := &exportertest.SinkTraceExporter{}
+	hs, err := NewTraceProcessorFactory().NewFromViper(v, sink)
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"}}
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Node: node, Spans: spansForTraces(traceIDs(1))})
+	hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansForTraces(traceIDs(1))})
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("got %d batches sent, want 1", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headsampling
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var (
+	statSampledSpans    = stats.Int64("head_sampling_spans_sampled", "Number of spans kept by head sampling", stats.UnitDimensionless)
+	statNotSampledSpans = stats.Int64("head_sampling_spans_not_sampled", "Number of spans discarded by head sampling", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metrics views related to head sampling.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	tagKeys := processor.MetricTagKeys(level)
+	if tagKeys == nil {
+		return nil
+	}
+
+	sampledView := &view.View{
+		Name:        statSampledSpans.Name(),
+		Measure:     statSampledSpans,
+		Description: statSampledSpans.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	notSampledView := &view.View{
+		Name:        statNotSampledSpans.Name(),
+		Measure:     statNotSampledSpans,
+		Description: statNotSampledSpans.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{sampledView, notSampledView}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headsampling
+
+// Option is a function that sets some option on the head sampling processor.
+type Option func(*headSampler)
+
+// WithName sets the name of the processor in the metrics.
+func WithName(name string) Option {
+	return func(hs *headSampler) {
+		hs.name = name
+	}
+}
+
+// WithSamplingPercentage sets the percentage, between 0 and 100, of the traces that are
+// kept. The default is 100.
+func WithSamplingPercentage(percentage float64) Option {
+	return func(hs *headSampler) {
+		hs.percentage = percentage
+	}
+}
+
+// WithHashSeed sets the seed of the hash of the trace IDs. Processors with different
+// seeds keep different traces, e.g.: to sample again traces that were already sampled.
+func WithHashSeed(seed uint32) Option {
+	return func(hs *headSampler) {
+		hs.seed = seed
+	}
+}
+
+// WithServicePercentages sets the sampling percentages of the spans of specific services,
+// by the name of the service in the node of the spans. The names are matched regardless of
+// case since the configuration keys are lowercased.
+func WithServicePercentages(percentages map[string]float64) Option {
+	return func(hs *headSampler) {
+		hs.servicePercents = percentages
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorylimiter
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TypeStr is the type of the processors created by the factories.
+const TypeStr = "memory-limiter"
+
+// Config holds the configuration of the processors created by the factories.
+type Config struct {
+	// CheckInterval is how often the memory usage is checked.
+	CheckInterval time.Duration `mapstructure:"check-interval"`
+	// SoftLimitMiB is the heap size, in MiB, above which the data is refused.
+	SoftLimitMiB uint64 `mapstructure:"soft-limit-mib"`
+	// HardLimitMiB is the heap size, in MiB, above which a garbage collection is forced.
+	HardLimitMiB uint64 `mapstructure:"hard-limit-mib"`
+}
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+type metricsFactory struct {
+	factory
+}
+
+var _ processor.MetricsProcessorFactory = (*metricsFactory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+	processor.RegisterMetricsProcessorFactory(NewMetricsProcessorFactory())
+}
+
+// NewTraceProcessorFactory creates a factory for processors that refuse the spans while
+// the heap is above the soft limit. The processors created have a Stop method that halts
+// the memory checks.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// NewMetricsProcessorFactory creates a factory for processors that refuse the metrics
+// while the heap is above the soft limit. The processors created have a Stop method that
+// halts the memory checks.
+func NewMetricsProcessorFactory() processor.MetricsProcessorFactory {
+	return &metricsFactory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewTraceProcessor(next, opts...)
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsProcessor.
+func (f *metricsFactory) NewFromViper(cfg *viper.Viper, next processor.MetricsProcessor) (processor.MetricsProcessor, error) {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricsProcessor(next, opts...)
+}
+
+// ValidateConfig checks the limits and check interval of the configuration, without
+// starting the memory checks.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = newMemoryLimiter(opts...)
+	return err
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory. The soft limit has no default and must be configured.
+func (f *factory) DefaultConfig() *viper.Viper {
+	v := viper.New()
+	v.SetDefault("check-interval", defaultCheckInterval.String())
+	return v
+}
+
+func optionsFromViper(cfg *viper.Viper) ([]Option, error) {
+	pCfg := Config{CheckInterval: defaultCheckInterval}
+	if cfg != nil {
+		if err := cfg.Unmarshal(&pCfg); err != nil {
+			return nil, err
+		}
+	}
+	return []Option{
+		WithCheckInterval(pCfg.CheckInterval),
+		WithSoftLimitMiB(pCfg.SoftLimitMiB),
+		WithHardLimitMiB(pCfg.HardLimitMiB),
+	}, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorylimiter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+)
+
+// ErrDataRefused is returned by the memory limiter while the heap is above its soft
+// limit. It is a consumererror.ResourceExhausted error: the data can be sent again once
+// the memory usage decreases.
+var ErrDataRefused = consumererror.ResourceExhausted(errors.New("data refused due to high memory usage"), 0)
+
+const mib = 1 << 20
+
+// memoryLimiter checks the heap usage of the process on an interval and refuses the
+// data while it is above the soft limit, forcing a garbage collection above the hard
+// limit.
+type memoryLimiter struct {
+	// refusing must be the first field so it is aligned for the atomic operations.
+	refusing      int32
+	name          string
+	logger        *zap.Logger
+	softLimit     uint64
+	hardLimit     uint64
+	checkInterval time.Duration
+	nextConsumer  consumer.TraceConsumer
+	nextMetrics   consumer.MetricsConsumer
+	readMemStats  func(*runtime.MemStats)
+	forceGC       func()
+	stopCh        chan struct{}
+	stopOnce      sync.Once
+	statsCtx      context.Context
+}
+
+var _ consumer.TraceConsumer = (*memoryLimiter)(nil)
+var _ consumer.MetricsConsumer = (*memoryLimiter)(nil)
+
+// NewTraceProcessor returns a trace processor that refuses the spans, returning
+// ErrDataRefused, while the heap of the process is above the soft limit. It must be
+// stopped with its Stop method.
+func NewTraceProcessor(next consumer.TraceConsumer, opts ...Option) (consumer.TraceConsumer, error) {
+	if next == nil {
+		return nil, errors.New("next consumer is nil")
+	}
+	ml, err := newMemoryLimiter(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ml.nextConsumer = next
+	ml.start()
+	return ml, nil
+}
+
+// NewMetricsProcessor returns a metrics processor that refuses the metrics, returning
+// ErrDataRefused, while the heap of the process is above the soft limit. It must be
+// stopped with its Stop method.
+func NewMetricsProcessor(next consumer.MetricsConsumer, opts ...Option) (consumer.MetricsConsumer, error) {
+	if next == nil {
+		return nil, errors.New("next consumer is nil")
+	}
+	ml, err := newMemoryLimiter(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ml.nextMetrics = next
+	ml.start()
+	return ml, nil
+}
+
+func newMemoryLimiter(opts ...Option) (*memoryLimiter, error) {
+	ml := &memoryLimiter{
+		name:          TypeStr,
+		logger:        zap.NewNop(),
+		checkInterval: defaultCheckInterval,
+		readMemStats:  runtime.ReadMemStats,
+		forceGC:       runtime.GC,
+		stopCh:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ml)
+	}
+	if ml.softLimit == 0 {
+		return nil, errors.New("memory limiter requires a soft limit")
+	}
+	if ml.hardLimit != 0 && ml.hardLimit < ml.softLimit {
+		return nil, errors.New("memory limiter hard limit must not be lower than the soft limit")
+	}
+	if ml.checkInterval <= 0 {
+		return nil, errors.New("memory limiter check interval must be positive")
+	}
+	ml.statsCtx, _ = tag.New(context.Background(), tag.Upsert(processor.TagExporterNameKey, ml.name))
+	return ml, nil
+}
+
+// start checks the memory usage right away and then on every check interval.
+func (ml *memoryLimiter) start() {
+	ml.checkMemory()
+	ticker := time.NewTicker(ml.checkInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ml.stopCh:
+				return
+			case <-ticker.C:
+				ml.checkMemory()
+			}
+		}
+	}()
+}
+
+// Stop halts the memory checks.
+func (ml *memoryLimiter) Stop() {
+	ml.stopOnce.Do(func() {
+		close(ml.stopCh)
+	})
+}
+
+func (ml *memoryLimiter) checkMemory() {
+	ms := &runtime.MemStats{}
+	ml.readMemStats(ms)
+	if ml.hardLimit > 0 && ms.Alloc >= ml.hardLimit {
+		ml.logger.Warn("Memory usage above the hard limit, forcing a garbage collection",
+			zap.String("processor", ml.name),
+			zap.Uint64("alloc-mib", ms.Alloc/mib),
+			zap.Uint64("hard-limit-mib", ml.hardLimit/mib))
+		ml.forceGC()
+		stats.Record(ml.statsCtx, statForcedGCs.M(1))
+		ml.readMemStats(ms)
+	}
+	stats.Record(ml.statsCtx, statHeapAlloc.M(int64(ms.Alloc)))
+
+	refuse := ms.Alloc >= ml.softLimit
+	var refusing int32
+	if refuse {
+		refusing = 1
+	}
+	if atomic.SwapInt32(&ml.refusing, refusing) == refusing {
+		return
+	}
+	if refuse {
+		ml.logger.Warn("Memory usage above the soft limit, refusing data",
+			zap.String("processor", ml.name),
+			zap.Uint64("alloc-mib", ms.Alloc/mib),
+			zap.Uint64("soft-limit-mib", ml.softLimit/mib))
+	} else {
+		ml.logger.Info("Memory usage back below the soft limit, accepting data",
+			zap.String("processor", ml.name),
+			zap.Uint64("alloc-mib", ms.Alloc/mib))
+	}
+}
+
+func (ml *memoryLimiter) isRefusing() bool {
+	return atomic.LoadInt32(&ml.refusing) == 1
+}
+
+func (ml *memoryLimiter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if ml.isRefusing() {
+		stats.RecordWithTags(context.Background(),
+			processor.StatsTagsForBatch(ml.name, processor.ServiceNameForNode(td.Node), td.SourceFormat),
+			statRefusedSpans.M(int64(len(td.Spans))))
+		return ErrDataRefused
+	}
+	return ml.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func (ml *memoryLimiter) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	if ml.isRefusing() {
+		stats.RecordWithTags(context.Background(),
+			processor.StatsTagsForMetricsBatch(ml.name, processor.ServiceNameForNode(md.Node)),
+			statRefusedMetrics.M(int64(len(md.Metrics))))
+		return ErrDataRefused
+	}
+	return ml.nextMetrics.ConsumeMetricsData(ctx, md)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorylimiter
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestMemoryLimiterRefusesAboveSoftLimit(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	metricsSink := &exportertest.SinkMetricsExporter{}
+	ml, err := newMemoryLimiter(WithSoftLimitMiB(100), WithHardLimitMiB(200))
+	if err != nil {
+		t.Fatalf("newMemoryLimiter() = %v", err)
+	}
+	ml.nextConsumer = sink
+	ml.nextMetrics = metricsSink
+	var alloc uint64
+	ml.readMemStats = func(ms *runtime.MemStats) { ms.Alloc = alloc }
+	gcs := 0
+	ml.forceGC = func() { gcs++ }
+
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+	md := data.MetricsData{Metrics: []*metricspb.Metric{{}}}
+
+	alloc = 50 * mib
+	ml.checkMemory()
+	if err := ml.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Errorf("ConsumeTraceData() below the soft limit = %v", err)
+	}
+
+	alloc = 150 * mib
+	ml.checkMemory()
+	if err := ml.ConsumeTraceData(context.Background(), td); err != ErrDataRefused {
+		t.Errorf("ConsumeTraceData() above the soft limit = %v, want ErrDataRefused", err)
+	}
+	if err := ml.ConsumeMetricsData(context.Background(), md); err != ErrDataRefused {
+		t.Errorf("ConsumeMetricsData() above the soft limit = %v, want ErrDataRefused", err)
+	}
+	if gcs != 0 {
+		t.Errorf("got %d forced garbage collections below the hard limit, want 0", gcs)
+	}
+
+	alloc = 50 * mib
+	ml.checkMemory()
+	if err := ml.ConsumeMetricsData(context.Background(), md); err != nil {
+		t.Errorf("ConsumeMetricsData() back below the soft limit = %v", err)
+	}
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("got %d trace batches sent, want 1", got)
+	}
+	if got := len(metricsSink.AllMetrics()); got != 1 {
+		t.Errorf("got %d metrics batches sent, want 1", got)
+	}
+}
+
+func TestMemoryLimiterForcesGCAboveHardLimit(t *testing.T) {
+	ml, err := newMemoryLimiter(WithSoftLimitMiB(100), WithHardLimitMiB(200))
+	if err != nil {
+		t.Fatalf("newMemoryLimiter() = %v", err)
+	}
+	alloc := uint64(250 * mib)
+	ml.readMemStats = func(ms *runtime.MemStats) { ms.Alloc = alloc }
+	ml.forceGC = func() { alloc = 80 * mib }
+
+	ml.checkMemory()
+	if ml.isRefusing() {
+		t.Error("refusing data after the forced garbage collection freed memory")
+	}
+}
+
+func TestNewMemoryLimiterErrors(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{WithSoftLimitMiB(200), WithHardLimitMiB(100)},
+		{WithSoftLimitMiB(100), WithCheckInterval(0)},
+	} {
+		if _, err := newMemoryLimiter(opts...); err == nil {
+			t.Errorf("newMemoryLimiter() with %d options succeeded, want an error", len(opts))
+		}
+	}
+}
+
+func TestFactoryNewFromViper(t *testing.T) {
+	f := NewTraceProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+	if _, err := f.NewFromViper(f.DefaultConfig(), exportertest.NewNopTraceExporter()); err == nil {
+		t.Fatal("NewFromViper() without soft limit succeeded, want an error")
+	}
+
+	cfg := f.DefaultConfig()
+	cfg.Set("soft-limit-mib", 1024)
+	cfg.Set("hard-limit-mib", 2048)
+	tp, err := f.NewFromViper(cfg, exportertest.NewNopTraceExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	ml := tp.(*memoryLimiter)
+	defer ml.Stop()
+	if ml.softLimit != 1024*mib || ml.hardLimit != 2048*mib || ml.checkInterval != defaultCheckInterval {
+		t.Errorf("NewFromViper() = %+v, want the limits of the configuration", ml)
+	}
+
+	mf := NewMetricsProcessorFactory()
+	v := viper.New()
+	v.Set("soft-limit-mib", 1024)
+	mp, err := mf.NewFromViper(v, exportertest.NewNopMetricsExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	mp.(*memoryLimiter).Stop()
+}
@@ -0,0 +1,80 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorylimiter
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var (
+	statRefusedSpans   = stats.Int64("spans_refused", "Number of spans refused due to high memory usage", stats.UnitDimensionless)
+	statRefusedMetrics = stats.Int64("metrics_refused", "Number of metrics refused due to high memory usage", stats.UnitDimensionless)
+
+	statHeapAlloc = stats.Int64("memory_limiter_heap_alloc", "Size of the heap objects allocated when the memory was last checked", stats.UnitBytes)
+	statForcedGCs = stats.Int64("memory_limiter_forced_gc", "Number of garbage collections forced above the hard limit", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metrics views related to the memory limiter.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	tagKeys := processor.MetricTagKeys(level)
+	if tagKeys == nil {
+		return nil
+	}
+
+	exporterTagKeys := []tag.Key{processor.TagExporterNameKey}
+
+	refusedSpansView := &view.View{
+		Name:        statRefusedSpans.Name(),
+		Measure:     statRefusedSpans,
+		Description: statRefusedSpans.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	refusedMetricsView := &view.View{
+		Name:        statRefusedMetrics.Name(),
+		Measure:     statRefusedMetrics,
+		Description: statRefusedMetrics.Description(),
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	heapAllocView := &view.View{
+		Name:        statHeapAlloc.Name(),
+		Measure:     statHeapAlloc,
+		Description: statHeapAlloc.Description(),
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.LastValue(),
+	}
+
+	forcedGCsView := &view.View{
+		Name:        statForcedGCs.Name(),
+		Measure:     statForcedGCs,
+		Description: statForcedGCs.Description(),
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{refusedSpansView, refusedMetricsView, heapAllocView, forcedGCsView}
+}
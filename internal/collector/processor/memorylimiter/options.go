@@ -0,0 +1,62 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memorylimiter
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultCheckInterval = time.Second
+
+// Option is a function that sets some option on the memory limiter.
+type Option func(*memoryLimiter)
+
+// WithName sets the name of the processor in the logs and metrics.
+func WithName(name string) Option {
+	return func(ml *memoryLimiter) {
+		ml.name = name
+	}
+}
+
+// WithLogger sets the logger of the processor.
+func WithLogger(logger *zap.Logger) Option {
+	return func(ml *memoryLimiter) {
+		ml.logger = logger
+	}
+}
+
+// WithCheckInterval sets how often the memory usage is checked.
+func WithCheckInterval(checkInterval time.Duration) Option {
+	return func(ml *memoryLimiter) {
+		ml.checkInterval = checkInterval
+	}
+}
+
+// WithSoftLimitMiB sets the heap size, in MiB, above which the data is refused.
+func WithSoftLimitMiB(softLimitMiB uint64) Option {
+	return func(ml *memoryLimiter) {
+		ml.softLimit = softLimitMiB * mib
+	}
+}
+
+// WithHardLimitMiB sets the heap size, in MiB, above which a garbage collection is
+// forced. Zero leaves the garbage collection to the runtime.
+func WithHardLimitMiB(hardLimitMiB uint64) Option {
+	return func(ml *memoryLimiter) {
+		ml.hardLimit = hardLimitMiB * mib
+	}
+}
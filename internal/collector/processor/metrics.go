@@ -31,6 +31,9 @@ var (
 
 	StatReceivedSpanCount = stats.Int64("spans_received", "counts the number of spans received", stats.UnitDimensionless)
 	StatDroppedSpanCount  = stats.Int64("spans_dropped", "counts the number of spans dropped", stats.UnitDimensionless)
+
+	StatReceivedMetricCount = stats.Int64("metrics_received", "counts the number of metrics received", stats.UnitDimensionless)
+	StatDroppedMetricCount  = stats.Int64("metrics_dropped", "counts the number of metrics dropped", stats.UnitDimensionless)
 )
 
 // MetricTagKeys returns the metric tag keys according to the given telemetry level.
@@ -90,7 +93,25 @@ func MetricViews(level telemetry.Level) []*view.View {
 		Aggregation: view.Sum(),
 	}
 
-	return []*view.View{receivedBatchesView, droppedBatchesView, receivedSpansView, droppedSpansView}
+	receivedMetricsView := &view.View{
+		Name:        StatReceivedMetricCount.Name(),
+		Measure:     StatReceivedMetricCount,
+		Description: "The number of metrics received.",
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+	droppedMetricsView := &view.View{
+		Name:        StatDroppedMetricCount.Name(),
+		Measure:     StatDroppedMetricCount,
+		Description: "The number of metrics dropped.",
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{
+		receivedBatchesView, droppedBatchesView, receivedSpansView, droppedSpansView,
+		receivedMetricsView, droppedMetricsView,
+	}
 }
 
 // ServiceNameForNode gets the service name for a specified node. Used for metrics.
@@ -118,3 +139,14 @@ func StatsTagsForBatch(processorName, serviceName, spanFormat string) []tag.Muta
 
 	return statsTags
 }
+
+// StatsTagsForMetricsBatch gets the stat tags based on the specified processorName and serviceName.
+// Metrics batches do not carry a source format so only the processor and service tags are set.
+func StatsTagsForMetricsBatch(processorName, serviceName string) []tag.Mutator {
+	statsTags := []tag.Mutator{
+		tag.Upsert(TagServiceNameKey, serviceName),
+		tag.Upsert(TagExporterNameKey, processorName),
+	}
+
+	return statsTags
+}
@@ -0,0 +1,140 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodebatcher
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TypeStr is the type of the processors created by the factories.
+const TypeStr = "batch"
+
+// Config holds the configuration of the processors created by the factories, the
+// fields left unset keep the defaults of the batcher.
+type Config struct {
+	// Timeout sets the time after which a batch will be sent regardless of size.
+	Timeout *time.Duration `mapstructure:"timeout,omitempty"`
+	// SendBatchSize is the size of a batch which after hit, will trigger it to be sent.
+	SendBatchSize *int `mapstructure:"send-batch-size,omitempty"`
+	// NumTickers sets the number of tickers to use to divide the work of looping
+	// over batch buckets. It is ignored for metrics.
+	NumTickers int `mapstructure:"num-tickers,omitempty"`
+	// TickTime sets time interval at which the tickers tick.
+	TickTime *time.Duration `mapstructure:"tick-time,omitempty"`
+	// RemoveAfterTicks is the number of ticks that must pass without data arriving
+	// from a node after which the batch for that node will be deleted.
+	RemoveAfterTicks *int `mapstructure:"remove-after-ticks,omitempty"`
+}
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+type metricsFactory struct {
+	factory
+}
+
+var _ processor.MetricsProcessorFactory = (*metricsFactory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+	processor.RegisterMetricsProcessorFactory(NewMetricsProcessorFactory())
+}
+
+// NewTraceProcessorFactory creates a factory for processors that batch spans by node and
+// resource. The processors created have a Stop method that sends the pending batches.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// NewMetricsProcessorFactory creates a factory for processors that batch metrics by node
+// and resource. The processors created have a Stop method that sends the pending batches.
+func NewMetricsProcessorFactory() processor.MetricsProcessorFactory {
+	return &metricsFactory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewBatcher(TypeStr, zap.NewNop(), next, opts...), nil
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsProcessor.
+func (f *metricsFactory) NewFromViper(cfg *viper.Viper, next processor.MetricsProcessor) (processor.MetricsProcessor, error) {
+	opts, err := optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewMetricsBatcher(TypeStr, zap.NewNop(), next, opts...), nil
+}
+
+// ValidateConfig checks the configuration can be unmarshaled.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	_, err := optionsFromViper(cfg)
+	return err
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory.
+func (f *factory) DefaultConfig() *viper.Viper {
+	v := viper.New()
+	v.SetDefault("timeout", defaultTimeout.String())
+	v.SetDefault("send-batch-size", defaultSendBatchSize)
+	v.SetDefault("num-tickers", defaultNumTickers)
+	v.SetDefault("tick-time", defaultTickTime.String())
+	v.SetDefault("remove-after-ticks", defaultRemoveAfterCycles)
+	return v
+}
+
+func optionsFromViper(cfg *viper.Viper) ([]Option, error) {
+	var pCfg Config
+	if cfg != nil {
+		if err := cfg.Unmarshal(&pCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var opts []Option
+	if pCfg.Timeout != nil {
+		opts = append(opts, WithTimeout(*pCfg.Timeout))
+	}
+	if pCfg.SendBatchSize != nil {
+		opts = append(opts, WithSendBatchSize(*pCfg.SendBatchSize))
+	}
+	if pCfg.NumTickers > 0 {
+		opts = append(opts, WithNumTickers(pCfg.NumTickers))
+	}
+	if pCfg.TickTime != nil {
+		opts = append(opts, WithTickTime(*pCfg.TickTime))
+	}
+	if pCfg.RemoveAfterTicks != nil {
+		opts = append(opts, WithRemoveAfterTicks(*pCfg.RemoveAfterTicks))
+	}
+	return opts, nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodebatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	"go.opencensus.io/stats"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+)
+
+// metricsBatcher is a component that accepts metrics, and places them into batches grouped
+// by node and resource. Since metrics are received at a much lower rate than spans, a single
+// ticker checks all batches: a batch is sent when it reaches the batch size, or when the
+// timeout passed since it was last sent. Batches that stay empty for the configured number
+// of ticks are removed.
+type metricsBatcher struct {
+	name   string
+	logger *zap.Logger
+	sender consumer.MetricsConsumer
+
+	removeAfterCycles uint32
+	sendBatchSize     uint32
+	timeout           time.Duration
+
+	mu      sync.Mutex
+	batches map[string]*metricsBatch
+
+	ticker   *time.Ticker
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+var _ consumer.MetricsConsumer = (*metricsBatcher)(nil)
+var _ consumer.Shutdowner = (*metricsBatcher)(nil)
+
+type metricsBatch struct {
+	node            *commonpb.Node
+	resource        *resourcepb.Resource
+	metrics         []*metricspb.Metric
+	cyclesUntouched uint32
+	lastSent        time.Time
+}
+
+// NewMetricsBatcher creates a new batcher that batches metrics by node and resource. The
+// options are the same as for NewBatcher, except WithNumTickers that is ignored.
+func NewMetricsBatcher(name string, logger *zap.Logger, sender consumer.MetricsConsumer, opts ...Option) consumer.MetricsConsumer {
+	// The options are defined on the span batcher, so they are applied to one to get
+	// their values.
+	cfg := &batcher{
+		removeAfterCycles: defaultRemoveAfterCycles,
+		sendBatchSize:     defaultSendBatchSize,
+		tickTime:          defaultTickTime,
+		timeout:           defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	mb := &metricsBatcher{
+		name:              name,
+		logger:            logger,
+		sender:            sender,
+		removeAfterCycles: cfg.removeAfterCycles,
+		sendBatchSize:     cfg.sendBatchSize,
+		timeout:           cfg.timeout,
+		batches:           make(map[string]*metricsBatch),
+		ticker:            time.NewTicker(cfg.tickTime),
+		stopCh:            make(chan struct{}),
+	}
+	go mb.run()
+	return mb
+}
+
+// ConsumeMetricsData implements the MetricsConsumer interface, the metrics are added to
+// the batch of their node and resource.
+func (mb *metricsBatcher) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	key := bucketID(mb.logger, md.Node, md.Resource, "")
+
+	mb.mu.Lock()
+	batch, ok := mb.batches[key]
+	if !ok {
+		batch = &metricsBatch{node: md.Node, resource: md.Resource, lastSent: time.Now()}
+		mb.batches[key] = batch
+		stats.Record(context.Background(), statNodesAddedToBatches.M(1))
+	}
+	batch.metrics = append(batch.metrics, md.Metrics...)
+	batch.cyclesUntouched = 0
+	var toSend *data.MetricsData
+	if uint32(len(batch.metrics)) > mb.sendBatchSize {
+		toSend = batch.getAndReset()
+	}
+	mb.mu.Unlock()
+
+	if toSend != nil {
+		mb.send(*toSend, statBatchSizeTriggerSend)
+	}
+	return nil
+}
+
+// Stop stops the ticker of the batcher and sends the metrics of all pending batches.
+func (mb *metricsBatcher) Stop() {
+	mb.Shutdown(context.Background())
+}
+
+// Shutdown stops the ticker of the batcher and sends the metrics of all pending batches.
+// The metrics of the batches not sent before ctx is done are reported with a
+// *processor.LostDataError.
+func (mb *metricsBatcher) Shutdown(ctx context.Context) error {
+	var lost int64
+	mb.stopOnce.Do(func() {
+		close(mb.stopCh)
+		mb.mu.Lock()
+		var toSend []data.MetricsData
+		for _, batch := range mb.batches {
+			if len(batch.metrics) > 0 {
+				toSend = append(toSend, *batch.getAndReset())
+			}
+		}
+		mb.mu.Unlock()
+		for _, md := range toSend {
+			if ctx.Err() != nil {
+				lost += int64(len(md.Metrics))
+				statsTags := processor.StatsTagsForMetricsBatch(mb.name, processor.ServiceNameForNode(md.Node))
+				stats.RecordWithTags(context.Background(), statsTags, processor.StatDroppedMetricCount.M(int64(len(md.Metrics))))
+				continue
+			}
+			mb.send(md, statTimeoutTriggerSend)
+		}
+	})
+	if lost > 0 {
+		return &processor.LostDataError{Processor: mb.name, Metrics: lost}
+	}
+	return nil
+}
+
+func (mb *metricsBatcher) run() {
+	defer mb.ticker.Stop()
+	for {
+		select {
+		case <-mb.ticker.C:
+			mb.processBatches()
+		case <-mb.stopCh:
+			return
+		}
+	}
+}
+
+// processBatches sends the batches whose timeout expired and removes the ones that
+// stayed empty for too long.
+func (mb *metricsBatcher) processBatches() {
+	now := time.Now()
+	var toSend []data.MetricsData
+	mb.mu.Lock()
+	for key, batch := range mb.batches {
+		if len(batch.metrics) > 0 {
+			if batch.lastSent.Add(mb.timeout).Before(now) {
+				toSend = append(toSend, *batch.getAndReset())
+			}
+			continue
+		}
+		batch.cyclesUntouched++
+		if batch.cyclesUntouched > mb.removeAfterCycles {
+			delete(mb.batches, key)
+			stats.Record(context.Background(), statNodesRemovedFromBatches.M(1))
+		}
+	}
+	mb.mu.Unlock()
+
+	for _, md := range toSend {
+		mb.send(md, statTimeoutTriggerSend)
+	}
+}
+
+func (mb *metricsBatcher) send(md data.MetricsData, measure *stats.Int64Measure) {
+	statsTags := processor.StatsTagsForBatch(mb.name, processor.ServiceNameForNode(md.Node), "")
+	_ = stats.RecordWithTags(context.Background(), statsTags, measure.M(1))
+	if err := mb.sender.ConsumeMetricsData(context.Background(), md); err != nil {
+		mb.logger.Warn("Failed to send metrics batch", zap.String("processor", mb.name), zap.Error(err))
+	}
+}
+
+// getAndReset returns the pending metrics of the batch and empties it, the caller must
+// hold the lock of the batcher.
+func (batch *metricsBatch) getAndReset() *data.MetricsData {
+	md := &data.MetricsData{
+		Node:     batch.node,
+		Resource: batch.resource,
+		Metrics:  batch.metrics,
+	}
+	batch.metrics = nil
+	batch.lastSent = time.Now()
+	return md
+}
@@ -0,0 +1,120 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodebatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestMetricsBatcherSizeTrigger(t *testing.T) {
+	sink := &exportertest.SinkMetricsExporter{}
+	mb := NewMetricsBatcher("test", zap.NewNop(), sink, WithSendBatchSize(2), WithTimeout(time.Hour)).(*metricsBatcher)
+	defer mb.Stop()
+
+	node1 := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc1"}}
+	node2 := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc2"}}
+	metrics := []*metricspb.Metric{{}, {}}
+	mb.ConsumeMetricsData(context.Background(), data.MetricsData{Node: node1, Metrics: metrics})
+	mb.ConsumeMetricsData(context.Background(), data.MetricsData{Node: node2, Metrics: metrics})
+	if got := len(sink.AllMetrics()); got != 0 {
+		t.Fatalf("got %d batches before reaching the batch size, want 0", got)
+	}
+
+	mb.ConsumeMetricsData(context.Background(), data.MetricsData{Node: node1, Metrics: metrics})
+	got := sink.AllMetrics()
+	if len(got) != 1 {
+		t.Fatalf("got %d batches, want 1", len(got))
+	}
+	if got[0].Node != node1 || len(got[0].Metrics) != 4 {
+		t.Errorf("got batch of %d metrics for %v, want 4 metrics for %v", len(got[0].Metrics), got[0].Node, node1)
+	}
+
+	mb.Stop()
+	got = sink.AllMetrics()
+	if len(got) != 2 || got[1].Node != node2 || len(got[1].Metrics) != 2 {
+		t.Errorf("Stop() did not send the pending batch of %v: %v", node2, got)
+	}
+}
+
+func TestMetricsBatcherTimeoutTrigger(t *testing.T) {
+	sink := &exportertest.SinkMetricsExporter{}
+	mb := NewMetricsBatcher(
+		"test", zap.NewNop(), sink,
+		WithTimeout(10*time.Millisecond),
+		WithTickTime(5*time.Millisecond),
+		WithRemoveAfterTicks(1)).(*metricsBatcher)
+	defer mb.Stop()
+
+	mb.ConsumeMetricsData(context.Background(), data.MetricsData{Metrics: []*metricspb.Metric{{}}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.AllMetrics()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := len(sink.AllMetrics()); got != 1 {
+		t.Fatalf("got %d batches after the timeout, want 1", got)
+	}
+
+	for time.Now().Before(deadline) {
+		mb.mu.Lock()
+		numBatches := len(mb.batches)
+		mb.mu.Unlock()
+		if numBatches == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("the empty batch was not removed")
+}
+
+func TestFactoryNewFromViper(t *testing.T) {
+	f := NewTraceProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+
+	cfg := f.DefaultConfig()
+	cfg.Set("send-batch-size", 10)
+	cfg.Set("timeout", "3s")
+	tp, err := f.NewFromViper(cfg, exportertest.NewNopTraceExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	b := tp.(*batcher)
+	defer b.Stop()
+	if b.sendBatchSize != 10 || b.timeout != 3*time.Second || b.numTickers != defaultNumTickers {
+		t.Errorf("NewFromViper() = %+v, want the options of the configuration", b)
+	}
+
+	mf := NewMetricsProcessorFactory()
+	mp, err := mf.NewFromViper(cfg, exportertest.NewNopMetricsExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	mb := mp.(*metricsBatcher)
+	defer mb.Stop()
+	if mb.sendBatchSize != 10 || mb.timeout != 3*time.Second {
+		t.Errorf("NewFromViper() = %+v, want the options of the configuration", mb)
+	}
+}
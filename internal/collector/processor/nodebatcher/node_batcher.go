@@ -73,9 +73,11 @@ type batcher struct {
 	timeout           time.Duration
 
 	bucketMu sync.RWMutex
+	stopOnce sync.Once
 }
 
 var _ consumer.TraceConsumer = (*batcher)(nil)
+var _ consumer.Shutdowner = (*batcher)(nil)
 
 // NewBatcher creates a new batcher that batches spans by node and resource
 func NewBatcher(name string, logger *zap.Logger, sender consumer.TraceConsumer, opts ...Option) consumer.TraceConsumer {
@@ -111,12 +113,55 @@ func (b *batcher) ConsumeTraceData(ctx context.Context, td data.TraceData) error
 	return nil
 }
 
+// Stop stops the tickers of the batcher and sends the spans of all pending batches.
+func (b *batcher) Stop() {
+	b.Shutdown(context.Background())
+}
+
+// Shutdown stops the tickers of the batcher and sends the spans of all pending batches.
+// The spans of the batches not sent before ctx is done are reported with a
+// *processor.LostDataError.
+func (b *batcher) Shutdown(ctx context.Context) error {
+	var lost int64
+	b.stopOnce.Do(func() {
+		for _, ticker := range b.tickers {
+			ticker.stop()
+		}
+		b.buckets.Range(func(key, value interface{}) bool {
+			nb := value.(*nodeBatch)
+			nb.mu.Lock()
+			itemsToProcess, itemCount := nb.getAndReset()
+			nb.mu.Unlock()
+			if len(itemsToProcess) == 0 {
+				return true
+			}
+			if ctx.Err() != nil {
+				lost += int64(itemCount)
+				statsTags := processor.StatsTagsForBatch(b.name, processor.ServiceNameForNode(nb.node), nb.format)
+				stats.RecordWithTags(context.Background(), statsTags, processor.StatDroppedSpanCount.M(int64(itemCount)))
+				return true
+			}
+			nb.sendItems(itemsToProcess, itemCount, statTimeoutTriggerSend)
+			return true
+		})
+	})
+	if lost > 0 {
+		return &processor.LostDataError{Processor: b.name, Spans: lost}
+	}
+	return nil
+}
+
 func (b *batcher) genBucketID(node *commonpb.Node, resource *resourcepb.Resource, spanFormat string) string {
+	return bucketID(b.logger, node, resource, spanFormat)
+}
+
+// bucketID returns the key of the batch for the given node, resource and format.
+func bucketID(logger *zap.Logger, node *commonpb.Node, resource *resourcepb.Resource, format string) string {
 	h := md5.New()
 	if node != nil {
 		nodeKey, err := proto.Marshal(node)
 		if err != nil {
-			b.logger.Error("Error marshalling node to batcher mapkey.", zap.Error(err))
+			logger.Error("Error marshalling node to batcher mapkey.", zap.Error(err))
 		} else {
 			h.Write(nodeKey)
 		}
@@ -124,12 +169,12 @@ func (b *batcher) genBucketID(node *commonpb.Node, resource *resourcepb.Resource
 	if resource != nil {
 		resourceKey, err := proto.Marshal(resource) // TODO: remove once resource is in span
 		if err != nil {
-			b.logger.Error("Error marshalling resource to batcher mapkey.", zap.Error(err))
+			logger.Error("Error marshalling resource to batcher mapkey.", zap.Error(err))
 		} else {
 			h.Write(resourceKey)
 		}
 	}
-	return fmt.Sprintf("%x", h.Sum([]byte(spanFormat)))
+	return fmt.Sprintf("%x", h.Sum([]byte(format)))
 }
 
 func (b *batcher) getBucket(bucketID string) *nodeBatch {
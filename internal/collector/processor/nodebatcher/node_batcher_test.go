@@ -24,6 +24,7 @@ import (
 	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 	"go.uber.org/zap"
 )
 
@@ -386,3 +387,45 @@ func (ts *testSender) waitFor(spans int, timeout time.Duration) chan error {
 	}()
 	return errorCn
 }
+
+func TestBatcherShutdown(t *testing.T) {
+	sender := newTestSender()
+	b := NewBatcher("test", zap.NewNop(), sender, WithTimeout(time.Hour)).(*batcher)
+	request := data.TraceData{
+		Node:         &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}},
+		Spans:        []*tracepb.Span{{Name: getTestSpanName(0, 0)}, {Name: getTestSpanName(0, 1)}},
+		SourceFormat: "oc_trace",
+	}
+	b.ConsumeTraceData(context.Background(), request)
+
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := len(sender.reqChan); got != 1 {
+		t.Errorf("got %d batches flushed, want 1", got)
+	}
+}
+
+func TestBatcherShutdownReportsLostSpans(t *testing.T) {
+	sender := newTestSender()
+	b := NewBatcher("test", zap.NewNop(), sender, WithTimeout(time.Hour)).(*batcher)
+	request := data.TraceData{
+		Node:         &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}},
+		Spans:        []*tracepb.Span{{Name: getTestSpanName(0, 0)}, {Name: getTestSpanName(0, 1)}},
+		SourceFormat: "oc_trace",
+	}
+	b.ConsumeTraceData(context.Background(), request)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lostErr, ok := b.Shutdown(ctx).(*processor.LostDataError)
+	if !ok {
+		t.Fatalf("Shutdown() did not return a *processor.LostDataError")
+	}
+	if lostErr.Spans != 2 {
+		t.Errorf("got %d lost spans, want 2", lostErr.Spans)
+	}
+	if got := len(sender.reqChan); got != 0 {
+		t.Errorf("got %d batches flushed after the deadline, want 0", got)
+	}
+}
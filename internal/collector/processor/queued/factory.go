@@ -0,0 +1,193 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TypeStr is the type of the processors created by the factories.
+const TypeStr = "queued-retry"
+
+const (
+	numWorkersKey      = "num-workers"
+	queueSizeKey       = "queue-size"
+	retryOnFailureKey  = "retry-on-failure"
+	backoffDelayKey    = "backoff-delay"
+	maxBackoffDelayKey = "max-backoff-delay"
+	persistenceKey     = "persistence"
+
+	defaultBackoffDelay = 5 * time.Second
+)
+
+var errPersistenceNotSupported = errors.New("persistence is only supported by the trace processors")
+
+// Config holds the configuration of the processors created by the factories.
+type Config struct {
+	// NumWorkers is the number of queue workers that dequeue batches and send them out.
+	NumWorkers int `mapstructure:"num-workers"`
+	// QueueSize is the maximum number of batches allowed in queue at a given time.
+	QueueSize int `mapstructure:"queue-size"`
+	// RetryOnFailure indicates whether failed batches are retried.
+	RetryOnFailure bool `mapstructure:"retry-on-failure"`
+	// BackoffDelay is the amount of time a failed batch waits before its first retry, it
+	// doubles on each retry up to MaxBackoffDelay.
+	BackoffDelay time.Duration `mapstructure:"backoff-delay"`
+	// MaxBackoffDelay is the maximum amount of time a failed batch waits before a retry.
+	MaxBackoffDelay time.Duration `mapstructure:"max-backoff-delay"`
+	// MaxRetries is the number of retries after which a batch is dropped, zero means no
+	// limit.
+	MaxRetries int `mapstructure:"max-retries"`
+	// MaxElapsedTime is the time after the first failure of a batch after which it is
+	// dropped, zero means no limit.
+	MaxElapsedTime time.Duration `mapstructure:"max-elapsed-time"`
+	// Persistence keeps the queue on disk, so it survives restarts. Only supported by
+	// the trace processors.
+	Persistence *PersistenceConfig `mapstructure:"persistence"`
+}
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+type metricsFactory struct {
+	factory
+}
+
+var _ processor.MetricsProcessorFactory = (*metricsFactory)(nil)
+var _ processor.ConfigValidator = (*metricsFactory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+	processor.RegisterMetricsProcessorFactory(NewMetricsProcessorFactory())
+}
+
+// NewTraceProcessorFactory creates a factory for processors that queue the span
+// batches, in memory or on disk, and send them to the next processor from a pool of
+// workers, retrying the failed ones. The processors created have a Stop method that
+// halts the workers.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// NewMetricsProcessorFactory creates a factory for processors that queue the metrics
+// batches and send them to the next processor from a pool of workers, retrying the
+// failed ones. The processors created have a Stop method that halts the workers.
+func NewMetricsProcessorFactory() processor.MetricsProcessorFactory {
+	return &metricsFactory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	opts, err := f.optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewQueuedSpanProcessor(next, opts...)
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsProcessor.
+func (f *metricsFactory) NewFromViper(cfg *viper.Viper, next processor.MetricsProcessor) (processor.MetricsProcessor, error) {
+	if cfg != nil && cfg.IsSet(persistenceKey) {
+		return nil, errPersistenceNotSupported
+	}
+	opts, err := f.optionsFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewQueuedMetricsProcessor(next, opts...), nil
+}
+
+// ValidateConfig checks the configuration, including the persistence settings, without
+// opening the on-disk queue.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	pCfg, err := f.configFromViper(cfg)
+	if err != nil {
+		return err
+	}
+	if pCfg.Persistence != nil {
+		if _, err := pCfg.Persistence.withDefaults(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateConfig checks the configuration, rejecting persistence that is not supported
+// for metrics.
+func (f *metricsFactory) ValidateConfig(cfg *viper.Viper) error {
+	if cfg != nil && cfg.IsSet(persistenceKey) {
+		return errPersistenceNotSupported
+	}
+	_, err := f.configFromViper(cfg)
+	return err
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory.
+func (f *factory) DefaultConfig() *viper.Viper {
+	v := viper.New()
+	v.SetDefault(numWorkersKey, DefaultNumWorkers)
+	v.SetDefault(queueSizeKey, DefaultQueueSize)
+	v.SetDefault(retryOnFailureKey, true)
+	v.SetDefault(backoffDelayKey, defaultBackoffDelay.String())
+	v.SetDefault(maxBackoffDelayKey, DefaultMaxBackoffDelay.String())
+	return v
+}
+
+func (f *factory) configFromViper(cfg *viper.Viper) (Config, error) {
+	pCfg := Config{
+		NumWorkers:      DefaultNumWorkers,
+		QueueSize:       DefaultQueueSize,
+		RetryOnFailure:  true,
+		BackoffDelay:    defaultBackoffDelay,
+		MaxBackoffDelay: DefaultMaxBackoffDelay,
+	}
+	if cfg != nil {
+		if err := cfg.Unmarshal(&pCfg); err != nil {
+			return pCfg, err
+		}
+	}
+	return pCfg, nil
+}
+
+func (f *factory) optionsFromViper(cfg *viper.Viper) ([]Option, error) {
+	pCfg, err := f.configFromViper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []Option{
+		Options.WithName(TypeStr),
+		Options.WithNumWorkers(pCfg.NumWorkers),
+		Options.WithQueueSize(pCfg.QueueSize),
+		Options.WithRetryOnProcessingFailures(pCfg.RetryOnFailure),
+		Options.WithBackoffDelay(pCfg.BackoffDelay),
+		Options.WithMaxBackoffDelay(pCfg.MaxBackoffDelay),
+		Options.WithMaxRetries(pCfg.MaxRetries),
+		Options.WithMaxElapsedTime(pCfg.MaxElapsedTime),
+		Options.WithPersistence(pCfg.Persistence),
+	}, nil
+}
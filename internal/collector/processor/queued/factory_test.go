@@ -0,0 +1,93 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestFactoryNewFromViper(t *testing.T) {
+	f := NewTraceProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+
+	cfg := viper.New()
+	cfg.Set("num-workers", 3)
+	cfg.Set("queue-size", 7)
+	cfg.Set("retry-on-failure", false)
+	cfg.Set("backoff-delay", "2s")
+	cfg.Set("max-retries", 4)
+	cfg.Set("max-elapsed-time", "10m")
+
+	tp, err := f.NewFromViper(cfg, exportertest.NewNopTraceExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	qsp := tp.(*queuedSpanProcessor)
+	defer qsp.Stop()
+	wantPolicy := retryPolicy{
+		initialDelay:   2 * time.Second,
+		maxDelay:       DefaultMaxBackoffDelay,
+		maxRetries:     4,
+		maxElapsedTime: 10 * time.Minute,
+	}
+	if qsp.numWorkers != 3 || qsp.retryOnProcessingFailure || qsp.retryPolicy != wantPolicy || qsp.name != TypeStr {
+		t.Errorf("NewFromViper() = %+v, want the options of the configuration", qsp)
+	}
+
+	tp, err = f.NewFromViper(f.DefaultConfig(), exportertest.NewNopTraceExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() with default configuration = %v", err)
+	}
+	qsp = tp.(*queuedSpanProcessor)
+	defer qsp.Stop()
+	if qsp.numWorkers != DefaultNumWorkers || !qsp.retryOnProcessingFailure || qsp.retryPolicy.initialDelay != defaultBackoffDelay {
+		t.Errorf("NewFromViper() with default configuration = %+v", qsp)
+	}
+}
+
+func TestMetricsFactoryNewFromViper(t *testing.T) {
+	f := NewMetricsProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+
+	cfg := f.DefaultConfig()
+	cfg.Set("num-workers", 2)
+	mp, err := f.NewFromViper(cfg, exportertest.NewNopMetricsExporter())
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+	qmp := mp.(*queuedMetricsProcessor)
+	defer qmp.Stop()
+	if qmp.numWorkers != 2 || !qmp.retryOnProcessingFailure {
+		t.Errorf("NewFromViper() = %+v, want the options of the configuration", qmp)
+	}
+}
+
+func TestMetricsFactoryRejectsPersistence(t *testing.T) {
+	f := NewMetricsProcessorFactory()
+	cfg := f.DefaultConfig()
+	cfg.Set("persistence.directory", "/var/lib/occollector/queue")
+	if _, err := f.NewFromViper(cfg, exportertest.NewNopMetricsExporter()); err == nil {
+		t.Fatal("NewFromViper() with persistence succeeded, want an error")
+	}
+}
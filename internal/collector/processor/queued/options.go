@@ -35,10 +35,14 @@ type options struct {
 	numWorkers               int
 	queueSize                int
 	backoffDelay             time.Duration
+	maxBackoffDelay          time.Duration
+	maxRetries               int
+	maxElapsedTime           time.Duration
 	extraFormatTypes         []string
 	retryOnProcessingFailure bool
 	batchingEnabled          bool
 	batchingOptions          []nodebatcher.Option
+	persistence              *PersistenceConfig
 }
 
 // Option is a function that sets some option on the component.
@@ -75,13 +79,37 @@ func (options) WithQueueSize(queueSize int) Option {
 	}
 }
 
-// WithBackoffDelay creates an Option that initializes the backoff delay
+// WithBackoffDelay creates an Option that initializes the backoff delay before the first
+// retry of a batch, it doubles on each retry
 func (options) WithBackoffDelay(backoffDelay time.Duration) Option {
 	return func(b *options) {
 		b.backoffDelay = backoffDelay
 	}
 }
 
+// WithMaxBackoffDelay creates an Option that initializes the maximum backoff delay
+func (options) WithMaxBackoffDelay(maxBackoffDelay time.Duration) Option {
+	return func(b *options) {
+		b.maxBackoffDelay = maxBackoffDelay
+	}
+}
+
+// WithMaxRetries creates an Option that initializes the maximum number of retries of a
+// batch, zero means no limit
+func (options) WithMaxRetries(maxRetries int) Option {
+	return func(b *options) {
+		b.maxRetries = maxRetries
+	}
+}
+
+// WithMaxElapsedTime creates an Option that initializes the maximum time a batch is
+// retried after its first failure, zero means no limit
+func (options) WithMaxElapsedTime(maxElapsedTime time.Duration) Option {
+	return func(b *options) {
+		b.maxElapsedTime = maxElapsedTime
+	}
+}
+
 // WithExtraFormatTypes creates an Option that initializes the extra list of format types
 func (options) WithExtraFormatTypes(extraFormatTypes []string) Option {
 	return func(b *options) {
@@ -111,6 +139,14 @@ func (options) WithBatchingOptions(opts ...nodebatcher.Option) Option {
 	}
 }
 
+// WithPersistence creates an Option that keeps the queue on disk, as configured by cfg,
+// instead of in memory. The queue size is then limited by the size of the files.
+func (options) WithPersistence(cfg *PersistenceConfig) Option {
+	return func(b *options) {
+		b.persistence = cfg
+	}
+}
+
 func (o options) apply(opts ...Option) options {
 	ret := options{}
 	for _, opt := range opts {
@@ -125,5 +161,17 @@ func (o options) apply(opts ...Option) options {
 	if ret.queueSize == 0 {
 		ret.queueSize = DefaultQueueSize
 	}
+	if ret.maxBackoffDelay == 0 {
+		ret.maxBackoffDelay = DefaultMaxBackoffDelay
+	}
 	return ret
 }
+
+func (o options) retryPolicy() retryPolicy {
+	return retryPolicy{
+		initialDelay:   o.backoffDelay,
+		maxDelay:       o.maxBackoffDelay,
+		maxRetries:     o.maxRetries,
+		maxElapsedTime: o.maxElapsedTime,
+	}
+}
@@ -0,0 +1,608 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SyncPolicy sets when the files of a persistent queue are flushed to disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes each batch to disk before it is accepted by the queue.
+	SyncAlways SyncPolicy = "always"
+	// SyncPeriodic flushes the batches written to disk every sync period, a crash of the
+	// host can lose the batches of the last period.
+	SyncPeriodic SyncPolicy = "periodic"
+	// SyncNever leaves the flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	defaultMaxSizeMiB = 1024
+	defaultSyncPeriod = time.Second
+
+	// maxSegmentSize is the size after which the queue starts writing a new file. A file
+	// is deleted once all its batches were sent.
+	maxSegmentSize = 16 << 20
+	segmentSuffix  = ".wal"
+	lockFileName   = "LOCK"
+
+	// Each record starts with the length of the payload, the CRC-32C of the number of
+	// spans and the payload, the number of spans in the batch and whether the batch was
+	// sent.
+	recordHeaderSize = 13
+	recordStateAt    = 12
+	recordSent       = 1
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// PersistenceConfig configures the queue kept on disk by a queued span processor. The
+// batches in the queue survive restarts of the process and are replayed when the
+// processor is created again with the same directory.
+type PersistenceConfig struct {
+	// Directory is where the queue files are kept, it must not be shared with other
+	// queues.
+	Directory string `mapstructure:"directory"`
+	// MaxSizeMiB is the maximum size of the queue files in MiB, batches are dropped
+	// once it is reached.
+	MaxSizeMiB int `mapstructure:"max-size-mib"`
+	// Sync sets when the queue files are flushed to disk: always, periodic or never.
+	Sync SyncPolicy `mapstructure:"sync"`
+	// SyncPeriod is how often the queue files are flushed with the periodic policy.
+	SyncPeriod time.Duration `mapstructure:"sync-period"`
+}
+
+func (cfg PersistenceConfig) withDefaults() (PersistenceConfig, error) {
+	if cfg.Directory == "" {
+		return cfg, errors.New("persistent queue requires a directory")
+	}
+	if cfg.MaxSizeMiB == 0 {
+		cfg.MaxSizeMiB = defaultMaxSizeMiB
+	}
+	if cfg.MaxSizeMiB < 0 {
+		return cfg, fmt.Errorf("invalid persistent queue max-size-mib %d", cfg.MaxSizeMiB)
+	}
+	switch cfg.Sync {
+	case "":
+		cfg.Sync = SyncPeriodic
+	case SyncAlways, SyncPeriodic, SyncNever:
+	default:
+		return cfg, fmt.Errorf("unknown persistent queue sync policy %q", cfg.Sync)
+	}
+	if cfg.SyncPeriod <= 0 {
+		cfg.SyncPeriod = defaultSyncPeriod
+	}
+	return cfg, nil
+}
+
+// itemCodec converts the items of a persistent queue to and from the payload of the
+// records written to disk.
+type itemCodec struct {
+	// encode returns the payload of item and the number of spans in it.
+	encode func(item interface{}) (numItems int, payload []byte, err error)
+	decode func(payload []byte) (interface{}, error)
+	// onCorrupted is called for the records that could not be read back, they are
+	// discarded.
+	onCorrupted func(numItems int, err error)
+}
+
+// segment is one of the files of a persistent queue.
+type segment struct {
+	path    string
+	file    *os.File
+	size    int64
+	records int
+	acked   int
+}
+
+// record is a batch written to a segment.
+type record struct {
+	seg      *segment
+	offset   int64
+	size     int
+	numItems int
+}
+
+// claimedSegments holds the segment files owned by the persistent queues of this
+// process. The processors created by a reload must not replay the files of the ones
+// they replace, which are still sending them.
+var claimedSegments = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+func claimSegment(path string) bool {
+	claimedSegments.Lock()
+	defer claimedSegments.Unlock()
+	if claimedSegments.paths[path] {
+		return false
+	}
+	claimedSegments.paths[path] = true
+	return true
+}
+
+func releaseSegment(path string) {
+	claimedSegments.Lock()
+	defer claimedSegments.Unlock()
+	delete(claimedSegments.paths, path)
+}
+
+// lockedDirectories holds the directories locked by the persistent queues of this
+// process. The lock file keeps other processes from using a directory, while the queues
+// of this process, e.g.: the ones created by a reload, share its lock.
+var lockedDirectories = struct {
+	sync.Mutex
+	locks map[string]*directoryLock
+}{locks: make(map[string]*directoryLock)}
+
+type directoryLock struct {
+	file *os.File
+	refs int
+}
+
+func lockDirectory(dir string) error {
+	lockedDirectories.Lock()
+	defer lockedDirectories.Unlock()
+	if lock, ok := lockedDirectories.locks[dir]; ok {
+		lock.refs++
+		return nil
+	}
+	file, err := lockFile(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return fmt.Errorf("failed to lock persistent queue directory %q, it may be used by another process: %v", dir, err)
+	}
+	lockedDirectories.locks[dir] = &directoryLock{file: file, refs: 1}
+	return nil
+}
+
+func unlockDirectory(dir string) {
+	lockedDirectories.Lock()
+	defer lockedDirectories.Unlock()
+	lock, ok := lockedDirectories.locks[dir]
+	if !ok {
+		return
+	}
+	lock.refs--
+	if lock.refs == 0 {
+		lock.file.Close()
+		delete(lockedDirectories.locks, dir)
+	}
+}
+
+// persistentQueue is a write-ahead queue kept in segment files in a directory. Batches
+// are appended to the last segment and handed to the workers in order. Sent batches are
+// marked in their record, without syncing, and a segment is deleted once all its batches
+// were sent. The batches are delivered at least once: a crash of the host can lose the
+// marks and the batches are sent again after a restart.
+type persistentQueue struct {
+	cfg         PersistenceConfig
+	maxSize     int64
+	segmentSize int64
+	codec       itemCodec
+	logger      *zap.Logger
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	segments  map[*segment]bool
+	writing   *segment
+	nextIndex uint64
+	// records are the batches waiting for a worker.
+	records  []*record
+	size     int64
+	dirty    bool
+	stopping bool
+	closed   bool
+
+	workers sync.WaitGroup
+	stopCh  chan struct{}
+
+	replayedBatches int64
+	replayedItems   int64
+}
+
+// openPersistentQueue opens the queue kept in cfg.Directory, creating the directory if
+// needed, and loads the batches left in it by a previous process. The directory is
+// locked until the queue is stopped, so it fails if another process is using it.
+func openPersistentQueue(cfg PersistenceConfig, codec itemCodec, logger *zap.Logger) (*persistentQueue, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Directory, err = filepath.Abs(cfg.Directory); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create persistent queue directory: %v", err)
+	}
+	if err := lockDirectory(cfg.Directory); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(cfg.Directory, "*"+segmentSuffix))
+	if err != nil {
+		unlockDirectory(cfg.Directory)
+		return nil, err
+	}
+	// The segment names are zero padded, so they sort in the order they were written.
+	sort.Strings(paths)
+
+	q := &persistentQueue{
+		cfg:      cfg,
+		maxSize:  int64(cfg.MaxSizeMiB) << 20,
+		codec:    codec,
+		logger:   logger,
+		segments: make(map[*segment]bool),
+		stopCh:   make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.segmentSize = q.maxSize / 4
+	if q.segmentSize > maxSegmentSize {
+		q.segmentSize = maxSegmentSize
+	}
+
+	for _, path := range paths {
+		index, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), segmentSuffix), 10, 64)
+		if err != nil {
+			logger.Warn("Ignoring unknown file in persistent queue directory", zap.String("file", path))
+			continue
+		}
+		if index >= q.nextIndex {
+			q.nextIndex = index + 1
+		}
+		if !claimSegment(path) {
+			continue
+		}
+		if err := q.replaySegment(path); err != nil {
+			releaseSegment(path)
+			q.closeSegments()
+			unlockDirectory(cfg.Directory)
+			return nil, err
+		}
+	}
+
+	if cfg.Sync == SyncPeriodic {
+		go q.syncPeriodically()
+	}
+	return q, nil
+}
+
+// replaySegment loads the records of the segment file at path. A record cut by a crash
+// ends the segment, the ones before it are kept.
+func (q *persistentQueue) replaySegment(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open persistent queue file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open persistent queue file: %v", err)
+	}
+
+	seg := &segment{path: path, file: file, size: info.Size()}
+	header := make([]byte, recordHeaderSize)
+	var offset int64
+	for offset < seg.size {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			if err != io.EOF {
+				file.Close()
+				return fmt.Errorf("failed to read persistent queue file: %v", err)
+			}
+			q.logger.Warn("Ignoring incomplete batch at the end of persistent queue file",
+				zap.String("file", path), zap.Int64("offset", offset))
+			break
+		}
+		size := int(binary.LittleEndian.Uint32(header[0:]))
+		if offset+int64(recordHeaderSize+size) > seg.size {
+			q.logger.Warn("Ignoring incomplete batch at the end of persistent queue file",
+				zap.String("file", path), zap.Int64("offset", offset))
+			break
+		}
+		seg.records++
+		if header[recordStateAt] == recordSent {
+			seg.acked++
+		} else {
+			rec := &record{
+				seg:      seg,
+				offset:   offset,
+				size:     size,
+				numItems: int(binary.LittleEndian.Uint32(header[8:])),
+			}
+			q.records = append(q.records, rec)
+			q.replayedBatches++
+			q.replayedItems += int64(rec.numItems)
+		}
+		offset += int64(recordHeaderSize + size)
+	}
+
+	q.size += seg.size
+	if seg.acked == seg.records {
+		q.removeSegment(seg)
+		return nil
+	}
+	q.segments[seg] = true
+	return nil
+}
+
+// Produce writes item to the queue, it returns false if the queue is full or the item
+// could not be written.
+func (q *persistentQueue) Produce(item interface{}) bool {
+	rec, ok := q.persist(item)
+	if !ok {
+		return false
+	}
+	return q.enqueue(rec)
+}
+
+// persist writes item to the queue files without handing it to the workers, see
+// enqueue. A record persisted is replayed after a restart even if it was never enqueued.
+func (q *persistentQueue) persist(item interface{}) (*record, bool) {
+	numItems, payload, err := q.codec.encode(item)
+	if err != nil {
+		q.logger.Error("Failed to encode batch for the persistent queue", zap.Error(err))
+		return nil, false
+	}
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(numItems))
+	copy(buf[recordHeaderSize:], payload)
+	binary.LittleEndian.PutUint32(buf[4:], recordChecksum(buf))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.size+int64(len(buf)) > q.maxSize {
+		return nil, false
+	}
+	if q.writing == nil || (q.writing.size > 0 && q.writing.size+int64(len(buf)) > q.segmentSize) {
+		if err := q.rotate(); err != nil {
+			q.logger.Error("Failed to create persistent queue file", zap.Error(err))
+			return nil, false
+		}
+	}
+
+	seg := q.writing
+	// A failed write is overwritten by the next one, since the size is not updated.
+	if _, err := seg.file.WriteAt(buf, seg.size); err != nil {
+		q.logger.Error("Failed to write batch to the persistent queue", zap.Error(err))
+		return nil, false
+	}
+	if q.cfg.Sync == SyncAlways {
+		if err := seg.file.Sync(); err != nil {
+			q.logger.Error("Failed to sync the persistent queue", zap.Error(err))
+			return nil, false
+		}
+	} else {
+		q.dirty = true
+	}
+
+	rec := &record{seg: seg, offset: seg.size, size: len(payload), numItems: numItems}
+	seg.records++
+	seg.size += int64(len(buf))
+	q.size += int64(len(buf))
+	return rec, true
+}
+
+// enqueue hands a persisted record to the workers, it returns false if the queue is
+// closed, in which case the record is kept for the next start.
+func (q *persistentQueue) enqueue(rec *record) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.records = append(q.records, rec)
+	q.cond.Signal()
+	return true
+}
+
+// rotate starts a new segment for the writes, syncing the previous one.
+func (q *persistentQueue) rotate() error {
+	if prev := q.writing; prev != nil {
+		q.syncWriting()
+		q.writing = nil
+		if prev.acked == prev.records {
+			q.removeSegment(prev)
+		}
+	}
+	for {
+		path := filepath.Join(q.cfg.Directory, fmt.Sprintf("%020d%s", q.nextIndex, segmentSuffix))
+		q.nextIndex++
+		// The file may have been created by the queue being replaced on a reload.
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		claimSegment(path)
+		q.writing = &segment{path: path, file: file}
+		q.segments[q.writing] = true
+		return nil
+	}
+}
+
+// syncWriting flushes the segment being written to disk, unless the policy leaves it to
+// the operating system.
+func (q *persistentQueue) syncWriting() {
+	if q.writing == nil || !q.dirty || q.cfg.Sync == SyncNever {
+		return
+	}
+	if err := q.writing.file.Sync(); err != nil {
+		q.logger.Error("Failed to sync the persistent queue", zap.Error(err))
+		return
+	}
+	q.dirty = false
+}
+
+func (q *persistentQueue) syncPeriodically() {
+	ticker := time.NewTicker(q.cfg.SyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-ticker.C:
+			q.mu.Lock()
+			q.syncWriting()
+			q.mu.Unlock()
+		}
+	}
+}
+
+// StartConsumers starts num workers calling consumer with the items of the queue. A
+// record is deleted once consumer returns, so a consumer keeping an item to retry it
+// later must persist it again first.
+func (q *persistentQueue) StartConsumers(num int, consumer func(item interface{})) {
+	for i := 0; i < num; i++ {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for {
+				rec, ok := q.next()
+				if !ok {
+					return
+				}
+				item, err := q.read(rec)
+				if err != nil {
+					q.codec.onCorrupted(rec.numItems, err)
+				} else {
+					consumer(item)
+				}
+				q.ack(rec)
+			}
+		}()
+	}
+}
+
+// next waits for a record to send, it returns false once the queue is stopping.
+func (q *persistentQueue) next() (*record, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.records) == 0 && !q.stopping {
+		q.cond.Wait()
+	}
+	if q.stopping {
+		return nil, false
+	}
+	rec := q.records[0]
+	q.records[0] = nil
+	q.records = q.records[1:]
+	return rec, true
+}
+
+func (q *persistentQueue) read(rec *record) (interface{}, error) {
+	buf := make([]byte, recordHeaderSize+rec.size)
+	if _, err := rec.seg.file.ReadAt(buf, rec.offset); err != nil {
+		return nil, err
+	}
+	if recordChecksum(buf) != binary.LittleEndian.Uint32(buf[4:]) {
+		return nil, fmt.Errorf("checksum mismatch at offset %d of %s", rec.offset, rec.seg.path)
+	}
+	return q.codec.decode(buf[recordHeaderSize:])
+}
+
+// recordChecksum returns the checksum of the record in buf, which skips the state
+// changed when the record is sent.
+func recordChecksum(buf []byte) uint32 {
+	crc := crc32.Checksum(buf[8:recordStateAt], crcTable)
+	return crc32.Update(crc, crcTable, buf[recordStateAt+1:])
+}
+
+// ack marks rec as sent, deleting its segment if it was the last record pending in it.
+func (q *persistentQueue) ack(rec *record) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	seg := rec.seg
+	seg.acked++
+	if seg != q.writing && seg.acked == seg.records {
+		q.removeSegment(seg)
+		return
+	}
+	if _, err := seg.file.WriteAt([]byte{recordSent}, rec.offset+recordStateAt); err != nil {
+		q.logger.Warn("Failed to mark batch as sent in the persistent queue", zap.Error(err))
+	}
+}
+
+func (q *persistentQueue) removeSegment(seg *segment) {
+	seg.file.Close()
+	if err := os.Remove(seg.path); err != nil {
+		q.logger.Warn("Failed to remove persistent queue file", zap.String("file", seg.path), zap.Error(err))
+	}
+	releaseSegment(seg.path)
+	delete(q.segments, seg)
+	q.size -= seg.size
+}
+
+// Stop waits for the workers to finish sending the records they hold and closes the
+// queue files. The records not sent are kept for the next start.
+func (q *persistentQueue) Stop() {
+	q.mu.Lock()
+	if q.stopping {
+		q.mu.Unlock()
+		return
+	}
+	q.stopping = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	close(q.stopCh)
+	q.workers.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.syncWriting()
+	q.closed = true
+	q.closeSegments()
+	unlockDirectory(q.cfg.Directory)
+}
+
+func (q *persistentQueue) closeSegments() {
+	for seg := range q.segments {
+		seg.file.Close()
+		releaseSegment(seg.path)
+	}
+	q.segments = nil
+	q.writing = nil
+}
+
+// Size returns the number of batches waiting for a worker.
+func (q *persistentQueue) Size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.records)
+}
+
+// diskSize returns the size of the queue files in bytes.
+func (q *persistentQueue) diskSize() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
@@ -0,0 +1,401 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestPersistenceConfigWithDefaults(t *testing.T) {
+	cfg, err := PersistenceConfig{Directory: "/tmp/queue"}.withDefaults()
+	if err != nil {
+		t.Fatalf("withDefaults() = %v", err)
+	}
+	want := PersistenceConfig{Directory: "/tmp/queue", MaxSizeMiB: defaultMaxSizeMiB, Sync: SyncPeriodic, SyncPeriod: defaultSyncPeriod}
+	if cfg != want {
+		t.Errorf("withDefaults() = %+v, want %+v", cfg, want)
+	}
+
+	for _, bad := range []PersistenceConfig{
+		{},
+		{Directory: "/tmp/queue", MaxSizeMiB: -1},
+		{Directory: "/tmp/queue", Sync: "sometimes"},
+	} {
+		if _, err := bad.withDefaults(); err == nil {
+			t.Errorf("withDefaults() of %+v succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestQueueItemEncoding(t *testing.T) {
+	item := &queueItem{
+		queuedTime: time.Unix(1500000000, 123),
+		retry:      retryState{retries: 3, firstFailure: time.Unix(1500000100, 0)},
+		td: data.TraceData{
+			Node:         &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}},
+			Spans:        []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "a"}}, {}},
+			SourceFormat: "oc_trace",
+		},
+	}
+	numSpans, payload, err := encodeQueueItem(item)
+	if err != nil {
+		t.Fatalf("encodeQueueItem() = %v", err)
+	}
+	if numSpans != 2 {
+		t.Errorf("encodeQueueItem() returned %d spans, want 2", numSpans)
+	}
+	got, err := decodeQueueItem(payload)
+	if err != nil {
+		t.Fatalf("decodeQueueItem() = %v", err)
+	}
+	gotItem := got.(*queueItem)
+	if !gotItem.queuedTime.Equal(item.queuedTime) || gotItem.retry.retries != 3 ||
+		!gotItem.retry.firstFailure.Equal(item.retry.firstFailure) || gotItem.td.SourceFormat != item.td.SourceFormat ||
+		!proto.Equal(gotItem.td.Node, item.td.Node) || len(gotItem.td.Spans) != len(item.td.Spans) ||
+		!proto.Equal(gotItem.td.Spans[0], item.td.Spans[0]) {
+		t.Errorf("decodeQueueItem() = %+v, want %+v", gotItem.td, item.td)
+	}
+
+	if _, err := decodeQueueItem(payload[:5]); err == nil {
+		t.Error("decodeQueueItem() of a truncated payload succeeded, want an error")
+	}
+}
+
+func TestPersistentQueueReplay(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	cfg := PersistenceConfig{Directory: dir, Sync: SyncAlways}
+
+	q := openTestQueue(t, cfg, nil)
+	for i := 1; i <= 3; i++ {
+		if !q.Produce(testItem(i)) {
+			t.Fatalf("Produce() = false")
+		}
+	}
+	if q.Size() != 3 || q.diskSize() == 0 {
+		t.Errorf("got size %d and disk size %d, want 3 batches on disk", q.Size(), q.diskSize())
+	}
+	q.Stop()
+	if q.Produce(testItem(1)) {
+		t.Error("Produce() after Stop() = true, want false")
+	}
+
+	q = openTestQueue(t, cfg, nil)
+	if q.replayedBatches != 3 || q.replayedItems != 6 {
+		t.Fatalf("replayed %d batches and %d spans, want 3 and 6", q.replayedBatches, q.replayedItems)
+	}
+	got := make(chan int, 3)
+	q.StartConsumers(1, func(item interface{}) {
+		got <- len(item.(*queueItem).td.Spans)
+	})
+	for i := 1; i <= 3; i++ {
+		if n := <-got; n != i {
+			t.Errorf("got batch of %d spans, want %d", n, i)
+		}
+	}
+	q.Stop()
+
+	q = openTestQueue(t, cfg, nil)
+	if q.replayedBatches != 0 {
+		t.Errorf("replayed %d batches after they were sent, want 0", q.replayedBatches)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix)); len(paths) != 0 {
+		t.Errorf("got files %v after all batches were sent, want none", paths)
+	}
+	q.Stop()
+}
+
+func TestPersistentQueueDeletesSentSegments(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	q := openTestQueue(t, PersistenceConfig{Directory: dir, MaxSizeMiB: 1, Sync: SyncNever}, nil)
+	defer q.Stop()
+	var sent int32
+	q.StartConsumers(2, func(item interface{}) {
+		atomic.AddInt32(&sent, 1)
+	})
+
+	// Each segment has a quarter of the max size, so sending more than that requires
+	// the sent segments to be deleted.
+	spans := make([]*tracepb.Span, 1000)
+	for i := range spans {
+		spans[i] = &tracepb.Span{Name: &tracepb.TruncatableString{Value: "a-span-name-long-enough"}}
+	}
+	for i := 0; i < 200; i++ {
+		item := &queueItem{queuedTime: time.Now(), td: data.TraceData{Spans: spans}}
+		for !q.Produce(item) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for atomic.LoadInt32(&sent) < 200 {
+		time.Sleep(time.Millisecond)
+	}
+	if size := q.diskSize(); size > q.segmentSize {
+		t.Errorf("got disk size %d after all batches were sent, want at most one segment", size)
+	}
+}
+
+func TestPersistentQueueMaxSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	q := openTestQueue(t, PersistenceConfig{Directory: dir, MaxSizeMiB: 1}, nil)
+	defer q.Stop()
+	spans := make([]*tracepb.Span, 10000)
+	for i := range spans {
+		spans[i] = &tracepb.Span{Name: &tracepb.TruncatableString{Value: "a-span-name"}}
+	}
+	item := &queueItem{td: data.TraceData{Spans: spans}}
+	produced := 0
+	for q.Produce(item) {
+		produced++
+	}
+	if produced == 0 || q.diskSize() > 1<<20 {
+		t.Errorf("produced %d batches using %d bytes, want some batches within 1 MiB", produced, q.diskSize())
+	}
+}
+
+func TestPersistentQueueTornAndCorruptedRecords(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	cfg := PersistenceConfig{Directory: dir}
+
+	q := openTestQueue(t, cfg, nil)
+	q.Produce(testItem(1))
+	q.Produce(testItem(2))
+	q.Stop()
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(paths) != 1 {
+		t.Fatalf("got files %v, want one segment", paths)
+	}
+	f, err := os.OpenFile(paths[0], os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the first record and append an incomplete one.
+	if _, err := f.WriteAt([]byte{0xff}, recordHeaderSize+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{100, 0, 0}, info.Size()); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var corrupted int32
+	q = openTestQueue(t, cfg, func(numItems int, err error) {
+		atomic.AddInt32(&corrupted, int32(numItems))
+	})
+	defer q.Stop()
+	if q.replayedBatches != 2 {
+		t.Fatalf("replayed %d batches, want 2", q.replayedBatches)
+	}
+	got := make(chan int, 2)
+	q.StartConsumers(1, func(item interface{}) {
+		got <- len(item.(*queueItem).td.Spans)
+	})
+	if n := <-got; n != 2 {
+		t.Errorf("got batch of %d spans, want 2", n)
+	}
+	if n := atomic.LoadInt32(&corrupted); n != 1 {
+		t.Errorf("got %d corrupted spans, want 1", n)
+	}
+}
+
+func TestQueuedSpanProcessor_PersistentQueueSurvivesRestart(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	persistence := &PersistenceConfig{Directory: dir}
+
+	// The sender blocks, so the batches stay in the queue.
+	blocked := &blockingSpanSender{release: make(chan struct{})}
+	tc, err := NewQueuedSpanProcessor(blocked, Options.WithNumWorkers(1), Options.WithPersistence(persistence))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}, {}}})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// Release the batch held by the worker after the timeout, the others remain queued.
+	time.AfterFunc(100*time.Millisecond, func() { close(blocked.release) })
+	if err := tc.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v, want the spans to be kept on disk", err)
+	}
+	sent := atomic.LoadInt32(&blocked.spanCount)
+
+	sender := &blockingSpanSender{release: make(chan struct{})}
+	close(sender.release)
+	tc, err = NewQueuedSpanProcessor(sender, Options.WithNumWorkers(1), Options.WithPersistence(persistence))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := sent + atomic.LoadInt32(&sender.spanCount); got != 10 {
+		t.Errorf("got %d spans sent across restarts, want 10", got)
+	}
+}
+
+func TestQueuedSpanProcessor_PersistentQueueKeepsBatchesWaitingForRetry(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sender := &failingSpanSender{err: errors.New("unavailable")}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Hour),
+		Options.WithPersistence(&PersistenceConfig{Directory: dir}))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	sp := tc.(*queuedSpanProcessor)
+	defer sp.Stop()
+	sp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}, {}}})
+
+	// Once the failed record is acked, the batch waiting for its retry must still be on
+	// disk, so a crash during the back-off does not lose it.
+	unsent := func() (acked, unacked int) {
+		q := sp.persistentQueue
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for seg := range q.segments {
+			acked += seg.acked
+			unacked += seg.records - seg.acked
+		}
+		return acked, unacked
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		acked, unacked := unsent()
+		if acked == 1 {
+			if unacked != 1 {
+				t.Fatalf("got %d batches on disk while waiting for the retry, want 1", unacked)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the failed batch was not acked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPersistentQueueDirectoryInUse(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	cfg := PersistenceConfig{Directory: dir}
+
+	old := openTestQueue(t, cfg, nil)
+	old.Produce(testItem(1))
+
+	// A queue replacing another one on reload does not replay the files still in use.
+	q := openTestQueue(t, cfg, nil)
+	if q.replayedBatches != 0 {
+		t.Errorf("replayed %d batches of a queue in use, want 0", q.replayedBatches)
+	}
+	if !q.Produce(testItem(2)) {
+		t.Error("Produce() = false")
+	}
+	q.Stop()
+	old.Stop()
+
+	q = openTestQueue(t, cfg, nil)
+	defer q.Stop()
+	if q.replayedBatches != 2 {
+		t.Errorf("replayed %d batches, want the 2 left by both queues", q.replayedBatches)
+	}
+}
+
+func TestPersistentQueueDirectoryLockedByAnotherProcess(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// The lock held by another process is stood for by a lock on the file not taken
+	// via lockDirectory.
+	lock, err := lockFile(filepath.Join(dir, lockFileName))
+	if err != nil {
+		t.Fatalf("lockFile() = %v", err)
+	}
+	codec := itemCodec{encode: encodeQueueItem, decode: decodeQueueItem}
+	if _, err := openPersistentQueue(PersistenceConfig{Directory: dir}, codec, zap.NewNop()); err == nil {
+		t.Fatal("openPersistentQueue() = nil, want error for a locked directory")
+	}
+	lock.Close()
+
+	q := openTestQueue(t, PersistenceConfig{Directory: dir}, nil)
+	q.Stop()
+	if len(lockedDirectories.locks) != 0 {
+		t.Errorf("got %d directories still locked after Stop, want 0", len(lockedDirectories.locks))
+	}
+}
+
+func openTestQueue(t *testing.T, cfg PersistenceConfig, onCorrupted func(int, error)) *persistentQueue {
+	if onCorrupted == nil {
+		onCorrupted = func(numItems int, err error) {
+			t.Errorf("unexpected corrupted batch: %v", err)
+		}
+	}
+	q, err := openPersistentQueue(cfg, itemCodec{
+		encode:      encodeQueueItem,
+		decode:      decodeQueueItem,
+		onCorrupted: onCorrupted,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("openPersistentQueue() = %v", err)
+	}
+	return q
+}
+
+func testItem(numSpans int) *queueItem {
+	spans := make([]*tracepb.Span, numSpans)
+	for i := range spans {
+		spans[i] = &tracepb.Span{}
+	}
+	return &queueItem{queuedTime: time.Now(), td: data.TraceData{Spans: spans}}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "persistent-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,243 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jaegertracing/jaeger/pkg/queue"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+)
+
+type queuedMetricsProcessor struct {
+	// pending must be the first field so its counters are 64-bit aligned for the atomic
+	// operations on 32-bit platforms.
+	pending                  pendingCounter
+	name                     string
+	queue                    *queue.BoundedQueue
+	logger                   *zap.Logger
+	sender                   consumer.MetricsConsumer
+	numWorkers               int
+	retryOnProcessingFailure bool
+	retryPolicy              retryPolicy
+	retries                  *retryScheduler
+	stopCh                   chan struct{}
+	stopOnce                 sync.Once
+}
+
+var _ consumer.MetricsConsumer = (*queuedMetricsProcessor)(nil)
+var _ consumer.Shutdowner = (*queuedMetricsProcessor)(nil)
+
+type metricsQueueItem struct {
+	queuedTime time.Time
+	md         data.MetricsData
+	ctx        context.Context
+	retry      retryState
+}
+
+// NewQueuedMetricsProcessor returns a metrics processor that maintains a bounded
+// in-memory queue of metrics batches, and sends out metrics batches using the
+// provided sender. Batching options are ignored since each data.MetricsData is
+// already sent downstream as a single batch.
+func NewQueuedMetricsProcessor(sender consumer.MetricsConsumer, opts ...Option) consumer.MetricsConsumer {
+	options := Options.apply(opts...)
+	mp := newQueuedMetricsProcessor(sender, options)
+
+	mp.queue.StartConsumers(mp.numWorkers, func(item interface{}) {
+		value := item.(*metricsQueueItem)
+		mp.processItemFromQueue(value)
+	})
+
+	// Start a timer to report the queue length.
+	ctx, _ := tag.New(context.Background(), tag.Upsert(processor.TagExporterNameKey, mp.name))
+	ticker := time.NewTicker(1 * time.Second)
+	go func(ctx context.Context) {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mp.stopCh:
+				return
+			case <-ticker.C:
+				length := int64(mp.queue.Size())
+				stats.Record(ctx, statQueueLength.M(length))
+			}
+		}
+	}(ctx)
+
+	return mp
+}
+
+func newQueuedMetricsProcessor(sender consumer.MetricsConsumer, opts options) *queuedMetricsProcessor {
+	boundedQueue := queue.NewBoundedQueue(opts.queueSize, func(item interface{}) {})
+	return &queuedMetricsProcessor{
+		name:                     opts.name,
+		queue:                    boundedQueue,
+		logger:                   opts.logger,
+		numWorkers:               opts.numWorkers,
+		sender:                   sender,
+		retryOnProcessingFailure: opts.retryOnProcessingFailure,
+		retryPolicy:              opts.retryPolicy(),
+		retries:                  newRetryScheduler(),
+		stopCh:                   make(chan struct{}),
+	}
+}
+
+// Stop halts the metrics processor and all its goroutines. The batches waiting to be
+// retried are put back in the queue first.
+func (mp *queuedMetricsProcessor) Stop() {
+	mp.stopOnce.Do(func() {
+		close(mp.stopCh)
+		mp.retries.stop()
+		mp.queue.Stop()
+	})
+}
+
+// Shutdown puts the batches waiting for a retry back in the queue, for a last attempt,
+// and waits until all queued batches are sent, or ctx is done, and then halts the metrics
+// processor. The metrics that could not be sent are reported with a
+// *processor.LostDataError.
+func (mp *queuedMetricsProcessor) Shutdown(ctx context.Context) error {
+	mp.retries.stop()
+	mp.pending.drain(ctx)
+	mp.Stop()
+
+	if lost := mp.pending.numUnsent(); lost > 0 {
+		stats.RecordWithTags(context.Background(),
+			processor.StatsTagsForMetricsBatch(mp.name, ""),
+			processor.StatDroppedMetricCount.M(lost))
+		return &processor.LostDataError{Processor: mp.name, Metrics: lost}
+	}
+	return nil
+}
+
+// ConsumeMetricsData implements the MetricsProcessor interface
+func (mp *queuedMetricsProcessor) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	item := &metricsQueueItem{
+		queuedTime: time.Now(),
+		md:         md,
+		ctx:        ctx,
+	}
+
+	statsTags := processor.StatsTagsForMetricsBatch(mp.name, processor.ServiceNameForNode(md.Node))
+	numMetrics := len(md.Metrics)
+	stats.RecordWithTags(context.Background(), statsTags, processor.StatReceivedMetricCount.M(int64(numMetrics)))
+
+	addedToQueue := mp.enqueue(item)
+	if !addedToQueue {
+		mp.onItemDropped(item, statsTags)
+		return errQueueFull
+	}
+	return nil
+}
+
+// enqueue adds item to the queue, counting it as pending until it is sent or dropped.
+func (mp *queuedMetricsProcessor) enqueue(item *metricsQueueItem) bool {
+	mp.pending.add(len(item.md.Metrics))
+	if !mp.queue.Produce(item) {
+		mp.pending.done(len(item.md.Metrics))
+		return false
+	}
+	return true
+}
+
+func (mp *queuedMetricsProcessor) processItemFromQueue(item *metricsQueueItem) {
+	numMetrics := len(item.md.Metrics)
+	startTime := time.Now()
+	err := mp.sender.ConsumeMetricsData(item.ctx, item.md)
+	statsTags := processor.StatsTagsForMetricsBatch(mp.name, processor.ServiceNameForNode(item.md.Node))
+	if err == nil {
+		// Record latency metrics and return
+		sendLatencyMs := int64(time.Since(startTime) / time.Millisecond)
+		inQueueLatencyMs := int64(time.Since(item.queuedTime) / time.Millisecond)
+		stats.RecordWithTags(context.Background(),
+			statsTags,
+			statSuccessSendOps.M(1),
+			statSendLatencyMs.M(sendLatencyMs),
+			statInQueueLatencyMs.M(inQueueLatencyMs))
+		mp.pending.done(numMetrics)
+		return
+	}
+
+	// There was an error
+	stats.RecordWithTags(context.Background(), statsTags, statFailedSendOps.M(1))
+	mp.logger.Warn("Sender failed", zap.String("processor", mp.name), zap.Error(err))
+	if !mp.retryOnProcessingFailure {
+		// throw away the batch
+		mp.logger.Error("Failed to process batch, discarding", zap.String("processor", mp.name), zap.Int("batch-size", numMetrics))
+		mp.onItemDropped(item, statsTags)
+		mp.pending.done(numMetrics)
+		return
+	}
+	if consumererror.IsPermanent(err) {
+		mp.logger.Error("Failed to process batch with a permanent error, discarding", zap.String("processor", mp.name), zap.Int("batch-size", numMetrics))
+		stats.RecordWithTags(context.Background(), statsTags, statDroppedPermanentError.M(1))
+		mp.onItemDropped(item, statsTags)
+		mp.pending.done(numMetrics)
+		return
+	}
+
+	item.retry.failed()
+	delay, ok := mp.retryPolicy.nextDelay(item.retry)
+	if !ok {
+		mp.logger.Error("Failed to process batch after the maximum retries, discarding",
+			zap.String("processor", mp.name),
+			zap.Int("batch-size", numMetrics),
+			zap.Int("retries", item.retry.retries-1))
+		stats.RecordWithTags(context.Background(), statsTags, statDroppedMaxRetries.M(1))
+		mp.onItemDropped(item, statsTags)
+		mp.pending.done(numMetrics)
+		return
+	}
+
+	if mp.retries.isStopping() && item.retry.lastAttempt {
+		// The batch had its last attempt.
+		mp.pending.markUnsent(numMetrics)
+		return
+	}
+
+	// The batch waits for its retry out of the queue, so the worker can send the next
+	// ones, and is still counted as pending.
+	mp.logger.Warn("Failed to process batch, retrying after back-off",
+		zap.String("processor", mp.name),
+		zap.Int("batch-size", numMetrics),
+		zap.Int("retry", item.retry.retries),
+		zap.Duration("backoff-delay", delay))
+	mp.retries.schedule(delay, func() {
+		item.retry.lastAttempt = mp.retries.isStopping()
+		if !mp.queue.Produce(item) {
+			mp.logger.Error("Failed to re-enqueue batch", zap.String("processor", mp.name), zap.Int("batch-size", numMetrics))
+			mp.onItemDropped(item, statsTags)
+			mp.pending.done(numMetrics)
+		}
+	})
+}
+
+func (mp *queuedMetricsProcessor) onItemDropped(item *metricsQueueItem, statsTags []tag.Mutator) {
+	numMetrics := len(item.md.Metrics)
+	stats.RecordWithTags(context.Background(), statsTags, processor.StatDroppedMetricCount.M(int64(numMetrics)))
+
+	mp.logger.Warn("Metrics batch dropped",
+		zap.String("processor", mp.name),
+		zap.Int("#metrics", numMetrics))
+}
@@ -16,9 +16,13 @@ package queued
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"sync"
 	"time"
 
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	"github.com/golang/protobuf/proto"
 	"github.com/jaegertracing/jaeger/pkg/queue"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
@@ -26,38 +30,127 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
 )
 
+// errQueueFull is returned when a batch does not fit in the queue. It is a
+// consumererror.ResourceExhausted error so that the sender of the batch retries it.
+var errQueueFull = consumererror.ResourceExhausted(errors.New("queue is full"), 0)
+
 type queuedSpanProcessor struct {
+	// pending must be the first field so its counters are 64-bit aligned for the atomic
+	// operations on 32-bit platforms.
+	pending                  pendingCounter
 	name                     string
-	queue                    *queue.BoundedQueue
+	queue                    itemQueue
+	persistentQueue          *persistentQueue
 	logger                   *zap.Logger
 	sender                   consumer.TraceConsumer
 	numWorkers               int
 	retryOnProcessingFailure bool
-	backoffDelay             time.Duration
+	retryPolicy              retryPolicy
+	retries                  *retryScheduler
 	stopCh                   chan struct{}
 	stopOnce                 sync.Once
 }
 
 var _ consumer.TraceConsumer = (*queuedSpanProcessor)(nil)
+var _ consumer.Shutdowner = (*queuedSpanProcessor)(nil)
+
+// itemQueue is the queue of the batches waiting for a worker, a queue.BoundedQueue in
+// memory or a persistentQueue on disk.
+type itemQueue interface {
+	Produce(item interface{}) bool
+	StartConsumers(num int, consumer func(item interface{}))
+	Stop()
+	Size() int
+}
 
 type queueItem struct {
 	queuedTime time.Time
 	td         data.TraceData
 	ctx        context.Context
+	retry      retryState
+}
+
+// encodeQueueItem encodes a *queueItem for the persistent queue: the queued time, the
+// time of the first failure, the number of retries, the length of the source format and
+// the format, followed by the node, resource and spans as an ExportTraceServiceRequest.
+func encodeQueueItem(item interface{}) (int, []byte, error) {
+	qi := item.(*queueItem)
+	req, err := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Node:     qi.td.Node,
+		Resource: qi.td.Resource,
+		Spans:    qi.td.Spans,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	buf := make([]byte, 16+2*binary.MaxVarintLen64+len(qi.td.SourceFormat)+len(req))
+	binary.LittleEndian.PutUint64(buf, uint64(qi.queuedTime.UnixNano()))
+	if !qi.retry.firstFailure.IsZero() {
+		binary.LittleEndian.PutUint64(buf[8:], uint64(qi.retry.firstFailure.UnixNano()))
+	}
+	n := 16 + binary.PutUvarint(buf[16:], uint64(qi.retry.retries))
+	n += binary.PutUvarint(buf[n:], uint64(len(qi.td.SourceFormat)))
+	n += copy(buf[n:], qi.td.SourceFormat)
+	n += copy(buf[n:], req)
+	return len(qi.td.Spans), buf[:n], nil
+}
+
+var errInvalidQueueItem = errors.New("invalid span batch in persistent queue")
+
+func decodeQueueItem(payload []byte) (interface{}, error) {
+	if len(payload) < 16 {
+		return nil, errInvalidQueueItem
+	}
+	item := &queueItem{
+		queuedTime: time.Unix(0, int64(binary.LittleEndian.Uint64(payload))),
+		// The context of the receiver does not survive a restart.
+		ctx: context.Background(),
+	}
+	if firstFailure := int64(binary.LittleEndian.Uint64(payload[8:])); firstFailure != 0 {
+		item.retry.firstFailure = time.Unix(0, firstFailure)
+	}
+	retries, n := binary.Uvarint(payload[16:])
+	if n <= 0 {
+		return nil, errInvalidQueueItem
+	}
+	item.retry.retries = int(retries)
+	start := 16 + n
+	formatLen, n := binary.Uvarint(payload[start:])
+	if n <= 0 || uint64(len(payload)-start-n) < formatLen {
+		return nil, errInvalidQueueItem
+	}
+	start += n
+	format := string(payload[start : start+int(formatLen)])
+	req := &agenttracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(payload[start+int(formatLen):], req); err != nil {
+		return nil, err
+	}
+	item.td = data.TraceData{
+		Node:         req.Node,
+		Resource:     req.Resource,
+		Spans:        req.Spans,
+		SourceFormat: format,
+	}
+	return item, nil
 }
 
 // NewQueuedSpanProcessor returns a span processor that maintains a bounded
-// in-memory queue of span batches, and sends out span batches using the
-// provided sender
-func NewQueuedSpanProcessor(sender consumer.TraceConsumer, opts ...Option) consumer.TraceConsumer {
+// queue of span batches, in memory or on disk if persistence is configured, and
+// sends out span batches using the provided sender
+func NewQueuedSpanProcessor(sender consumer.TraceConsumer, opts ...Option) (consumer.TraceConsumer, error) {
 	options := Options.apply(opts...)
-	sp := newQueuedSpanProcessor(sender, options)
+	sp, err := newQueuedSpanProcessor(sender, options)
+	if err != nil {
+		return nil, err
+	}
 
 	sp.queue.StartConsumers(sp.numWorkers, func(item interface{}) {
 		value := item.(*queueItem)
@@ -76,6 +169,9 @@ func NewQueuedSpanProcessor(sender consumer.TraceConsumer, opts ...Option) consu
 			case <-ticker.C:
 				length := int64(sp.queue.Size())
 				stats.Record(ctx, statQueueLength.M(length))
+				if sp.persistentQueue != nil {
+					stats.Record(ctx, statQueueDiskBytes.M(sp.persistentQueue.diskSize()))
+				}
 			}
 		}
 	}(ctx)
@@ -83,34 +179,115 @@ func NewQueuedSpanProcessor(sender consumer.TraceConsumer, opts ...Option) consu
 	if options.batchingEnabled {
 		sp.logger.Info("Using queued processor with batching.")
 		batcher := nodebatcher.NewBatcher(sp.name, sp.logger, sp, options.batchingOptions...)
-		return batcher
+		return &batchingQueuedSpanProcessor{TraceConsumer: batcher, queue: sp}, nil
 	}
 
-	return sp
+	return sp, nil
+}
+
+// batchingQueuedSpanProcessor is the batcher placed in front of a queued span processor
+// when batching is enabled, it stops and shuts down both.
+type batchingQueuedSpanProcessor struct {
+	consumer.TraceConsumer
+	queue *queuedSpanProcessor
+}
+
+var _ consumer.Shutdowner = (*batchingQueuedSpanProcessor)(nil)
+
+// Stop sends the pending batches to the queue and halts the queue workers.
+func (bp *batchingQueuedSpanProcessor) Stop() {
+	bp.TraceConsumer.(interface{ Stop() }).Stop()
+	bp.queue.Stop()
 }
 
-func newQueuedSpanProcessor(sender consumer.TraceConsumer, opts options) *queuedSpanProcessor {
-	boundedQueue := queue.NewBoundedQueue(opts.queueSize, func(item interface{}) {})
-	return &queuedSpanProcessor{
+// Shutdown sends the pending batches to the queue and then drains it, see
+// queuedSpanProcessor.Shutdown.
+func (bp *batchingQueuedSpanProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := bp.TraceConsumer.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := bp.queue.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return internal.CombineErrors(errs)
+}
+
+func newQueuedSpanProcessor(sender consumer.TraceConsumer, opts options) (*queuedSpanProcessor, error) {
+	sp := &queuedSpanProcessor{
 		name:                     opts.name,
-		queue:                    boundedQueue,
 		logger:                   opts.logger,
 		numWorkers:               opts.numWorkers,
 		sender:                   sender,
 		retryOnProcessingFailure: opts.retryOnProcessingFailure,
-		backoffDelay:             opts.backoffDelay,
+		retryPolicy:              opts.retryPolicy(),
+		retries:                  newRetryScheduler(),
 		stopCh:                   make(chan struct{}),
 	}
+	if opts.persistence == nil {
+		sp.queue = queue.NewBoundedQueue(opts.queueSize, func(item interface{}) {})
+		return sp, nil
+	}
+
+	codec := itemCodec{
+		encode:      encodeQueueItem,
+		decode:      decodeQueueItem,
+		onCorrupted: sp.onCorruptedItem,
+	}
+	pq, err := openPersistentQueue(*opts.persistence, codec, sp.logger)
+	if err != nil {
+		return nil, err
+	}
+	sp.queue = pq
+	sp.persistentQueue = pq
+	sp.pending.addBatches(pq.replayedBatches, pq.replayedItems)
+
+	ctx, _ := tag.New(context.Background(), tag.Upsert(processor.TagExporterNameKey, sp.name))
+	stats.Record(ctx, statReplayedBatches.M(pq.replayedBatches), statReplayedSpans.M(pq.replayedItems))
+	if pq.replayedBatches > 0 {
+		sp.logger.Info("Replaying span batches from the persistent queue",
+			zap.String("processor", sp.name),
+			zap.String("directory", opts.persistence.Directory),
+			zap.Int64("batches", pq.replayedBatches),
+			zap.Int64("#spans", pq.replayedItems))
+	}
+	return sp, nil
 }
 
-// Stop halts the span processor and all its goroutines.
+// Stop halts the span processor and all its goroutines. The batches waiting to be
+// retried are put back in the queue first.
 func (sp *queuedSpanProcessor) Stop() {
 	sp.stopOnce.Do(func() {
 		close(sp.stopCh)
+		sp.retries.stop()
 		sp.queue.Stop()
 	})
 }
 
+// Shutdown puts the batches waiting for a retry back in the queue, for a last attempt,
+// and waits until all queued batches are sent, or ctx is done, and then halts the span
+// processor. The spans that could not be sent are reported with a
+// *processor.LostDataError, unless they are kept in the persistent queue.
+func (sp *queuedSpanProcessor) Shutdown(ctx context.Context) error {
+	sp.retries.stop()
+	sp.pending.drain(ctx)
+	sp.Stop()
+
+	if lost := sp.pending.numUnsent(); lost > 0 {
+		if sp.persistentQueue != nil {
+			sp.logger.Info("Spans kept in the persistent queue until the next start",
+				zap.String("processor", sp.name),
+				zap.Int64("#spans", lost))
+			return nil
+		}
+		stats.RecordWithTags(context.Background(),
+			processor.StatsTagsForBatch(sp.name, "", ""),
+			processor.StatDroppedSpanCount.M(lost))
+		return &processor.LostDataError{Processor: sp.name, Spans: lost}
+	}
+	return nil
+}
+
 // ConsumeTraceData implements the SpanProcessor interface
 func (sp *queuedSpanProcessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
 	item := &queueItem{
@@ -123,14 +300,26 @@ func (sp *queuedSpanProcessor) ConsumeTraceData(ctx context.Context, td data.Tra
 	numSpans := len(td.Spans)
 	stats.RecordWithTags(context.Background(), statsTags, processor.StatReceivedSpanCount.M(int64(numSpans)))
 
-	addedToQueue := sp.queue.Produce(item)
+	addedToQueue := sp.enqueue(item)
 	if !addedToQueue {
 		sp.onItemDropped(item, statsTags)
+		return errQueueFull
 	}
 	return nil
 }
 
+// enqueue adds item to the queue, counting it as pending until it is sent or dropped.
+func (sp *queuedSpanProcessor) enqueue(item *queueItem) bool {
+	sp.pending.add(len(item.td.Spans))
+	if !sp.queue.Produce(item) {
+		sp.pending.done(len(item.td.Spans))
+		return false
+	}
+	return true
+}
+
 func (sp *queuedSpanProcessor) processItemFromQueue(item *queueItem) {
+	numSpans := len(item.td.Spans)
 	startTime := time.Now()
 	err := sp.sender.ConsumeTraceData(item.ctx, item.td)
 	if err == nil {
@@ -143,44 +332,91 @@ func (sp *queuedSpanProcessor) processItemFromQueue(item *queueItem) {
 			statSuccessSendOps.M(1),
 			statSendLatencyMs.M(sendLatencyMs),
 			statInQueueLatencyMs.M(inQueueLatencyMs))
-
+		sp.pending.done(numSpans)
 		return
 	}
 
 	// There was an error
+	if failed, ok := consumererror.FailedTraceData(err); ok {
+		// Only the failed spans are retried or dropped, the others were sent.
+		sp.pending.done(numSpans - len(failed.Spans))
+		item.td = failed
+		numSpans = len(failed.Spans)
+	}
 	statsTags := processor.StatsTagsForBatch(sp.name, processor.ServiceNameForNode(item.td.Node), item.td.SourceFormat)
 	stats.RecordWithTags(context.Background(), statsTags, statFailedSendOps.M(1))
-	batchSize := len(item.td.Spans)
 	sp.logger.Warn("Sender failed", zap.String("processor", sp.name), zap.Error(err), zap.String("spanFormat", item.td.SourceFormat))
 	if !sp.retryOnProcessingFailure {
 		// throw away the batch
-		sp.logger.Error("Failed to process batch, discarding", zap.String("processor", sp.name), zap.Int("batch-size", batchSize))
+		sp.logger.Error("Failed to process batch, discarding", zap.String("processor", sp.name), zap.Int("batch-size", numSpans))
 		sp.onItemDropped(item, statsTags)
-	} else {
-		// TODO: (@pjanotti) do not put it back on the end of the queue, retry with it directly.
-		// This will have the benefit of keeping the batch closer to related ones in time.
-		if !sp.queue.Produce(item) {
-			sp.logger.Error("Failed to process batch and failed to re-enqueue", zap.String("processor", sp.name), zap.Int("batch-size", batchSize))
-			sp.onItemDropped(item, statsTags)
-		} else {
-			sp.logger.Warn("Failed to process batch, re-enqueued", zap.String("processor", sp.name), zap.Int("batch-size", batchSize))
-		}
+		sp.pending.done(numSpans)
+		return
+	}
+	if consumererror.IsPermanent(err) {
+		sp.logger.Error("Failed to process batch with a permanent error, discarding", zap.String("processor", sp.name), zap.Int("batch-size", numSpans))
+		stats.RecordWithTags(context.Background(), statsTags, statDroppedPermanentError.M(1))
+		sp.onItemDropped(item, statsTags)
+		sp.pending.done(numSpans)
+		return
 	}
 
-	// back-off for configured delay, but get interrupted when shutting down
-	if sp.backoffDelay > 0 {
-		sp.logger.Warn("Backing off before next attempt",
+	item.retry.failed()
+	delay, ok := sp.retryPolicy.nextDelay(item.retry)
+	if !ok {
+		sp.logger.Error("Failed to process batch after the maximum retries, discarding",
 			zap.String("processor", sp.name),
-			zap.Duration("backoff-delay", sp.backoffDelay))
-		select {
-		case <-sp.stopCh:
-			sp.logger.Info("Interrupted due to shutdown", zap.String("processor", sp.name))
-			break
-		case <-time.After(sp.backoffDelay):
-			sp.logger.Info("Resume processing", zap.String("processor", sp.name))
-			break
+			zap.Int("batch-size", numSpans),
+			zap.Int("retries", item.retry.retries-1))
+		stats.RecordWithTags(context.Background(), statsTags, statDroppedMaxRetries.M(1))
+		sp.onItemDropped(item, statsTags)
+		sp.pending.done(numSpans)
+		return
+	}
+
+	if sp.retries.isStopping() && (sp.persistentQueue != nil || item.retry.lastAttempt) {
+		// The batch is kept in the persistent queue until the next start, or had its last
+		// attempt and is lost.
+		if sp.persistentQueue != nil {
+			if _, ok := sp.persistentQueue.persist(item); !ok {
+				sp.onItemDropped(item, statsTags)
+				sp.pending.done(numSpans)
+				return
+			}
+		}
+		sp.pending.markUnsent(numSpans)
+		return
+	}
+
+	// The batch waits for its retry out of the queue, so the worker can send the next
+	// ones, and is still counted as pending.
+	sp.logger.Warn("Failed to process batch, retrying after back-off",
+		zap.String("processor", sp.name),
+		zap.Int("batch-size", numSpans),
+		zap.Int("retry", item.retry.retries),
+		zap.Duration("backoff-delay", delay))
+	if sp.persistentQueue != nil {
+		// The record of the batch is deleted once this function returns, so the batch is
+		// written again, with its retry state, before waiting for the back-off.
+		rec, ok := sp.persistentQueue.persist(item)
+		if !ok {
+			sp.logger.Error("Failed to persist batch for retry", zap.String("processor", sp.name), zap.Int("batch-size", numSpans))
+			sp.onItemDropped(item, statsTags)
+			sp.pending.done(numSpans)
+			return
 		}
+		// If the queue is already closed the batch is replayed on the next start.
+		sp.retries.schedule(delay, func() { sp.persistentQueue.enqueue(rec) })
+		return
 	}
+	sp.retries.schedule(delay, func() {
+		item.retry.lastAttempt = sp.retries.isStopping()
+		if !sp.queue.Produce(item) {
+			sp.logger.Error("Failed to re-enqueue batch", zap.String("processor", sp.name), zap.Int("batch-size", numSpans))
+			sp.onItemDropped(item, statsTags)
+			sp.pending.done(numSpans)
+		}
+	})
 }
 
 func (sp *queuedSpanProcessor) onItemDropped(item *queueItem, statsTags []tag.Mutator) {
@@ -193,6 +429,18 @@ func (sp *queuedSpanProcessor) onItemDropped(item *queueItem, statsTags []tag.Mu
 		zap.String("spanSource", item.td.SourceFormat))
 }
 
+// onCorruptedItem discards a batch of the persistent queue that could not be read back.
+func (sp *queuedSpanProcessor) onCorruptedItem(numSpans int, err error) {
+	stats.RecordWithTags(context.Background(),
+		processor.StatsTagsForBatch(sp.name, "", ""),
+		processor.StatDroppedSpanCount.M(int64(numSpans)))
+	sp.pending.done(numSpans)
+	sp.logger.Error("Failed to read span batch from the persistent queue, discarding",
+		zap.String("processor", sp.name),
+		zap.Int("#spans", numSpans),
+		zap.Error(err))
+}
+
 // Variables related to metrics specific to queued processor.
 var (
 	statInQueueLatencyMs = stats.Int64("queue_latency", "Latency (in milliseconds) that a batch stayed in queue", stats.UnitMilliseconds)
@@ -202,6 +450,13 @@ var (
 	statFailedSendOps  = stats.Int64("fail_send", "Number of failed send operations", stats.UnitDimensionless)
 
 	statQueueLength = stats.Int64("queue_length", "Current length of the queue (in batches)", stats.UnitDimensionless)
+
+	statDroppedPermanentError = stats.Int64("dropped_permanent_error", "Number of batches dropped because of a permanent error", stats.UnitDimensionless)
+	statDroppedMaxRetries     = stats.Int64("dropped_after_max_retries", "Number of batches dropped after exceeding the maximum retries", stats.UnitDimensionless)
+
+	statQueueDiskBytes  = stats.Int64("queue_disk_bytes", "Current size of the persistent queue files", stats.UnitBytes)
+	statReplayedBatches = stats.Int64("queue_replayed_batches", "Number of batches replayed from the persistent queue on start", stats.UnitDimensionless)
+	statReplayedSpans   = stats.Int64("queue_replayed_spans", "Number of spans replayed from the persistent queue on start", stats.UnitDimensionless)
 )
 
 // MetricViews return the metrics views according to given telemetry level.
@@ -239,6 +494,21 @@ func MetricViews(level telemetry.Level) []*view.View {
 		Aggregation: view.Sum(),
 	}
 
+	countDroppedPermanentErrorView := &view.View{
+		Name:        statDroppedPermanentError.Name(),
+		Measure:     statDroppedPermanentError,
+		Description: "The number of batches dropped by queued exporter because of a permanent error",
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+	countDroppedMaxRetriesView := &view.View{
+		Name:        statDroppedMaxRetries.Name(),
+		Measure:     statDroppedMaxRetries,
+		Description: "The number of batches dropped by queued exporter after exceeding the maximum retries",
+		TagKeys:     tagKeys,
+		Aggregation: view.Sum(),
+	}
+
 	latencyDistributionAggregation := view.Distribution(10, 25, 50, 75, 100, 250, 500, 750, 1000, 2000, 3000, 4000, 5000, 10000, 20000, 30000, 50000)
 
 	sendLatencyView := &view.View{
@@ -256,5 +526,30 @@ func MetricViews(level telemetry.Level) []*view.View {
 		Aggregation: latencyDistributionAggregation,
 	}
 
-	return []*view.View{queueLengthView, countSuccessSendView, countFailuresSendView, sendLatencyView, inQueueLatencyView}
+	queueDiskBytesView := &view.View{
+		Name:        statQueueDiskBytes.Name(),
+		Measure:     statQueueDiskBytes,
+		Description: "Current size in bytes of the persistent queue files of the queued exporter",
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.LastValue(),
+	}
+	countReplayedBatchesView := &view.View{
+		Name:        statReplayedBatches.Name(),
+		Measure:     statReplayedBatches,
+		Description: "The number of batches replayed from the persistent queue of the queued exporter",
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.Sum(),
+	}
+	countReplayedSpansView := &view.View{
+		Name:        statReplayedSpans.Name(),
+		Measure:     statReplayedSpans,
+		Description: "The number of spans replayed from the persistent queue of the queued exporter",
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{
+		queueLengthView, countSuccessSendView, countFailuresSendView, sendLatencyView, inQueueLatencyView,
+		countDroppedPermanentErrorView, countDroppedMaxRetriesView, queueDiskBytesView, countReplayedBatchesView, countReplayedSpansView,
+	}
 }
@@ -16,19 +16,28 @@ package queued
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/data"
 )
 
 func TestQueueProcessorHappyPath(t *testing.T) {
 	mockProc := newMockConcurrentSpanProcessor()
-	qp := NewQueuedSpanProcessor(mockProc)
+	qp, err := NewQueuedSpanProcessor(mockProc)
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
 	goFn := func(td data.TraceData) {
 		qp.ConsumeTraceData(context.Background(), td)
 	}
@@ -84,3 +93,364 @@ func (p *mockConcurrentSpanProcessor) runConcurrently(fn func()) {
 func (p *mockConcurrentSpanProcessor) awaitAsyncProcessing() {
 	p.waitGroup.Wait()
 }
+
+func TestQueueMetricsProcessorHappyPath(t *testing.T) {
+	mockProc := newMockConcurrentMetricsProcessor()
+	qp := NewQueuedMetricsProcessor(mockProc)
+	goFn := func(md data.MetricsData) {
+		qp.ConsumeMetricsData(context.Background(), md)
+	}
+
+	metrics := []*metricspb.Metric{{}}
+	wantBatches := 10
+	wantMetrics := 0
+	for i := 0; i < wantBatches; i++ {
+		md := data.MetricsData{
+			Metrics: metrics,
+		}
+		wantMetrics += len(metrics)
+		metrics = append(metrics, &metricspb.Metric{})
+		fn := func() { goFn(md) }
+		mockProc.runConcurrently(fn)
+	}
+
+	// Wait until all batches received
+	mockProc.awaitAsyncProcessing()
+
+	if wantBatches != int(mockProc.batchCount) {
+		t.Fatalf("Wanted %d batches, got %d", wantBatches, mockProc.batchCount)
+	}
+	if wantMetrics != int(mockProc.metricCount) {
+		t.Fatalf("Wanted %d metrics, got %d", wantMetrics, mockProc.metricCount)
+	}
+}
+
+type mockConcurrentMetricsProcessor struct {
+	waitGroup   *sync.WaitGroup
+	batchCount  int32
+	metricCount int32
+}
+
+var _ consumer.MetricsConsumer = (*mockConcurrentMetricsProcessor)(nil)
+
+func (p *mockConcurrentMetricsProcessor) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	atomic.AddInt32(&p.batchCount, 1)
+	atomic.AddInt32(&p.metricCount, int32(len(md.Metrics)))
+	p.waitGroup.Done()
+	return nil
+}
+
+func newMockConcurrentMetricsProcessor() *mockConcurrentMetricsProcessor {
+	return &mockConcurrentMetricsProcessor{waitGroup: new(sync.WaitGroup)}
+}
+
+func (p *mockConcurrentMetricsProcessor) runConcurrently(fn func()) {
+	p.waitGroup.Add(1)
+	go fn()
+}
+
+func (p *mockConcurrentMetricsProcessor) awaitAsyncProcessing() {
+	p.waitGroup.Wait()
+}
+
+func TestQueuedSpanProcessor_ShutdownDrainsQueue(t *testing.T) {
+	sender := &blockingSpanSender{release: make(chan struct{})}
+	tc, err := NewQueuedSpanProcessor(sender, Options.WithNumWorkers(1))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	qp := tc.(consumer.Shutdowner)
+	for i := 0; i < 5; i++ {
+		tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}, {}}})
+	}
+
+	close(sender.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := qp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := atomic.LoadInt32(&sender.spanCount); got != 10 {
+		t.Errorf("got %d spans sent, want 10", got)
+	}
+}
+
+func TestQueuedSpanProcessor_ShutdownReportsLostSpans(t *testing.T) {
+	// The workers are not started, so nothing leaves the queue.
+	qp, err := newQueuedSpanProcessor(newMockConcurrentSpanProcessor(), Options.apply())
+	if err != nil {
+		t.Fatalf("newQueuedSpanProcessor() = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		qp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}, {}}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = qp.Shutdown(ctx)
+	lostErr, ok := err.(*processor.LostDataError)
+	if !ok {
+		t.Fatalf("Shutdown() = %v, want a *processor.LostDataError", err)
+	}
+	if lostErr.Spans != 10 {
+		t.Errorf("got %d lost spans, want 10", lostErr.Spans)
+	}
+}
+
+func TestQueuedSpanProcessor_FullQueueRefusesData(t *testing.T) {
+	// The workers are not started, so nothing leaves the queue.
+	qp, err := newQueuedSpanProcessor(newMockConcurrentSpanProcessor(), Options.apply(Options.WithQueueSize(1)))
+	if err != nil {
+		t.Fatalf("newQueuedSpanProcessor() = %v", err)
+	}
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+	if err := qp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+	err = qp.ConsumeTraceData(context.Background(), td)
+	if !consumererror.IsResourceExhausted(err) {
+		t.Errorf("ConsumeTraceData() with a full queue = %v, want a resource exhausted error", err)
+	}
+}
+
+// blockingSpanSender sends each batch only once release is closed.
+type blockingSpanSender struct {
+	release   chan struct{}
+	spanCount int32
+}
+
+func (s *blockingSpanSender) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	<-s.release
+	atomic.AddInt32(&s.spanCount, int32(len(td.Spans)))
+	return nil
+}
+
+func TestQueuedSpanProcessor_PermanentErrorNotRetried(t *testing.T) {
+	sender := &failingSpanSender{err: consumererror.Permanent(errors.New("rejected"))}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := atomic.LoadInt32(&sender.calls); got != 1 {
+		t.Errorf("got %d attempts, want 1", got)
+	}
+}
+
+func TestQueuedSpanProcessor_DropsAfterMaxRetries(t *testing.T) {
+	sender := &failingSpanSender{err: errors.New("unavailable")}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Millisecond),
+		Options.WithMaxRetries(2))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}})
+
+	// Shutdown flushes the retries, so it is called once the batch is dropped.
+	waitUntil(t, func() bool { return atomic.LoadInt32(&sender.calls) == 3 })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if got := atomic.LoadInt32(&sender.calls); got != 3 {
+		t.Errorf("got %d attempts, want the first one and 2 retries", got)
+	}
+}
+
+func TestQueuedSpanProcessor_ShutdownFlushesRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		wantAttempts int32
+		wantLost     int64
+	}{
+		{name: "last_attempt_succeeds", failures: 1, wantAttempts: 2},
+		{name: "last_attempt_fails", failures: 2, wantAttempts: 2, wantLost: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &failingSpanSender{err: errors.New("unavailable")}
+			sender.fail = func(td data.TraceData) bool {
+				return atomic.LoadInt32(&sender.calls) <= tt.failures
+			}
+			tc, err := NewQueuedSpanProcessor(sender,
+				Options.WithNumWorkers(1),
+				Options.WithRetryOnProcessingFailures(true),
+				Options.WithBackoffDelay(time.Hour))
+			if err != nil {
+				t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+			}
+			tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}})
+			waitUntil(t, func() bool { return atomic.LoadInt32(&sender.calls) == 1 })
+
+			// The batch waits an hour for its retry, Shutdown sends it right away instead
+			// of waiting for the deadline.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			err = tc.(consumer.Shutdowner).Shutdown(ctx)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Shutdown() took %v, want the retry sent right away", elapsed)
+			}
+			if got := atomic.LoadInt32(&sender.calls); got != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantLost == 0 {
+				if err != nil {
+					t.Errorf("Shutdown() = %v", err)
+				}
+				return
+			}
+			lostErr, ok := err.(*processor.LostDataError)
+			if !ok {
+				t.Fatalf("Shutdown() = %v, want a *processor.LostDataError", err)
+			}
+			if lostErr.Spans != tt.wantLost {
+				t.Errorf("got %d lost spans, want %d", lostErr.Spans, tt.wantLost)
+			}
+		})
+	}
+}
+
+func TestQueuedSpanProcessor_ShutdownRetriesBatchFailingDuringDrain(t *testing.T) {
+	release := make(chan struct{})
+	sender := &failingSpanSender{err: errors.New("unavailable")}
+	sender.fail = func(td data.TraceData) bool {
+		if atomic.LoadInt32(&sender.calls) == 1 {
+			<-release
+			return true
+		}
+		return false
+	}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Hour))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}})
+	waitUntil(t, func() bool { return atomic.LoadInt32(&sender.calls) == 1 })
+
+	// The first attempt fails once Shutdown started, the batch still gets its last attempt.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- tc.(consumer.Shutdowner).Shutdown(ctx) }()
+	waitUntil(t, func() bool { return tc.(*queuedSpanProcessor).retries.isStopping() })
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if got := atomic.LoadInt32(&sender.calls); got != 2 {
+		t.Errorf("got %d attempts, want 2", got)
+	}
+}
+
+func TestQueuedSpanProcessor_RetryDoesNotBlockWorker(t *testing.T) {
+	sent := make(chan string, 1)
+	sender := &failingSpanSender{
+		err: errors.New("unavailable"),
+		fail: func(td data.TraceData) bool {
+			if td.SourceFormat == "failing" {
+				return true
+			}
+			sent <- td.SourceFormat
+			return false
+		},
+	}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Hour))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	defer tc.(interface{ Stop() }).Stop()
+
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}, SourceFormat: "failing"})
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}, SourceFormat: "ok"})
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the batch after the failed one was not sent while it waits for its retry")
+	}
+}
+
+func TestQueuedSpanProcessor_PartialErrorRetriesFailedSpans(t *testing.T) {
+	var mu sync.Mutex
+	var sent []data.TraceData
+	failed := data.TraceData{Spans: []*tracepb.Span{{TraceId: []byte{2}}}}
+	sender := &failingSpanSender{
+		err: consumererror.PartialTraces(errors.New("unavailable"), failed),
+		fail: func(td data.TraceData) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, td)
+			return len(sent) == 1
+		},
+	}
+	tc, err := NewQueuedSpanProcessor(sender,
+		Options.WithNumWorkers(1),
+		Options.WithRetryOnProcessingFailures(true),
+		Options.WithBackoffDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewQueuedSpanProcessor() = %v", err)
+	}
+	tc.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{TraceId: []byte{1}}, failed.Spans[0]}})
+
+	waitUntil(t, func() bool { return atomic.LoadInt32(&sender.calls) == 2 })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tc.(consumer.Shutdowner).Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(sent))
+	}
+	if !reflect.DeepEqual(sent[1].Spans, failed.Spans) {
+		t.Errorf("retried %v, want only the failed spans %v", sent[1].Spans, failed.Spans)
+	}
+}
+
+// failingSpanSender fails with err the batches for which fail returns true, or all of
+// them if fail is nil.
+type failingSpanSender struct {
+	err   error
+	fail  func(td data.TraceData) bool
+	calls int32
+}
+
+func (s *failingSpanSender) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	atomic.AddInt32(&s.calls, 1)
+	if s.fail == nil || s.fail(td) {
+		return s.err
+	}
+	return nil
+}
+
+// waitUntil waits for cond to be true, failing the test after 5 seconds.
+func waitUntil(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
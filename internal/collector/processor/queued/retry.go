@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxBackoffDelay is the default maximum delay between the retries of a batch
+	DefaultMaxBackoffDelay = time.Minute
+
+	backoffMultiplier = 2
+	// backoffJitter is the fraction of the delay randomly added or removed, so the
+	// batches that failed together are not all retried at the same time.
+	backoffJitter = 0.5
+)
+
+// retryPolicy decides if and when a failed batch is retried.
+type retryPolicy struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	// maxRetries and maxElapsedTime limit the retries of a batch, zero means no limit.
+	maxRetries     int
+	maxElapsedTime time.Duration
+}
+
+// retryState is the retry progress of a batch.
+type retryState struct {
+	retries      int
+	firstFailure time.Time
+	// lastAttempt is set when the batch is retried during shutdown, it is not retried
+	// again.
+	lastAttempt bool
+}
+
+// failed records a failed attempt to send the batch.
+func (rs *retryState) failed() {
+	if rs.retries == 0 {
+		rs.firstFailure = time.Now()
+	}
+	rs.retries++
+}
+
+// nextDelay returns how long to wait before retrying a batch, the delay grows
+// exponentially with the number of retries and, with its jitter, never exceeds
+// maxDelay. It returns false if the batch exceeded the retries allowed and must be
+// dropped.
+func (rp retryPolicy) nextDelay(rs retryState) (time.Duration, bool) {
+	if rp.maxRetries > 0 && rs.retries > rp.maxRetries {
+		return 0, false
+	}
+	if rp.maxElapsedTime > 0 && time.Since(rs.firstFailure) > rp.maxElapsedTime {
+		return 0, false
+	}
+	delay := rp.initialDelay
+	for i := 1; i < rs.retries && delay > 0 && delay < rp.maxDelay; i++ {
+		delay *= backoffMultiplier
+	}
+	jitter := backoffJitter * (2*rand.Float64() - 1)
+	delay = time.Duration(float64(delay) * (1 + jitter))
+	if delay > rp.maxDelay {
+		delay = rp.maxDelay
+	}
+	return delay, true
+}
+
+// retryScheduler puts the failed batches back in the queue after their delay, without
+// holding a worker.
+type retryScheduler struct {
+	mu       sync.Mutex
+	stopping bool
+	stopCh   chan struct{}
+	waiting  sync.WaitGroup
+}
+
+func newRetryScheduler() *retryScheduler {
+	return &retryScheduler{stopCh: make(chan struct{})}
+}
+
+// schedule calls retry after delay, or right away once the scheduler is stopping.
+func (rs *retryScheduler) schedule(delay time.Duration, retry func()) {
+	rs.mu.Lock()
+	if rs.stopping {
+		rs.mu.Unlock()
+		retry()
+		return
+	}
+	rs.waiting.Add(1)
+	rs.mu.Unlock()
+
+	go func() {
+		defer rs.waiting.Done()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-rs.stopCh:
+		}
+		retry()
+	}()
+}
+
+// isStopping reports whether stop was called, the batches retried from then on have their
+// last attempt.
+func (rs *retryScheduler) isStopping() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.stopping
+}
+
+// stop calls the scheduled retries right away and waits for them, so the batches are
+// back in the queue before it is stopped.
+func (rs *retryScheduler) stop() {
+	rs.mu.Lock()
+	if !rs.stopping {
+		rs.stopping = true
+		close(rs.stopCh)
+	}
+	rs.mu.Unlock()
+	rs.waiting.Wait()
+}
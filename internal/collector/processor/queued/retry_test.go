@@ -0,0 +1,78 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	rp := retryPolicy{initialDelay: time.Second, maxDelay: 10 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	rs := retryState{}
+	for _, base := range want {
+		rs.failed()
+		delay, ok := rp.nextDelay(rs)
+		if !ok {
+			t.Fatalf("nextDelay() after %d retries = false, want true", rs.retries)
+		}
+		// The jitter adds or removes up to half of the delay.
+		if delay < base/2 || delay > base*3/2 {
+			t.Errorf("nextDelay() after %d retries = %v, want within 50%% of %v", rs.retries, delay, base)
+		}
+		if delay > rp.maxDelay {
+			t.Errorf("nextDelay() after %d retries = %v, want at most %v", rs.retries, delay, rp.maxDelay)
+		}
+	}
+}
+
+func TestRetryPolicyLimits(t *testing.T) {
+	rp := retryPolicy{initialDelay: time.Millisecond, maxDelay: time.Second, maxRetries: 2}
+	rs := retryState{}
+	for i := 0; i < 2; i++ {
+		rs.failed()
+		if _, ok := rp.nextDelay(rs); !ok {
+			t.Fatalf("nextDelay() of retry %d = false, want true", rs.retries)
+		}
+	}
+	rs.failed()
+	if _, ok := rp.nextDelay(rs); ok {
+		t.Errorf("nextDelay() after %d failures = true, want false", rs.retries)
+	}
+
+	rp = retryPolicy{initialDelay: time.Millisecond, maxDelay: time.Second, maxElapsedTime: time.Minute}
+	rs = retryState{retries: 3, firstFailure: time.Now().Add(-time.Hour)}
+	if _, ok := rp.nextDelay(rs); ok {
+		t.Error("nextDelay() after the max elapsed time = true, want false")
+	}
+}
+
+func TestRetrySchedulerStop(t *testing.T) {
+	rs := newRetryScheduler()
+	var retried int32
+	rs.schedule(time.Hour, func() { atomic.AddInt32(&retried, 1) })
+
+	// Stopping retries right away instead of waiting for the delay.
+	rs.stop()
+	if atomic.LoadInt32(&retried) != 1 {
+		t.Fatal("stop() returned before the scheduled retry")
+	}
+	rs.schedule(time.Hour, func() { atomic.AddInt32(&retried, 1) })
+	if atomic.LoadInt32(&retried) != 2 {
+		t.Error("schedule() after stop() did not retry right away")
+	}
+}
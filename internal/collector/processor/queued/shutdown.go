@@ -0,0 +1,80 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queued
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// drainCheckInterval is how often Shutdown checks if the queue was drained.
+const drainCheckInterval = 10 * time.Millisecond
+
+// pendingCounter counts the batches that are in the queue or being sent, and the number
+// of spans or metrics in them, so Shutdown can wait for them and report the ones lost.
+type pendingCounter struct {
+	batches int64
+	items   int64
+	unsent  int64
+}
+
+func (pc *pendingCounter) add(items int) {
+	atomic.AddInt64(&pc.batches, 1)
+	atomic.AddInt64(&pc.items, int64(items))
+}
+
+// addBatches counts batches already in the queue, e.g.: the ones replayed from disk.
+func (pc *pendingCounter) addBatches(batches, items int64) {
+	atomic.AddInt64(&pc.batches, batches)
+	atomic.AddInt64(&pc.items, items)
+}
+
+func (pc *pendingCounter) done(items int) {
+	atomic.AddInt64(&pc.batches, -1)
+	atomic.AddInt64(&pc.items, -int64(items))
+}
+
+// markUnsent stops counting a batch as pending, and counts it as not sent, e.g.: it
+// failed again after the retries were flushed by Shutdown.
+func (pc *pendingCounter) markUnsent(items int) {
+	pc.done(items)
+	atomic.AddInt64(&pc.unsent, int64(items))
+}
+
+// numItems returns the number of spans or metrics in the pending batches.
+func (pc *pendingCounter) numItems() int64 {
+	return atomic.LoadInt64(&pc.items)
+}
+
+// numUnsent returns the number of spans or metrics in the batches not sent, including
+// the ones still pending.
+func (pc *pendingCounter) numUnsent() int64 {
+	return atomic.LoadInt64(&pc.unsent) + pc.numItems()
+}
+
+// drain waits until there are no pending batches, returning false if ctx is done first.
+func (pc *pendingCounter) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&pc.batches) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LostDataError is returned by the Shutdown of a processor that could not send all the
+// data it held before the deadline.
+type LostDataError struct {
+	// Processor is the name of the processor that lost the data.
+	Processor string
+	// Spans is the number of spans lost.
+	Spans int64
+	// Metrics is the number of metrics lost.
+	Metrics int64
+	// Traces is the number of traces lost before a sampling decision was made for them,
+	// their spans are counted in Spans.
+	Traces int64
+}
+
+var _ error = (*LostDataError)(nil)
+
+func (e *LostDataError) Error() string {
+	var lost []string
+	if e.Traces > 0 {
+		lost = append(lost, fmt.Sprintf("%d traces", e.Traces))
+	}
+	if e.Spans > 0 {
+		lost = append(lost, fmt.Sprintf("%d spans", e.Spans))
+	}
+	if e.Metrics > 0 {
+		lost = append(lost, fmt.Sprintf("%d metrics", e.Metrics))
+	}
+	if len(lost) == 0 {
+		lost = append(lost, "data")
+	}
+	return fmt.Sprintf("processor %q did not finish shutting down, lost %s", e.Processor, strings.Join(lost, ", "))
+}
@@ -0,0 +1,113 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+)
+
+// decisionCache keeps the decisions of the traces removed from memory, so the spans
+// arriving late for them get the same decisions instead of starting a new trace. It
+// holds at most size traces, for at most ttl each, evicting the oldest ones first.
+type decisionCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[traceKey]*list.Element
+	// order holds the entries from the oldest to the newest, which, since all of them
+	// have the same ttl, is also the order in which they expire.
+	order *list.List
+}
+
+// cachedDecision is the decision of each policy for a trace, in the order of the
+// policies, and the time they were taken.
+type cachedDecision struct {
+	key          traceKey
+	decisions    []sampling.Decision
+	decisionTime time.Time
+	expiration   time.Time
+}
+
+func newDecisionCache(size int, ttl time.Duration) *decisionCache {
+	return &decisionCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[traceKey]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// add keeps the decisions of the given trace, replacing the previous ones if any.
+func (dc *decisionCache) add(key traceKey, decisions []sampling.Decision, decisionTime time.Time) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	now := dc.now()
+	dc.removeExpired(now)
+	if elem, ok := dc.entries[key]; ok {
+		dc.order.Remove(elem)
+		delete(dc.entries, key)
+	}
+	for dc.order.Len() >= dc.size {
+		oldest := dc.order.Front()
+		dc.order.Remove(oldest)
+		delete(dc.entries, oldest.Value.(*cachedDecision).key)
+	}
+
+	dc.entries[key] = dc.order.PushBack(&cachedDecision{
+		key:          key,
+		decisions:    append([]sampling.Decision(nil), decisions...),
+		decisionTime: decisionTime,
+		expiration:   now.Add(dc.ttl),
+	})
+}
+
+// get returns the decisions of the given trace, if they are kept and not expired.
+func (dc *decisionCache) get(key traceKey) (*cachedDecision, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.removeExpired(dc.now())
+	elem, ok := dc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*cachedDecision), true
+}
+
+// len returns the number of traces kept, including the expired ones not removed yet.
+func (dc *decisionCache) len() int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.order.Len()
+}
+
+func (dc *decisionCache) removeExpired(now time.Time) {
+	for elem := dc.order.Front(); elem != nil; elem = dc.order.Front() {
+		entry := elem.Value.(*cachedDecision)
+		if now.Before(entry.expiration) {
+			return
+		}
+		dc.order.Remove(elem)
+		delete(dc.entries, entry.key)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+)
+
+func TestDecisionCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dc := newDecisionCache(2, time.Minute)
+	dc.now = func() time.Time { return now }
+
+	sampled := []sampling.Decision{sampling.Sampled, sampling.NotSampled}
+	dc.add("a", sampled, now)
+	dc.add("b", []sampling.Decision{sampling.NotSampled, sampling.NotSampled}, now)
+
+	got, ok := dc.get("a")
+	if !ok {
+		t.Fatal("get(a) found nothing")
+	}
+	if !reflect.DeepEqual(got.decisions, sampled) || !got.decisionTime.Equal(now) {
+		t.Errorf("get(a) = %v at %v, want %v at %v", got.decisions, got.decisionTime, sampled, now)
+	}
+
+	// The oldest trace is evicted once the cache is full.
+	dc.add("c", sampled, now)
+	if _, ok := dc.get("a"); ok {
+		t.Error("get(a) found the oldest trace after the cache was full")
+	}
+	if dc.len() != 2 {
+		t.Errorf("len() = %d, want 2", dc.len())
+	}
+
+	// Adding a trace again replaces it, and restarts its ttl.
+	now = now.Add(30 * time.Second)
+	dc.add("b", sampled, now)
+	now = now.Add(40 * time.Second)
+	if _, ok := dc.get("c"); ok {
+		t.Error("get(c) found a trace after its ttl")
+	}
+	got, ok = dc.get("b")
+	if !ok || !reflect.DeepEqual(got.decisions, sampled) {
+		t.Errorf("get(b) = %v, %v, want the decisions added last", got, ok)
+	}
+	if dc.len() != 1 {
+		t.Errorf("len() = %d, want 1", dc.len())
+	}
+}
+
+func TestDecisionCache_copiesDecisions(t *testing.T) {
+	dc := newDecisionCache(1, time.Minute)
+	decisions := []sampling.Decision{sampling.Sampled}
+	dc.add("a", decisions, time.Now())
+	decisions[0] = sampling.NotSampled
+
+	if got, _ := dc.get("a"); got.decisions[0] != sampling.Sampled {
+		t.Errorf("get(a) = %v, want the decisions at the time they were added", got.decisions)
+	}
+}
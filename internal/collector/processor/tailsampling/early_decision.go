@@ -0,0 +1,107 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// completionTracker follows the spans of the traces waiting for a decision to tell
+// when they look complete: their root span was received and so were all the parent
+// spans referenced by their spans. Traces without new spans for idleTimeout are also
+// reported, unless idleTimeout is zero.
+type completionTracker struct {
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu     sync.Mutex
+	traces map[traceKey]*traceShape
+}
+
+// traceShape is what is known of the span tree of a trace.
+type traceShape struct {
+	spanIDs map[string]struct{}
+	// missingParents are the parent spans referenced but not received yet.
+	missingParents map[string]struct{}
+	hasRoot        bool
+	lastArrival    time.Time
+}
+
+func newCompletionTracker(idleTimeout time.Duration) *completionTracker {
+	return &completionTracker{
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		traces:      make(map[traceKey]*traceShape),
+	}
+}
+
+// addSpans records the spans received for the given trace.
+func (ct *completionTracker) addSpans(key traceKey, spans []*tracepb.Span) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	shape, ok := ct.traces[key]
+	if !ok {
+		shape = &traceShape{
+			spanIDs:        make(map[string]struct{}),
+			missingParents: make(map[string]struct{}),
+		}
+		ct.traces[key] = shape
+	}
+	shape.lastArrival = ct.now()
+	for _, span := range spans {
+		spanID := string(span.SpanId)
+		shape.spanIDs[spanID] = struct{}{}
+		delete(shape.missingParents, spanID)
+		if len(span.ParentSpanId) == 0 {
+			shape.hasRoot = true
+			continue
+		}
+		if _, ok := shape.spanIDs[string(span.ParentSpanId)]; !ok {
+			shape.missingParents[string(span.ParentSpanId)] = struct{}{}
+		}
+	}
+}
+
+// remove stops following the given trace, e.g.: because its decision was taken.
+func (ct *completionTracker) remove(key traceKey) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	delete(ct.traces, key)
+}
+
+// takeReady stops following, and returns, the traces that look complete and the ones
+// idle for idleTimeout.
+func (ct *completionTracker) takeReady() (complete, idle []traceKey) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	now := ct.now()
+	for key, shape := range ct.traces {
+		switch {
+		case shape.hasRoot && len(shape.missingParents) == 0:
+			complete = append(complete, key)
+		case ct.idleTimeout > 0 && now.Sub(shape.lastArrival) >= ct.idleTimeout:
+			idle = append(idle, key)
+		default:
+			continue
+		}
+		delete(ct.traces, key)
+	}
+	return complete, idle
+}
@@ -0,0 +1,53 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+func TestCompletionTracker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	ct := newCompletionTracker(time.Second)
+	ct.now = func() time.Time { return now }
+
+	// Spans out of order: the parent of "c" arrives with the root after it.
+	ct.addSpans("trace-1", []*tracepb.Span{{SpanId: []byte("c"), ParentSpanId: []byte("b")}})
+	ct.addSpans("trace-2", []*tracepb.Span{{SpanId: []byte("a")}})
+	if complete, idle := ct.takeReady(); len(complete) != 1 || complete[0] != "trace-2" || len(idle) != 0 {
+		t.Fatalf("takeReady() = %v, %v, want only trace-2 complete", complete, idle)
+	}
+
+	ct.addSpans("trace-1", []*tracepb.Span{{SpanId: []byte("a")}})
+	if complete, idle := ct.takeReady(); len(complete) != 0 || len(idle) != 0 {
+		t.Fatalf("takeReady() = %v, %v, want trace-1 still missing span b", complete, idle)
+	}
+	ct.addSpans("trace-1", []*tracepb.Span{{SpanId: []byte("b"), ParentSpanId: []byte("a")}})
+	if complete, _ := ct.takeReady(); len(complete) != 1 || complete[0] != "trace-1" {
+		t.Fatalf("takeReady() = %v, want trace-1 complete", complete)
+	}
+
+	ct.addSpans("trace-3", []*tracepb.Span{{SpanId: []byte("b"), ParentSpanId: []byte("a")}})
+	now = now.Add(time.Second)
+	if complete, idle := ct.takeReady(); len(complete) != 0 || len(idle) != 1 || idle[0] != "trace-3" {
+		t.Fatalf("takeReady() = %v, %v, want only trace-3 idle", complete, idle)
+	}
+	if len(ct.traces) != 0 {
+		t.Errorf("got %d traces still followed, want 0", len(ct.traces))
+	}
+}
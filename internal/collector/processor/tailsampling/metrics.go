@@ -26,20 +26,26 @@ import (
 var (
 	tagPolicyKey, _  = tag.NewKey("policy")
 	tagSampledKey, _ = tag.NewKey("sampled")
+	// tagEarlyDecisionReasonKey tells if an early decision was taken because the trace
+	// looked complete or was idle.
+	tagEarlyDecisionReasonKey, _ = tag.NewKey("reason")
 
 	statDecisionLatencyMicroSec  = stats.Int64("sampling_decision_latency", "Latency (in microseconds) of a given sampling policy", "µs")
 	statOverallDecisionLatencyµs = stats.Int64("sampling_decision_timer_latency", "Latency (in microseconds) of each run of the sampling decision timer", "µs")
 
 	statTraceRemovalAgeSec           = stats.Int64("sampling_trace_removal_age", "Time (in seconds) from arrival of a new trace until its removal from memory", "s")
 	statLateSpanArrivalAfterDecision = stats.Int64("sampling_late_span_age", "Time (in seconds) from the sampling decision was taken and the arrival of a late span", "s")
+	statCountLateSpans               = stats.Int64("count_late_spans", "Count of spans that arrived after the sampling decision of their trace", stats.UnitDimensionless)
 
 	statPolicyEvaluationErrorCount = stats.Int64("sampling_policy_evaluation_error", "Count of sampling policy evaluation errors", stats.UnitDimensionless)
 
 	statCountTracesSampled = stats.Int64("count_traces_sampled", "Count of traces that were sampled or not", stats.UnitDimensionless)
+	statEarlyDecisionCount = stats.Int64("sampling_early_decisions", "Count of traces evaluated before the configured wait time", stats.UnitDimensionless)
 
 	statDroppedTooEarlyCount    = stats.Int64("sampling_trace_dropped_too_early", "Count of traces that needed to be dropped the configured wait time", stats.UnitDimensionless)
 	statNewTraceIDReceivedCount = stats.Int64("new_trace_id_received", "Counts the arrival of new traces", stats.UnitDimensionless)
 	statTracesOnMemoryGauge     = stats.Int64("sampling_traces_on_memory", "Tracks the number of traces current on memory", stats.UnitDimensionless)
+	statDecisionCacheSize       = stats.Int64("sampling_decision_cache_size", "Tracks the number of decisions kept for the traces removed from memory", stats.UnitDimensionless)
 )
 
 // SamplingProcessorMetricViews return the metrics views according to given telemetry level.
@@ -95,6 +101,20 @@ func SamplingProcessorMetricViews(level telemetry.Level) []*view.View {
 		TagKeys:     sampledTagKeys,
 		Aggregation: view.Sum(),
 	}
+	countEarlyDecisionsView := &view.View{
+		Name:        statEarlyDecisionCount.Name(),
+		Measure:     statEarlyDecisionCount,
+		Description: statEarlyDecisionCount.Description(),
+		TagKeys:     []tag.Key{tagEarlyDecisionReasonKey},
+		Aggregation: view.Sum(),
+	}
+	countLateSpansView := &view.View{
+		Name:        statCountLateSpans.Name(),
+		Measure:     statCountLateSpans,
+		Description: statCountLateSpans.Description(),
+		TagKeys:     sampledTagKeys,
+		Aggregation: view.Sum(),
+	}
 
 	countTraceDroppedTooEarlyView := &view.View{
 		Name:        statDroppedTooEarlyCount.Name(),
@@ -114,6 +134,12 @@ func SamplingProcessorMetricViews(level telemetry.Level) []*view.View {
 		Description: statTracesOnMemoryGauge.Description(),
 		Aggregation: view.LastValue(),
 	}
+	trackDecisionCacheSizeView := &view.View{
+		Name:        statDecisionCacheSize.Name(),
+		Measure:     statDecisionCacheSize,
+		Description: statDecisionCacheSize.Description(),
+		Aggregation: view.LastValue(),
+	}
 
 	return []*view.View{
 		decisionLatencyView,
@@ -125,9 +151,12 @@ func SamplingProcessorMetricViews(level telemetry.Level) []*view.View {
 		countPolicyEvaluationErrorView,
 
 		countTracesSampledView,
+		countEarlyDecisionsView,
+		countLateSpansView,
 
 		countTraceDroppedTooEarlyView,
 		countTraceIDArrivalView,
 		trackTracesOnMemorylView,
+		trackDecisionCacheSizeView,
 	}
 }
@@ -0,0 +1,48 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"time"
+)
+
+// Option is a function that sets some option on the tail sampling processor.
+type Option func(*tailSamplingSpanProcessor)
+
+// WithDecisionCache keeps the decisions of up to size traces, for up to ttl, after
+// they are removed from memory. The spans arriving late for those traces are sent
+// to the destinations of the policies that sampled them, and dropped otherwise,
+// instead of being evaluated again as a new trace. A size of zero disables it.
+func WithDecisionCache(size int, ttl time.Duration) Option {
+	return func(tsp *tailSamplingSpanProcessor) {
+		if size <= 0 || ttl <= 0 {
+			tsp.decisionCache = nil
+			return
+		}
+		tsp.decisionCache = newDecisionCache(size, ttl)
+	}
+}
+
+// WithEarlyDecision evaluates the policies for a trace as soon as it looks complete,
+// i.e.: its root span was received and so were all the parent spans referenced by its
+// spans, or once no new span arrived for it for idleTimeout, instead of always waiting
+// for the decision wait of the processor, which remains the maximum wait. Spans arriving
+// after such decision are handled as late spans. An idleTimeout of zero only takes the
+// early decisions for the complete traces.
+func WithEarlyDecision(idleTimeout time.Duration) Option {
+	return func(tsp *tailSamplingSpanProcessor) {
+		tsp.completionTracker = newCompletionTracker(idleTimeout)
+	}
+}
@@ -17,6 +17,7 @@ package tailsampling
 import (
 	"context"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/idbatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 	"github.com/census-instrumentation/opencensus-service/observability"
@@ -63,6 +65,15 @@ type tailSamplingSpanProcessor struct {
 	decisionBatcher idbatcher.Batcher
 	deleteChan      chan traceKey
 	numTracesOnMap  uint64
+	// decisionMu serializes the policy evaluations of the timer and of Shutdown.
+	decisionMu sync.Mutex
+	stopOnce   sync.Once
+	// decisionCache keeps the decisions of the traces removed from idToTrace, nil if
+	// disabled.
+	decisionCache *decisionCache
+	// completionTracker tells the traces that can be decided before the decision wait,
+	// nil if early decisions are disabled.
+	completionTracker *completionTracker
 }
 
 const (
@@ -70,6 +81,7 @@ const (
 )
 
 var _ consumer.TraceConsumer = (*tailSamplingSpanProcessor)(nil)
+var _ consumer.Shutdowner = (*tailSamplingSpanProcessor)(nil)
 
 // NewTailSamplingSpanProcessor creates a TailSamplingSpanProcessor with the given policies.
 // It will keep maxNumTraces on memory and will attempt to wait until decisionWait before evaluating if
@@ -79,7 +91,8 @@ func NewTailSamplingSpanProcessor(
 	policies []*Policy,
 	maxNumTraces, expectedNewTracesPerSec uint64,
 	decisionWait time.Duration,
-	logger *zap.Logger) (consumer.TraceConsumer, error) {
+	logger *zap.Logger,
+	opts ...Option) (consumer.TraceConsumer, error) {
 
 	numDecisionBatches := uint64(decisionWait.Seconds())
 	inBatcher, err := idbatcher.New(numDecisionBatches, expectedNewTracesPerSec, uint64(2*runtime.NumCPU()))
@@ -93,6 +106,9 @@ func NewTailSamplingSpanProcessor(
 		logger:          logger,
 		decisionBatcher: inBatcher,
 	}
+	for _, opt := range opts {
+		opt(tsp)
+	}
 
 	for _, policy := range policies {
 		policyCtx, err := tag.New(tsp.ctx, tag.Upsert(tagPolicyKey, policy.Name), tag.Upsert(observability.TagKeyReceiver, sourceFormat))
@@ -106,8 +122,16 @@ func NewTailSamplingSpanProcessor(
 	return tsp, nil
 }
 
+// policyMetrics holds the counters of an evaluation of the sampling policies.
+type policyMetrics struct {
+	idNotFoundOnMapCount, evaluateErrorCount, decisionSampled, decisionNotSampled int64
+}
+
 func (tsp *tailSamplingSpanProcessor) samplingPolicyOnTick() {
-	var idNotFoundOnMapCount, evaluateErrorCount, decisionSampled, decisionNotSampled int64
+	tsp.decisionMu.Lock()
+	defer tsp.decisionMu.Unlock()
+
+	metrics := policyMetrics{}
 	startTime := time.Now()
 	batch, _ := tsp.decisionBatcher.CloseCurrentAndTakeFirstBatch()
 	batchLen := len(batch)
@@ -115,71 +139,188 @@ func (tsp *tailSamplingSpanProcessor) samplingPolicyOnTick() {
 	for _, id := range batch {
 		d, ok := tsp.idToTrace.Load(traceKey(id))
 		if !ok {
-			idNotFoundOnMapCount++
+			metrics.idNotFoundOnMapCount++
 			continue
 		}
 		trace := d.(*sampling.TraceData)
-		trace.DecisionTime = time.Now()
-		for i, policy := range tsp.policies {
-			policyEvaluateStartTime := time.Now()
-			decision, err := policy.Evaluator.Evaluate(id, trace)
-			stats.Record(
-				policy.ctx,
-				statDecisionLatencyMicroSec.M(int64(time.Since(policyEvaluateStartTime)/time.Microsecond)))
-			if err != nil {
-				trace.Decision[i] = sampling.NotSampled
-				evaluateErrorCount++
-				tsp.logger.Error("Sampling policy error", zap.Error(err))
+		if tsp.completionTracker != nil {
+			tsp.completionTracker.remove(traceKey(id))
+			trace.Lock()
+			pending := len(trace.Decision) > 0 && trace.Decision[0] == sampling.Pending
+			trace.Unlock()
+			if !pending {
+				// Already decided early.
 				continue
 			}
-
-			trace.Decision[i] = decision
-
-			switch decision {
-			case sampling.Sampled:
-				stats.RecordWithTags(
-					policy.ctx,
-					[]tag.Mutator{tag.Insert(tagSampledKey, "true")},
-					statCountTracesSampled.M(int64(1)),
-				)
-				decisionSampled++
-
-				trace.Lock()
-				traceBatches := trace.ReceivedBatches
-				trace.Unlock()
-
-				for j := 0; j < len(traceBatches); j++ {
-					policy.Destination.ConsumeTraceData(policy.ctx, traceBatches[j])
-				}
-			case sampling.NotSampled:
-				stats.RecordWithTags(
-					policy.ctx,
-					[]tag.Mutator{tag.Insert(tagSampledKey, "false")},
-					statCountTracesSampled.M(int64(1)),
-				)
-				decisionNotSampled++
-			}
 		}
-
-		// Sampled or not, remove the batches
-		trace.Lock()
-		trace.ReceivedBatches = nil
-		trace.Unlock()
+		tsp.makeDecision(id, trace, &metrics)
+	}
+	if tsp.completionTracker != nil {
+		tsp.makeEarlyDecisions(&metrics)
 	}
 
 	stats.Record(tsp.ctx,
 		statOverallDecisionLatencyµs.M(int64(time.Since(startTime)/time.Microsecond)),
-		statDroppedTooEarlyCount.M(idNotFoundOnMapCount),
-		statPolicyEvaluationErrorCount.M(evaluateErrorCount),
+		statDroppedTooEarlyCount.M(metrics.idNotFoundOnMapCount),
+		statPolicyEvaluationErrorCount.M(metrics.evaluateErrorCount),
 		statTracesOnMemoryGauge.M(int64(atomic.LoadUint64(&tsp.numTracesOnMap))))
+	if tsp.decisionCache != nil {
+		stats.Record(tsp.ctx, statDecisionCacheSize.M(int64(tsp.decisionCache.len())))
+	}
 
 	tsp.logger.Debug("Sampling policy evaluation completed",
 		zap.Int("batch.len", batchLen),
-		zap.Int64("sampled", decisionSampled),
-		zap.Int64("notSampled", decisionNotSampled),
-		zap.Int64("droppedPriorToEvaluation", idNotFoundOnMapCount),
-		zap.Int64("policyEvaluationErrors", evaluateErrorCount),
+		zap.Int64("sampled", metrics.decisionSampled),
+		zap.Int64("notSampled", metrics.decisionNotSampled),
+		zap.Int64("droppedPriorToEvaluation", metrics.idNotFoundOnMapCount),
+		zap.Int64("policyEvaluationErrors", metrics.evaluateErrorCount),
+	)
+}
+
+// makeEarlyDecisions evaluates the policies for the traces that look complete, or are
+// idle, before their decision wait.
+func (tsp *tailSamplingSpanProcessor) makeEarlyDecisions(metrics *policyMetrics) {
+	complete, idle := tsp.completionTracker.takeReady()
+	for _, ready := range []struct {
+		reason string
+		keys   []traceKey
+	}{{"complete", complete}, {"idle", idle}} {
+		var count int64
+		for _, key := range ready.keys {
+			d, ok := tsp.idToTrace.Load(key)
+			if !ok {
+				continue
+			}
+			trace := d.(*sampling.TraceData)
+			trace.Lock()
+			pending := len(trace.Decision) > 0 && trace.Decision[0] == sampling.Pending
+			trace.Unlock()
+			if !pending {
+				continue
+			}
+			tsp.makeDecision([]byte(key), trace, metrics)
+			count++
+		}
+		if count > 0 {
+			stats.RecordWithTags(
+				tsp.ctx,
+				[]tag.Mutator{tag.Insert(tagEarlyDecisionReasonKey, ready.reason)},
+				statEarlyDecisionCount.M(count),
+			)
+		}
+	}
+}
+
+// makeDecision evaluates all policies for the trace, sending it to the destinations of
+// the policies that sampled it.
+func (tsp *tailSamplingSpanProcessor) makeDecision(id idbatcher.ID, trace *sampling.TraceData, metrics *policyMetrics) {
+	trace.DecisionTime = time.Now()
+	for i, policy := range tsp.policies {
+		policyEvaluateStartTime := time.Now()
+		decision, err := policy.Evaluator.Evaluate(id, trace)
+		stats.Record(
+			policy.ctx,
+			statDecisionLatencyMicroSec.M(int64(time.Since(policyEvaluateStartTime)/time.Microsecond)))
+		if err != nil {
+			trace.Decision[i] = sampling.NotSampled
+			metrics.evaluateErrorCount++
+			tsp.logger.Error("Sampling policy error", zap.Error(err))
+			continue
+		}
+
+		trace.Decision[i] = decision
+
+		switch decision {
+		case sampling.Sampled:
+			countTrace(policy, trace, true)
+			metrics.decisionSampled++
+
+			trace.Lock()
+			traceBatches := trace.ReceivedBatches
+			trace.Unlock()
+
+			for j := 0; j < len(traceBatches); j++ {
+				policy.Destination.ConsumeTraceData(policy.ctx, traceBatches[j])
+			}
+		case sampling.NotSampled:
+			countTrace(policy, trace, false)
+			metrics.decisionNotSampled++
+		}
+	}
+
+	// Sampled or not, remove the batches
+	trace.Lock()
+	trace.ReceivedBatches = nil
+	trace.Unlock()
+}
+
+// countTrace records the trace as sampled or not by the policy, and passes it to the
+// policy if it learns from the counted traces.
+func countTrace(policy *Policy, trace *sampling.TraceData, sampled bool) {
+	stats.RecordWithTags(
+		policy.ctx,
+		[]tag.Mutator{tag.Insert(tagSampledKey, strconv.FormatBool(sampled))},
+		statCountTracesSampled.M(int64(1)),
 	)
+	if counter, ok := policy.Evaluator.(sampling.TraceCounter); ok {
+		counter.CountTrace(trace)
+	}
+}
+
+// Shutdown stops the timer of the policy evaluations and makes a decision for all traces
+// still waiting for one, without waiting for the rest of their spans, so the sampled ones
+// reach their destinations. The traces not evaluated before ctx is done are reported with
+// a *processor.LostDataError.
+func (tsp *tailSamplingSpanProcessor) Shutdown(ctx context.Context) error {
+	var lostTraces, lostSpans int64
+	tsp.stopOnce.Do(func() {
+		// Once start has run the timer can no longer be started by new data.
+		tsp.start.Do(func() {})
+		tsp.policyTicker.Stop()
+
+		tsp.decisionMu.Lock()
+		defer tsp.decisionMu.Unlock()
+
+		metrics := policyMetrics{}
+		tsp.idToTrace.Range(func(key, value interface{}) bool {
+			trace := value.(*sampling.TraceData)
+			trace.Lock()
+			pending := len(trace.Decision) > 0 && trace.Decision[0] == sampling.Pending
+			trace.Unlock()
+			if !pending {
+				return true
+			}
+			if ctx.Err() != nil {
+				lostTraces++
+				lostSpans += atomic.LoadInt64(&trace.SpanCount)
+				return true
+			}
+			tsp.makeDecision([]byte(key.(traceKey)), trace, &metrics)
+			return true
+		})
+
+		tsp.logger.Info("Sampling decisions forced on shutdown",
+			zap.Int64("sampled", metrics.decisionSampled),
+			zap.Int64("notSampled", metrics.decisionNotSampled),
+			zap.Int64("policyEvaluationErrors", metrics.evaluateErrorCount),
+			zap.Int64("lost", lostTraces),
+		)
+
+		// Policies with state, e.g.: adaptive sampling, save it once the last decisions are made.
+		for _, policy := range tsp.policies {
+			if shutdowner, ok := policy.Evaluator.(consumer.Shutdowner); ok {
+				if err := shutdowner.Shutdown(ctx); err != nil {
+					tsp.logger.Warn("Failed to shut down sampling policy",
+						zap.String("policy", policy.Name),
+						zap.Error(err))
+				}
+			}
+		}
+	})
+	if lostTraces > 0 {
+		return &processor.LostDataError{Processor: sourceFormat, Traces: lostTraces, Spans: lostSpans}
+	}
+	return nil
 }
 
 // ConsumeTraceData is required by the SpanProcessor interface.
@@ -203,6 +344,18 @@ func (tsp *tailSamplingSpanProcessor) ConsumeTraceData(ctx context.Context, td d
 	var newTraceIDs int64
 	singleTrace := len(idToSpans) == 1
 	for id, spans := range idToSpans {
+		if tsp.decisionCache != nil {
+			// Late spans of a trace already removed from memory get the decisions it had.
+			if _, ok := tsp.idToTrace.Load(id); !ok {
+				if cached, ok := tsp.decisionCache.get(id); ok {
+					for i, policy := range tsp.policies {
+						tsp.onLateArrivingSpans(policy, cached.decisions[i], cached.decisionTime, spans, singleTrace, td)
+					}
+					continue
+				}
+			}
+		}
+
 		lenSpans := int64(len(spans))
 		lenPolicies := len(tsp.policies)
 		initialDecisions := make([]sampling.Decision, lenPolicies, lenPolicies)
@@ -246,32 +399,15 @@ func (tsp *tailSamplingSpanProcessor) ConsumeTraceData(ctx context.Context, td d
 				// be duplicated in the final trace.
 				traceTd := prepareTraceBatch(spans, singleTrace, td)
 				actualData.ReceivedBatches = append(actualData.ReceivedBatches, traceTd)
+				if tsp.completionTracker != nil {
+					tsp.completionTracker.addSpans(id, spans)
+				}
 				actualData.Unlock()
 				break
 			}
 			actualData.Unlock()
 
-			switch actualDecision {
-			case sampling.Pending:
-				// All process for pending done above, keep the case so it doesn't go to default.
-			case sampling.Sampled:
-				// Forward the spans to the policy destinations
-				traceTd := prepareTraceBatch(spans, singleTrace, td)
-				if err := policyAndDests.Destination.ConsumeTraceData(policyAndDests.ctx, traceTd); err != nil {
-					tsp.logger.Warn("Error sending late arrived spans to destination",
-						zap.String("policy", policyAndDests.Name),
-						zap.Error(err))
-				}
-				fallthrough // so OnLateArrivingSpans is also called for decision Sampled.
-			case sampling.NotSampled:
-				policyAndDests.Evaluator.OnLateArrivingSpans(actualDecision, spans)
-				stats.Record(tsp.ctx, statLateSpanArrivalAfterDecision.M(int64(time.Since(actualData.DecisionTime)/time.Second)))
-
-			default:
-				tsp.logger.Warn("Encountered unexpected sampling decision",
-					zap.String("policy", policyAndDests.Name),
-					zap.Int("decision", int(actualDecision)))
-			}
+			tsp.onLateArrivingSpans(policyAndDests, actualDecision, actualData.DecisionTime, spans, singleTrace, td)
 		}
 	}
 
@@ -279,6 +415,42 @@ func (tsp *tailSamplingSpanProcessor) ConsumeTraceData(ctx context.Context, td d
 	return nil
 }
 
+// onLateArrivingSpans handles the spans that arrived after the given decision of the
+// policy was taken, sending them to the destination of the policy if it sampled the trace.
+func (tsp *tailSamplingSpanProcessor) onLateArrivingSpans(
+	policy *Policy,
+	decision sampling.Decision,
+	decisionTime time.Time,
+	spans []*tracepb.Span,
+	singleTrace bool,
+	td data.TraceData,
+) {
+	switch decision {
+	case sampling.Sampled:
+		// Forward the spans to the policy destinations
+		traceTd := prepareTraceBatch(spans, singleTrace, td)
+		if err := policy.Destination.ConsumeTraceData(policy.ctx, traceTd); err != nil {
+			tsp.logger.Warn("Error sending late arrived spans to destination",
+				zap.String("policy", policy.Name),
+				zap.Error(err))
+		}
+		fallthrough // so OnLateArrivingSpans is also called for decision Sampled.
+	case sampling.NotSampled:
+		policy.Evaluator.OnLateArrivingSpans(decision, spans)
+		stats.Record(tsp.ctx, statLateSpanArrivalAfterDecision.M(int64(time.Since(decisionTime)/time.Second)))
+		stats.RecordWithTags(
+			policy.ctx,
+			[]tag.Mutator{tag.Insert(tagSampledKey, strconv.FormatBool(decision == sampling.Sampled))},
+			statCountLateSpans.M(int64(len(spans))),
+		)
+
+	default:
+		tsp.logger.Warn("Encountered unexpected sampling decision",
+			zap.String("policy", policy.Name),
+			zap.Int("decision", int(decision)))
+	}
+}
+
 func (tsp *tailSamplingSpanProcessor) dropTrace(traceID traceKey, deletionTime time.Time) {
 	var trace *sampling.TraceData
 	if d, ok := tsp.idToTrace.Load(traceID); ok {
@@ -291,8 +463,19 @@ func (tsp *tailSamplingSpanProcessor) dropTrace(traceID traceKey, deletionTime t
 		tsp.logger.Error("Attempt to delete traceID not on table")
 		return
 	}
+	if tsp.completionTracker != nil {
+		tsp.completionTracker.remove(traceID)
+	}
 	policiesLen := len(tsp.policies)
 	stats.Record(tsp.ctx, statTraceRemovalAgeSec.M(int64(deletionTime.Sub(trace.ArrivalTime)/time.Second)))
+
+	if tsp.decisionCache != nil {
+		trace.Lock()
+		if decided(trace.Decision) {
+			tsp.decisionCache.add(traceID, trace.Decision, trace.DecisionTime)
+		}
+		trace.Unlock()
+	}
 	for j := 0; j < policiesLen; j++ {
 		if trace.Decision[j] == sampling.Pending {
 			policy := tsp.policies[j]
@@ -306,6 +489,16 @@ func (tsp *tailSamplingSpanProcessor) dropTrace(traceID traceKey, deletionTime t
 	}
 }
 
+// decided returns true if none of the given decisions is pending.
+func decided(decisions []sampling.Decision) bool {
+	for _, decision := range decisions {
+		if decision == sampling.Pending {
+			return false
+		}
+	}
+	return true
+}
+
 func prepareTraceBatch(spans []*tracepb.Span, singleTrace bool, td data.TraceData) data.TraceData {
 	var traceTd data.TraceData
 	if singleTrace {
@@ -348,7 +541,9 @@ func (pt *policyTicker) OnTick() {
 	pt.onTick()
 }
 func (pt *policyTicker) Stop() {
-	pt.ticker.Stop()
+	if pt.ticker != nil {
+		pt.ticker.Stop()
+	}
 }
 
 var _ tTicker = (*policyTicker)(nil)
@@ -22,6 +22,7 @@ import (
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/idbatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 	tracetranslator "github.com/census-instrumentation/opencensus-service/translator/trace"
@@ -180,6 +181,9 @@ func TestSamplingPolicyTypicalPath(t *testing.T) {
 	if msp.TotalSpans != numSpansPerBatchWindow {
 		t.Fatalf("not all spans of first window were accounted for: got %d, want %d", msp.TotalSpans, numSpansPerBatchWindow)
 	}
+	if mpe.CountedTracesCount != mpe.EvaluationCount {
+		t.Fatalf("policy was passed %d counted traces, want the %d evaluated", mpe.CountedTracesCount, mpe.EvaluationCount)
+	}
 
 	// Late span of a sampled trace should be sent directly down the pipeline exporter
 	tsp.ConsumeTraceData(context.Background(), batches[0])
@@ -192,6 +196,144 @@ func TestSamplingPolicyTypicalPath(t *testing.T) {
 	}
 }
 
+func TestLateSpansUseCachedDecision(t *testing.T) {
+	for _, decision := range []sampling.Decision{sampling.Sampled, sampling.NotSampled} {
+		msp := &mockSpanProcessor{}
+		mpe := &mockPolicyEvaluator{NextDecision: decision}
+		testPolicy := []*Policy{{Name: "test", Evaluator: mpe, Destination: msp}}
+		const maxSize = 10
+		sp, _ := NewTailSamplingSpanProcessor(testPolicy, maxSize, 64, time.Second, zap.NewNop(), WithDecisionCache(100, time.Minute))
+		tsp := sp.(*tailSamplingSpanProcessor)
+		tsp.policyTicker = &manualTTicker{}
+		tsp.decisionBatcher = newSyncIDBatcher(1)
+
+		traceIds, batches := generateIdsAndBatches(maxSize + 1)
+		tsp.ConsumeTraceData(context.Background(), batches[0])
+		tsp.samplingPolicyOnTick()
+		tsp.samplingPolicyOnTick()
+		if mpe.EvaluationCount != 1 {
+			t.Fatalf("got %d evaluations, want 1", mpe.EvaluationCount)
+		}
+		sentBeforeLateSpan := msp.TotalSpans
+
+		// The first trace is removed from memory by the next ones.
+		for _, batch := range batches[1:] {
+			tsp.ConsumeTraceData(context.Background(), batch)
+		}
+		if _, ok := tsp.idToTrace.Load(traceKey(traceIds[0])); ok {
+			t.Fatal("first trace still on memory")
+		}
+
+		tsp.ConsumeTraceData(context.Background(), batches[0])
+		if _, ok := tsp.idToTrace.Load(traceKey(traceIds[0])); ok {
+			t.Errorf("late span of decision %v started a new trace", decision)
+		}
+		wantSent := sentBeforeLateSpan
+		if decision == sampling.Sampled {
+			wantSent++
+		}
+		if msp.TotalSpans != wantSent {
+			t.Errorf("got %d spans sent for decision %v, want %d", msp.TotalSpans, decision, wantSent)
+		}
+		if mpe.LateArrivingSpansCount != 1 {
+			t.Errorf("got %d notifications of late spans for decision %v, want 1", mpe.LateArrivingSpansCount, decision)
+		}
+	}
+}
+
+func TestEarlyDecisions(t *testing.T) {
+	const decisionWaitSeconds = 5
+	msp := &mockSpanProcessor{}
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	testPolicy := []*Policy{{Name: "test", Evaluator: mpe, Destination: msp}}
+	sp, _ := NewTailSamplingSpanProcessor(testPolicy, 100, 64, decisionWaitSeconds*time.Second, zap.NewNop(), WithEarlyDecision(2*time.Second))
+	tsp := sp.(*tailSamplingSpanProcessor)
+	tsp.policyTicker = &manualTTicker{}
+	tsp.decisionBatcher = newSyncIDBatcher(decisionWaitSeconds)
+	now := time.Unix(1000, 0)
+	tsp.completionTracker.now = func() time.Time { return now }
+
+	rootID := tracetranslator.UInt64ToByteSpanID(1)
+	childID := tracetranslator.UInt64ToByteSpanID(2)
+	completeTrace := []*tracepb.Span{
+		{TraceId: tracetranslator.UInt64ToByteTraceID(1, 1), SpanId: childID, ParentSpanId: rootID},
+		{TraceId: tracetranslator.UInt64ToByteTraceID(1, 1), SpanId: rootID},
+	}
+	// The root span of this trace is still missing.
+	incompleteTrace := []*tracepb.Span{
+		{TraceId: tracetranslator.UInt64ToByteTraceID(1, 2), SpanId: childID, ParentSpanId: rootID},
+	}
+	tsp.ConsumeTraceData(context.Background(), data.TraceData{Spans: completeTrace})
+	tsp.ConsumeTraceData(context.Background(), data.TraceData{Spans: incompleteTrace})
+
+	tsp.samplingPolicyOnTick()
+	if mpe.EvaluationCount != 1 || msp.TotalSpans != len(completeTrace) {
+		t.Fatalf("got %d evaluations and %d spans sent, want the complete trace only", mpe.EvaluationCount, msp.TotalSpans)
+	}
+
+	now = now.Add(2 * time.Second)
+	tsp.samplingPolicyOnTick()
+	if mpe.EvaluationCount != 2 || msp.TotalSpans != len(completeTrace)+len(incompleteTrace) {
+		t.Fatalf("got %d evaluations and %d spans sent, want the idle trace evaluated", mpe.EvaluationCount, msp.TotalSpans)
+	}
+
+	// Once the decision wait passes the traces decided early are not evaluated again.
+	for i := 0; i < decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	if mpe.EvaluationCount != 2 {
+		t.Errorf("got %d evaluations, want 2", mpe.EvaluationCount)
+	}
+}
+
+func TestShutdownForcesPendingDecisions(t *testing.T) {
+	msp := &mockSpanProcessor{}
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	testPolicy := []*Policy{{Name: "test", Evaluator: mpe, Destination: msp}}
+	sp, _ := NewTailSamplingSpanProcessor(testPolicy, 100, 64, defaultTestDecisionWait, zap.NewNop())
+	tsp := sp.(*tailSamplingSpanProcessor)
+	tsp.policyTicker = &manualTTicker{}
+
+	traceIds, batches := generateIdsAndBatches(10)
+	for _, batch := range batches {
+		tsp.ConsumeTraceData(context.Background(), batch)
+	}
+
+	if err := tsp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if mpe.EvaluationCount != len(traceIds) {
+		t.Errorf("got %d evaluations, want %d", mpe.EvaluationCount, len(traceIds))
+	}
+	if msp.TotalSpans != len(batches) {
+		t.Errorf("got %d spans sent, want %d", msp.TotalSpans, len(batches))
+	}
+	if mpe.ShutdownCount != 1 {
+		t.Errorf("got %d shutdowns of the policy, want 1", mpe.ShutdownCount)
+	}
+}
+
+func TestShutdownReportsLostTraces(t *testing.T) {
+	sp, _ := NewTailSamplingSpanProcessor(newTestPolicy(), 100, 64, defaultTestDecisionWait, zap.NewNop())
+	tsp := sp.(*tailSamplingSpanProcessor)
+	tsp.policyTicker = &manualTTicker{}
+
+	traceIds, batches := generateIdsAndBatches(10)
+	for _, batch := range batches {
+		tsp.ConsumeTraceData(context.Background(), batch)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lostErr, ok := tsp.Shutdown(ctx).(*processor.LostDataError)
+	if !ok {
+		t.Fatalf("Shutdown() did not return a *processor.LostDataError")
+	}
+	if lostErr.Traces != int64(len(traceIds)) || lostErr.Spans != int64(len(batches)) {
+		t.Errorf("got %d traces and %d spans lost, want %d and %d", lostErr.Traces, lostErr.Spans, len(traceIds), len(batches))
+	}
+}
+
 func generateIdsAndBatches(numIds int) ([][]byte, []data.TraceData) {
 	traceIds := make([][]byte, numIds, numIds)
 	for i := 0; i < numIds; i++ {
@@ -237,9 +379,12 @@ type mockPolicyEvaluator struct {
 	EvaluationCount        int
 	LateArrivingSpansCount int
 	OnDroppedSpansCount    int
+	CountedTracesCount     int
+	ShutdownCount          int
 }
 
 var _ (sampling.PolicyEvaluator) = (*mockPolicyEvaluator)(nil)
+var _ (sampling.TraceCounter) = (*mockPolicyEvaluator)(nil)
 
 func (m *mockPolicyEvaluator) OnLateArrivingSpans(earlyDecision sampling.Decision, spans []*tracepb.Span) error {
 	m.LateArrivingSpansCount++
@@ -253,6 +398,13 @@ func (m *mockPolicyEvaluator) OnDroppedSpans(traceID []byte, trace *sampling.Tra
 	m.OnDroppedSpansCount++
 	return m.NextDecision, m.NextError
 }
+func (m *mockPolicyEvaluator) CountTrace(trace *sampling.TraceData) {
+	m.CountedTracesCount++
+}
+func (m *mockPolicyEvaluator) Shutdown(context.Context) error {
+	m.ShutdownCount++
+	return nil
+}
 
 type manualTTicker struct {
 	Started bool
@@ -0,0 +1,343 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
+)
+
+const (
+	// rateSmoothing is the weight of the last interval in the smoothed rate of traces of
+	// each key.
+	rateSmoothing = 0.5
+	// minTracesPerSecond is the smoothed rate of traces below which a key is forgotten.
+	minTracesPerSecond = 0.001
+)
+
+// DefaultAdaptiveHashSeed is the default seed of the hash of the trace IDs of the adaptive
+// policy. It differs from the default seed of head sampling and of the probabilistic policy,
+// since with the same seed the traces kept with the lowest probability would always be a
+// subset of the traces kept by the others.
+const DefaultAdaptiveHashSeed uint32 = 1
+
+// adaptiveKey is the service and operation of the root span of a trace.
+type adaptiveKey struct {
+	service   string
+	operation string
+}
+
+type adaptiveKeyState struct {
+	probability float64
+	// tracesInInterval counts the traces of the key counted by the tail-sampling processor
+	// since the last adjustment.
+	tracesInInterval int64
+	// tracesPerSecond is the smoothed rate of traces of the key.
+	tracesPerSecond float64
+}
+
+type adaptive struct {
+	sync.Mutex
+	tracesPerSecond float64
+	interval        time.Duration
+	stateFile       string
+	seed            uint32
+
+	keys map[adaptiveKey]*adaptiveKeyState
+	// saved are the probabilities read from the state file for the keys not seen yet,
+	// they are dropped at the first adjustment.
+	saved      map[adaptiveKey]float64
+	lastAdjust time.Time
+	now        func() time.Time
+
+	// saveMu serializes the writes of the state file, done without holding the lock.
+	saveMu sync.Mutex
+}
+
+var _ PolicyEvaluator = (*adaptive)(nil)
+var _ consumer.Shutdowner = (*adaptive)(nil)
+var _ TraceCounter = (*adaptive)(nil)
+
+// NewAdaptive creates a policy evaluator that samples about tracesPerSecond traces per
+// second. Every interval it sets the probability of sampling the traces of each service and
+// root operation from the number of traces counted by the tail-sampling processor,
+// splitting the traces per second evenly among the keys, and giving the share unused by the
+// quiet keys to the busy ones. The traces are sampled by hashing their trace IDs with the
+// given seed.
+//
+// If stateFile is not empty the probabilities are saved to it after each adjustment and on
+// Shutdown, and read from it on creation so a restarted collector starts from the
+// probabilities it had learned.
+func NewAdaptive(tracesPerSecond float64, interval time.Duration, stateFile string, seed uint32) (PolicyEvaluator, error) {
+	a := &adaptive{
+		tracesPerSecond: tracesPerSecond,
+		interval:        interval,
+		stateFile:       stateFile,
+		seed:            seed,
+		keys:            make(map[adaptiveKey]*adaptiveKeyState),
+		saved:           make(map[adaptiveKey]float64),
+		now:             time.Now,
+	}
+	if stateFile != "" {
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+	}
+	a.lastAdjust = a.now()
+	return a, nil
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (a *adaptive) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (a *adaptive) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+
+	a.Lock()
+	var probabilities map[adaptiveKey]float64
+	if now := a.now(); now.Sub(a.lastAdjust) >= a.interval {
+		a.adjust(now)
+		if a.stateFile != "" {
+			probabilities = a.probabilities()
+		}
+	}
+	probability := a.stateOf(rootKey(batches)).probability
+	a.Unlock()
+
+	var err error
+	if probabilities != nil {
+		err = a.save(probabilities)
+	}
+	if TraceIDSampled(a.seed, traceID, TraceIDThreshold(100*probability)) {
+		return Sampled, err
+	}
+	return NotSampled, err
+}
+
+// CountTrace counts the trace for the next adjustment of the probability of its key.
+func (a *adaptive) CountTrace(trace *TraceData) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+
+	a.Lock()
+	defer a.Unlock()
+	a.stateOf(rootKey(batches)).tracesInInterval++
+}
+
+// stateOf returns the state of key, starting from the saved probability of the key if any.
+func (a *adaptive) stateOf(key adaptiveKey) *adaptiveKeyState {
+	state, ok := a.keys[key]
+	if !ok {
+		probability, ok := a.saved[key]
+		if ok {
+			delete(a.saved, key)
+		} else {
+			probability = 1
+		}
+		state = &adaptiveKeyState{probability: probability}
+		a.keys[key] = state
+	}
+	return state
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (a *adaptive) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
+
+// Shutdown saves the probabilities to the state file.
+func (a *adaptive) Shutdown(context.Context) error {
+	if a.stateFile == "" {
+		return nil
+	}
+	a.Lock()
+	probabilities := a.probabilities()
+	a.Unlock()
+	return a.save(probabilities)
+}
+
+// adjust updates the rates of traces of the keys with the traces of the last interval and
+// sets their probabilities from them. The saved probabilities of the keys without traces
+// since the restart are dropped.
+func (a *adaptive) adjust(now time.Time) {
+	seconds := now.Sub(a.lastAdjust).Seconds()
+	a.lastAdjust = now
+
+	states := make([]*adaptiveKeyState, 0, len(a.keys))
+	for key, state := range a.keys {
+		rate := float64(state.tracesInInterval) / seconds
+		if state.tracesPerSecond == 0 {
+			state.tracesPerSecond = rate
+		} else {
+			state.tracesPerSecond = rateSmoothing*rate + (1-rateSmoothing)*state.tracesPerSecond
+		}
+		state.tracesInInterval = 0
+		if state.tracesPerSecond < minTracesPerSecond {
+			delete(a.keys, key)
+			continue
+		}
+		states = append(states, state)
+	}
+
+	// The quietest keys take their share first, leaving what they don't use to the others.
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].tracesPerSecond < states[j].tracesPerSecond
+	})
+	remaining := a.tracesPerSecond
+	for i, state := range states {
+		share := remaining / float64(len(states)-i)
+		if state.tracesPerSecond <= share {
+			state.probability = 1
+			remaining -= state.tracesPerSecond
+			continue
+		}
+		state.probability = share / state.tracesPerSecond
+		remaining -= share
+	}
+	a.saved = make(map[adaptiveKey]float64)
+}
+
+// adaptiveState is the content of the state file.
+type adaptiveState struct {
+	Probabilities []adaptiveSavedProbability `json:"probabilities"`
+}
+
+// adaptiveSavedProbability is the probability of a service and root operation in the state
+// file.
+type adaptiveSavedProbability struct {
+	Service     string  `json:"service"`
+	Operation   string  `json:"operation"`
+	Probability float64 `json:"probability"`
+}
+
+func (a *adaptive) load() error {
+	blob, err := ioutil.ReadFile(a.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state adaptiveState
+	if err := json.Unmarshal(blob, &state); err != nil {
+		return err
+	}
+	for _, saved := range state.Probabilities {
+		a.saved[adaptiveKey{service: saved.Service, operation: saved.Operation}] = saved.Probability
+	}
+	return nil
+}
+
+// probabilities returns a copy of the probabilities to save.
+func (a *adaptive) probabilities() map[adaptiveKey]float64 {
+	probabilities := make(map[adaptiveKey]float64, len(a.saved)+len(a.keys))
+	for key, probability := range a.saved {
+		probabilities[key] = probability
+	}
+	for key, keyState := range a.keys {
+		probabilities[key] = keyState.probability
+	}
+	return probabilities
+}
+
+// save writes the probabilities to a temporary file renamed to the state file, so the
+// state file is never left half written. The entries are sorted so the file only changes
+// with the probabilities.
+func (a *adaptive) save(probabilities map[adaptiveKey]float64) error {
+	var state adaptiveState
+	for key, probability := range probabilities {
+		state.Probabilities = append(state.Probabilities, adaptiveSavedProbability{
+			Service:     key.service,
+			Operation:   key.operation,
+			Probability: probability,
+		})
+	}
+	sort.Slice(state.Probabilities, func(i, j int) bool {
+		pi, pj := state.Probabilities[i], state.Probabilities[j]
+		if pi.Service != pj.Service {
+			return pi.Service < pj.Service
+		}
+		return pi.Operation < pj.Operation
+	})
+	blob, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	tmpFile, err := ioutil.TempFile(filepath.Dir(a.stateFile), filepath.Base(a.stateFile))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(blob); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), a.stateFile)
+}
+
+// rootKey returns the service and operation of the root span of the trace, or of the first
+// span if the root span did not arrive.
+func rootKey(batches []data.TraceData) adaptiveKey {
+	var first *adaptiveKey
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			if span == nil {
+				continue
+			}
+			key := adaptiveKey{
+				service:   processor.ServiceNameForNode(batch.Node),
+				operation: span.Name.GetValue(),
+			}
+			if len(span.ParentSpanId) == 0 {
+				return key
+			}
+			if first == nil {
+				first = &key
+			}
+		}
+	}
+	if first == nil {
+		return adaptiveKey{service: processor.ServiceNameForNode(nil)}
+	}
+	return *first
+}
@@ -0,0 +1,205 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+func rootSpanTrace(service, operation string) *TraceData {
+	return newTraceData(batchOfService(service, &tracepb.Span{
+		SpanId: []byte{1},
+		Name:   &tracepb.TruncatableString{Value: operation},
+	}))
+}
+
+// evaluate evaluates the trace and counts it as the tail-sampling processor does.
+func (a *adaptive) evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	decision, err := a.Evaluate(traceID, trace)
+	a.CountTrace(trace)
+	return decision, err
+}
+
+func TestAdaptiveTargetsTracesPerSecond(t *testing.T) {
+	evaluator, err := NewAdaptive(100, 10*time.Second, "", DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() = %v", err)
+	}
+	a := evaluator.(*adaptive)
+	now := time.Unix(1500000000, 0)
+	a.now = func() time.Time { return now }
+	a.lastAdjust = now
+
+	// 10s of traffic: 1000 traces/s of a busy operation and 10 traces/s of a quiet one.
+	traceID := make([]byte, 16)
+	evaluateInterval := func() (busySampled, quietSampled int) {
+		for i := 0; i < 10000; i++ {
+			binary.BigEndian.PutUint64(traceID[8:], uint64(now.UnixNano())+uint64(i))
+			if d, _ := a.evaluate(traceID, rootSpanTrace("frontend", "GET /")); d == Sampled {
+				busySampled++
+			}
+			if i%100 == 0 {
+				if d, _ := a.evaluate(traceID, rootSpanTrace("backend", "Health")); d == Sampled {
+					quietSampled++
+				}
+			}
+		}
+		now = now.Add(10 * time.Second)
+		return busySampled, quietSampled
+	}
+
+	evaluateInterval()
+	busySampled, quietSampled := evaluateInterval()
+	if quietSampled != 100 {
+		t.Errorf("got %d traces of the quiet operation sampled, want all 100", quietSampled)
+	}
+	if want := 900; math.Abs(float64(busySampled-want)) > 100 {
+		t.Errorf("got %d traces of the busy operation sampled, want about %d", busySampled, want)
+	}
+	if got := a.keys[adaptiveKey{service: "frontend", operation: "GET /"}].probability; math.Abs(got-0.09) > 0.001 {
+		t.Errorf("got probability %v for the busy operation, want 0.09", got)
+	}
+}
+
+func TestAdaptiveStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adaptive")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	evaluator, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() = %v", err)
+	}
+	a := evaluator.(*adaptive)
+	now := time.Unix(1500000000, 0)
+	a.now = func() time.Time { return now }
+	a.lastAdjust = now
+	for i := 0; i < 4; i++ {
+		a.evaluate([]byte{byte(i)}, rootSpanTrace("frontend", "GET /"))
+	}
+	now = now.Add(time.Second)
+	if _, err := a.evaluate(nil, rootSpanTrace("backend", "Health")); err != nil {
+		t.Fatalf("Evaluate() = %v", err)
+	}
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	restarted, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() after restart = %v", err)
+	}
+	ra := restarted.(*adaptive)
+	ra.evaluate(nil, rootSpanTrace("frontend", "GET /"))
+	if got := ra.keys[adaptiveKey{service: "frontend", operation: "GET /"}].probability; got != 0.25 {
+		t.Errorf("got probability %v after restart, want the saved 0.25", got)
+	}
+
+	if err := ioutil.WriteFile(stateFile, []byte("{"), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if _, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed); err == nil {
+		t.Errorf("NewAdaptive() with a corrupt state file = nil error, want an error")
+	}
+}
+
+func TestAdaptiveStateFileDropsUnseenKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adaptive")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(stateFile, []byte(`{"probabilities":[
+		{"service":"frontend","operation":"GET /","probability":0.5},
+		{"service":"gone","operation":"GET /","probability":0.5}
+	]}`), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	evaluator, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() = %v", err)
+	}
+	a := evaluator.(*adaptive)
+	now := time.Unix(1500000000, 0)
+	a.now = func() time.Time { return now }
+	a.lastAdjust = now
+	a.evaluate(nil, rootSpanTrace("frontend", "GET /"))
+	now = now.Add(time.Second)
+	if _, err := a.evaluate(nil, rootSpanTrace("frontend", "GET /")); err != nil {
+		t.Fatalf("Evaluate() = %v", err)
+	}
+
+	blob, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	var state adaptiveState
+	if err := json.Unmarshal(blob, &state); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if len(state.Probabilities) != 1 || state.Probabilities[0].Service != "frontend" || state.Probabilities[0].Operation != "GET /" {
+		t.Errorf("got probabilities %+v in the state file, want only the seen key", state.Probabilities)
+	}
+}
+
+func TestAdaptiveStateFileKeepsServiceAndOperationApart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adaptive")
+	if err != nil {
+		t.Fatalf("TempDir() = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	evaluator, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() = %v", err)
+	}
+	a := evaluator.(*adaptive)
+	// Joined with "/" both keys would be "shop/cart/checkout".
+	a.stateOf(adaptiveKey{service: "shop/cart", operation: "checkout"}).probability = 0.25
+	a.stateOf(adaptiveKey{service: "shop", operation: "cart/checkout"}).probability = 0.75
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	restarted, err := NewAdaptive(1, time.Second, stateFile, DefaultAdaptiveHashSeed)
+	if err != nil {
+		t.Fatalf("NewAdaptive() after restart = %v", err)
+	}
+	ra := restarted.(*adaptive)
+	want := map[adaptiveKey]float64{
+		{service: "shop/cart", operation: "checkout"}: 0.25,
+		{service: "shop", operation: "cart/checkout"}: 0.75,
+	}
+	if !reflect.DeepEqual(ra.saved, want) {
+		t.Errorf("got saved probabilities %v after restart, want %v", ra.saved, want)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type and struct {
+	subPolicies []PolicyEvaluator
+}
+
+var _ PolicyEvaluator = (*and)(nil)
+var _ TraceCounter = (*and)(nil)
+var _ consumer.Shutdowner = (*and)(nil)
+
+// NewAnd creates a policy evaluator that samples the traces sampled by all the given
+// policy evaluators.
+func NewAnd(subPolicies ...PolicyEvaluator) PolicyEvaluator {
+	return &and{subPolicies: subPolicies}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (a *and) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return onLateArrivingSpans(a.subPolicies, earlyDecision, spans)
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (a *and) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	for _, sub := range a.subPolicies {
+		decision, err := sub.Evaluate(traceID, trace)
+		if err != nil || decision != Sampled {
+			return NotSampled, err
+		}
+	}
+	return Sampled, nil
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (a *and) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	for _, sub := range a.subPolicies {
+		decision, err := sub.OnDroppedSpans(traceID, trace)
+		if err != nil || decision != Sampled {
+			return NotSampled, err
+		}
+	}
+	return Sampled, nil
+}
+
+// CountTrace passes the trace counted for the policy to its sub-policies.
+func (a *and) CountTrace(trace *TraceData) {
+	countSubPolicies(a.subPolicies, trace)
+}
+
+// Shutdown shuts down the sub-policies of the conjunction.
+func (a *and) Shutdown(ctx context.Context) error {
+	return shutdownSubPolicies(ctx, a.subPolicies)
+}
+
+type or struct {
+	subPolicies []PolicyEvaluator
+}
+
+var _ PolicyEvaluator = (*or)(nil)
+var _ TraceCounter = (*or)(nil)
+var _ consumer.Shutdowner = (*or)(nil)
+
+// NewOr creates a policy evaluator that samples the traces sampled by any of the given
+// policy evaluators.
+func NewOr(subPolicies ...PolicyEvaluator) PolicyEvaluator {
+	return &or{subPolicies: subPolicies}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (o *or) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return onLateArrivingSpans(o.subPolicies, earlyDecision, spans)
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (o *or) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	var errs []error
+	for _, sub := range o.subPolicies {
+		decision, err := sub.Evaluate(traceID, trace)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if decision == Sampled {
+			return Sampled, nil
+		}
+	}
+	return NotSampled, internal.CombineErrors(errs)
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (o *or) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	var errs []error
+	for _, sub := range o.subPolicies {
+		decision, err := sub.OnDroppedSpans(traceID, trace)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if decision == Sampled {
+			return Sampled, nil
+		}
+	}
+	return NotSampled, internal.CombineErrors(errs)
+}
+
+// CountTrace passes the trace counted for the policy to its sub-policies.
+func (o *or) CountTrace(trace *TraceData) {
+	countSubPolicies(o.subPolicies, trace)
+}
+
+// Shutdown shuts down the sub-policies of the disjunction.
+func (o *or) Shutdown(ctx context.Context) error {
+	return shutdownSubPolicies(ctx, o.subPolicies)
+}
+
+type not struct {
+	subPolicy PolicyEvaluator
+}
+
+var _ PolicyEvaluator = (*not)(nil)
+var _ TraceCounter = (*not)(nil)
+var _ consumer.Shutdowner = (*not)(nil)
+
+// NewNot creates a policy evaluator that samples the traces not sampled by the given
+// policy evaluator.
+func NewNot(subPolicy PolicyEvaluator) PolicyEvaluator {
+	return &not{subPolicy: subPolicy}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (n *not) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return n.subPolicy.OnLateArrivingSpans(invert(earlyDecision), spans)
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (n *not) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	decision, err := n.subPolicy.Evaluate(traceID, trace)
+	if err != nil {
+		return NotSampled, err
+	}
+	return invert(decision), nil
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (n *not) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	decision, err := n.subPolicy.OnDroppedSpans(traceID, trace)
+	if err != nil {
+		return NotSampled, err
+	}
+	return invert(decision), nil
+}
+
+// CountTrace passes the trace counted for the policy to its sub-policy.
+func (n *not) CountTrace(trace *TraceData) {
+	countSubPolicies([]PolicyEvaluator{n.subPolicy}, trace)
+}
+
+// Shutdown shuts down the negated sub-policy.
+func (n *not) Shutdown(ctx context.Context) error {
+	return shutdownSubPolicies(ctx, []PolicyEvaluator{n.subPolicy})
+}
+
+func invert(decision Decision) Decision {
+	switch decision {
+	case Sampled:
+		return NotSampled
+	case NotSampled:
+		return Sampled
+	}
+	return decision
+}
+
+func onLateArrivingSpans(subPolicies []PolicyEvaluator, earlyDecision Decision, spans []*tracepb.Span) error {
+	var errs []error
+	for _, sub := range subPolicies {
+		if err := sub.OnLateArrivingSpans(earlyDecision, spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// countSubPolicies passes the trace to the sub-policies that implement TraceCounter, since
+// the tail-sampling processor counts the traces of the top-level policies only.
+func countSubPolicies(subPolicies []PolicyEvaluator, trace *TraceData) {
+	for _, sub := range subPolicies {
+		if counter, ok := sub.(TraceCounter); ok {
+			counter.CountTrace(trace)
+		}
+	}
+}
+
+// shutdownSubPolicies shuts down the sub-policies that implement consumer.Shutdowner, the
+// ones with state to release or persist, e.g.: the adaptive policy saving its
+// probabilities.
+func shutdownSubPolicies(ctx context.Context, subPolicies []PolicyEvaluator) error {
+	var errs []error
+	for _, sub := range subPolicies {
+		if shutdowner, ok := sub.(consumer.Shutdowner); ok {
+			if err := shutdowner.Shutdown(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return internal.CombineErrors(errs)
+}
@@ -0,0 +1,114 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+)
+
+// fixedPolicy is a policy evaluator that always returns the same decision and error.
+type fixedPolicy struct {
+	decision    Decision
+	err         error
+	evaluations int
+}
+
+var _ PolicyEvaluator = (*fixedPolicy)(nil)
+
+func (fp *fixedPolicy) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+func (fp *fixedPolicy) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	fp.evaluations++
+	return fp.decision, fp.err
+}
+
+func (fp *fixedPolicy) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return fp.decision, fp.err
+}
+
+func TestBooleanPolicies(t *testing.T) {
+	sampled := &fixedPolicy{decision: Sampled}
+	notSampled := &fixedPolicy{decision: NotSampled}
+	failed := &fixedPolicy{decision: NotSampled, err: errors.New("failed")}
+	tests := []struct {
+		name      string
+		evaluator PolicyEvaluator
+		want      Decision
+		wantErr   bool
+	}{
+		{name: "and all sampled", evaluator: NewAnd(sampled, sampled), want: Sampled},
+		{name: "and one not sampled", evaluator: NewAnd(sampled, notSampled), want: NotSampled},
+		{name: "and error", evaluator: NewAnd(sampled, failed), want: NotSampled, wantErr: true},
+		{name: "or one sampled", evaluator: NewOr(notSampled, sampled), want: Sampled},
+		{name: "or none sampled", evaluator: NewOr(notSampled, notSampled), want: NotSampled},
+		{name: "or error and sampled", evaluator: NewOr(failed, sampled), want: Sampled},
+		{name: "or error", evaluator: NewOr(failed, notSampled), want: NotSampled, wantErr: true},
+		{name: "not sampled", evaluator: NewNot(sampled), want: NotSampled},
+		{name: "not not sampled", evaluator: NewNot(notSampled), want: Sampled},
+		{name: "not error", evaluator: NewNot(failed), want: NotSampled, wantErr: true},
+		{name: "nested", evaluator: NewAnd(NewOr(notSampled, sampled), NewNot(notSampled)), want: Sampled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.evaluator.Evaluate(nil, newTraceData())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// shutdownPolicy is a policy evaluator with state that counts its shutdowns.
+type shutdownPolicy struct {
+	fixedPolicy
+	shutdowns int
+}
+
+func (sp *shutdownPolicy) Shutdown(context.Context) error {
+	sp.shutdowns++
+	return nil
+}
+
+func TestCombinedPoliciesShutdownSubPolicies(t *testing.T) {
+	inAnd := &shutdownPolicy{}
+	inOr := &shutdownPolicy{}
+	inNot := &shutdownPolicy{}
+	inComposite := &shutdownPolicy{}
+	evaluator := NewComposite(100, []WeightedPolicy{
+		{Evaluator: NewAnd(&fixedPolicy{}, inAnd), Weight: 1},
+		{Evaluator: NewOr(inOr, NewNot(inNot)), Weight: 1},
+		{Evaluator: inComposite, Weight: 1},
+	})
+
+	if err := evaluator.(consumer.Shutdowner).Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	for name, sp := range map[string]*shutdownPolicy{"and": inAnd, "or": inOr, "not": inNot, "composite": inComposite} {
+		if sp.shutdowns != 1 {
+			t.Errorf("sub-policy of %s shut down %d times, want 1", name, sp.shutdowns)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"math"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// WeightedPolicy is a sub-policy of a composite policy with the share of the spans per
+// second of the composite policy that it can sample.
+type WeightedPolicy struct {
+	Evaluator PolicyEvaluator
+	// Weight is the share of the budget of the composite policy, relative to the sum of
+	// the weights of all its sub-policies.
+	Weight float64
+}
+
+type budgetedPolicy struct {
+	evaluator            PolicyEvaluator
+	spansPerSecond       int64
+	spansInCurrentSecond int64
+}
+
+type composite struct {
+	subPolicies   []*budgetedPolicy
+	currentSecond int64
+	now           func() time.Time
+}
+
+var _ PolicyEvaluator = (*composite)(nil)
+var _ TraceCounter = (*composite)(nil)
+var _ consumer.Shutdowner = (*composite)(nil)
+
+// NewComposite creates a policy evaluator that samples a trace when one of the given
+// sub-policies samples it while the spans sampled by that sub-policy in the current second
+// are within its share, by weight, of spansPerSecond. The sub-policies are evaluated in
+// order, a trace sampled by a sub-policy without budget left is offered to the next ones.
+func NewComposite(spansPerSecond int64, subPolicies []WeightedPolicy) PolicyEvaluator {
+	var totalWeight float64
+	for _, sub := range subPolicies {
+		totalWeight += sub.Weight
+	}
+	c := &composite{now: time.Now}
+	for _, sub := range subPolicies {
+		var budget int64
+		if totalWeight > 0 {
+			// Rounded so that a small share of a small budget still samples some spans.
+			budget = int64(math.Round(float64(spansPerSecond) * sub.Weight / totalWeight))
+		}
+		c.subPolicies = append(c.subPolicies, &budgetedPolicy{
+			evaluator:      sub.Evaluator,
+			spansPerSecond: budget,
+		})
+	}
+	return c
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (c *composite) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	var errs []error
+	for _, sub := range c.subPolicies {
+		if err := sub.evaluator.OnLateArrivingSpans(earlyDecision, spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (c *composite) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	currSecond := c.now().Unix()
+	if c.currentSecond != currSecond {
+		c.currentSecond = currSecond
+		for _, sub := range c.subPolicies {
+			sub.spansInCurrentSecond = 0
+		}
+	}
+
+	trace.Lock()
+	spanCount := trace.SpanCount
+	trace.Unlock()
+
+	var errs []error
+	for _, sub := range c.subPolicies {
+		spansInSecondIfSampled := sub.spansInCurrentSecond + spanCount
+		if spansInSecondIfSampled > sub.spansPerSecond {
+			continue
+		}
+		decision, err := sub.evaluator.Evaluate(traceID, trace)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if decision == Sampled {
+			sub.spansInCurrentSecond = spansInSecondIfSampled
+			return Sampled, nil
+		}
+	}
+	return NotSampled, internal.CombineErrors(errs)
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (c *composite) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
+
+// CountTrace passes the trace counted for the policy to its sub-policies.
+func (c *composite) CountTrace(trace *TraceData) {
+	countSubPolicies(c.evaluators(), trace)
+}
+
+// Shutdown shuts down the weighted sub-policies.
+func (c *composite) Shutdown(ctx context.Context) error {
+	return shutdownSubPolicies(ctx, c.evaluators())
+}
+
+func (c *composite) evaluators() []PolicyEvaluator {
+	evaluators := make([]PolicyEvaluator, 0, len(c.subPolicies))
+	for _, sub := range c.subPolicies {
+		evaluators = append(evaluators, sub.evaluator)
+	}
+	return evaluators
+}
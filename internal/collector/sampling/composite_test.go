@@ -0,0 +1,77 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestCompositeBudgets(t *testing.T) {
+	errorsPolicy := &fixedPolicy{decision: Sampled}
+	slowPolicy := &fixedPolicy{decision: NotSampled}
+	restPolicy := &fixedPolicy{decision: Sampled}
+	c := NewComposite(100, []WeightedPolicy{
+		{Evaluator: errorsPolicy, Weight: 50},
+		{Evaluator: slowPolicy, Weight: 30},
+		{Evaluator: restPolicy, Weight: 20},
+	}).(*composite)
+	now := time.Unix(1500000000, 0)
+	c.now = func() time.Time { return now }
+
+	// Traces of 10 spans: the first 5 fit the budget of 50 spans of the errors policy, the
+	// next 2 the budget of 20 spans of the rest policy.
+	trace := newTraceData(data.TraceData{Spans: make([]*tracepb.Span, 10)})
+	for i := 0; i < 7; i++ {
+		if got, _ := c.Evaluate(nil, trace); got != Sampled {
+			t.Fatalf("trace %d: Evaluate() = %v, want Sampled", i, got)
+		}
+	}
+	if got, _ := c.Evaluate(nil, trace); got != NotSampled {
+		t.Fatalf("Evaluate() with all budgets spent = %v, want NotSampled", got)
+	}
+	if errorsPolicy.evaluations != 5 {
+		t.Errorf("got %d evaluations of the errors policy, want 5 while it had budget", errorsPolicy.evaluations)
+	}
+	if restPolicy.evaluations != 2 {
+		t.Errorf("got %d evaluations of the rest policy, want 2", restPolicy.evaluations)
+	}
+
+	now = now.Add(time.Second)
+	if got, _ := c.Evaluate(nil, trace); got != Sampled {
+		t.Errorf("Evaluate() in the next second = %v, want Sampled", got)
+	}
+}
+
+func TestCompositeSmallWeight(t *testing.T) {
+	// 1% of a budget of 50 spans per second for everything else.
+	restPolicy := &fixedPolicy{decision: Sampled}
+	c := NewComposite(50, []WeightedPolicy{
+		{Evaluator: &fixedPolicy{decision: NotSampled}, Weight: 99},
+		{Evaluator: restPolicy, Weight: 1},
+	})
+
+	trace := newTraceData(data.TraceData{Spans: make([]*tracepb.Span, 1)})
+	if got, _ := c.Evaluate(nil, trace); got != Sampled {
+		t.Errorf("Evaluate() = %v, want Sampled within the small share", got)
+	}
+	if restPolicy.evaluations != 1 {
+		t.Errorf("got %d evaluations of the small-weight policy, want 1", restPolicy.evaluations)
+	}
+}
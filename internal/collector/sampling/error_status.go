@@ -0,0 +1,192 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"strconv"
+	"strings"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+const (
+	errorAttributeKey      = "error"
+	httpStatusAttributeKey = "http.status_code"
+	eventAttributeKey      = "event"
+)
+
+// canonicalCodes are the canonical status codes by name, see
+// https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto.
+var canonicalCodes = map[string]int32{
+	"OK":                  0,
+	"CANCELLED":           1,
+	"UNKNOWN":             2,
+	"INVALID_ARGUMENT":    3,
+	"DEADLINE_EXCEEDED":   4,
+	"NOT_FOUND":           5,
+	"ALREADY_EXISTS":      6,
+	"PERMISSION_DENIED":   7,
+	"RESOURCE_EXHAUSTED":  8,
+	"FAILED_PRECONDITION": 9,
+	"ABORTED":             10,
+	"OUT_OF_RANGE":        11,
+	"UNIMPLEMENTED":       12,
+	"INTERNAL":            13,
+	"UNAVAILABLE":         14,
+	"DATA_LOSS":           15,
+	"UNAUTHENTICATED":     16,
+}
+
+// ParseStatusCode returns the canonical status code with the given name, e.g.: "INTERNAL",
+// regardless of case.
+func ParseStatusCode(name string) (int32, bool) {
+	code, ok := canonicalCodes[strings.ToUpper(name)]
+	return code, ok
+}
+
+// HTTPStatusRange is an inclusive range of HTTP status codes.
+type HTTPStatusRange struct {
+	Min, Max int64
+}
+
+type errorStatus struct {
+	statusCodes      map[int32]bool
+	httpStatusRanges []HTTPStatusRange
+	errorTag         bool
+	errorAnnotations bool
+}
+
+var _ PolicyEvaluator = (*errorStatus)(nil)
+
+// NewErrorStatus creates a policy evaluator that samples the traces with a span whose status
+// has one of the given canonical codes, or nil for all codes but OK, or whose
+// "http.status_code" attribute is in one of the given ranges. If errorTag is true the traces
+// with a span whose "error" attribute is set, as done by OpenTracing, are sampled too, and
+// if errorAnnotations is true the traces with a span that has an error annotation, i.e.: an
+// annotation described as "error" or with the "event" attribute equal to "error".
+func NewErrorStatus(statusCodes []int32, httpStatusRanges []HTTPStatusRange, errorTag, errorAnnotations bool) PolicyEvaluator {
+	codes := make(map[int32]bool)
+	if statusCodes == nil {
+		for _, code := range canonicalCodes {
+			if code != 0 {
+				codes[code] = true
+			}
+		}
+	}
+	for _, code := range statusCodes {
+		codes[code] = true
+	}
+	return &errorStatus{
+		statusCodes:      codes,
+		httpStatusRanges: httpStatusRanges,
+		errorTag:         errorTag,
+		errorAnnotations: errorAnnotations,
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (es *errorStatus) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (es *errorStatus) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			if span != nil && es.isError(span) {
+				return Sampled, nil
+			}
+		}
+	}
+
+	return NotSampled, nil
+}
+
+func (es *errorStatus) isError(span *tracepb.Span) bool {
+	if span.Status != nil && es.statusCodes[span.Status.Code] {
+		return true
+	}
+	if span.Attributes != nil {
+		if es.errorTag && isTrue(span.Attributes.AttributeMap[errorAttributeKey]) {
+			return true
+		}
+		if v, ok := span.Attributes.AttributeMap[httpStatusAttributeKey]; ok && es.isHTTPError(v) {
+			return true
+		}
+	}
+	if es.errorAnnotations && span.TimeEvents != nil {
+		for _, te := range span.TimeEvents.TimeEvent {
+			if isErrorAnnotation(te.GetAnnotation()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (es *errorStatus) isHTTPError(v *tracepb.AttributeValue) bool {
+	status := v.GetIntValue()
+	if s := v.GetStringValue(); s != nil {
+		// The Zipkin receiver keeps all tags as string attributes.
+		var err error
+		if status, err = strconv.ParseInt(s.Value, 10, 64); err != nil {
+			return false
+		}
+	}
+	for _, r := range es.httpStatusRanges {
+		if status >= r.Min && status <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrue returns whether the attribute is a true boolean or a string other than "false",
+// the Zipkin receiver keeps the "error" tag as a string with the error message or code.
+func isTrue(v *tracepb.AttributeValue) bool {
+	if v == nil {
+		return false
+	}
+	if s := v.GetStringValue(); s != nil {
+		return s.Value != "" && !strings.EqualFold(s.Value, "false")
+	}
+	return v.GetBoolValue()
+}
+
+func isErrorAnnotation(annotation *tracepb.Span_TimeEvent_Annotation) bool {
+	if annotation == nil {
+		return false
+	}
+	if annotation.Description != nil && strings.EqualFold(annotation.Description.Value, errorAttributeKey) {
+		return true
+	}
+	if annotation.Attributes != nil {
+		event := annotation.Attributes.AttributeMap[eventAttributeKey].GetStringValue()
+		return event != nil && strings.EqualFold(event.Value, errorAttributeKey)
+	}
+	return false
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (es *errorStatus) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func stringAttribute(key, value string) *tracepb.Span_Attributes {
+	return &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+		key: {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: value}}},
+	}}
+}
+
+func TestErrorStatus(t *testing.T) {
+	defaultPolicy := NewErrorStatus(nil, []HTTPStatusRange{{Min: 500, Max: 599}}, true, true)
+	tests := []struct {
+		name      string
+		evaluator PolicyEvaluator
+		span      *tracepb.Span
+		want      Decision
+	}{
+		{
+			name:      "ok status",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Status: &tracepb.Status{Code: 0}},
+			want:      NotSampled,
+		},
+		{
+			name:      "non-ok status",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Status: &tracepb.Status{Code: 13}},
+			want:      Sampled,
+		},
+		{
+			name:      "status not in the configured codes",
+			evaluator: NewErrorStatus([]int32{14}, nil, false, false),
+			span:      &tracepb.Span{Status: &tracepb.Status{Code: 13}},
+			want:      NotSampled,
+		},
+		{
+			name:      "http status as int",
+			evaluator: defaultPolicy,
+			span: &tracepb.Span{Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+				"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 503}},
+			}}},
+			want: Sampled,
+		},
+		{
+			name:      "http status as string",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Attributes: stringAttribute("http.status_code", "502")},
+			want:      Sampled,
+		},
+		{
+			name:      "http status out of range",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Attributes: stringAttribute("http.status_code", "404")},
+			want:      NotSampled,
+		},
+		{
+			name:      "error tag as bool",
+			evaluator: defaultPolicy,
+			span: &tracepb.Span{Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+				"error": {Value: &tracepb.AttributeValue_BoolValue{BoolValue: true}},
+			}}},
+			want: Sampled,
+		},
+		{
+			name:      "error tag as string",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Attributes: stringAttribute("error", "INTERNAL")},
+			want:      Sampled,
+		},
+		{
+			name:      "error tag false",
+			evaluator: defaultPolicy,
+			span:      &tracepb.Span{Attributes: stringAttribute("error", "false")},
+			want:      NotSampled,
+		},
+		{
+			name:      "error tag ignored",
+			evaluator: NewErrorStatus(nil, nil, false, true),
+			span:      &tracepb.Span{Attributes: stringAttribute("error", "true")},
+			want:      NotSampled,
+		},
+		{
+			name:      "error event annotation",
+			evaluator: defaultPolicy,
+			span: &tracepb.Span{TimeEvents: &tracepb.Span_TimeEvents{TimeEvent: []*tracepb.Span_TimeEvent{
+				{Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Attributes: stringAttribute("event", "error"),
+				}}},
+			}}},
+			want: Sampled,
+		},
+		{
+			name:      "error description annotation ignored",
+			evaluator: NewErrorStatus(nil, nil, true, false),
+			span: &tracepb.Span{TimeEvents: &tracepb.Span_TimeEvents{TimeEvent: []*tracepb.Span_TimeEvent{
+				{Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: "error"},
+				}}},
+			}}},
+			want: NotSampled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := newTraceData(data.TraceData{Spans: []*tracepb.Span{{}, tt.span}})
+			got, err := tt.evaluator.Evaluate(nil, trace)
+			if err != nil {
+				t.Fatalf("Evaluate() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusCode(t *testing.T) {
+	if code, ok := ParseStatusCode("deadline_exceeded"); !ok || code != 4 {
+		t.Errorf("ParseStatusCode(deadline_exceeded) = %d, %v, want 4, true", code, ok)
+	}
+	if _, ok := ParseStatusCode("BROKEN"); ok {
+		t.Errorf("ParseStatusCode(BROKEN) succeeded, want failure")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// idleBucketTimeout is how long the token bucket of a key is kept after its last trace.
+const idleBucketTimeout = time.Minute
+
+// maxTaggedValues is the number of attribute values, besides the overridden ones, that get
+// their own series of the rate limiting metric. The services always get their own.
+const maxTaggedValues = 100
+
+// otherValuesTagValue is the value of the rate_limiting_key tag for the traces of the
+// attribute values past maxTaggedValues, so the metric has a bounded number of series.
+const otherValuesTagValue = "other"
+
+// tokenBucket allows up to rate spans per second, with bursts of up to one second worth
+// of spans.
+type tokenBucket struct {
+	rate     float64
+	tokens   float64
+	lastSeen time.Time
+}
+
+func (tb *tokenBucket) take(spans int64, now time.Time) bool {
+	tb.tokens += tb.rate * now.Sub(tb.lastSeen).Seconds()
+	if tb.tokens > tb.rate {
+		tb.tokens = tb.rate
+	}
+	tb.lastSeen = now
+
+	if float64(spans) > tb.tokens {
+		return false
+	}
+	tb.tokens -= float64(spans)
+	return true
+}
+
+type keyedRateLimiting struct {
+	name           string
+	key            string
+	spansPerSecond int64
+	overrides      map[string]int64
+
+	buckets   map[string]*tokenBucket
+	tagged    map[string]bool
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+var _ PolicyEvaluator = (*keyedRateLimiting)(nil)
+
+// NewKeyedRateLimiting creates a policy evaluator that limits the spans per second sampled
+// for each value of the given attribute key, or for each service if key is empty, so one
+// busy service can't take all the sampled spans. The overrides are the limits of specific
+// values, spansPerSecond is the limit of the others. The name identifies the policy in the
+// metrics of the sampled traces per service or value, past a limit the values that are not
+// overridden are counted together.
+func NewKeyedRateLimiting(name, key string, spansPerSecond int64, overrides map[string]int64) PolicyEvaluator {
+	return &keyedRateLimiting{
+		name:           name,
+		key:            key,
+		spansPerSecond: spansPerSecond,
+		overrides:      overrides,
+		buckets:        make(map[string]*tokenBucket),
+		tagged:         make(map[string]bool),
+		now:            time.Now,
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (krl *keyedRateLimiting) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (krl *keyedRateLimiting) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	spanCount := trace.SpanCount
+	trace.Unlock()
+
+	now := krl.now()
+	if now.Sub(krl.lastSweep) >= idleBucketTimeout {
+		krl.sweep(now)
+	}
+
+	value := krl.valueOf(batches)
+	rate, overridden := krl.overrides[value]
+	bucket, ok := krl.buckets[value]
+	if !ok {
+		if !overridden {
+			rate = krl.spansPerSecond
+		}
+		bucket = &tokenBucket{rate: float64(rate), tokens: float64(rate), lastSeen: now}
+		krl.buckets[value] = bucket
+	}
+	tagValue := krl.tagValueOf(value, overridden)
+
+	decision := NotSampled
+	if bucket.take(spanCount, now) {
+		decision = Sampled
+	}
+	stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{
+			tag.Upsert(tagPolicyKey, krl.name),
+			tag.Upsert(tagRateLimitingValueKey, tagValue),
+			tag.Upsert(tagSampledKey, strconv.FormatBool(decision == Sampled)),
+		},
+		statRateLimitedTraces.M(1))
+	return decision, nil
+}
+
+// tagValueOf returns the value of the rate_limiting_key tag of the traces of value.
+func (krl *keyedRateLimiting) tagValueOf(value string, overridden bool) string {
+	if krl.key == "" || overridden || krl.tagged[value] {
+		return value
+	}
+	if len(krl.tagged) < maxTaggedValues {
+		krl.tagged[value] = true
+		return value
+	}
+	return otherValuesTagValue
+}
+
+// sweep removes the buckets that haven't seen traces lately, so that keys with many values
+// don't hold memory forever.
+func (krl *keyedRateLimiting) sweep(now time.Time) {
+	for value, bucket := range krl.buckets {
+		if now.Sub(bucket.lastSeen) >= idleBucketTimeout {
+			delete(krl.buckets, value)
+		}
+	}
+	krl.lastSweep = now
+}
+
+// valueOf returns the value of the key of the trace: the first value of the attribute key,
+// or the service of the root span if the key is empty.
+func (krl *keyedRateLimiting) valueOf(batches []data.TraceData) string {
+	if krl.key == "" {
+		return rootKey(batches).service
+	}
+	for _, batch := range batches {
+		if batch.Node != nil {
+			if v, ok := batch.Node.Attributes[krl.key]; ok {
+				return v
+			}
+		}
+		for _, span := range batch.Spans {
+			if span == nil || span.Attributes == nil {
+				continue
+			}
+			if v, ok := span.Attributes.AttributeMap[krl.key]; ok {
+				return attributeValueString(v)
+			}
+		}
+	}
+	return ""
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (krl *keyedRateLimiting) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
+
+func attributeValueString(v *tracepb.AttributeValue) string {
+	switch value := v.Value.(type) {
+	case *tracepb.AttributeValue_StringValue:
+		return value.StringValue.GetValue()
+	case *tracepb.AttributeValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *tracepb.AttributeValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *tracepb.AttributeValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64)
+	}
+	return ""
+}
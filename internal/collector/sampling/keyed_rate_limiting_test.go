@@ -0,0 +1,180 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func traceOfService(service string, numSpans int) *TraceData {
+	spans := make([]*tracepb.Span, numSpans)
+	for i := range spans {
+		spans[i] = &tracepb.Span{SpanId: []byte{byte(i + 1)}}
+		if i > 0 {
+			spans[i].ParentSpanId = []byte{1}
+		}
+	}
+	return newTraceData(batchOfService(service, spans...))
+}
+
+func TestKeyedRateLimitingPerService(t *testing.T) {
+	krl := NewKeyedRateLimiting("per-service", "", 10, map[string]int64{"quiet": 20}).(*keyedRateLimiting)
+	now := time.Unix(1500000000, 0)
+	krl.now = func() time.Time { return now }
+
+	sampledOf := func(service string, traces int) int {
+		sampled := 0
+		for i := 0; i < traces; i++ {
+			if d, _ := krl.Evaluate(nil, traceOfService(service, 5)); d == Sampled {
+				sampled++
+			}
+		}
+		return sampled
+	}
+
+	if got := sampledOf("noisy", 100); got != 2 {
+		t.Errorf("got %d traces of the noisy service sampled, want 2", got)
+	}
+	if got := sampledOf("quiet", 10); got != 4 {
+		t.Errorf("got %d traces of the quiet service sampled, want 4 despite the noisy service", got)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if got := sampledOf("noisy", 10); got != 1 {
+		t.Errorf("got %d traces of the noisy service sampled after half a second, want 1", got)
+	}
+
+	now = now.Add(idleBucketTimeout)
+	sampledOf("quiet", 1)
+	if _, ok := krl.buckets["noisy"]; ok {
+		t.Errorf("the bucket of the idle service was not removed")
+	}
+}
+
+func TestKeyedRateLimitingRootService(t *testing.T) {
+	krl := NewKeyedRateLimiting("per-service", "", 10, map[string]int64{"frontend": 0}).(*keyedRateLimiting)
+	root := &tracepb.Span{SpanId: []byte{1}}
+	child := &tracepb.Span{SpanId: []byte{2}, ParentSpanId: []byte{1}}
+	trace := newTraceData(batchOfService("backend", child), batchOfService("frontend", root))
+	if d, _ := krl.Evaluate(nil, trace); d != NotSampled {
+		t.Errorf("Evaluate() = %v, want NotSampled by the limit of the root service", d)
+	}
+}
+
+func TestKeyedRateLimitingPerAttribute(t *testing.T) {
+	krl := NewKeyedRateLimiting("per-tenant", "tenant", 1, map[string]int64{"42": 10})
+	trace := newTraceData(data.TraceData{
+		Node: &commonpb.Node{},
+		Spans: []*tracepb.Span{{Attributes: &tracepb.Span_Attributes{AttributeMap: map[string]*tracepb.AttributeValue{
+			"tenant": {Value: &tracepb.AttributeValue_IntValue{IntValue: 42}},
+		}}}, {}},
+	})
+	for i := 0; i < 5; i++ {
+		if d, _ := krl.Evaluate(nil, trace); d != Sampled {
+			t.Fatalf("trace %d: Evaluate() = %v, want Sampled within the override of the tenant", i, d)
+		}
+	}
+
+	other := newTraceData(data.TraceData{
+		Node:  &commonpb.Node{Attributes: map[string]string{"tenant": "7"}},
+		Spans: []*tracepb.Span{{}, {}},
+	})
+	if d, _ := krl.Evaluate(nil, other); d != NotSampled {
+		t.Errorf("Evaluate() = %v, want NotSampled above the default limit", d)
+	}
+}
+
+func TestKeyedRateLimitingMetricTags(t *testing.T) {
+	tenantTrace := func(tenant string) *TraceData {
+		return newTraceData(data.TraceData{
+			Node:  &commonpb.Node{Attributes: map[string]string{"tenant": tenant}},
+			Spans: []*tracepb.Span{{}},
+		})
+	}
+	var manyTenants []*TraceData
+	var firstTenants []string
+	for i := 0; i < maxTaggedValues+2; i++ {
+		tenant := strconv.Itoa(i)
+		manyTenants = append(manyTenants, tenantTrace(tenant))
+		if i < maxTaggedValues {
+			firstTenants = append(firstTenants, tenant)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		traces     []*TraceData
+		wantValues []string
+	}{
+		{
+			name:       "services",
+			traces:     []*TraceData{traceOfService("quiet", 1), traceOfService("noisy", 1), traceOfService("busy", 1)},
+			wantValues: []string{"busy", "noisy", "quiet"},
+		},
+		{
+			name:       "attribute values",
+			key:        "tenant",
+			traces:     append(manyTenants, tenantTrace("quiet")),
+			wantValues: append(append(firstTenants, otherValuesTagValue), "quiet"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			views := MetricViews(telemetry.Normal)
+			if err := view.Register(views...); err != nil {
+				t.Fatalf("Failed to register the views: %v", err)
+			}
+			defer view.Unregister(views...)
+
+			krl := NewKeyedRateLimiting("tagged", tt.key, 10, map[string]int64{"quiet": 20})
+			for _, trace := range tt.traces {
+				krl.Evaluate(nil, trace)
+			}
+
+			rows, err := view.RetrieveData(statRateLimitedTraces.Name())
+			if err != nil {
+				t.Fatalf("Failed to retrieve the metric: %v", err)
+			}
+			seen := make(map[string]bool)
+			var values []string
+			for _, row := range rows {
+				for _, tag := range row.Tags {
+					if tag.Key == tagRateLimitingValueKey && !seen[tag.Value] {
+						seen[tag.Value] = true
+						values = append(values, tag.Value)
+					}
+				}
+			}
+			sort.Strings(values)
+			sort.Strings(tt.wantValues)
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("got %q values of the %s tag, want %q", values, tagRateLimitingValueKey.Name(), tt.wantValues)
+			}
+		})
+	}
+}
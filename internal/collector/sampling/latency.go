@@ -0,0 +1,100 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+type latency struct {
+	traceThreshold      time.Duration
+	spanThreshold       time.Duration
+	operationThresholds map[string]time.Duration
+}
+
+var _ PolicyEvaluator = (*latency)(nil)
+
+// NewLatency creates a policy evaluator that samples the traces that last longer than
+// traceThreshold, from the start of their first span to the end of their last span, or that
+// have a span slower than the threshold of its operation in operationThresholds or, for other
+// operations, slower than spanThreshold. Zero thresholds are not checked.
+func NewLatency(traceThreshold, spanThreshold time.Duration, operationThresholds map[string]time.Duration) PolicyEvaluator {
+	return &latency{
+		traceThreshold:      traceThreshold,
+		spanThreshold:       spanThreshold,
+		operationThresholds: operationThresholds,
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (l *latency) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (l *latency) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	trace.Unlock()
+
+	var traceStart, traceEnd time.Time
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			if span == nil || span.StartTime == nil || span.EndTime == nil {
+				continue
+			}
+			start, end := timestampToTime(span.StartTime), timestampToTime(span.EndTime)
+			if threshold := l.thresholdFor(span); threshold > 0 && end.Sub(start) > threshold {
+				return Sampled, nil
+			}
+			if traceStart.IsZero() || start.Before(traceStart) {
+				traceStart = start
+			}
+			if end.After(traceEnd) {
+				traceEnd = end
+			}
+		}
+	}
+
+	if l.traceThreshold > 0 && traceEnd.Sub(traceStart) > l.traceThreshold {
+		return Sampled, nil
+	}
+	return NotSampled, nil
+}
+
+func (l *latency) thresholdFor(span *tracepb.Span) time.Duration {
+	if span.Name != nil {
+		if threshold, ok := l.operationThresholds[span.Name.Value]; ok {
+			return threshold
+		}
+	}
+	return l.spanThreshold
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (l *latency) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
+
+func timestampToTime(ts *timestamp.Timestamp) time.Time {
+	return time.Unix(ts.Seconds, int64(ts.Nanos))
+}
@@ -0,0 +1,108 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func spanWithDuration(name string, start time.Time, d time.Duration) *tracepb.Span {
+	return &tracepb.Span{
+		Name:      &tracepb.TruncatableString{Value: name},
+		StartTime: internal.TimeToTimestamp(start),
+		EndTime:   internal.TimeToTimestamp(start.Add(d)),
+	}
+}
+
+func newTraceData(batches ...data.TraceData) *TraceData {
+	td := &TraceData{ReceivedBatches: batches}
+	for _, batch := range batches {
+		td.SpanCount += int64(len(batch.Spans))
+	}
+	return td
+}
+
+func TestLatency(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	tests := []struct {
+		name      string
+		evaluator PolicyEvaluator
+		spans     []*tracepb.Span
+		want      Decision
+	}{
+		{
+			name:      "trace above threshold",
+			evaluator: NewLatency(time.Second, 0, nil),
+			spans: []*tracepb.Span{
+				spanWithDuration("a", start, 600*time.Millisecond),
+				spanWithDuration("b", start.Add(500*time.Millisecond), 600*time.Millisecond),
+			},
+			want: Sampled,
+		},
+		{
+			name:      "trace below threshold",
+			evaluator: NewLatency(time.Second, 0, nil),
+			spans: []*tracepb.Span{
+				spanWithDuration("a", start, 600*time.Millisecond),
+				spanWithDuration("b", start.Add(100*time.Millisecond), 600*time.Millisecond),
+			},
+			want: NotSampled,
+		},
+		{
+			name:      "span above threshold",
+			evaluator: NewLatency(0, 100*time.Millisecond, nil),
+			spans:     []*tracepb.Span{spanWithDuration("a", start, 200*time.Millisecond)},
+			want:      Sampled,
+		},
+		{
+			name:      "operation below its own threshold",
+			evaluator: NewLatency(0, 100*time.Millisecond, map[string]time.Duration{"a": time.Second}),
+			spans:     []*tracepb.Span{spanWithDuration("a", start, 200*time.Millisecond)},
+			want:      NotSampled,
+		},
+		{
+			name:      "operation above its own threshold",
+			evaluator: NewLatency(0, 0, map[string]time.Duration{"b": 100 * time.Millisecond}),
+			spans: []*tracepb.Span{
+				spanWithDuration("a", start, time.Second),
+				spanWithDuration("b", start, 200*time.Millisecond),
+			},
+			want: Sampled,
+		},
+		{
+			name:      "spans without times",
+			evaluator: NewLatency(time.Nanosecond, time.Nanosecond, nil),
+			spans:     []*tracepb.Span{{}, nil},
+			want:      NotSampled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.evaluator.Evaluate(nil, newTraceData(data.TraceData{Spans: tt.spans}))
+			if err != nil {
+				t.Fatalf("Evaluate() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+// Variables related to metrics specific to the sampling policies.
+var (
+	tagPolicyKey, _            = tag.NewKey("policy")
+	tagSampledKey, _           = tag.NewKey("sampled")
+	tagRateLimitingValueKey, _ = tag.NewKey("rate_limiting_key")
+
+	statRateLimitedTraces = stats.Int64("rate_limiting_traces", "Count of traces that were sampled or not by rate limiting, per key value", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metrics views related to the sampling policies.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	rateLimitedTracesView := &view.View{
+		Name:        statRateLimitedTraces.Name(),
+		Measure:     statRateLimitedTraces,
+		Description: statRateLimitedTraces.Description(),
+		TagKeys:     []tag.Key{tagPolicyKey, tagRateLimitingValueKey, tagSampledKey},
+		Aggregation: view.Sum(),
+	}
+
+	return []*view.View{rateLimitedTracesView}
+}
@@ -73,3 +73,10 @@ type PolicyEvaluator interface {
 	// pressure, before the decision_wait time has been reached.
 	OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error)
 }
+
+// TraceCounter is implemented by the policy evaluators that learn from the traces that the
+// tail-sampling processor counts for them in its count_traces_sampled metric.
+type TraceCounter interface {
+	// CountTrace is called for each trace counted as sampled or not by the policy.
+	CountTrace(trace *TraceData)
+}
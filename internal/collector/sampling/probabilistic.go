@@ -0,0 +1,95 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// numHashBuckets is the number of buckets the trace IDs are hashed to, it gives the
+// sampling percentages a precision of 0.01%.
+const numHashBuckets = 10000
+
+// HashTraceID returns the FNV-1a hash of the seed and trace ID, mixed with the finalizer
+// of MurmurHash3 so that similar trace IDs land in unrelated buckets.
+func HashTraceID(seed uint32, traceID []byte) uint32 {
+	var seedBytes [4]byte
+	binary.LittleEndian.PutUint32(seedBytes[:], seed)
+	h := fnv.New32a()
+	h.Write(seedBytes[:])
+	h.Write(traceID)
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// TraceIDThreshold returns the threshold for TraceIDSampled that samples the given
+// percentage, between 0 and 100, of the trace IDs.
+func TraceIDThreshold(percentage float64) uint32 {
+	return uint32(math.Round(percentage * numHashBuckets / 100))
+}
+
+// TraceIDSampled returns whether the trace ID hashes, with the given seed, below the
+// threshold. The same trace IDs are sampled everywhere the seed and threshold are the same.
+func TraceIDSampled(seed uint32, traceID []byte, threshold uint32) bool {
+	return HashTraceID(seed, traceID)%numHashBuckets < threshold
+}
+
+type probabilistic struct {
+	seed      uint32
+	threshold uint32
+}
+
+var _ PolicyEvaluator = (*probabilistic)(nil)
+
+// NewProbabilistic creates a policy evaluator that samples the given percentage, between 0
+// and 100, of the traces by hashing their trace IDs with the given seed. It samples the same
+// traces as the head-sampling processor with the same percentage and seed.
+func NewProbabilistic(percentage float64, seed uint32) PolicyEvaluator {
+	return &probabilistic{
+		seed:      seed,
+		threshold: TraceIDThreshold(percentage),
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (p *probabilistic) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (p *probabilistic) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	if TraceIDSampled(p.seed, traceID, p.threshold) {
+		return Sampled, nil
+	}
+	return NotSampled, nil
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (p *probabilistic) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return p.Evaluate(traceID, trace)
+}
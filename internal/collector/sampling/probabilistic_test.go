@@ -0,0 +1,152 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/headsampling"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+)
+
+func traceIDs(n int) [][]byte {
+	ids := make([][]byte, n)
+	for i := range ids {
+		ids[i] = make([]byte, 16)
+		binary.BigEndian.PutUint64(ids[i][8:], uint64(i))
+	}
+	return ids
+}
+
+func TestTraceIDThreshold(t *testing.T) {
+	tests := []struct {
+		percentage float64
+		want       uint32
+	}{
+		{percentage: 0, want: 0},
+		{percentage: 0.01, want: 1},
+		{percentage: 12.5, want: 1250},
+		{percentage: 100, want: 10000},
+	}
+	for _, tt := range tests {
+		if got := sampling.TraceIDThreshold(tt.percentage); got != tt.want {
+			t.Errorf("TraceIDThreshold(%v) = %d, want %d", tt.percentage, got, tt.want)
+		}
+	}
+}
+
+func TestTraceIDSampledNoneAndAll(t *testing.T) {
+	none, all := sampling.TraceIDThreshold(0), sampling.TraceIDThreshold(100)
+	for _, seed := range []uint32{0, 1, 42, 0xffffffff} {
+		for _, id := range traceIDs(10000) {
+			if sampling.TraceIDSampled(seed, id, none) {
+				t.Fatalf("trace ID %x sampled at 0%% with seed %d", id, seed)
+			}
+			if !sampling.TraceIDSampled(seed, id, all) {
+				t.Fatalf("trace ID %x not sampled at 100%% with seed %d", id, seed)
+			}
+		}
+	}
+}
+
+func TestTraceIDSampledSeedIndependence(t *testing.T) {
+	ids := traceIDs(20000)
+	threshold := sampling.TraceIDThreshold(50)
+	var bySeed0, bySeed1, byBoth int
+	for _, id := range ids {
+		if sampling.HashTraceID(0, id) != sampling.HashTraceID(0, id) {
+			t.Fatalf("HashTraceID is not deterministic for trace ID %x", id)
+		}
+		sampled0 := sampling.TraceIDSampled(0, id, threshold)
+		sampled1 := sampling.TraceIDSampled(1, id, threshold)
+		if sampled0 {
+			bySeed0++
+		}
+		if sampled1 {
+			bySeed1++
+		}
+		if sampled0 && sampled1 {
+			byBoth++
+		}
+	}
+
+	// Each seed samples about half of the traces, and the decisions of different seeds are
+	// unrelated, so about a quarter of the traces are sampled with both.
+	for seed, sampled := range []int{bySeed0, bySeed1} {
+		if sampled < 9600 || sampled > 10400 {
+			t.Errorf("got %d of 20000 traces sampled at 50%% with seed %d, want about 10000", sampled, seed)
+		}
+	}
+	if byBoth < 4600 || byBoth > 5400 {
+		t.Errorf("got %d of 20000 traces sampled with both seeds, want about 5000", byBoth)
+	}
+}
+
+func TestProbabilistic(t *testing.T) {
+	p := sampling.NewProbabilistic(25, 0)
+	sampled := 0
+	for _, id := range traceIDs(10000) {
+		if d, _ := p.Evaluate(id, nil); d == sampling.Sampled {
+			sampled++
+		}
+	}
+	if sampled < 2400 || sampled > 2600 {
+		t.Errorf("got %d of 10000 traces sampled, want about 2500", sampled)
+	}
+}
+
+func TestProbabilisticAgreesWithHeadSampling(t *testing.T) {
+	const percentage, seed = 12.5, 42
+	ids := traceIDs(10000)
+
+	sink := &exportertest.SinkTraceExporter{}
+	hs, err := headsampling.NewTraceProcessor(sink,
+		headsampling.WithSamplingPercentage(percentage),
+		headsampling.WithHashSeed(seed),
+	)
+	if err != nil {
+		t.Fatalf("headsampling.NewTraceProcessor() = %v", err)
+	}
+	spans := make([]*tracepb.Span, 0, len(ids))
+	for _, id := range ids {
+		spans = append(spans, &tracepb.Span{TraceId: id})
+	}
+	if err := hs.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+	headSampled := make(map[string]bool)
+	for _, td := range sink.AllTraces() {
+		for _, span := range td.Spans {
+			headSampled[string(span.TraceId)] = true
+		}
+	}
+
+	p := sampling.NewProbabilistic(percentage, seed)
+	for _, id := range ids {
+		d, _ := p.Evaluate(id, nil)
+		if got, want := d == sampling.Sampled, headSampled[string(id)]; got != want {
+			t.Errorf("trace ID %x: probabilistic sampled = %v, head sampling sampled = %v", id, got, want)
+		}
+	}
+	if len(headSampled) == 0 {
+		t.Errorf("head sampling sampled no traces at %v%%", percentage)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+type traceShape struct {
+	minSpans    int64
+	minDepth    int
+	minServices int
+	spanKinds   map[tracepb.Span_SpanKind]bool
+}
+
+var _ PolicyEvaluator = (*traceShape)(nil)
+
+// NewTraceShape creates a policy evaluator that samples the traces that have at least
+// minSpans spans, a chain of at least minDepth spans from a root span, spans from at least
+// minServices services, or a span of one of the given kinds. Zero minimums are not checked.
+func NewTraceShape(minSpans int64, minDepth, minServices int, spanKinds []tracepb.Span_SpanKind) PolicyEvaluator {
+	kinds := make(map[tracepb.Span_SpanKind]bool, len(spanKinds))
+	for _, kind := range spanKinds {
+		kinds[kind] = true
+	}
+	return &traceShape{
+		minSpans:    minSpans,
+		minDepth:    minDepth,
+		minServices: minServices,
+		spanKinds:   kinds,
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (ts *traceShape) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (ts *traceShape) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	trace.Lock()
+	batches := trace.ReceivedBatches
+	spanCount := trace.SpanCount
+	trace.Unlock()
+
+	if ts.minSpans > 0 && spanCount >= ts.minSpans {
+		return Sampled, nil
+	}
+
+	services := make(map[string]bool)
+	parents := make(map[string]string)
+	for _, batch := range batches {
+		if batch.Node != nil && batch.Node.ServiceInfo != nil {
+			services[batch.Node.ServiceInfo.Name] = true
+		}
+		for _, span := range batch.Spans {
+			if span == nil {
+				continue
+			}
+			if ts.spanKinds[span.Kind] {
+				return Sampled, nil
+			}
+			parents[string(span.SpanId)] = string(span.ParentSpanId)
+		}
+	}
+
+	if ts.minServices > 0 && len(services) >= ts.minServices {
+		return Sampled, nil
+	}
+	if ts.minDepth > 0 && traceDepth(parents) >= ts.minDepth {
+		return Sampled, nil
+	}
+	return NotSampled, nil
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (ts *traceShape) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return NotSampled, nil
+}
+
+// traceDepth returns the number of spans in the longest chain of spans linked by their
+// parents, given the parent ID of each span ID. Spans whose parents did not arrive start
+// a chain.
+func traceDepth(parents map[string]string) int {
+	depths := make(map[string]int, len(parents))
+	var depthOf func(spanID string, hops int) int
+	depthOf = func(spanID string, hops int) int {
+		if d, ok := depths[spanID]; ok {
+			return d
+		}
+		parentID, ok := parents[spanID]
+		if !ok {
+			return 0
+		}
+		d := 1
+		// The number of hops bounds the recursion when the parents form a cycle.
+		if _, parentArrived := parents[parentID]; parentArrived && hops < len(parents) {
+			d += depthOf(parentID, hops+1)
+		}
+		depths[spanID] = d
+		return d
+	}
+
+	maxDepth := 0
+	for spanID := range parents {
+		if d := depthOf(spanID, 0); d > maxDepth {
+			maxDepth = d
+		}
+	}
+	return maxDepth
+}
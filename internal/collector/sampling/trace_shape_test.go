@@ -0,0 +1,122 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// chainOfSpans returns n spans, each one the child of the previous one.
+func chainOfSpans(n int) []*tracepb.Span {
+	spans := make([]*tracepb.Span, n)
+	for i := range spans {
+		spans[i] = &tracepb.Span{SpanId: []byte{byte(i + 1)}}
+		if i > 0 {
+			spans[i].ParentSpanId = spans[i-1].SpanId
+		}
+	}
+	return spans
+}
+
+func batchOfService(service string, spans ...*tracepb.Span) data.TraceData {
+	return data.TraceData{
+		Node:  &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: service}},
+		Spans: spans,
+	}
+}
+
+func TestTraceShape(t *testing.T) {
+	chain := chainOfSpans(4)
+	tests := []struct {
+		name      string
+		evaluator PolicyEvaluator
+		trace     *TraceData
+		want      Decision
+	}{
+		{
+			name:      "enough spans",
+			evaluator: NewTraceShape(4, 0, 0, nil),
+			trace:     newTraceData(data.TraceData{Spans: chain}),
+			want:      Sampled,
+		},
+		{
+			name:      "too few spans",
+			evaluator: NewTraceShape(5, 0, 0, nil),
+			trace:     newTraceData(data.TraceData{Spans: chain}),
+			want:      NotSampled,
+		},
+		{
+			name:      "deep across batches",
+			evaluator: NewTraceShape(0, 4, 0, nil),
+			trace:     newTraceData(batchOfService("a", chain[2:]...), batchOfService("b", chain[:2]...)),
+			want:      Sampled,
+		},
+		{
+			name:      "missing parent splits the chain",
+			evaluator: NewTraceShape(0, 3, 0, nil),
+			trace:     newTraceData(data.TraceData{Spans: []*tracepb.Span{chain[0], chain[1], chain[3]}}),
+			want:      NotSampled,
+		},
+		{
+			name:      "parent cycle",
+			evaluator: NewTraceShape(0, 10, 0, nil),
+			trace: newTraceData(data.TraceData{Spans: []*tracepb.Span{
+				{SpanId: []byte{1}, ParentSpanId: []byte{2}},
+				{SpanId: []byte{2}, ParentSpanId: []byte{1}},
+			}}),
+			want: NotSampled,
+		},
+		{
+			name:      "enough services",
+			evaluator: NewTraceShape(0, 0, 2, nil),
+			trace:     newTraceData(batchOfService("a", chain[0]), batchOfService("b", chain[1]), batchOfService("a", chain[2])),
+			want:      Sampled,
+		},
+		{
+			name:      "too few services",
+			evaluator: NewTraceShape(0, 0, 2, nil),
+			trace:     newTraceData(batchOfService("a", chain[0]), batchOfService("a", chain[1])),
+			want:      NotSampled,
+		},
+		{
+			name:      "span kind",
+			evaluator: NewTraceShape(0, 0, 0, []tracepb.Span_SpanKind{tracepb.Span_CLIENT}),
+			trace:     newTraceData(data.TraceData{Spans: []*tracepb.Span{{Kind: tracepb.Span_SERVER}, {Kind: tracepb.Span_CLIENT}}}),
+			want:      Sampled,
+		},
+		{
+			name:      "no conditions",
+			evaluator: NewTraceShape(0, 0, 0, nil),
+			trace:     newTraceData(data.TraceData{Spans: chain}),
+			want:      NotSampled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.evaluator.Evaluate(nil, tt.trace)
+			if err != nil {
+				t.Fatalf("Evaluate() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package shopify
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
+	"github.com/census-instrumentation/opencensus-service/receiver/shopifyreceiver"
+)
+
+// TypeStr is the type of the Shopify receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "shopify"
+
+func init() {
+	receiver.RegisterTraceReceiverFactory(NewTraceReceiverFactory())
+}
+
+// NewTraceReceiverFactory creates a factory for Shopify receivers using the same
+// configuration settings of the "receivers" section of the collector.
+func NewTraceReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		TypeStr,
+		func() interface{} { return builder.NewDefaultShopifyReceiverCfg() },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rOpts := cfg.(*builder.ShopifyReceiverCfg)
+			addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
+			return shopifyreceiver.New(addr, next)
+		})
+	return f
+}
@@ -0,0 +1,68 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vmmetricsreceiver wraps the functionality to start the receiver
+// that collects metrics about the VM the collector is running on.
+package vmmetricsreceiver
+
+import (
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/vmmetricsreceiver"
+)
+
+// TypeStr is the type of the VM metrics receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "vmmetrics"
+
+func init() {
+	receiver.RegisterMetricsReceiverFactory(NewMetricsReceiverFactory())
+}
+
+type factory struct{}
+
+var _ receiver.MetricsReceiverFactory = (*factory)(nil)
+var _ receiver.ConfigValidator = (*factory)(nil)
+
+// NewMetricsReceiverFactory creates a factory for VM metrics receivers. The configuration is passed as-is to the receiver, when empty the receiver
+// defaults are used.
+func NewMetricsReceiverFactory() receiver.MetricsReceiverFactory {
+	return &factory{}
+}
+
+// Type gets the type of the receiver created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsReceiver.
+func (f *factory) NewFromViper(v *viper.Viper, next consumer.MetricsConsumer, logger *zap.Logger) (receiver.MetricsReceiver, error) {
+	return vmmetricsreceiver.New(v, next)
+}
+
+// ValidateConfig creates a receiver from v without starting it, creating the receiver
+// does not start any scraping.
+func (f *factory) ValidateConfig(v *viper.Viper) error {
+	_, err := vmmetricsreceiver.New(v, exportertest.NewNopMetricsExporter())
+	return err
+}
+
+// DefaultConfig gets the default configuration for the receiver created by this factory.
+func (f *factory) DefaultConfig() interface{} {
+	return &vmmetricsreceiver.Configuration{}
+}
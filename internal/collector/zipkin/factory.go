@@ -1,4 +1,4 @@
-// Copyright 2018, OpenCensus Authors
+// Copyright 2019, OpenCensus Authors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,37 +17,35 @@
 package zipkinreceiver
 
 import (
-	"context"
-	"fmt"
 	"strconv"
 
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
 	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver"
 )
 
-// Start starts the Zipkin receiver endpoint.
-func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
-	rOpts, err := builder.NewDefaultZipkinReceiverCfg().InitFromViper(v)
-	if err != nil {
-		return nil, err
-	}
+// TypeStr is the type of the Zipkin receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "zipkin"
 
-	addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
-	zi, err := zipkinreceiver.New(addr, traceConsumer)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create the Zipkin receiver: %v", err)
-	}
-
-	if err := zi.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("Cannot start Zipkin receiver to address %q: %v", addr, err)
-	}
-
-	logger.Info("Zipkin receiver is running.", zap.Int("port", rOpts.Port))
+func init() {
+	receiver.RegisterTraceReceiverFactory(NewTraceReceiverFactory())
+}
 
-	return zi, nil
+// NewTraceReceiverFactory creates a factory for Zipkin receivers using the same
+// configuration settings of the "receivers" section of the collector.
+func NewTraceReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		TypeStr,
+		func() interface{} { return builder.NewDefaultZipkinReceiverCfg() },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rOpts := cfg.(*builder.ZipkinReceiverCfg)
+			addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
+			return zipkinreceiver.New(addr, next)
+		})
+	return f
 }
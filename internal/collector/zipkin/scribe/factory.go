@@ -17,35 +17,32 @@
 package zipkinscribereceiver
 
 import (
-	"context"
-	"fmt"
-
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
 	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver/zipkinscribereceiver"
 )
 
-// Start starts the Zipkin Scribe receiver endpoint.
-func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
-	rOpts, err := builder.NewDefaultZipkinScribeReceiverCfg().InitFromViper(v)
-	if err != nil {
-		return nil, err
-	}
-
-	sr, err := zipkinscribereceiver.NewReceiver(rOpts.Address, rOpts.Port, rOpts.Category, traceConsumer)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create the Zipkin Scribe receiver: %v", err)
-	}
+// TypeStr is the type of the Zipkin Scribe receiver, it is also the key of its
+// configuration under the "receivers" section.
+const TypeStr = "zipkin-scribe"
 
-	if err := sr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
-		return nil, fmt.Errorf("Cannot start Zipkin Scribe receiver %+v: %v", rOpts, err)
-	}
-
-	logger.Info("Zipkin Scribe receiver is running.", zap.Uint16("port", rOpts.Port), zap.String("category", rOpts.Category))
+func init() {
+	receiver.RegisterTraceReceiverFactory(NewTraceReceiverFactory())
+}
 
-	return sr, nil
+// NewTraceReceiverFactory creates a factory for Zipkin Scribe receivers using the same
+// configuration settings of the "receivers" section of the collector.
+func NewTraceReceiverFactory() receiver.TraceReceiverFactory {
+	f, _ := factorytemplate.NewTraceReceiverFactory(
+		TypeStr,
+		func() interface{} { return builder.NewDefaultZipkinScribeReceiverCfg() },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			rOpts := cfg.(*builder.ScribeReceiverCfg)
+			return zipkinscribereceiver.NewReceiver(rOpts.Address, rOpts.Port, rOpts.Category, next)
+		})
+	return f
 }
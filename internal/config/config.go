@@ -19,7 +19,9 @@ import (
 	"net"
 	"net/url"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -27,15 +29,8 @@ import (
 	"google.golang.org/grpc/credentials"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
-	"github.com/census-instrumentation/opencensus-service/exporter/awsexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/datadogexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/honeycombexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/jaegerexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/kafkaexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/opencensusexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/prometheusexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/stackdriverexporter"
-	"github.com/census-instrumentation/opencensus-service/exporter/wavefrontexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
 	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/prometheusreceiver"
@@ -66,13 +61,30 @@ import (
 //          enable_tracing: true
 //      zipkin:
 //          endpoint: "http://localhost:9411/api/v2/spans"
+//      zipkin/dr:
+//          endpoint: "http://dr.example.com:9411/api/v2/spans"
+//
+//  processors:
+//      add-attributes:
+//          values:
+//              cluster: <cluster>
+//      queued-retry:
+//          num-workers: 4
+//
+//  processor-chains:
+//      traces: [add-attributes, queued-retry]
+//      metrics: [add-attributes, queued-retry]
 //
 //  zpages:
 //      port: 55679
+//
+//  shutdown:
+//      timeout: 10s
 
 const (
 	defaultOCReceiverAddress = ":55678"
 	defaultZPagesPort        = 55679
+	defaultShutdownTimeout   = 10 * time.Second
 )
 
 var defaultOCReceiverCorsAllowedOrigins = []string{}
@@ -86,10 +98,30 @@ var defaultScribeConfiguration = &ScribeReceiverConfig{
 // * Receivers
 // * ZPages
 // * Exporters
+// * ProcessorChains
+// * Shutdown
 type Config struct {
-	Receivers *Receivers    `mapstructure:"receivers"`
-	ZPages    *ZPagesConfig `mapstructure:"zpages"`
-	Exporters *Exporters    `mapstructure:"exporters"`
+	Receivers       *Receivers       `mapstructure:"receivers"`
+	ZPages          *ZPagesConfig    `mapstructure:"zpages"`
+	Exporters       *Exporters       `mapstructure:"exporters"`
+	ProcessorChains *ProcessorChains `mapstructure:"processor-chains"`
+	Shutdown        *ShutdownConfig  `mapstructure:"shutdown"`
+}
+
+// ShutdownConfig configures how the agent exits.
+type ShutdownConfig struct {
+	// Timeout is how long the processors and exporters have to send the data they hold,
+	// e.g.: queued and batched spans, once the receivers are stopped.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ProcessorChains lists, in order, the processors the data goes through between the
+// receivers and the exporters. The processors are referenced by their name in the
+// "processors" section, the entries of that section are named like the exporters,
+// e.g.: "add-attributes/region".
+type ProcessorChains struct {
+	Traces  []string `mapstructure:"traces"`
+	Metrics []string `mapstructure:"metrics"`
 }
 
 // Receivers denotes configurations for the various telemetry ingesters, such as:
@@ -125,6 +157,12 @@ type ReceiverConfig struct {
 	CollectorHTTPPort   int    `mapstructure:"collector_http_port"`
 	CollectorThriftPort int    `mapstructure:"collector_thrift_port"`
 
+	// SamplingStrategiesFile and BaggageRestrictionsFile are the files with the sampling
+	// strategies and baggage restrictions served to Jaeger clients, they are only
+	// applicable to the Jaeger receiver.
+	SamplingStrategiesFile  string `mapstructure:"sampling_strategies_file"`
+	BaggageRestrictionsFile string `mapstructure:"baggage_restrictions_file"`
+
 	// The allowed CORS origins for HTTP/JSON requests the grpc-gateway adapter
 	// for the OpenCensus receiver. See github.com/rs/cors
 	// An empty list means that CORS is not enabled at all. A wildcard (*) can be
@@ -138,6 +176,10 @@ type ReceiverConfig struct {
 
 	// TLSCredentials is a (cert_file, key_file) configuration.
 	TLSCredentials *TLSCredentials `mapstructure:"tls_credentials"`
+
+	// TraceConfig is the configuration pushed to the libraries connected to the Config
+	// stream, it is only applicable to the OpenCensus receiver.
+	TraceConfig *TraceConfigs `mapstructure:"trace_config"`
 }
 
 // ScribeReceiverConfig carries the settings for the Zipkin Scribe receiver.
@@ -215,6 +257,33 @@ func (c *Config) openCensusReceiverEnabled() bool {
 		c.Receivers.OpenCensus != nil
 }
 
+// TraceProcessors returns the names of the processors the traces go through, or nil if
+// there is none.
+func (c *Config) TraceProcessors() []string {
+	if c == nil || c.ProcessorChains == nil {
+		return nil
+	}
+	return c.ProcessorChains.Traces
+}
+
+// MetricsProcessors returns the names of the processors the metrics go through, or nil
+// if there is none.
+func (c *Config) MetricsProcessors() []string {
+	if c == nil || c.ProcessorChains == nil {
+		return nil
+	}
+	return c.ProcessorChains.Metrics
+}
+
+// ShutdownTimeout returns how long the processors and exporters have to send the data
+// they hold when the agent exits, 10 seconds if not configured.
+func (c *Config) ShutdownTimeout() time.Duration {
+	if c == nil || c.Shutdown == nil || c.Shutdown.Timeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return c.Shutdown.Timeout
+}
+
 // ZPagesDisabled returns true if zPages have not been enabled.
 // It returns true if Config is nil or if ZPages are explicitly disabled.
 func (c *Config) ZPagesDisabled() bool {
@@ -334,6 +403,15 @@ func (rCfg *ReceiverConfig) HasTLSCredentials() bool {
 	return rCfg != nil && rCfg.TLSCredentials != nil && rCfg.TLSCredentials.nonEmpty()
 }
 
+// Validate checks the settings that can be verified without starting the receiver,
+// i.e.: the TLS credentials.
+func (rCfg *ReceiverConfig) Validate() error {
+	if !rCfg.HasTLSCredentials() {
+		return nil
+	}
+	return rCfg.TLSCredentials.Validate()
+}
+
 // OpenCensusReceiverTLSServerCredentials retrieves the TLS credentials
 // from this Config's OpenCensus receiver if any.
 func (c *Config) OpenCensusReceiverTLSServerCredentials() *TLSCredentials {
@@ -442,66 +520,108 @@ func eqLocalHost(host string) bool {
 	}
 }
 
-// ExportersFromViperConfig uses the viper configuration payload to returns the respective exporters
-// from:
-//  + datadog
-//  + stackdriver
-//  + zipkin
-//  + jaeger
-//  + kafka
-//  + opencensus
-//  + prometheus
-//  + aws-xray
-//  + honeycomb
+// exporterNameSeparator separates the type of an exporter from the name of the instance in
+// the "exporters" section, e.g.: "zipkin/primary" is the "primary" instance of a "zipkin"
+// exporter.
+const exporterNameSeparator = "/"
+
+// ExportersFromViperConfig uses the viper configuration payload to return the exporters
+// configured in the "exporters" section. The available exporters are the ones registered
+// via exporter.RegisterTraceExporterFactory and exporter.RegisterMetricsExporterFactory,
+// typically by the init function of each exporter package linked in the binary.
+//
+// Multiple instances of the same type of exporter can be configured by appending a name
+// to the type, e.g.: "zipkin/primary" and "zipkin/dr". Each instance has its own
+// configuration and close function, and its observability metrics are tagged with the
+// name of the instance.
 func ExportersFromViperConfig(logger *zap.Logger, v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
-	parseFns := []struct {
-		name string
-		fn   func(*viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error)
-	}{
-		{name: "wavefront", fn: wavefrontexporter.WavefrontTraceExportersFromViper},
-		{name: "datadog", fn: datadogexporter.DatadogTraceExportersFromViper},
-		{name: "stackdriver", fn: stackdriverexporter.StackdriverTraceExportersFromViper},
-		{name: "zipkin", fn: zipkinexporter.ZipkinExportersFromViper},
-		{name: "jaeger", fn: jaegerexporter.JaegerExportersFromViper},
-		{name: "kafka", fn: kafkaexporter.KafkaExportersFromViper},
-		{name: "opencensus", fn: opencensusexporter.OpenCensusTraceExportersFromViper},
-		{name: "prometheus", fn: prometheusexporter.PrometheusExportersFromViper},
-		{name: "aws-xray", fn: awsexporter.AWSXRayTraceExportersFromViper},
-		{name: "honeycomb", fn: honeycombexporter.HoneycombTraceExportersFromViper},
+	exportersViper := v.Sub("exporters")
+	if exportersViper == nil {
+		return nil, nil, nil, nil
+	}
+
+	var names []string
+	for name := range exportersViper.AllSettings() {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
 	var traceExporters []consumer.TraceConsumer
 	var metricsExporters []consumer.MetricsConsumer
 	var doneFns []func() error
+	for _, name := range names {
+		tes, mes, dfs, err := ExporterFromViperConfig(logger, v, name)
+		if err != nil {
+			for _, doneFn := range doneFns {
+				doneFn()
+			}
+			return nil, nil, nil, err
+		}
+		traceExporters = append(traceExporters, tes...)
+		metricsExporters = append(metricsExporters, mes...)
+		doneFns = append(doneFns, dfs...)
+	}
+	return traceExporters, metricsExporters, doneFns, nil
+}
+
+// ExporterFromViperConfig returns the exporters of the instance with the given name in the
+// "exporters" section, e.g.: "zipkin/primary". It returns no exporters if the instance is
+// not configured, has an unknown type, or is not enabled by its configuration.
+func ExporterFromViperConfig(logger *zap.Logger, v *viper.Viper, name string) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
 	exportersViper := v.Sub("exporters")
 	if exportersViper == nil {
 		return nil, nil, nil, nil
 	}
-	for _, cfg := range parseFns {
-		tes, mes, tesDoneFns, err := cfg.fn(exportersViper)
-		if err != nil {
-			err = fmt.Errorf("failed to create config for %q: %v", cfg.name, err)
-			return nil, nil, nil, err
-		}
+	ev := exportersViper.Sub(name)
+	if ev == nil {
+		return nil, nil, nil, nil
+	}
 
-		for _, te := range tes {
-			if te != nil {
-				traceExporters = append(traceExporters, te)
-				logger.Info("Trace Exporter enabled", zap.String("exporter", cfg.name))
+	exporterType := strings.SplitN(name, exporterNameSeparator, 2)[0]
+	traceFactory := exporter.GetTraceExporterFactory(exporterType)
+	metricsFactory := exporter.GetMetricsExporterFactory(exporterType)
+	if traceFactory == nil && metricsFactory == nil {
+		logger.Warn("Unknown exporter type, ignoring its configuration", zap.String("exporter", name))
+		return nil, nil, nil, nil
+	}
+
+	var traceExporters []consumer.TraceConsumer
+	var metricsExporters []consumer.MetricsConsumer
+	var doneFns []func() error
+	if traceFactory != nil {
+		te, err := traceFactory.NewFromViper(ev)
+		if err != nil && err != exporterhelper.ErrExporterNotEnabled {
+			return nil, nil, nil, fmt.Errorf("failed to create config for %q: %v", name, err)
+		}
+		if err == nil {
+			if name != exporterType {
+				te = exporterhelper.NewNamedTraceExporter(name, te)
+			}
+			traceExporters = append(traceExporters, te)
+			if stopper, ok := te.(exporterhelper.Stopper); ok {
+				doneFns = append(doneFns, stopper.Stop)
 			}
+			logger.Info("Trace Exporter enabled", zap.String("exporter", name))
 		}
+	}
 
-		for _, me := range mes {
-			if me != nil {
-				metricsExporters = append(metricsExporters, me)
-				logger.Info("Metrics Exporter enabled", zap.String("exporter", cfg.name))
+	if metricsFactory != nil {
+		me, err := metricsFactory.NewFromViper(ev)
+		if err != nil && err != exporterhelper.ErrExporterNotEnabled {
+			for _, doneFn := range doneFns {
+				doneFn()
 			}
+			return nil, nil, nil, fmt.Errorf("failed to create config for %q: %v", name, err)
 		}
-
-		for _, doneFn := range tesDoneFns {
-			if doneFn != nil {
-				doneFns = append(doneFns, doneFn)
+		if err == nil {
+			if name != exporterType {
+				me = exporterhelper.NewNamedMetricsExporter(name, me)
+			}
+			metricsExporters = append(metricsExporters, me)
+			if stopper, ok := me.(exporterhelper.Stopper); ok {
+				doneFns = append(doneFns, stopper.Stop)
 			}
+			logger.Info("Metrics Exporter enabled", zap.String("exporter", name))
 		}
 	}
 	return traceExporters, metricsExporters, doneFns, nil
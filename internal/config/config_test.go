@@ -18,7 +18,9 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
+	"github.com/census-instrumentation/opencensus-service/exporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
 	"github.com/census-instrumentation/opencensus-service/internal/config"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
@@ -108,3 +110,71 @@ receivers:
 		t.Fatal("yaml.CanRunOpenCensusMetricsReceiver: Unexpected True for a nil Receiver.OpenCensus")
 	}
 }
+
+func TestExportersFromViperConfigMultipleInstances(t *testing.T) {
+	exportersYAML := []byte(`
+exporters:
+    zipkin/primary:
+        endpoint: "http://primary:9411/api/v2/spans"
+    zipkin/dr:
+        endpoint: "http://dr:9411/api/v2/spans"
+    unknown:
+        endpoint: "http://unknown:1234"
+`)
+
+	v := viper.New()
+	if err := viperutils.LoadYAMLBytes(v, exportersYAML); err != nil {
+		t.Fatalf("Unexpected YAML parse error: %v", err)
+	}
+	traceExporters, metricsExporters, doneFns, err := config.ExportersFromViperConfig(zap.NewNop(), v)
+	if err != nil {
+		t.Fatalf("Unexpected error creating exporters: %v", err)
+	}
+	if len(traceExporters) != 2 || len(metricsExporters) != 0 {
+		t.Fatalf("Got %d trace and %d metrics exporters, want 2 and 0", len(traceExporters), len(metricsExporters))
+	}
+	for _, te := range traceExporters {
+		if g, w := te.(exporter.TraceExporter).TraceExportFormat(), "zipkin"; g != w {
+			t.Errorf("TraceExportFormat() mismatch\nGot: %s\nWant:%s", g, w)
+		}
+	}
+	if len(doneFns) != 2 {
+		t.Fatalf("Got %d close functions, want one per exporter instance", len(doneFns))
+	}
+	for _, doneFn := range doneFns {
+		if err := doneFn(); err != nil {
+			t.Errorf("Unexpected error closing exporter: %v", err)
+		}
+	}
+}
+
+func TestExporterFromViperConfig(t *testing.T) {
+	exportersYAML := []byte(`
+exporters:
+    zipkin/primary:
+        endpoint: "http://primary:9411/api/v2/spans"
+    zipkin/dr:
+        endpoint: "http://dr:9411/api/v2/spans"
+`)
+
+	v := viper.New()
+	if err := viperutils.LoadYAMLBytes(v, exportersYAML); err != nil {
+		t.Fatalf("Unexpected YAML parse error: %v", err)
+	}
+	traceExporters, metricsExporters, doneFns, err := config.ExporterFromViperConfig(zap.NewNop(), v, "zipkin/dr")
+	if err != nil {
+		t.Fatalf("Unexpected error creating exporter: %v", err)
+	}
+	if len(traceExporters) != 1 || len(metricsExporters) != 0 || len(doneFns) != 1 {
+		t.Fatalf("Got %d trace exporters, %d metrics exporters and %d close functions, want 1, 0 and 1",
+			len(traceExporters), len(metricsExporters), len(doneFns))
+	}
+	if err := doneFns[0](); err != nil {
+		t.Errorf("Unexpected error closing exporter: %v", err)
+	}
+
+	traceExporters, _, _, err = config.ExporterFromViperConfig(zap.NewNop(), v, "zipkin/missing")
+	if err != nil || len(traceExporters) != 0 {
+		t.Errorf("Got %d trace exporters and error %v for a missing instance, want none", len(traceExporters), err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// EffectiveSettings returns the settings of v with the defaults of the configured
+// components filled in. The defaults of each entry of the "receivers", "processors"
+// and "exporters" sections are taken from the DefaultConfig of the registered
+// factories of its type, the settings in v take precedence over them. As in viper
+// all keys are lower case.
+func EffectiveSettings(v *viper.Viper) map[string]interface{} {
+	settings := v.AllSettings()
+	sections := []struct {
+		name     string
+		defaults func(componentType string) map[string]interface{}
+	}{
+		{name: "receivers", defaults: receiverDefaults},
+		{name: "processors", defaults: processorDefaults},
+		{name: "exporters", defaults: exporterDefaults},
+	}
+	for _, section := range sections {
+		// Entries without settings, that enable a component with its defaults, are
+		// left out by AllSettings so the names are taken from GetStringMap.
+		names := v.GetStringMap(section.name)
+		if len(names) == 0 {
+			continue
+		}
+		components, _ := settings[section.name].(map[string]interface{})
+		if components == nil {
+			components = make(map[string]interface{})
+			settings[section.name] = components
+		}
+		for name := range names {
+			cfg, hasCfg := components[name]
+			cfgMap, isMap := cfg.(map[string]interface{})
+			if hasCfg && !isMap {
+				continue
+			}
+			componentType := strings.SplitN(name, exporterNameSeparator, 2)[0]
+			components[name] = mergeSettings(cfgMap, section.defaults(componentType))
+		}
+	}
+	return settings
+}
+
+// receiverDefaults returns the default configuration of the registered receivers of
+// the given type, or nil if there is none.
+func receiverDefaults(receiverType string) map[string]interface{} {
+	if factory := receiver.GetTraceReceiverFactory(receiverType); factory != nil {
+		return structToSettings(factory.DefaultConfig())
+	}
+	if factory := receiver.GetMetricsReceiverFactory(receiverType); factory != nil {
+		return structToSettings(factory.DefaultConfig())
+	}
+	return nil
+}
+
+// processorDefaults returns the default configuration of the registered processors of
+// the given type, or nil if there is none.
+func processorDefaults(processorType string) map[string]interface{} {
+	if factory := processor.GetTraceProcessorFactory(processorType); factory != nil {
+		return factory.DefaultConfig().AllSettings()
+	}
+	if factory := processor.GetMetricsProcessorFactory(processorType); factory != nil {
+		return factory.DefaultConfig().AllSettings()
+	}
+	return nil
+}
+
+// exporterDefaults returns the default configuration of the registered exporters of
+// the given type, or nil if there is none.
+func exporterDefaults(exporterType string) map[string]interface{} {
+	if factory := exporter.GetTraceExporterFactory(exporterType); factory != nil {
+		return factory.DefaultConfig().AllSettings()
+	}
+	if factory := exporter.GetMetricsExporterFactory(exporterType); factory != nil {
+		return factory.DefaultConfig().AllSettings()
+	}
+	return nil
+}
+
+// mergeSettings returns settings with the missing keys taken from defaults, nested
+// maps are merged recursively.
+func mergeSettings(settings, defaults map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(settings)+len(defaults))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range settings {
+		valueMap, ok := value.(map[string]interface{})
+		defaultMap, defaultOk := merged[key].(map[string]interface{})
+		if ok && defaultOk {
+			value = mergeSettings(valueMap, defaultMap)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// structToSettings converts a configuration struct, as returned by the DefaultConfig
+// of the receiver factories, into settings keyed by its mapstructure tags. Zero values
+// are left out since they are equivalent to not setting the key.
+func structToSettings(cfg interface{}) map[string]interface{} {
+	val := reflect.ValueOf(cfg)
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	settings := make(map[string]interface{})
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		tagParts := strings.Split(field.Tag.Get("mapstructure"), ",")
+		key := tagParts[0]
+		if key == "-" {
+			continue
+		}
+		fieldVal := val.Field(i)
+		if len(tagParts) > 1 && tagParts[1] == "squash" {
+			for squashedKey, value := range structToSettings(fieldVal.Interface()) {
+				settings[squashedKey] = value
+			}
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		if value := settingValue(fieldVal); value != nil {
+			settings[strings.ToLower(key)] = value
+		}
+	}
+	return settings
+}
+
+func settingValue(val reflect.Value) interface{} {
+	if reflect.DeepEqual(val.Interface(), reflect.Zero(val.Type()).Interface()) {
+		return nil
+	}
+	if d, ok := val.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	elem := val
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Struct {
+		if nested := structToSettings(elem.Interface()); len(nested) > 0 {
+			return nested
+		}
+		return nil
+	}
+	return val.Interface()
+}
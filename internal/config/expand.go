@@ -0,0 +1,167 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/census-instrumentation/opencensus-service/internal/config/secrets"
+)
+
+const filePrefix = "file:"
+
+// ReadConfigFile reads the YAML configuration file into v, expanding the references
+// found in its string values: "${NAME}" is replaced by the value of the environment
+// variable NAME, "${file:PATH}" by the contents of the file at PATH without its
+// trailing newline (e.g. a secret mounted in the container), and "$${" by a literal "${".
+//
+// Referencing an unset environment variable or an unreadable file is an error. All the
+// resolved values are registered with the secrets package so they are redacted when the
+// configuration is logged.
+func ReadConfigFile(v *viper.Viper, file string) error {
+	v.SetConfigFile(file)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("cannot read the configuration file %q: %v", file, err)
+	}
+
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("cannot parse the configuration file %q: %v", file, err)
+	}
+	expanded, changed, err := expandValue("", raw)
+	if err != nil {
+		return fmt.Errorf("cannot expand the configuration file %q: %v", file, err)
+	}
+	if changed {
+		if data, err = yaml.Marshal(expanded); err != nil {
+			return err
+		}
+	}
+
+	// ReadConfig replaces the settings previously read, as ReadInConfig does, so
+	// reloading the same file does not keep stale values.
+	v.SetConfigType("yaml")
+	return v.ReadConfig(bytes.NewReader(data))
+}
+
+// expandValue returns value with the references in its strings expanded and whether
+// anything was expanded. path is the dotted key of value, used in error messages.
+func expandValue(path string, value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		expanded, err := expandString(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("%q: %v", path, err)
+		}
+		return expanded, expanded != v, nil
+	case []interface{}:
+		anyChanged := false
+		for i, elem := range v {
+			expanded, changed, err := expandValue(fmt.Sprintf("%s[%d]", path, i), elem)
+			if err != nil {
+				return nil, false, err
+			}
+			v[i] = expanded
+			anyChanged = anyChanged || changed
+		}
+		return v, anyChanged, nil
+	case map[interface{}]interface{}:
+		anyChanged := false
+		for key, elem := range v {
+			keyPath := fmt.Sprint(key)
+			if path != "" {
+				keyPath = path + "." + keyPath
+			}
+			expanded, changed, err := expandValue(keyPath, elem)
+			if err != nil {
+				return nil, false, err
+			}
+			v[key] = expanded
+			anyChanged = anyChanged || changed
+		}
+		return v, anyChanged, nil
+	default:
+		return value, false, nil
+	}
+}
+
+// expandString expands the ${NAME} and ${file:PATH} references in s.
+func expandString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var buf strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			buf.WriteString(s)
+			return buf.String(), nil
+		}
+		if start > 0 && s[start-1] == '$' {
+			// Escaped reference: "$${" is a literal "${".
+			buf.WriteString(s[:start-1])
+			buf.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference \"${%s\"", s[start+2:])
+		}
+		end += start
+
+		resolved, err := resolveReference(s[start+2 : end])
+		if err != nil {
+			return "", err
+		}
+		secrets.Add(resolved)
+		buf.WriteString(s[:start])
+		buf.WriteString(resolved)
+		s = s[end+1:]
+	}
+}
+
+// resolveReference returns the value of the reference ref, the text between "${" and "}".
+func resolveReference(ref string) (string, error) {
+	if strings.HasPrefix(ref, filePrefix) {
+		path := strings.TrimPrefix(ref, filePrefix)
+		if path == "" {
+			return "", fmt.Errorf("empty file name in reference \"${%s}\"", ref)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read the file referenced by \"${%s}\": %v", ref, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if ref == "" {
+		return "", fmt.Errorf("empty reference \"${}\"")
+	}
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", ref)
+	}
+	return value, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/secrets"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expand")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	os.Setenv("EXPAND_TEST_WRITE_KEY", "env-secret")
+	defer os.Unsetenv("EXPAND_TEST_WRITE_KEY")
+	os.Setenv("EXPAND_TEST_PORT", "55678")
+	defer os.Unsetenv("EXPAND_TEST_PORT")
+	os.Setenv("EXPAND_TEST_TEAM", "env-team")
+	defer os.Unsetenv("EXPAND_TEST_TEAM")
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "no_references",
+			yaml: "exporters:\n  honeycomb:\n    write_key: inline\n",
+			want: map[string]interface{}{"exporters.honeycomb.write_key": "inline"},
+		},
+		{
+			name: "environment_variable",
+			yaml: "exporters:\n  honeycomb:\n    write_key: ${EXPAND_TEST_WRITE_KEY}\n",
+			want: map[string]interface{}{"exporters.honeycomb.write_key": "env-secret"},
+		},
+		{
+			name: "file",
+			yaml: "exporters:\n  jaeger:\n    password: ${file:" + secretFile + "}\n",
+			want: map[string]interface{}{"exporters.jaeger.password": "file-secret"},
+		},
+		{
+			name: "inside_string_and_list",
+			yaml: "receivers:\n  opencensus:\n    address: \"127.0.0.1:${EXPAND_TEST_PORT}\"\n    cors_allowed_origins:\n    - http://${EXPAND_TEST_PORT}\n",
+			want: map[string]interface{}{
+				"receivers.opencensus.address":              "127.0.0.1:55678",
+				"receivers.opencensus.cors_allowed_origins": []interface{}{"http://55678"},
+			},
+		},
+		{
+			name: "headers",
+			yaml: "exporters:\n  opencensus:\n    headers:\n      api-key: ${EXPAND_TEST_WRITE_KEY}\n",
+			want: map[string]interface{}{"exporters.opencensus.headers": map[string]interface{}{"api-key": "env-secret"}},
+		},
+		{
+			name: "header_not_naming_a_credential",
+			yaml: "exporters:\n  opencensus:\n    headers:\n      x-honeycomb-team: ${EXPAND_TEST_TEAM}\n",
+			want: map[string]interface{}{"exporters.opencensus.headers": map[string]interface{}{"x-honeycomb-team": "env-team"}},
+		},
+		{
+			name: "escaped",
+			yaml: "exporters:\n  honeycomb:\n    write_key: $${EXPAND_TEST_WRITE_KEY}\n",
+			want: map[string]interface{}{"exporters.honeycomb.write_key": "${EXPAND_TEST_WRITE_KEY}"},
+		},
+		{
+			name:    "unset_variable",
+			yaml:    "exporters:\n  honeycomb:\n    write_key: ${EXPAND_TEST_UNSET}\n",
+			wantErr: `"exporters.honeycomb.write_key": environment variable "EXPAND_TEST_UNSET" is not set`,
+		},
+		{
+			name:    "missing_file",
+			yaml:    "exporters:\n  jaeger:\n    password: ${file:" + filepath.Join(dir, "missing") + "}\n",
+			wantErr: "cannot read the file referenced by",
+		},
+		{
+			name:    "unterminated",
+			yaml:    "exporters:\n  jaeger:\n    password: ${EXPAND_TEST_WRITE_KEY\n",
+			wantErr: "unterminated reference",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name+".yaml")
+			if err := ioutil.WriteFile(file, []byte(tt.yaml), 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			v := viper.New()
+			err := config.ReadConfigFile(v, file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReadConfigFile() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadConfigFile() error = %v", err)
+			}
+			if got := v.ConfigFileUsed(); got != file {
+				t.Errorf("ConfigFileUsed() = %q, want %q", got, file)
+			}
+			for key, want := range tt.want {
+				if got := v.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("Get(%q) = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+
+	// All the resolved values are redacted, whatever their key, e.g.: a header or the port.
+	got := secrets.Redact("write_key=env-secret password=file-secret x-honeycomb-team=env-team address=127.0.0.1:55678")
+	if want := "write_key=[REDACTED] password=[REDACTED] x-honeycomb-team=[REDACTED] address=127.0.0.1:[REDACTED]"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestReadConfigFile_Reload(t *testing.T) {
+	f, err := ioutil.TempFile("", "expand")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	os.Setenv("EXPAND_TEST_RELOAD", "first")
+	defer os.Unsetenv("EXPAND_TEST_RELOAD")
+	if err := ioutil.WriteFile(f.Name(), []byte("a: ${EXPAND_TEST_RELOAD}\nb: removed\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	v := viper.New()
+	if err := config.ReadConfigFile(v, f.Name()); err != nil {
+		t.Fatalf("ReadConfigFile() error = %v", err)
+	}
+
+	os.Setenv("EXPAND_TEST_RELOAD", "second")
+	if err := ioutil.WriteFile(f.Name(), []byte("a: ${EXPAND_TEST_RELOAD}\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := config.ReadConfigFile(v, v.ConfigFileUsed()); err != nil {
+		t.Fatalf("ReadConfigFile() error = %v", err)
+	}
+	if got := v.GetString("a"); got != "second" {
+		t.Errorf("GetString(\"a\") = %q, want %q", got, "second")
+	}
+	if v.IsSet("b") {
+		t.Errorf("IsSet(\"b\") = true, want the settings of the previous read to be replaced")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TraceProcessorsFromViperConfig creates the processors with the given names, configured
+// in the "processors" section, chained so the data goes through them in order before
+// reaching next. It returns the head of the chain, which is next if there are no
+// processors, and the functions that shut down the processors that hold data or goroutines,
+// e.g.: the "queued-retry" and "batch" processors. The available processors are
+// the ones registered via processor.RegisterTraceProcessorFactory, a processor without
+// configuration uses the defaults of its factory.
+func TraceProcessorsFromViperConfig(v *viper.Viper, names []string, next consumer.TraceConsumer) (consumer.TraceConsumer, []func(context.Context) error, error) {
+	var shutdownFns []func(context.Context) error
+	// Processors are chained starting from the last one so the data goes through them
+	// in the order they are listed.
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		factory := processor.GetTraceProcessorFactory(processorType(name))
+		if factory == nil {
+			shutdownProcessors(shutdownFns)
+			return nil, nil, fmt.Errorf("unknown trace processor type for %q", name)
+		}
+		pv := processorViper(v, name)
+		if pv == nil {
+			pv = factory.DefaultConfig()
+		}
+		tp, err := factory.NewFromViper(pv, next)
+		if err != nil {
+			shutdownProcessors(shutdownFns)
+			return nil, nil, fmt.Errorf("failed to create trace processor %q: %v", name, err)
+		}
+		if shutdownFn := processor.ShutdownFunc(tp); shutdownFn != nil {
+			// The processors closer to the receivers are shut down first, so the data
+			// they flush reaches the ones after them.
+			shutdownFns = append([]func(context.Context) error{shutdownFn}, shutdownFns...)
+		}
+		next = tp
+	}
+	return next, shutdownFns, nil
+}
+
+// MetricsProcessorsFromViperConfig is the equivalent of TraceProcessorsFromViperConfig
+// for metrics, using the factories registered via processor.RegisterMetricsProcessorFactory.
+func MetricsProcessorsFromViperConfig(v *viper.Viper, names []string, next consumer.MetricsConsumer) (consumer.MetricsConsumer, []func(context.Context) error, error) {
+	var shutdownFns []func(context.Context) error
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		factory := processor.GetMetricsProcessorFactory(processorType(name))
+		if factory == nil {
+			shutdownProcessors(shutdownFns)
+			return nil, nil, fmt.Errorf("unknown metrics processor type for %q", name)
+		}
+		pv := processorViper(v, name)
+		if pv == nil {
+			pv = factory.DefaultConfig()
+		}
+		mp, err := factory.NewFromViper(pv, next)
+		if err != nil {
+			shutdownProcessors(shutdownFns)
+			return nil, nil, fmt.Errorf("failed to create metrics processor %q: %v", name, err)
+		}
+		if shutdownFn := processor.ShutdownFunc(mp); shutdownFn != nil {
+			shutdownFns = append([]func(context.Context) error{shutdownFn}, shutdownFns...)
+		}
+		next = mp
+	}
+	return next, shutdownFns, nil
+}
+
+// processorType returns the type of the named processor, e.g.: "add-attributes" for
+// "add-attributes/region".
+func processorType(name string) string {
+	return strings.SplitN(name, exporterNameSeparator, 2)[0]
+}
+
+// processorViper returns the configuration of the named processor, or nil if it is not
+// present.
+func processorViper(v *viper.Viper, name string) *viper.Viper {
+	pv := v.Sub("processors")
+	if pv == nil {
+		return nil
+	}
+	return pv.Sub(name)
+}
+
+// shutdownProcessors shuts down the processors of a chain that is not going to be used.
+func shutdownProcessors(shutdownFns []func(context.Context) error) {
+	for _, shutdownFn := range shutdownFns {
+		shutdownFn(context.Background())
+	}
+}
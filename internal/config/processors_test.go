@@ -0,0 +1,167 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	_ "github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	_ "github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+	_ "github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
+)
+
+const processorsConfig = `
+processors:
+  add-attributes:
+    values:
+      cluster: east
+  attribute-key/rename:
+    key-mapping:
+      - key: cluster
+        replacement: region
+  queued-retry:
+    num-workers: 1
+
+processor-chains:
+  traces: [add-attributes, attribute-key/rename]
+  metrics: [add-attributes, queued-retry]
+`
+
+func TestTraceProcessorsFromViperConfig(t *testing.T) {
+	v, err := viperutils.ViperFromYAMLBytes([]byte(processorsConfig))
+	if err != nil {
+		t.Fatalf("ViperFromYAMLBytes: %v", err)
+	}
+	var cfg config.Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	sink := &exportertest.SinkTraceExporter{}
+	head, shutdownFns, err := config.TraceProcessorsFromViperConfig(v, cfg.TraceProcessors(), sink)
+	if err != nil {
+		t.Fatalf("TraceProcessorsFromViperConfig() = %v", err)
+	}
+	if len(shutdownFns) != 0 {
+		t.Errorf("got %d shutdown functions, want 0", len(shutdownFns))
+	}
+
+	td := data.TraceData{Spans: []*tracepb.Span{{}}}
+	if err := head.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+	// The attribute is added before being renamed.
+	attributes := sink.AllTraces()[0].Spans[0].Attributes.AttributeMap
+	if _, ok := attributes["cluster"]; ok || attributes["region"].GetStringValue().GetValue() != "east" {
+		t.Errorf("got attributes %v, want only \"region\"", attributes)
+	}
+}
+
+func TestMetricsProcessorsFromViperConfig(t *testing.T) {
+	v, err := viperutils.ViperFromYAMLBytes([]byte(processorsConfig))
+	if err != nil {
+		t.Fatalf("ViperFromYAMLBytes: %v", err)
+	}
+	var cfg config.Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	sink := &exportertest.SinkMetricsExporter{}
+	head, shutdownFns, err := config.MetricsProcessorsFromViperConfig(v, cfg.MetricsProcessors(), sink)
+	if err != nil {
+		t.Fatalf("MetricsProcessorsFromViperConfig() = %v", err)
+	}
+	if len(shutdownFns) != 1 {
+		t.Fatalf("got %d shutdown functions, want 1 for \"queued-retry\"", len(shutdownFns))
+	}
+
+	md := data.MetricsData{Metrics: []*metricspb.Metric{{MetricDescriptor: &metricspb.MetricDescriptor{}}}}
+	if err := head.ConsumeMetricsData(context.Background(), md); err != nil {
+		t.Fatalf("ConsumeMetricsData() = %v", err)
+	}
+	defer shutdownFns[0](context.Background())
+
+	// The batches are sent from the workers of the queue.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.AllMetrics()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := sink.AllMetrics()
+	if len(got) != 1 {
+		t.Fatalf("got %d batches, want 1", len(got))
+	}
+	if labelKeys := got[0].Metrics[0].MetricDescriptor.LabelKeys; len(labelKeys) != 1 || labelKeys[0].Key != "cluster" {
+		t.Errorf("got label keys %v, want \"cluster\"", labelKeys)
+	}
+}
+
+func TestValidateProcessorChains(t *testing.T) {
+	v, err := viperutils.ViperFromYAMLBytes([]byte(processorsConfig))
+	if err != nil {
+		t.Fatalf("ViperFromYAMLBytes: %v", err)
+	}
+
+	if err := config.ValidateProcessorChains(v, []string{"add-attributes", "queued-retry"}, []string{"attribute-key/rename"}); err != nil {
+		t.Errorf("ValidateProcessorChains() = %v", err)
+	}
+
+	err = config.ValidateProcessorChains(v, []string{"unknown"}, []string{"queued-retry/missing"})
+	if err == nil || !strings.Contains(err.Error(), `unknown trace processor type for "unknown"`) {
+		t.Errorf("ValidateProcessorChains() = %v, want unknown processor type error", err)
+	}
+}
+
+func TestValidateProcessorChainsDoesNotOpenPersistentQueues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate-queue")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	queueDir := filepath.Join(dir, "queue")
+	v, err := viperutils.ViperFromYAMLBytes([]byte(fmt.Sprintf(`
+processors:
+  queued-retry/disk:
+    persistence:
+      directory: %q
+`, queueDir)))
+	if err != nil {
+		t.Fatalf("ViperFromYAMLBytes: %v", err)
+	}
+
+	if err := config.ValidateProcessorChains(v, []string{"queued-retry/disk"}, nil); err != nil {
+		t.Errorf("ValidateProcessorChains() = %v", err)
+	}
+	if _, err := os.Stat(queueDir); !os.IsNotExist(err) {
+		t.Errorf("ValidateProcessorChains() created the queue directory: %v", err)
+	}
+	if err := config.ValidateProcessorChains(v, nil, []string{"queued-retry/disk"}); err == nil {
+		t.Error("ValidateProcessorChains() = nil, want error for persistence of metrics")
+	}
+}
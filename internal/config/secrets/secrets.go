@@ -0,0 +1,145 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets keeps track of the values resolved from the environment variables
+// and files referenced by the configuration, so they can be redacted when the
+// configuration is logged.
+package secrets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secret values in the output of Redact and RedactValue.
+const Redacted = "[REDACTED]"
+
+// minSubstringLength is the length under which a value is redacted only where it is not
+// part of a longer word, e.g.: a port "80" is redacted in "host:80" but not in "8080", so
+// that short values do not garble the output.
+const minSubstringLength = 6
+
+var (
+	mu     sync.RWMutex
+	values []string
+)
+
+// Add marks value as a secret.
+func Add(value string) {
+	if value == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		if v == value {
+			return
+		}
+	}
+	values = append(values, value)
+	// Longer values first so a secret containing another one is fully redacted.
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+}
+
+// Redact returns s with all secret values replaced by Redacted.
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range values {
+		if len(v) >= minSubstringLength {
+			s = strings.Replace(s, v, Redacted, -1)
+		} else {
+			s = redactWords(s, v)
+		}
+	}
+	return s
+}
+
+// redactWords returns s with the occurrences of v that are not part of a longer word
+// replaced by Redacted.
+func redactWords(s, v string) string {
+	var buf strings.Builder
+	for {
+		i := strings.Index(s, v)
+		if i < 0 {
+			buf.WriteString(s)
+			return buf.String()
+		}
+		end := i + len(v)
+		if (i > 0 && isWordByte(s[i-1])) || (end < len(s) && isWordByte(s[end])) {
+			buf.WriteString(s[:i+1])
+			s = s[i+1:]
+			continue
+		}
+		buf.WriteString(s[:i])
+		buf.WriteString(Redacted)
+		s = s[end:]
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// RedactValue returns a copy of value with the secret values in its strings replaced by
+// Redacted. Maps and slices, as produced by viper and YAML decoding, are copied
+// recursively; other types are returned as-is.
+func RedactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i, s := range v {
+			redacted[i] = Redact(s)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, elem := range v {
+			redacted[i] = RedactValue(elem)
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			redacted[key] = RedactValue(elem)
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for key, elem := range v {
+			redacted[key] = Redact(elem)
+		}
+		return redacted
+	case map[interface{}]interface{}:
+		redacted := make(map[interface{}]interface{}, len(v))
+		for key, elem := range v {
+			redacted[key] = RedactValue(elem)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// reset forgets all secrets, it is used by tests.
+func reset() {
+	mu.Lock()
+	values = nil
+	mu.Unlock()
+}
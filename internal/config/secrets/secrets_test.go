@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	defer reset()
+	Add("")
+	Add("info")
+	Add("80")
+	Add("s3cr3t")
+	Add("s3cr3t-with-suffix")
+	Add("s3cr3t")
+
+	if got, want := Redact("a s3cr3t-with-suffix and a s3cr3t at info level"), "a [REDACTED] and a [REDACTED] at [REDACTED] level"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+	// Short values are not redacted inside longer words.
+	if got, want := Redact("host:80 and host:8080 with information"), "host:[REDACTED] and host:8080 with information"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+	if got, want := Redact("nothing secret"), "nothing secret"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+
+	value := map[string]interface{}{
+		"key":     "s3cr3t",
+		"port":    55678,
+		"headers": map[string]string{"api-key": "s3cr3t"},
+		"list":    []interface{}{"a s3cr3t", map[interface{}]interface{}{"k": "s3cr3t"}},
+	}
+	want := map[string]interface{}{
+		"key":     Redacted,
+		"port":    55678,
+		"headers": map[string]string{"api-key": Redacted},
+		"list":    []interface{}{"a " + Redacted, map[interface{}]interface{}{"k": Redacted}},
+	}
+	if got := RedactValue(value); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValue() = %v, want %v", got, want)
+	}
+	if value["key"] != "s3cr3t" {
+		t.Errorf("RedactValue() modified its argument")
+	}
+}
@@ -14,6 +14,12 @@
 
 package config
 
+import (
+	"fmt"
+
+	"google.golang.org/grpc/credentials"
+)
+
 // TLSCredentials holds the fields for TLS credentials
 // that are used for starting a server.
 type TLSCredentials struct {
@@ -29,3 +35,14 @@ type TLSCredentials struct {
 func (tc *TLSCredentials) nonEmpty() bool {
 	return tc != nil && (tc.CertFile != "" || tc.KeyFile != "")
 }
+
+// Validate checks that the certificate and key files, if any, can be loaded.
+func (tc *TLSCredentials) Validate() error {
+	if !tc.nonEmpty() {
+		return nil
+	}
+	if _, err := credentials.NewServerTLSFromFile(tc.CertFile, tc.KeyFile); err != nil {
+		return fmt.Errorf("invalid TLS credentials: %v", err)
+	}
+	return nil
+}
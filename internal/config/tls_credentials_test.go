@@ -126,3 +126,21 @@ receivers:
 		}
 	}
 }
+
+func TestTLSCredentialsValidate(t *testing.T) {
+	var nilCreds *TLSCredentials
+	if err := nilCreds.Validate(); err != nil {
+		t.Errorf("Validate() on nil credentials got error: %v", err)
+	}
+	if err := (&TLSCredentials{}).Validate(); err != nil {
+		t.Errorf("Validate() on empty credentials got error: %v", err)
+	}
+	missing := &TLSCredentials{CertFile: "testdata/missing.crt", KeyFile: "testdata/missing.key"}
+	if err := missing.Validate(); err == nil {
+		t.Error("Validate() on missing files got nil error")
+	}
+	rCfg := &ReceiverConfig{TLSCredentials: missing}
+	if err := rCfg.Validate(); err == nil {
+		t.Error("ReceiverConfig.Validate() on missing files got nil error")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"path"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver/octrace"
+)
+
+// The samplers of a TraceConfig.
+const (
+	AlwaysOnSampler     = "always_on"
+	AlwaysOffSampler    = "always_off"
+	AlwaysParentSampler = "always_parent"
+	ProbabilitySampler  = "probability"
+	RateLimitingSampler = "rate_limiting"
+)
+
+// TraceConfigs holds the TraceConfig pushed by the OpenCensus receiver to the
+// libraries connected to it. The first service rule whose name pattern matches the
+// service name of a library is used, or the default one if none matches.
+type TraceConfigs struct {
+	Default  *TraceConfig          `mapstructure:"default"`
+	Services []*ServiceTraceConfig `mapstructure:"services"`
+}
+
+// TraceConfig is the sampler and the limits of the spans of a library, the limits
+// not set are sent as zero.
+type TraceConfig struct {
+	// Sampler is one of always_on, always_off, always_parent, probability or rate_limiting.
+	Sampler string `mapstructure:"sampler"`
+	// SamplingProbability is the probability of the probability sampler, from 0 to 1.
+	SamplingProbability float64 `mapstructure:"sampling_probability"`
+	// QPS is the number of traces per second of the rate_limiting sampler.
+	QPS int64 `mapstructure:"qps"`
+
+	MaxNumberOfAttributes    int64 `mapstructure:"max_number_of_attributes"`
+	MaxNumberOfAnnotations   int64 `mapstructure:"max_number_of_annotations"`
+	MaxNumberOfMessageEvents int64 `mapstructure:"max_number_of_message_events"`
+	MaxNumberOfLinks         int64 `mapstructure:"max_number_of_links"`
+}
+
+// ServiceTraceConfig is the TraceConfig of the services whose name matches ServiceName,
+// a pattern with the syntax of path.Match, e.g.: "frontend-*".
+type ServiceTraceConfig struct {
+	ServiceName string `mapstructure:"service_name"`
+	TraceConfig `mapstructure:",squash"`
+}
+
+// OpenCensusReceiverTraceConfig returns the TraceConfig that the OpenCensus receiver
+// pushes to the libraries, the default one is nil if not set. It returns an error if
+// any TraceConfig is invalid.
+func (c *Config) OpenCensusReceiverTraceConfig() (*tracepb.TraceConfig, []octrace.TraceConfigRule, error) {
+	if !c.openCensusReceiverEnabled() || c.Receivers.OpenCensus.TraceConfig == nil {
+		return nil, nil, nil
+	}
+	tcs := c.Receivers.OpenCensus.TraceConfig
+
+	var defaultConfig *tracepb.TraceConfig
+	if tcs.Default != nil {
+		var err error
+		if defaultConfig, err = tcs.Default.toProto(); err != nil {
+			return nil, nil, fmt.Errorf("invalid default trace config: %v", err)
+		}
+	}
+
+	rules := make([]octrace.TraceConfigRule, 0, len(tcs.Services))
+	for i, stc := range tcs.Services {
+		if stc.ServiceName == "" {
+			return nil, nil, fmt.Errorf("trace config #%d has no service_name", i)
+		}
+		if _, err := path.Match(stc.ServiceName, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid service_name pattern %q: %v", stc.ServiceName, err)
+		}
+		cfg, err := stc.toProto()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid trace config for service %q: %v", stc.ServiceName, err)
+		}
+		rules = append(rules, octrace.TraceConfigRule{ServiceName: stc.ServiceName, Config: cfg})
+	}
+	return defaultConfig, rules, nil
+}
+
+func (tc *TraceConfig) toProto() (*tracepb.TraceConfig, error) {
+	cfg := &tracepb.TraceConfig{
+		MaxNumberOfAttributes:    tc.MaxNumberOfAttributes,
+		MaxNumberOfAnnotations:   tc.MaxNumberOfAnnotations,
+		MaxNumberOfMessageEvents: tc.MaxNumberOfMessageEvents,
+		MaxNumberOfLinks:         tc.MaxNumberOfLinks,
+	}
+	switch tc.Sampler {
+	case AlwaysOnSampler:
+		cfg.Sampler = constantSampler(tracepb.ConstantSampler_ALWAYS_ON)
+	case AlwaysOffSampler:
+		cfg.Sampler = constantSampler(tracepb.ConstantSampler_ALWAYS_OFF)
+	case AlwaysParentSampler:
+		cfg.Sampler = constantSampler(tracepb.ConstantSampler_ALWAYS_PARENT)
+	case ProbabilitySampler:
+		if tc.SamplingProbability < 0 || tc.SamplingProbability > 1 {
+			return nil, fmt.Errorf("sampling_probability must be between 0 and 1, got %v", tc.SamplingProbability)
+		}
+		cfg.Sampler = &tracepb.TraceConfig_ProbabilitySampler{
+			ProbabilitySampler: &tracepb.ProbabilitySampler{SamplingProbability: tc.SamplingProbability},
+		}
+	case RateLimitingSampler:
+		if tc.QPS <= 0 {
+			return nil, fmt.Errorf("qps must be positive, got %d", tc.QPS)
+		}
+		cfg.Sampler = &tracepb.TraceConfig_RateLimitingSampler{
+			RateLimitingSampler: &tracepb.RateLimitingSampler{Qps: tc.QPS},
+		}
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", tc.Sampler)
+	}
+	if tc.MaxNumberOfAttributes < 0 || tc.MaxNumberOfAnnotations < 0 ||
+		tc.MaxNumberOfMessageEvents < 0 || tc.MaxNumberOfLinks < 0 {
+		return nil, fmt.Errorf("limits must not be negative")
+	}
+	return cfg, nil
+}
+
+func constantSampler(decision tracepb.ConstantSampler_ConstantDecision) *tracepb.TraceConfig_ConstantSampler {
+	return &tracepb.TraceConfig_ConstantSampler{
+		ConstantSampler: &tracepb.ConstantSampler{Decision: decision},
+	}
+}
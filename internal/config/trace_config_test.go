@@ -0,0 +1,153 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver/octrace"
+)
+
+func TestOpenCensusReceiverTraceConfig(t *testing.T) {
+	cfg := configFromYAML(t, `
+receivers:
+  opencensus:
+    trace_config:
+      default:
+        sampler: probability
+        sampling_probability: 0.1
+        max_number_of_attributes: 32
+      services:
+        - service_name: "frontend-*"
+          sampler: always_on
+        - service_name: "batch"
+          sampler: rate_limiting
+          qps: 5
+`)
+
+	defaultConfig, rules, err := cfg.OpenCensusReceiverTraceConfig()
+	if err != nil {
+		t.Fatalf("OpenCensusReceiverTraceConfig() = %v", err)
+	}
+	wantDefault := &tracepb.TraceConfig{
+		Sampler: &tracepb.TraceConfig_ProbabilitySampler{
+			ProbabilitySampler: &tracepb.ProbabilitySampler{SamplingProbability: 0.1},
+		},
+		MaxNumberOfAttributes: 32,
+	}
+	if !reflect.DeepEqual(defaultConfig, wantDefault) {
+		t.Errorf("default config = %v, want %v", defaultConfig, wantDefault)
+	}
+	wantRules := []octrace.TraceConfigRule{
+		{
+			ServiceName: "frontend-*",
+			Config: &tracepb.TraceConfig{
+				Sampler: &tracepb.TraceConfig_ConstantSampler{
+					ConstantSampler: &tracepb.ConstantSampler{Decision: tracepb.ConstantSampler_ALWAYS_ON},
+				},
+			},
+		},
+		{
+			ServiceName: "batch",
+			Config: &tracepb.TraceConfig{
+				Sampler: &tracepb.TraceConfig_RateLimitingSampler{
+					RateLimitingSampler: &tracepb.RateLimitingSampler{Qps: 5},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Errorf("rules = %v, want %v", rules, wantRules)
+	}
+}
+
+func TestOpenCensusReceiverTraceConfig_notSet(t *testing.T) {
+	cfg := configFromYAML(t, `
+receivers:
+  opencensus:
+    address: "127.0.0.1:55678"
+`)
+	defaultConfig, rules, err := cfg.OpenCensusReceiverTraceConfig()
+	if defaultConfig != nil || len(rules) != 0 || err != nil {
+		t.Errorf("OpenCensusReceiverTraceConfig() = %v, %v, %v, want nothing", defaultConfig, rules, err)
+	}
+}
+
+func TestOpenCensusReceiverTraceConfig_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "unknown_sampler",
+			yaml:    "default: {sampler: sometimes}",
+			wantErr: `unknown sampler "sometimes"`,
+		},
+		{
+			name:    "probability_out_of_range",
+			yaml:    "default: {sampler: probability, sampling_probability: 2}",
+			wantErr: "sampling_probability must be between 0 and 1",
+		},
+		{
+			name:    "no_qps",
+			yaml:    "services: [{service_name: foo, sampler: rate_limiting}]",
+			wantErr: "qps must be positive",
+		},
+		{
+			name:    "no_service_name",
+			yaml:    "services: [{sampler: always_on}]",
+			wantErr: "has no service_name",
+		},
+		{
+			name:    "bad_pattern",
+			yaml:    "services: [{service_name: \"[\", sampler: always_on}]",
+			wantErr: "invalid service_name pattern",
+		},
+		{
+			name:    "negative_limit",
+			yaml:    "default: {sampler: always_on, max_number_of_links: -1}",
+			wantErr: "limits must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := configFromYAML(t, "receivers:\n  opencensus:\n    trace_config:\n      "+tt.yaml+"\n")
+			_, _, err := cfg.OpenCensusReceiverTraceConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("OpenCensusReceiverTraceConfig() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func configFromYAML(t *testing.T, yaml string) *config.Config {
+	v := viper.New()
+	if err := viperutils.LoadYAMLBytes(v, []byte(yaml)); err != nil {
+		t.Fatalf("Unexpected YAML parse error: %v", err)
+	}
+	var cfg config.Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		t.Fatalf("Unexpected error unmarshaling viper: %v", err)
+	}
+	return &cfg
+}
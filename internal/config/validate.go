@@ -0,0 +1,150 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// ValidateReceiverConfig checks the configuration of the receivers of the given type,
+// using the registered factories, without creating the receivers. The configuration
+// is checked by the factories implementing receiver.ConfigValidator, the others are
+// only required to be registered.
+func ValidateReceiverConfig(receiverType string, rv *viper.Viper) error {
+	traceFactory := receiver.GetTraceReceiverFactory(receiverType)
+	metricsFactory := receiver.GetMetricsReceiverFactory(receiverType)
+	if traceFactory == nil && metricsFactory == nil {
+		return fmt.Errorf("unknown receiver type for %q", receiverType)
+	}
+	if rv == nil {
+		rv = viper.New()
+	}
+
+	validator, ok := traceFactory.(receiver.ConfigValidator)
+	if !ok {
+		validator, ok = metricsFactory.(receiver.ConfigValidator)
+	}
+	if !ok {
+		return nil
+	}
+	if err := validator.ValidateConfig(rv); err != nil {
+		return fmt.Errorf("invalid config for receiver %q: %v", receiverType, err)
+	}
+	return nil
+}
+
+// ValidateExportersConfig checks the configuration of all exporters in the "exporters"
+// section, using the registered factories, without creating the exporters. Unlike
+// ExportersFromViperConfig, exporters of unknown types are reported as errors.
+func ValidateExportersConfig(v *viper.Viper) error {
+	exportersViper := v.Sub("exporters")
+	if exportersViper == nil {
+		return nil
+	}
+
+	var names []string
+	for name := range exportersViper.AllSettings() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := ValidateExporterConfig(name, exportersViper.Sub(name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// ValidateExporterConfig checks the configuration of the exporter instance with the
+// given name, e.g.: "zipkin/primary", using the factories of its type. The configuration
+// is checked by the factories implementing exporter.ConfigValidator, the others are
+// only required to be registered.
+func ValidateExporterConfig(name string, ev *viper.Viper) error {
+	exporterType := strings.SplitN(name, exporterNameSeparator, 2)[0]
+	traceFactory := exporter.GetTraceExporterFactory(exporterType)
+	metricsFactory := exporter.GetMetricsExporterFactory(exporterType)
+	if traceFactory == nil && metricsFactory == nil {
+		return fmt.Errorf("unknown exporter type for %q", name)
+	}
+	if ev == nil {
+		ev = viper.New()
+	}
+
+	validator, ok := traceFactory.(exporter.ConfigValidator)
+	if !ok {
+		validator, ok = metricsFactory.(exporter.ConfigValidator)
+	}
+	if !ok {
+		return nil
+	}
+	if err := validator.ValidateConfig(ev); err != nil {
+		return fmt.Errorf("invalid config for exporter %q: %v", name, err)
+	}
+	return nil
+}
+
+// ValidateProcessorChains checks the processors of the "processor-chains" section of the
+// configuration without creating them, since some processors start goroutines or open
+// their on-disk queues on creation. The configuration is checked by the factories
+// implementing processor.ConfigValidator, the others are only required to be registered.
+func ValidateProcessorChains(v *viper.Viper, traceProcessors, metricsProcessors []string) error {
+	var errs []error
+	for _, name := range traceProcessors {
+		factory := processor.GetTraceProcessorFactory(processorType(name))
+		if factory == nil {
+			errs = append(errs, fmt.Errorf("unknown trace processor type for %q", name))
+			continue
+		}
+		if err := validateProcessorConfig(name, factory, processorViper(v, name), factory.DefaultConfig()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range metricsProcessors {
+		factory := processor.GetMetricsProcessorFactory(processorType(name))
+		if factory == nil {
+			errs = append(errs, fmt.Errorf("unknown metrics processor type for %q", name))
+			continue
+		}
+		if err := validateProcessorConfig(name, factory, processorViper(v, name), factory.DefaultConfig()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+func validateProcessorConfig(name string, factory interface{}, pv, defaultConfig *viper.Viper) error {
+	validator, ok := factory.(processor.ConfigValidator)
+	if !ok {
+		return nil
+	}
+	if pv == nil {
+		pv = defaultConfig
+	}
+	if err := validator.ValidateConfig(pv); err != nil {
+		return fmt.Errorf("invalid config for processor %q: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/factorytemplate"
+)
+
+const (
+	validatingReceiverType = "validating-receiver"
+	validatingExporterType = "validating-exporter"
+)
+
+func init() {
+	receiverFactory, _ := factorytemplate.NewTraceReceiverFactory(
+		validatingReceiverType,
+		func() interface{} { return &config.ReceiverConfig{Address: "localhost:1234"} },
+		func(cfg interface{}, next consumer.TraceConsumer, logger *zap.Logger) (receiver.TraceReceiver, error) {
+			return nil, errors.New("receivers must not be created when validating")
+		})
+	receiver.RegisterTraceReceiverFactory(receiverFactory)
+
+	exporter.RegisterTraceExporterFactory(exporterhelper.NewTraceExporterFactory(
+		validatingExporterType,
+		func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+			return nil, nil, nil, errors.New("exporters must not be created when validating")
+		},
+		exporterhelper.WithConfigValidator(func(v *viper.Viper) error {
+			if v.GetBool(validatingExporterType + ".fail") {
+				return errors.New("invalid configuration")
+			}
+			return nil
+		}),
+		exporterhelper.WithDefaultConfig(map[string]interface{}{
+			"fail":    false,
+			"timeout": "5s",
+		}),
+	))
+}
+
+func TestValidateReceiverConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		receiverType string
+		cfg          map[string]interface{}
+		wantErr      bool
+	}{
+		{
+			name:         "valid",
+			receiverType: validatingReceiverType,
+			cfg:          map[string]interface{}{"address": "localhost:4321"},
+		},
+		{
+			name:         "no_config",
+			receiverType: validatingReceiverType,
+		},
+		{
+			name:         "invalid_field",
+			receiverType: validatingReceiverType,
+			cfg:          map[string]interface{}{"collector_http_port": "not_a_number"},
+			wantErr:      true,
+		},
+		{
+			name:         "missing_tls_files",
+			receiverType: validatingReceiverType,
+			cfg: map[string]interface{}{
+				"tls_credentials": map[string]interface{}{
+					"cert_file": "testdata/missing.crt",
+					"key_file":  "testdata/missing.key",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "unknown_type",
+			receiverType: "unknown",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rv *viper.Viper
+			if tt.cfg != nil {
+				rv = viper.New()
+				for key, value := range tt.cfg {
+					rv.Set(key, value)
+				}
+			}
+			err := config.ValidateReceiverConfig(tt.receiverType, rv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateReceiverConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExportersConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			yaml: `
+exporters:
+  validating-exporter:
+  validating-exporter/secondary:
+    fail: false
+  zipkin:
+    endpoint: "http://localhost:9411/api/v2/spans"
+`,
+		},
+		{
+			name: "invalid_instance",
+			yaml: `
+exporters:
+  validating-exporter:
+  validating-exporter/secondary:
+    fail: true
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown_type",
+			yaml: `
+exporters:
+  unknown:
+    endpoint: "localhost:1234"
+`,
+			wantErr: true,
+		},
+		{
+			name: "no_exporters",
+			yaml: `
+receivers:
+  validating-receiver:
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			if err := viperutils.LoadYAMLBytes(v, []byte(tt.yaml)); err != nil {
+				t.Fatalf("Unexpected YAML parse error: %v", err)
+			}
+			err := config.ValidateExportersConfig(v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateExportersConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEffectiveSettings(t *testing.T) {
+	v := viper.New()
+	err := viperutils.LoadYAMLBytes(v, []byte(`
+receivers:
+  validating-receiver:
+    disable_metrics: true
+exporters:
+  validating-exporter:
+  validating-exporter/secondary:
+    timeout: 10s
+  zipkin:
+    upload_period: 1s
+  unknown:
+    endpoint: "localhost:1234"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected YAML parse error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"receivers": map[string]interface{}{
+			validatingReceiverType: map[string]interface{}{
+				"address":         "localhost:1234",
+				"disable_metrics": true,
+			},
+		},
+		"exporters": map[string]interface{}{
+			validatingExporterType: map[string]interface{}{
+				"fail":    false,
+				"timeout": "5s",
+			},
+			validatingExporterType + "/secondary": map[string]interface{}{
+				"fail":    false,
+				"timeout": "10s",
+			},
+			zipkinexporter.TypeStr: map[string]interface{}{
+				"endpoint":      zipkinexporter.DefaultZipkinEndpointURL,
+				"upload_period": "1s",
+			},
+			"unknown": map[string]interface{}{
+				"endpoint": "localhost:1234",
+			},
+		},
+	}
+	if got := config.EffectiveSettings(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("EffectiveSettings() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reload has the building blocks used by the agent and the collector to apply
+// a new configuration without restarting the process: the requests that trigger a
+// reload, consumers whose destination can be replaced while receivers keep running,
+// helpers to find what changed between two configurations, and the shutdown of the
+// components replaced by a reload.
+package reload
+
+import (
+	"reflect"
+)
+
+// Settings is a snapshot of a configuration, as returned by viper.AllSettings. The
+// keys are lower case, as viper normalizes them.
+type Settings map[string]interface{}
+
+// Lookup returns the value at the given path, or nil if there is none. Each element of
+// the path is a key in a nested map, so names like "zipkin/primary" are a single element.
+func (s Settings) Lookup(path ...string) interface{} {
+	var value interface{} = map[string]interface{}(s)
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// Changed returns true if the value at the given path differs between the old and the
+// new settings.
+func Changed(oldSettings, newSettings Settings, path ...string) bool {
+	return !reflect.DeepEqual(oldSettings.Lookup(path...), newSettings.Lookup(path...))
+}
+
+// ChangedExcept returns true if any value, other than the ones under the given
+// top-level keys, differs between the old and the new settings.
+func ChangedExcept(oldSettings, newSettings Settings, keys ...string) bool {
+	return !reflect.DeepEqual(without(oldSettings, keys), without(newSettings, keys))
+}
+
+func without(s Settings, keys []string) Settings {
+	filtered := make(Settings, len(s))
+	for k, v := range s {
+		filtered[k] = v
+	}
+	for _, k := range keys {
+		delete(filtered, k)
+	}
+	return filtered
+}
@@ -0,0 +1,87 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestChanged(t *testing.T) {
+	oldSettings := Settings{
+		"receivers": map[string]interface{}{
+			"zipkin":        map[string]interface{}{"port": 9411},
+			"zipkin/shadow": map[string]interface{}{"port": 9412},
+		},
+		"log-level": "INFO",
+	}
+	newSettings := Settings{
+		"receivers": map[string]interface{}{
+			"zipkin":        map[string]interface{}{"port": 9411},
+			"zipkin/shadow": map[string]interface{}{"port": 9413},
+			"jaeger":        map[string]interface{}{},
+		},
+		"log-level": "INFO",
+	}
+
+	tests := []struct {
+		path []string
+		want bool
+	}{
+		{path: []string{"receivers", "zipkin"}, want: false},
+		{path: []string{"receivers", "zipkin/shadow"}, want: true},
+		{path: []string{"receivers", "jaeger"}, want: true},
+		{path: []string{"receivers", "opencensus"}, want: false},
+		{path: []string{"log-level", "not-a-map"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := Changed(oldSettings, newSettings, tt.path...); got != tt.want {
+			t.Errorf("Changed(%v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if ChangedExcept(oldSettings, newSettings, "receivers") {
+		t.Errorf("ChangedExcept(\"receivers\") = true, want false")
+	}
+	if !ChangedExcept(oldSettings, newSettings) {
+		t.Errorf("ChangedExcept() = false, want true")
+	}
+	if _, ok := oldSettings["receivers"]; !ok {
+		t.Errorf("ChangedExcept() should not modify the given settings")
+	}
+}
+
+func TestSwitches(t *testing.T) {
+	first, second := &exportertest.SinkTraceExporter{}, &exportertest.SinkTraceExporter{}
+	ts := NewTraceSwitch(first)
+	ts.ConsumeTraceData(context.Background(), data.TraceData{})
+	ts.Swap(second)
+	ts.ConsumeTraceData(context.Background(), data.TraceData{})
+	if len(first.AllTraces()) != 1 || len(second.AllTraces()) != 1 {
+		t.Errorf("TraceSwitch sent %d and %d batches, want 1 to each destination", len(first.AllTraces()), len(second.AllTraces()))
+	}
+
+	firstMetrics, secondMetrics := &exportertest.SinkMetricsExporter{}, &exportertest.SinkMetricsExporter{}
+	ms := NewMetricsSwitch(firstMetrics)
+	ms.ConsumeMetricsData(context.Background(), data.MetricsData{})
+	ms.Swap(secondMetrics)
+	ms.ConsumeMetricsData(context.Background(), data.MetricsData{})
+	if len(firstMetrics.AllMetrics()) != 1 || len(secondMetrics.AllMetrics()) != 1 {
+		t.Errorf("MetricsSwitch sent %d and %d batches, want 1 to each destination", len(firstMetrics.AllMetrics()), len(secondMetrics.AllMetrics()))
+	}
+}
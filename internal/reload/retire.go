@@ -0,0 +1,104 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RetireDelay is how long the processors and exporters replaced by a reload are kept
+// before being shut down, so the data already in them, e.g.: traces waiting for a
+// tail-sampling decision or queued batches, still reaches its destination.
+const RetireDelay = time.Minute
+
+// Retirer shuts down the processors and exporters replaced by a reload once they had
+// time to send the data already in them. It keeps track of the ones not shut down yet,
+// so they are flushed and waited for when the process exits.
+type Retirer struct {
+	delay time.Duration
+
+	mu      sync.Mutex
+	timeout time.Duration
+	pending []*retired
+}
+
+// retired is the shutdown of the components replaced by one reload.
+type retired struct {
+	once       sync.Once
+	shutdownFn func(context.Context)
+}
+
+func (r *retired) shutdown(ctx context.Context) {
+	r.once.Do(func() {
+		r.shutdownFn(ctx)
+	})
+}
+
+// NewRetirer creates a Retirer that shuts down the retired components after delay,
+// giving them until timeout to send the data they hold.
+func NewRetirer(delay, timeout time.Duration) *Retirer {
+	return &Retirer{delay: delay, timeout: timeout}
+}
+
+// SetTimeout sets how long the components retired from now on have to send the data
+// they hold once they are shut down.
+func (r *Retirer) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	r.timeout = timeout
+	r.mu.Unlock()
+}
+
+// Retire calls shutdownFn after the delay of the Retirer, or on Shutdown if that
+// happens first.
+func (r *Retirer) Retire(shutdownFn func(context.Context)) {
+	rt := &retired{shutdownFn: shutdownFn}
+	r.mu.Lock()
+	r.pending = append(r.pending, rt)
+	timeout := r.timeout
+	r.mu.Unlock()
+
+	time.AfterFunc(r.delay, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		rt.shutdown(ctx)
+		r.remove(rt)
+	})
+}
+
+// Shutdown shuts down the retired components in the order they were retired, without
+// waiting for their delay. It returns once all of them are shut down, including the
+// ones whose delay already elapsed and are still sending the data they hold.
+func (r *Retirer) Shutdown(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+	for _, rt := range pending {
+		rt.shutdown(ctx)
+	}
+}
+
+func (r *Retirer) remove(rt *retired) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, p := range r.pending {
+		if p == rt {
+			r.pending = append(r.pending[:i], r.pending[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetirerShutsDownAfterDelay(t *testing.T) {
+	r := NewRetirer(10*time.Millisecond, time.Second)
+	done := make(chan time.Duration, 1)
+	r.Retire(func(ctx context.Context) {
+		deadline, _ := ctx.Deadline()
+		done <- time.Until(deadline)
+	})
+
+	select {
+	case remaining := <-done:
+		if remaining <= 0 || remaining > time.Second {
+			t.Errorf("shutdown got %v until the deadline, want at most %v", remaining, time.Second)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retired components were not shut down after the delay")
+	}
+}
+
+func TestRetirerShutdown(t *testing.T) {
+	r := NewRetirer(time.Hour, time.Second)
+	var order []string
+	r.Retire(func(context.Context) { order = append(order, "first") })
+	r.Retire(func(context.Context) { order = append(order, "second") })
+
+	r.Shutdown(context.Background())
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("Shutdown shut down %v, want [first second]", order)
+	}
+
+	// Shutdown shuts down each retired component only once.
+	r.Shutdown(context.Background())
+	if len(order) != 2 {
+		t.Errorf("second Shutdown shut down %v again", order)
+	}
+}
+
+func TestRetirerShutdownWaitsForRunningShutdown(t *testing.T) {
+	r := NewRetirer(0, time.Second)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := false
+	r.Retire(func(context.Context) {
+		close(started)
+		<-release
+		finished = true
+	})
+	<-started
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	r.Shutdown(context.Background())
+	if !finished {
+		t.Error("Shutdown returned before the running shutdown finished")
+	}
+}
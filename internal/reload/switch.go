@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// TraceSwitch is a consumer.TraceConsumer that forwards the data to a destination that
+// can be replaced at any time. Receivers are connected to a switch so the processors
+// and exporters after them can be rebuilt without restarting the receivers.
+type TraceSwitch struct {
+	next atomic.Value // holds a traceDestination
+}
+
+// traceDestination wraps the consumer since atomic.Value requires the same concrete
+// type for all stored values.
+type traceDestination struct {
+	consumer.TraceConsumer
+}
+
+var _ consumer.TraceConsumer = (*TraceSwitch)(nil)
+
+// NewTraceSwitch creates a TraceSwitch that forwards the data to next.
+func NewTraceSwitch(next consumer.TraceConsumer) *TraceSwitch {
+	ts := &TraceSwitch{}
+	ts.Swap(next)
+	return ts
+}
+
+// Swap replaces the destination of the switch, data already being consumed by the
+// previous destination is not affected.
+func (ts *TraceSwitch) Swap(next consumer.TraceConsumer) {
+	ts.next.Store(traceDestination{next})
+}
+
+// ConsumeTraceData forwards the data to the current destination of the switch.
+func (ts *TraceSwitch) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return ts.next.Load().(traceDestination).ConsumeTraceData(ctx, td)
+}
+
+// MetricsSwitch is a consumer.MetricsConsumer that forwards the data to a destination
+// that can be replaced at any time, see TraceSwitch.
+type MetricsSwitch struct {
+	next atomic.Value // holds a metricsDestination
+}
+
+type metricsDestination struct {
+	consumer.MetricsConsumer
+}
+
+var _ consumer.MetricsConsumer = (*MetricsSwitch)(nil)
+
+// NewMetricsSwitch creates a MetricsSwitch that forwards the data to next.
+func NewMetricsSwitch(next consumer.MetricsConsumer) *MetricsSwitch {
+	ms := &MetricsSwitch{}
+	ms.Swap(next)
+	return ms
+}
+
+// Swap replaces the destination of the switch, data already being consumed by the
+// previous destination is not affected.
+func (ms *MetricsSwitch) Swap(next consumer.MetricsConsumer) {
+	ms.next.Store(metricsDestination{next})
+}
+
+// ConsumeMetricsData forwards the data to the current destination of the switch.
+func (ms *MetricsSwitch) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	return ms.next.Load().(metricsDestination).ConsumeMetricsData(ctx, md)
+}
@@ -0,0 +1,154 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReloadHTTPPort is the name of the flag used to specify the port of the admin
+	// endpoint used to reload the configuration.
+	ReloadHTTPPort = "reload-http-port"
+
+	// reloadPath is the path of the admin endpoint used to reload the configuration.
+	reloadPath = "/reload"
+)
+
+// AddFlags adds to the flag set a flag to configure the admin endpoint used to reload
+// the configuration.
+func AddFlags(flags *flag.FlagSet) {
+	flags.Uint(
+		ReloadHTTPPort,
+		0,
+		"Port on which to serve the admin endpoint that reloads the configuration (POST "+reloadPath+"), use 0 to disable it.")
+}
+
+// Request is a request to reload the configuration. The component handling the request
+// must call Done with the result of the reload.
+type Request struct {
+	// Source describes what triggered the request, e.g.: "SIGHUP".
+	Source string
+	done   chan error
+}
+
+// Done reports the result of the reload to the requester.
+func (r *Request) Done(err error) {
+	if r.done != nil {
+		r.done <- err
+	}
+}
+
+// Listen starts listening for reload requests: SIGHUP signals and, if a port is
+// configured, POST requests to the admin endpoint. The requests are delivered on the
+// returned channel, the returned function stops listening.
+func Listen(asyncErrorChannel chan<- error, v *viper.Viper, logger *zap.Logger) (<-chan *Request, func() error, error) {
+	requests := make(chan *Request)
+	stopCh := make(chan struct{})
+
+	signalsChannel := make(chan os.Signal, 1)
+	signal.Notify(signalsChannel, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case s := <-signalsChannel:
+				select {
+				case requests <- &Request{Source: s.String()}:
+				case <-stopCh:
+					return
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	stopSignals := func() error {
+		signal.Stop(signalsChannel)
+		close(stopCh)
+		return nil
+	}
+
+	port := v.GetInt(ReloadHTTPPort)
+	if port == 0 {
+		return requests, stopSignals, nil
+	}
+
+	addr := fmt.Sprintf(":%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		stopSignals()
+		return nil, nil, fmt.Errorf("failed to bind to run the reload endpoint on %q: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(reloadPath, &reloadHandler{requests: requests, stopCh: stopCh})
+	srv := http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			asyncErrorChannel <- fmt.Errorf("failed to serve the reload endpoint: %v", err)
+		}
+	}()
+	logger.Info("Running reload endpoint", zap.Int("port", port), zap.String("path", reloadPath))
+
+	return requests, func() error {
+		stopSignals()
+		return srv.Close()
+	}, nil
+}
+
+// reloadHandler forwards the POST requests as reload requests and replies with their
+// result.
+type reloadHandler struct {
+	requests chan<- *Request
+	stopCh   <-chan struct{}
+}
+
+func (h *reloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "reload requires a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &Request{Source: "HTTP " + reloadPath, done: make(chan error, 1)}
+	select {
+	case h.requests <- req:
+	case <-h.stopCh:
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	select {
+	case err := <-req.done:
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "configuration reloaded")
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestListenHTTP(t *testing.T) {
+	v := viper.New()
+	port := availablePort(t)
+	v.Set(ReloadHTTPPort, port)
+
+	requests, stopFn, err := Listen(make(chan error, 1), v, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer stopFn()
+
+	url := fmt.Sprintf("http://localhost:%d%s", port, reloadPath)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET %s status = %d, want %d", url, resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	results := []error{nil, errors.New("invalid configuration")}
+	go func() {
+		for _, result := range results {
+			req := <-requests
+			req.Done(result)
+		}
+	}()
+
+	for _, result := range results {
+		resp, err := http.Post(url, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", url, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		wantStatus := http.StatusOK
+		if result != nil {
+			wantStatus = http.StatusInternalServerError
+			if !strings.Contains(string(body), result.Error()) {
+				t.Errorf("POST %s body = %q, want the reload error", url, body)
+			}
+		}
+		if resp.StatusCode != wantStatus {
+			t.Errorf("POST %s status = %d, want %d", url, resp.StatusCode, wantStatus)
+		}
+	}
+}
+
+func TestListenSIGHUP(t *testing.T) {
+	requests, stopFn, err := Listen(make(chan error, 1), viper.New(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer stopFn()
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to find the test process: %v", err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("Failed to send SIGHUP: %v", err)
+	}
+	select {
+	case req := <-requests:
+		req.Done(nil)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No reload request after SIGHUP")
+	}
+}
+
+func availablePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to get a free local port: %v", err)
+	}
+	// There is a possible race if something else takes this same port before
+	// the test uses it, however, that is unlikely in practice.
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
@@ -110,6 +110,16 @@ func ContextWithExporterName(ctx context.Context, exporterName string) context.C
 	return ctx
 }
 
+// ContextWithDefaultExporterName is like ContextWithExporterName but keeps the exporter
+// name already present in the context, if any. Exporters use it so the name of the
+// configured instance (e.g. "zipkin/primary") takes precedence over their own name.
+func ContextWithDefaultExporterName(ctx context.Context, exporterName string) context.Context {
+	if _, ok := tag.FromContext(ctx).Value(TagKeyExporter); ok {
+		return ctx
+	}
+	return ContextWithExporterName(ctx, exporterName)
+}
+
 // RecordTraceExporterMetrics records the number of the spans received and dropped by the exporter.
 // Use it with a context.Context generated using ContextWithExporterName().
 func RecordTraceExporterMetrics(ctx context.Context, receivedSpans int, droppedSpans int) {
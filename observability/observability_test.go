@@ -50,3 +50,20 @@ func TestTracePieplineRecordedMetrics(t *testing.T) {
 		t.Fatalf("When check recorded values: want nil got %v", err)
 	}
 }
+
+func TestContextWithDefaultExporterName(t *testing.T) {
+	doneFn := observabilitytest.SetupRecordedMetricsTest()
+	defer doneFn()
+
+	const instanceName = "fake_exporter/primary"
+	receiverCtx := observability.ContextWithReceiverName(context.Background(), receiverName)
+	instanceCtx := observability.ContextWithExporterName(receiverCtx, instanceName)
+	observability.RecordTraceExporterMetrics(observability.ContextWithDefaultExporterName(instanceCtx, exporterName), 7, 3)
+	observability.RecordTraceExporterMetrics(observability.ContextWithDefaultExporterName(receiverCtx, exporterName), 5, 1)
+	if err := observabilitytest.CheckValueViewExporterReceivedSpans(receiverName, instanceName, 7); err != nil {
+		t.Fatalf("When check recorded values: want nil got %v", err)
+	}
+	if err := observabilitytest.CheckValueViewExporterReceivedSpans(receiverName, exporterName, 5); err != nil {
+		t.Fatalf("When check recorded values: want nil got %v", err)
+	}
+}
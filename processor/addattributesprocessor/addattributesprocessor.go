@@ -17,7 +17,9 @@ package addattributesprocessor
 import (
 	"context"
 	"errors"
+	"sort"
 
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/spf13/cast"
 
@@ -32,8 +34,13 @@ var (
 
 type addattributesprocessor struct {
 	attributeMap map[string]*tracepb.AttributeValue
-	overwrite    bool
-	nextConsumer consumer.TraceConsumer
+	// labelKeys and labelValues hold the attributes as the labels added to all
+	// metrics, the keys are sorted so the labels are always added in the same order.
+	labelKeys           []string
+	labelValues         map[string]string
+	overwrite           bool
+	nextConsumer        consumer.TraceConsumer
+	nextMetricsConsumer consumer.MetricsConsumer
 }
 
 // Option represents options that can be applied to a NopExporter.
@@ -47,10 +54,13 @@ func WithOverwrite(overwrite bool) Option {
 	}
 }
 
-// WithAttributes returns an Option to configure the attributes to be added to all spans.
+// WithAttributes returns an Option to configure the attributes to be added to all spans,
+// or as labels to all metrics.
 func WithAttributes(attributes map[string]interface{}) Option {
 	return func(aap *addattributesprocessor) error {
 		attributeMap := make(map[string]*tracepb.AttributeValue, len(attributes))
+		labelKeys := make([]string, 0, len(attributes))
+		labelValues := make(map[string]string, len(attributes))
 		// Copy all attributes that need to be added into the span's attribute map.
 		for key, value := range attributes {
 			attrib := &tracepb.AttributeValue{}
@@ -69,13 +79,19 @@ func WithAttributes(attributes map[string]interface{}) Option {
 				return errUnsupportedType
 			}
 			attributeMap[key] = attrib
+			labelKeys = append(labelKeys, key)
+			labelValues[key] = cast.ToString(value)
 		}
+		sort.Strings(labelKeys)
 		aap.attributeMap = attributeMap
+		aap.labelKeys = labelKeys
+		aap.labelValues = labelValues
 		return nil
 	}
 }
 
 var _ processor.TraceProcessor = (*addattributesprocessor)(nil)
+var _ processor.MetricsProcessor = (*addattributesprocessor)(nil)
 
 // NewTraceProcessor returns a processor.TraceProcessor that adds the WithAttributeMap(attributes) to all spans
 // passed to it. If a key already exists, we will only overwrite is the WithOverwrite(true) is set.
@@ -115,3 +131,60 @@ func (aap *addattributesprocessor) ConsumeTraceData(ctx context.Context, td data
 	}
 	return aap.nextConsumer.ConsumeTraceData(ctx, td)
 }
+
+// NewMetricsProcessor returns a processor.MetricsProcessor that adds the WithAttributes(attributes)
+// as labels to all metrics passed to it, the values are converted to strings. If a label
+// already exists, its values are only overwritten if WithOverwrite(true) is set.
+func NewMetricsProcessor(nextConsumer consumer.MetricsConsumer, options ...Option) (processor.MetricsProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	aap := &addattributesprocessor{nextMetricsConsumer: nextConsumer}
+	for _, opt := range options {
+		if err := opt(aap); err != nil {
+			return nil, err
+		}
+	}
+	return aap, nil
+}
+
+func (aap *addattributesprocessor) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	if len(aap.labelKeys) == 0 {
+		return aap.nextMetricsConsumer.ConsumeMetricsData(ctx, md)
+	}
+	for _, metric := range md.Metrics {
+		if metric == nil || metric.MetricDescriptor == nil {
+			continue
+		}
+		for _, key := range aap.labelKeys {
+			aap.addLabel(metric, key, aap.labelValues[key])
+		}
+	}
+	return aap.nextMetricsConsumer.ConsumeMetricsData(ctx, md)
+}
+
+// addLabel adds the label to the descriptor of metric and its value to all timeseries.
+func (aap *addattributesprocessor) addLabel(metric *metricspb.Metric, key, value string) {
+	descriptor := metric.MetricDescriptor
+	for i, labelKey := range descriptor.LabelKeys {
+		if labelKey.GetKey() != key {
+			continue
+		}
+		if !aap.overwrite {
+			return
+		}
+		for _, ts := range metric.Timeseries {
+			if ts != nil && i < len(ts.LabelValues) {
+				ts.LabelValues[i] = &metricspb.LabelValue{Value: value, HasValue: true}
+			}
+		}
+		return
+	}
+
+	descriptor.LabelKeys = append(descriptor.LabelKeys, &metricspb.LabelKey{Key: key})
+	for _, ts := range metric.Timeseries {
+		if ts != nil {
+			ts.LabelValues = append(ts.LabelValues, &metricspb.LabelValue{Value: value, HasValue: true})
+		}
+	}
+}
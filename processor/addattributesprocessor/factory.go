@@ -0,0 +1,101 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package addattributesprocessor
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+const (
+	// TypeStr is the type of the processor created by the factory.
+	TypeStr = "add-attributes"
+
+	overwriteKey = "overwrite"
+	valuesKey    = "values"
+)
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+type metricsFactory struct {
+	factory
+}
+
+var _ processor.MetricsProcessorFactory = (*metricsFactory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+	processor.RegisterMetricsProcessorFactory(NewMetricsProcessorFactory())
+}
+
+// NewTraceProcessorFactory creates a factory for processors that add attributes to
+// all spans. The configuration accepts the "values" to be added and the "overwrite"
+// flag.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	if cfg == nil {
+		cfg = f.DefaultConfig()
+	}
+	return NewTraceProcessor(
+		next,
+		WithAttributes(cfg.GetStringMap(valuesKey)),
+		WithOverwrite(cfg.GetBool(overwriteKey)))
+}
+
+// ValidateConfig checks the attributes of the configuration have supported types.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	if cfg == nil {
+		return nil
+	}
+	return WithAttributes(cfg.GetStringMap(valuesKey))(&addattributesprocessor{})
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory.
+func (f *factory) DefaultConfig() *viper.Viper {
+	v := viper.New()
+	v.SetDefault(overwriteKey, false)
+	return v
+}
+
+// NewMetricsProcessorFactory creates a factory for processors that add labels to all
+// metrics. The configuration is the same as for the trace processors.
+func NewMetricsProcessorFactory() processor.MetricsProcessorFactory {
+	return &metricsFactory{}
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsProcessor.
+func (f *metricsFactory) NewFromViper(cfg *viper.Viper, next processor.MetricsProcessor) (processor.MetricsProcessor, error) {
+	if cfg == nil {
+		cfg = f.DefaultConfig()
+	}
+	return NewMetricsProcessor(
+		next,
+		WithAttributes(cfg.GetStringMap(valuesKey)),
+		WithOverwrite(cfg.GetBool(overwriteKey)))
+}
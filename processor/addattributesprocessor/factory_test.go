@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package addattributesprocessor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestFactoryNewFromViper(t *testing.T) {
+	f := NewTraceProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+
+	cfg := f.DefaultConfig()
+	cfg.Set("values", map[string]interface{}{"env": "prod"})
+
+	sink := &exportertest.SinkTraceExporter{}
+	tp, err := f.NewFromViper(cfg, sink)
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+
+	td := data.TraceData{Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "span"}}}}
+	if err := tp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() = %v", err)
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 {
+		t.Fatalf("got %d batches, want 1", len(got))
+	}
+	attr := got[0].Spans[0].Attributes.AttributeMap["env"]
+	if attr == nil || attr.GetStringValue().GetValue() != "prod" {
+		t.Errorf("attribute \"env\" = %v, want \"prod\"", attr)
+	}
+
+	if _, err := f.NewFromViper(viper.New(), sink); err != nil {
+		t.Errorf("NewFromViper() with empty configuration = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package addattributesprocessor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestAddAttributesMetricsProcessor(t *testing.T) {
+	tests := []struct {
+		name       string
+		overwrite  bool
+		wantKeys   []string
+		wantValues []string
+	}{
+		{
+			name:       "no_overwrite",
+			overwrite:  false,
+			wantKeys:   []string{"region", "cluster"},
+			wantValues: []string{"us-east", "prod"},
+		},
+		{
+			name:       "overwrite",
+			overwrite:  true,
+			wantKeys:   []string{"region", "cluster"},
+			wantValues: []string{"eu-west", "prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &exportertest.SinkMetricsExporter{}
+			mp, err := NewMetricsProcessor(
+				sink,
+				WithAttributes(map[string]interface{}{"cluster": "prod", "region": "eu-west"}),
+				WithOverwrite(tt.overwrite))
+			if err != nil {
+				t.Fatalf("NewMetricsProcessor() = %v", err)
+			}
+
+			md := data.MetricsData{Metrics: []*metricspb.Metric{
+				{
+					MetricDescriptor: &metricspb.MetricDescriptor{
+						Name:      "requests",
+						LabelKeys: []*metricspb.LabelKey{{Key: "region"}},
+					},
+					Timeseries: []*metricspb.TimeSeries{
+						{LabelValues: []*metricspb.LabelValue{{Value: "us-east", HasValue: true}}},
+					},
+				},
+				nil,
+				{},
+			}}
+			if err := mp.ConsumeMetricsData(context.Background(), md); err != nil {
+				t.Fatalf("ConsumeMetricsData() = %v", err)
+			}
+
+			got := sink.AllMetrics()
+			if len(got) != 1 {
+				t.Fatalf("got %d batches, want 1", len(got))
+			}
+			metric := got[0].Metrics[0]
+			var keys []string
+			for _, labelKey := range metric.MetricDescriptor.LabelKeys {
+				keys = append(keys, labelKey.Key)
+			}
+			var values []string
+			for _, labelValue := range metric.Timeseries[0].LabelValues {
+				values = append(values, labelValue.Value)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("label keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("label values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestMetricsFactoryNewFromViper(t *testing.T) {
+	f := NewMetricsProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+	cfg := f.DefaultConfig()
+	cfg.Set("values", map[string]interface{}{"port": 8080})
+	if _, err := f.NewFromViper(cfg, &exportertest.SinkMetricsExporter{}); err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/processor"
@@ -40,36 +42,60 @@ type KeyReplacement struct {
 }
 
 type attributekeyprocessor struct {
-	nextConsumer consumer.TraceConsumer
-	replacements []KeyReplacement
+	nextConsumer        consumer.TraceConsumer
+	nextMetricsConsumer consumer.MetricsConsumer
+	replacements        []KeyReplacement
 }
 
 var _ processor.TraceProcessor = (*attributekeyprocessor)(nil)
+var _ processor.MetricsProcessor = (*attributekeyprocessor)(nil)
 
 // NewTraceProcessor returns a processor.TraceProcessor
 func NewTraceProcessor(nextConsumer consumer.TraceConsumer, replacements ...KeyReplacement) (processor.TraceProcessor, error) {
 	if nextConsumer == nil {
 		return nil, errors.New("nextConsumer is nil")
 	}
+	if err := validateReplacements(replacements); err != nil {
+		return nil, err
+	}
+
+	return &attributekeyprocessor{
+		nextConsumer: nextConsumer,
+		replacements: replacements,
+	}, nil
+}
+
+// NewMetricsProcessor returns a processor.MetricsProcessor that replaces the label keys
+// of all metrics, the replacements are applied as for span attributes.
+func NewMetricsProcessor(nextConsumer consumer.MetricsConsumer, replacements ...KeyReplacement) (processor.MetricsProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if err := validateReplacements(replacements); err != nil {
+		return nil, err
+	}
 
+	return &attributekeyprocessor{
+		nextMetricsConsumer: nextConsumer,
+		replacements:        replacements,
+	}, nil
+}
+
+func validateReplacements(replacements []KeyReplacement) error {
 	lenReplacements := len(replacements)
 	if lenReplacements > 0 {
 		seenKeys := make(map[string]bool, lenReplacements)
 		for _, replacement := range replacements {
 			if seenKeys[replacement.Key] {
-				return nil, fmt.Errorf("replacement key %q already specified", replacement.Key)
+				return fmt.Errorf("replacement key %q already specified", replacement.Key)
 			}
 			seenKeys[replacement.Key] = true
 			if seenKeys[replacement.NewKey] {
-				return nil, fmt.Errorf("replacement new key %q is already a key being mapped", replacement.NewKey)
+				return fmt.Errorf("replacement new key %q is already a key being mapped", replacement.NewKey)
 			}
 		}
 	}
-
-	return &attributekeyprocessor{
-		nextConsumer: nextConsumer,
-		replacements: replacements,
-	}, nil
+	return nil
 }
 
 func (akp *attributekeyprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
@@ -100,3 +126,85 @@ func (akp *attributekeyprocessor) ConsumeTraceData(ctx context.Context, td data.
 	}
 	return akp.nextConsumer.ConsumeTraceData(ctx, td)
 }
+
+func (akp *attributekeyprocessor) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	if len(akp.replacements) == 0 {
+		return akp.nextMetricsConsumer.ConsumeMetricsData(ctx, md)
+	}
+	for _, metric := range md.Metrics {
+		if metric == nil || metric.MetricDescriptor == nil || len(metric.MetricDescriptor.LabelKeys) == 0 {
+			// Nothing to do
+			continue
+		}
+		for _, replacement := range akp.replacements {
+			replaceLabelKey(metric, replacement)
+		}
+	}
+	return akp.nextMetricsConsumer.ConsumeMetricsData(ctx, md)
+}
+
+// replaceLabelKey applies replacement to the label keys of metric, moving the label
+// values of all timeseries accordingly.
+func replaceLabelKey(metric *metricspb.Metric, replacement KeyReplacement) {
+	descriptor := metric.MetricDescriptor
+	oldIndex := labelKeyIndex(descriptor, replacement.Key)
+	if oldIndex < 0 {
+		return
+	}
+	newIndex := labelKeyIndex(descriptor, replacement.NewKey)
+	if newIndex >= 0 && !replacement.Overwrite {
+		return
+	}
+
+	switch {
+	case newIndex >= 0:
+		for _, ts := range metric.Timeseries {
+			if ts != nil && oldIndex < len(ts.LabelValues) && newIndex < len(ts.LabelValues) {
+				ts.LabelValues[newIndex] = ts.LabelValues[oldIndex]
+			}
+		}
+	case replacement.KeepOriginal:
+		descriptor.LabelKeys = append(descriptor.LabelKeys, &metricspb.LabelKey{
+			Key:         replacement.NewKey,
+			Description: descriptor.LabelKeys[oldIndex].GetDescription(),
+		})
+		for _, ts := range metric.Timeseries {
+			if ts == nil {
+				continue
+			}
+			value := &metricspb.LabelValue{}
+			if oldIndex < len(ts.LabelValues) {
+				value = ts.LabelValues[oldIndex]
+			}
+			ts.LabelValues = append(ts.LabelValues, value)
+		}
+		return
+	default:
+		descriptor.LabelKeys[oldIndex] = &metricspb.LabelKey{
+			Key:         replacement.NewKey,
+			Description: descriptor.LabelKeys[oldIndex].GetDescription(),
+		}
+		return
+	}
+
+	if replacement.KeepOriginal {
+		return
+	}
+	descriptor.LabelKeys = append(descriptor.LabelKeys[:oldIndex], descriptor.LabelKeys[oldIndex+1:]...)
+	for _, ts := range metric.Timeseries {
+		if ts != nil && oldIndex < len(ts.LabelValues) {
+			ts.LabelValues = append(ts.LabelValues[:oldIndex], ts.LabelValues[oldIndex+1:]...)
+		}
+	}
+}
+
+// labelKeyIndex returns the index of key in the label keys of descriptor, or -1 if
+// it is not present.
+func labelKeyIndex(descriptor *metricspb.MetricDescriptor, key string) int {
+	for i, labelKey := range descriptor.LabelKeys {
+		if labelKey.GetKey() == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,105 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributekeyprocessor
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TypeStr is the type of the processor created by the factory.
+const TypeStr = "attribute-key"
+
+type factory struct{}
+
+var _ processor.TraceProcessorFactory = (*factory)(nil)
+var _ processor.ConfigValidator = (*factory)(nil)
+
+type metricsFactory struct {
+	factory
+}
+
+var _ processor.MetricsProcessorFactory = (*metricsFactory)(nil)
+
+func init() {
+	processor.RegisterTraceProcessorFactory(NewTraceProcessorFactory())
+	processor.RegisterMetricsProcessorFactory(NewMetricsProcessorFactory())
+}
+
+// Config holds the configuration of the processors created by the factory.
+type Config struct {
+	// KeyReplacements is the list of attribute keys to be replaced.
+	KeyReplacements []KeyReplacement `mapstructure:"key-mapping"`
+}
+
+// NewTraceProcessorFactory creates a factory for processors that replace attribute keys
+// of all spans according to the "key-mapping" list of the configuration.
+func NewTraceProcessorFactory() processor.TraceProcessorFactory {
+	return &factory{}
+}
+
+// Type gets the type of the processor created by this factory.
+func (f *factory) Type() string {
+	return TypeStr
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new TraceProcessor.
+func (f *factory) NewFromViper(cfg *viper.Viper, next processor.TraceProcessor) (processor.TraceProcessor, error) {
+	if cfg == nil {
+		cfg = f.DefaultConfig()
+	}
+	var pCfg Config
+	if err := cfg.Unmarshal(&pCfg); err != nil {
+		return nil, err
+	}
+	return NewTraceProcessor(next, pCfg.KeyReplacements...)
+}
+
+// ValidateConfig checks the key replacements of the configuration.
+func (f *factory) ValidateConfig(cfg *viper.Viper) error {
+	if cfg == nil {
+		return nil
+	}
+	var pCfg Config
+	if err := cfg.Unmarshal(&pCfg); err != nil {
+		return err
+	}
+	return validateReplacements(pCfg.KeyReplacements)
+}
+
+// DefaultConfig returns the default configuration for the processors created by
+// this factory.
+func (f *factory) DefaultConfig() *viper.Viper {
+	return viper.New()
+}
+
+// NewMetricsProcessorFactory creates a factory for processors that replace the label
+// keys of all metrics according to the "key-mapping" list of the configuration.
+func NewMetricsProcessorFactory() processor.MetricsProcessorFactory {
+	return &metricsFactory{}
+}
+
+// NewFromViper takes a viper.Viper configuration and creates a new MetricsProcessor.
+func (f *metricsFactory) NewFromViper(cfg *viper.Viper, next processor.MetricsProcessor) (processor.MetricsProcessor, error) {
+	if cfg == nil {
+		cfg = f.DefaultConfig()
+	}
+	var pCfg Config
+	if err := cfg.Unmarshal(&pCfg); err != nil {
+		return nil, err
+	}
+	return NewMetricsProcessor(next, pCfg.KeyReplacements...)
+}
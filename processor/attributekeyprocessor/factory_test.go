@@ -0,0 +1,51 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributekeyprocessor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestFactoryNewFromViper(t *testing.T) {
+	f := NewTraceProcessorFactory()
+	if f.Type() != TypeStr {
+		t.Fatalf("Type() = %q, want %q", f.Type(), TypeStr)
+	}
+
+	cfg := viper.New()
+	cfg.Set("key-mapping", []map[string]interface{}{
+		{"key": "user.email", "replacement": "user.id", "overwrite": true},
+	})
+
+	nop := processortest.NewNopTraceProcessor(nil)
+	tp, err := f.NewFromViper(cfg, nop)
+	if err != nil {
+		t.Fatalf("NewFromViper() = %v", err)
+	}
+
+	want := []KeyReplacement{{Key: "user.email", NewKey: "user.id", Overwrite: true}}
+	if got := tp.(*attributekeyprocessor).replacements; !reflect.DeepEqual(got, want) {
+		t.Errorf("replacements = %+v, want %+v", got, want)
+	}
+
+	if _, err := f.NewFromViper(f.DefaultConfig(), nop); err != nil {
+		t.Errorf("NewFromViper() with default configuration = %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributekeyprocessor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func Test_attributekeyprocessor_ConsumeMetricsData(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement KeyReplacement
+		wantKeys    []string
+		wantValues  []string
+	}{
+		{
+			name:        "rename",
+			replacement: KeyReplacement{Key: "host", NewKey: "hostname"},
+			wantKeys:    []string{"hostname", "region"},
+			wantValues:  []string{"h1", "us"},
+		},
+		{
+			name:        "keep_original",
+			replacement: KeyReplacement{Key: "host", NewKey: "hostname", KeepOriginal: true},
+			wantKeys:    []string{"host", "region", "hostname"},
+			wantValues:  []string{"h1", "us", "h1"},
+		},
+		{
+			name:        "existing_new_key",
+			replacement: KeyReplacement{Key: "host", NewKey: "region"},
+			wantKeys:    []string{"host", "region"},
+			wantValues:  []string{"h1", "us"},
+		},
+		{
+			name:        "overwrite",
+			replacement: KeyReplacement{Key: "host", NewKey: "region", Overwrite: true},
+			wantKeys:    []string{"region"},
+			wantValues:  []string{"h1"},
+		},
+		{
+			name:        "overwrite_keep_original",
+			replacement: KeyReplacement{Key: "host", NewKey: "region", Overwrite: true, KeepOriginal: true},
+			wantKeys:    []string{"host", "region"},
+			wantValues:  []string{"h1", "h1"},
+		},
+		{
+			name:        "missing_key",
+			replacement: KeyReplacement{Key: "zone", NewKey: "az"},
+			wantKeys:    []string{"host", "region"},
+			wantValues:  []string{"h1", "us"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &exportertest.SinkMetricsExporter{}
+			mp, err := NewMetricsProcessor(sink, tt.replacement)
+			if err != nil {
+				t.Fatalf("NewMetricsProcessor() = %v", err)
+			}
+
+			md := data.MetricsData{Metrics: []*metricspb.Metric{
+				{
+					MetricDescriptor: &metricspb.MetricDescriptor{
+						Name:      "requests",
+						LabelKeys: []*metricspb.LabelKey{{Key: "host"}, {Key: "region"}},
+					},
+					Timeseries: []*metricspb.TimeSeries{
+						{LabelValues: []*metricspb.LabelValue{
+							{Value: "h1", HasValue: true},
+							{Value: "us", HasValue: true},
+						}},
+					},
+				},
+				nil,
+			}}
+			if err := mp.ConsumeMetricsData(context.Background(), md); err != nil {
+				t.Fatalf("ConsumeMetricsData() = %v", err)
+			}
+
+			metric := sink.AllMetrics()[0].Metrics[0]
+			var keys []string
+			for _, labelKey := range metric.MetricDescriptor.LabelKeys {
+				keys = append(keys, labelKey.Key)
+			}
+			var values []string
+			for _, labelValue := range metric.Timeseries[0].LabelValues {
+				values = append(values, labelValue.Value)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("label keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("label values = %v, want %v", values, tt.wantValues)
+			}
+		})
+	}
+}
@@ -41,3 +41,13 @@ type MetricsProcessorFactory interface {
 	// created by this factory.
 	DefaultConfig() *viper.Viper
 }
+
+// ConfigValidator is implemented by the processor factories that can check a
+// configuration without creating a processor, since some processors start goroutines
+// or open their on-disk queues on creation. It is used to validate a configuration
+// file before running it.
+type ConfigValidator interface {
+	// ValidateConfig checks the given configuration, in the same format passed to
+	// NewFromViper, returning an error describing any problem found.
+	ValidateConfig(cfg *viper.Viper) error
+}
@@ -18,8 +18,8 @@ import (
 	"context"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
-	"github.com/census-instrumentation/opencensus-service/internal"
 	"github.com/census-instrumentation/opencensus-service/processor"
 )
 
@@ -40,7 +40,7 @@ func (mcs metricsConsumers) ConsumeMetricsData(ctx context.Context, md data.Metr
 			errs = append(errs, err)
 		}
 	}
-	return internal.CombineErrors(errs)
+	return combineErrors(errs, len(mcs))
 }
 
 // NewTraceProcessor wraps multiple trace consumers in a single one.
@@ -60,5 +60,16 @@ func (tcs traceConsumers) ConsumeTraceData(ctx context.Context, td data.TraceDat
 			errs = append(errs, err)
 		}
 	}
-	return internal.CombineErrors(errs)
+	return combineErrors(errs, len(tcs))
+}
+
+// combineErrors converts the errors of the consumers that failed, out of numConsumers,
+// into one error. If some consumers accepted the data the error is permanent, sending it
+// again would duplicate the data on them.
+func combineErrors(errs []error, numConsumers int) error {
+	err := consumererror.CombineErrors(errs)
+	if err != nil && len(errs) < numConsumers && !consumererror.IsPermanent(err) {
+		return consumererror.Permanent(err)
+	}
+	return err
 }
@@ -21,6 +21,7 @@ import (
 	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 )
 
@@ -87,6 +88,45 @@ func TestTraceProcessorWhenOneErrors(t *testing.T) {
 	}
 }
 
+func TestTraceProcessorWhenOneRefuses(t *testing.T) {
+	accepting := &mockTraceConsumer{}
+	refusing := &mockTraceConsumer{MustRefuse: true}
+	tdp := NewTraceProcessor([]consumer.TraceConsumer{accepting, refusing})
+	td := data.TraceData{
+		Spans: make([]*tracepb.Span, 5),
+	}
+
+	// A sender retries the data as long as it is refused.
+	var err error
+	for i := 0; i < 3; i++ {
+		err = tdp.ConsumeTraceData(context.Background(), td)
+		if !consumererror.IsResourceExhausted(err) {
+			break
+		}
+	}
+	if err == nil || consumererror.IsResourceExhausted(err) {
+		t.Fatalf("Wanted an error that is not resource exhausted got %v", err)
+	}
+	if !consumererror.IsPermanent(err) {
+		t.Errorf("Wanted a permanent error got %v", err)
+	}
+	if accepting.TotalSpans != len(td.Spans) {
+		t.Errorf("Wanted %d spans for the accepting processor but got %d", len(td.Spans), accepting.TotalSpans)
+	}
+}
+
+func TestTraceProcessorWhenAllRefuse(t *testing.T) {
+	processors := []consumer.TraceConsumer{
+		&mockTraceConsumer{MustRefuse: true},
+		&mockTraceConsumer{MustRefuse: true},
+	}
+	tdp := NewTraceProcessor(processors)
+	err := tdp.ConsumeTraceData(context.Background(), data.TraceData{Spans: make([]*tracepb.Span, 5)})
+	if !consumererror.IsResourceExhausted(err) {
+		t.Errorf("Wanted a resource exhausted error got %v", err)
+	}
+}
+
 func TestMetricsProcessorMultiplexing(t *testing.T) {
 	processors := make([]consumer.MetricsConsumer, 3)
 	for i := range processors {
@@ -153,11 +193,15 @@ func TestMetricsProcessorWhenOneErrors(t *testing.T) {
 type mockTraceConsumer struct {
 	TotalSpans int
 	MustFail   bool
+	MustRefuse bool
 }
 
 var _ consumer.TraceConsumer = &mockTraceConsumer{}
 
 func (p *mockTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if p.MustRefuse {
+		return consumererror.ResourceExhausted(fmt.Errorf("this processor must refuse"), 0)
+	}
 	p.TotalSpans += len(td.Spans)
 	if p.MustFail {
 		return fmt.Errorf("this processor must fail")
@@ -15,6 +15,8 @@
 package processor
 
 import (
+	"context"
+
 	"github.com/census-instrumentation/opencensus-service/consumer"
 )
 
@@ -31,3 +33,19 @@ type MetricsProcessor interface {
 
 	// TODO: Add processor specific functions.
 }
+
+// ShutdownFunc returns the function that shuts down p: its Shutdown method if p
+// implements consumer.Shutdowner, or its Stop method for the processors that hold
+// goroutines but no data. It returns nil if p has neither.
+func ShutdownFunc(p interface{}) func(context.Context) error {
+	switch s := p.(type) {
+	case consumer.Shutdowner:
+		return s.Shutdown
+	case interface{ Stop() }:
+		return func(context.Context) error {
+			s.Stop()
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The registries hold the factories of all processors linked in the binary. Processor
+// packages are expected to register their factories from an init function, so a
+// binary only needs to import a processor package to make it available.
+var (
+	registryMu                sync.RWMutex
+	traceProcessorFactories   = make(map[string]TraceProcessorFactory)
+	metricsProcessorFactories = make(map[string]MetricsProcessorFactory)
+)
+
+// RegisterTraceProcessorFactory makes a TraceProcessorFactory available by its type.
+// If RegisterTraceProcessorFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterTraceProcessorFactory(factory TraceProcessorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("processor: RegisterTraceProcessorFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := traceProcessorFactories[typeStr]; dup {
+		panic(fmt.Sprintf("processor: RegisterTraceProcessorFactory called twice for type %q", typeStr))
+	}
+	traceProcessorFactories[typeStr] = factory
+}
+
+// RegisterMetricsProcessorFactory makes a MetricsProcessorFactory available by its type.
+// If RegisterMetricsProcessorFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterMetricsProcessorFactory(factory MetricsProcessorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("processor: RegisterMetricsProcessorFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := metricsProcessorFactories[typeStr]; dup {
+		panic(fmt.Sprintf("processor: RegisterMetricsProcessorFactory called twice for type %q", typeStr))
+	}
+	metricsProcessorFactories[typeStr] = factory
+}
+
+// GetTraceProcessorFactory returns the registered TraceProcessorFactory for the given
+// type, or nil if there is none.
+func GetTraceProcessorFactory(typeStr string) TraceProcessorFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return traceProcessorFactories[typeStr]
+}
+
+// GetMetricsProcessorFactory returns the registered MetricsProcessorFactory for the given
+// type, or nil if there is none.
+func GetMetricsProcessorFactory(typeStr string) MetricsProcessorFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return metricsProcessorFactories[typeStr]
+}
+
+// TraceProcessorFactories returns all registered TraceProcessorFactory sorted by type.
+func TraceProcessorFactories() []TraceProcessorFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]TraceProcessorFactory, 0, len(traceProcessorFactories))
+	for _, factory := range traceProcessorFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
+
+// MetricsProcessorFactories returns all registered MetricsProcessorFactory sorted by type.
+func MetricsProcessorFactories() []MetricsProcessorFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]MetricsProcessorFactory, 0, len(metricsProcessorFactories))
+	for _, factory := range metricsProcessorFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
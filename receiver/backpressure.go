@@ -0,0 +1,55 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+)
+
+// DefaultRetryAfter is the delay that receivers ask their clients to wait before sending
+// refused data again when the consumer did not suggest one.
+const DefaultRetryAfter = time.Second
+
+// RetryAfter returns the delay after which a client should send again the data that the
+// next consumer refused with err, which must be a consumererror.ResourceExhausted error.
+func RetryAfter(err error) time.Duration {
+	if d := consumererror.RetryAfter(err); d > 0 {
+		return d
+	}
+	return DefaultRetryAfter
+}
+
+// WriteRetryLater replies to an HTTP request whose data was refused with err, a
+// consumererror.ResourceExhausted error, with a 503 Service Unavailable status and a
+// Retry-After header, so that the client sends the data again later.
+func WriteRetryLater(w http.ResponseWriter, err error) {
+	seconds := int64((RetryAfter(err) + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// GRPCRetryLater returns the error with a ResourceExhausted code that a gRPC service
+// returns to its client when its data was refused with err, a
+// consumererror.ResourceExhausted error.
+func GRPCRetryLater(err error) error {
+	return status.Errorf(codes.ResourceExhausted, "%s, retry after %v", err.Error(), RetryAfter(err))
+}
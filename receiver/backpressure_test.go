@@ -0,0 +1,59 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+)
+
+func TestWriteRetryLater(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     time.Duration
+		wantRetryAfter string
+	}{
+		{name: "default", wantRetryAfter: "1"},
+		{name: "rounded_up", retryAfter: 1500 * time.Millisecond, wantRetryAfter: "2"},
+		{name: "seconds", retryAfter: 30 * time.Second, wantRetryAfter: "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteRetryLater(w, consumererror.ResourceExhausted(errors.New("queue is full"), tt.retryAfter))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("got Retry-After %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestGRPCRetryLater(t *testing.T) {
+	err := GRPCRetryLater(consumererror.ResourceExhausted(errors.New("queue is full"), 0))
+	if got := status.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("got code %v, want %v", got, codes.ResourceExhausted)
+	}
+}
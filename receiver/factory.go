@@ -48,3 +48,12 @@ type MetricsReceiverFactory interface {
 	// created by this factory.
 	DefaultConfig() interface{}
 }
+
+// ConfigValidator is implemented by the receiver factories that can check a
+// configuration without creating a receiver, since some receivers bind their ports
+// on creation. It is used to validate a configuration file before running it.
+type ConfigValidator interface {
+	// ValidateConfig checks the given configuration, in the same format passed to
+	// NewFromViper, returning an error describing any problem found.
+	ValidateConfig(v *viper.Viper) error
+}
@@ -25,6 +25,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal/config/secrets"
 	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
@@ -55,6 +56,7 @@ type traceReceiverFactory struct {
 }
 
 var _ (receiver.TraceReceiverFactory) = (*traceReceiverFactory)(nil)
+var _ (receiver.ConfigValidator) = (*traceReceiverFactory)(nil)
 
 type metricsReceiverFactory struct {
 	factory
@@ -62,6 +64,7 @@ type metricsReceiverFactory struct {
 }
 
 var _ (receiver.MetricsReceiverFactory) = (*metricsReceiverFactory)(nil)
+var _ (receiver.ConfigValidator) = (*metricsReceiverFactory)(nil)
 
 // NewTraceReceiverFactory creates a factory for the given receiver "type" that
 // will have as the default configuration the object returned by newDefaultCfg and
@@ -152,7 +155,7 @@ func (trf *traceReceiverFactory) NewFromViper(v *viper.Viper, next consumer.Trac
 		return nil, err
 	}
 
-	logger.Info("Trace receiver created", zap.String("type", trf.Type()), zap.String("config", fmt.Sprintf("%+v", cfg)))
+	logger.Info("Trace receiver created", zap.String("type", trf.Type()), zap.String("config", secrets.Redact(fmt.Sprintf("%+v", cfg))))
 	return r, nil
 }
 
@@ -170,7 +173,7 @@ func (mrf *metricsReceiverFactory) NewFromViper(v *viper.Viper, next consumer.Me
 		return nil, err
 	}
 
-	logger.Info("Metrics receiver created", zap.String("type", mrf.Type()), zap.String("config", fmt.Sprintf("%+v", cfg)))
+	logger.Info("Metrics receiver created", zap.String("type", mrf.Type()), zap.String("config", secrets.Redact(fmt.Sprintf("%+v", cfg))))
 	return r, nil
 }
 
@@ -182,6 +185,25 @@ func (f *factory) DefaultConfig() interface{} {
 
 // configFromViper takes a viper.Viper, generates a default config and returns the
 // resulting configuration.
+// Validator is implemented by the configurations returned by newDefaultCfg that can
+// check their own settings, e.g.: that the files they refer to exist.
+type Validator interface {
+	Validate() error
+}
+
+// ValidateConfig decodes v into the default configuration, as done by NewFromViper,
+// and calls its Validate method if it implements Validator.
+func (f *factory) ValidateConfig(v *viper.Viper) error {
+	cfg, err := f.configFromViper(v)
+	if err != nil {
+		return err
+	}
+	if validator, ok := cfg.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
 func (f *factory) configFromViper(v *viper.Viper) (cfg interface{}, err error) {
 	if v == nil {
 		return nil, ErrNilViper
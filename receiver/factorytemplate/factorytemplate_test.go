@@ -431,6 +431,58 @@ func TestInvalidConfig(t *testing.T) {
 	}
 }
 
+func TestValidateConfig(t *testing.T) {
+	errNoAddress := errors.New("no address")
+	factory, err := NewTraceReceiverFactory(
+		"mockReceiver",
+		func() interface{} { return &validatingMockReceiverCfg{err: errNoAddress} },
+		newMockTraceReceiver,
+	)
+	if err != nil {
+		t.Fatalf("failed to create factory: %v", err)
+	}
+	validator, ok := factory.(receiver.ConfigValidator)
+	if !ok {
+		t.Fatal("factory does not implement receiver.ConfigValidator")
+	}
+
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		wantErr error
+	}{
+		{
+			name: "valid",
+			cfg:  map[string]interface{}{"address": "myaddress", "port": 616},
+		},
+		{
+			name:    "invalid_field",
+			cfg:     map[string]interface{}{"address": "myaddress", "port": "not_a_number"},
+			wantErr: errors.New("any"),
+		},
+		{
+			name:    "rejected_by_validate",
+			cfg:     map[string]interface{}{"port": 616},
+			wantErr: errNoAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			for key, value := range tt.cfg {
+				v.Set(key, value)
+			}
+			err := validator.ValidateConfig(v)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errNoAddress && err != errNoAddress {
+				t.Fatalf("ValidateConfig() error = %v, want %v", err, errNoAddress)
+			}
+		})
+	}
+}
+
 func Examplefactory_DefaultConfig() {
 	templateArgs := []struct {
 		receiverType  string
@@ -491,6 +543,19 @@ type mockReceiverCfg struct {
 	Port    uint16 `mapstructure:"Port"`
 }
 
+// validatingMockReceiverCfg rejects configurations without an address.
+type validatingMockReceiverCfg struct {
+	mockReceiverCfg `mapstructure:",squash"`
+	err             error
+}
+
+func (cfg *validatingMockReceiverCfg) Validate() error {
+	if cfg.Address == "" {
+		return cfg.err
+	}
+	return nil
+}
+
 type mockReceiver struct {
 	config *mockReceiverCfg
 }
@@ -0,0 +1,100 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaegerreceiver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/jaegertracing/jaeger/thrift-gen/jaeger"
+
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+var acceptedThriftFormats = map[string]struct{}{
+	"application/x-thrift":                 {},
+	"application/vnd.apache.thrift.binary": {},
+}
+
+// serveTraces receives a Jaeger Thrift batch on the HTTP endpoint of the collector,
+// like the handler of the Jaeger collector, but tells the client to send the batch again
+// later when the next consumer refuses it.
+func (jr *jReceiver) serveTraces(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to process request body: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot parse content type: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, ok := acceptedThriftFormats[contentType]; !ok {
+		http.Error(w, fmt.Sprintf("Unsupported content type: %v", contentType), http.StatusBadRequest)
+		return
+	}
+
+	batch := &jaeger.Batch{}
+	if err := thrift.NewTDeserializer().Read(batch, bodyBytes); err != nil {
+		http.Error(w, fmt.Sprintf("Unable to process request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The batch may be queued after the response is sent, so it must not be bound to
+	// the context of the request.
+	if err := jr.consumeBatch(context.Background(), batch); err != nil {
+		if consumererror.IsResourceExhausted(err) {
+			receiver.WriteRetryLater(w, err)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Cannot submit Jaeger batch: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveSamplingStrategy returns the sampling strategy of the service given by the
+// "service" query parameter in JSON, like the sampling endpoint of the Jaeger agent.
+func (jr *jReceiver) serveSamplingStrategy(w http.ResponseWriter, r *http.Request) {
+	services := r.URL.Query()["service"]
+	if len(services) != 1 {
+		http.Error(w, "'service' parameter must be provided once", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := jr.GetSamplingStrategy(services[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot get sampling strategy: %v", err), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot marshal sampling strategy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,356 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaegerreceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jaegertracing/jaeger/thrift-gen/baggage"
+	"github.com/jaegertracing/jaeger/thrift-gen/sampling"
+)
+
+const (
+	samplingTypeProbabilistic = "probabilistic"
+	samplingTypeRateLimiting  = "ratelimiting"
+
+	// defaultSamplingProbability is the probability used by the Jaeger collector when
+	// the strategies file does not set a default strategy.
+	defaultSamplingProbability = 0.001
+
+	defaultStrategiesReloadInterval = 10 * time.Second
+)
+
+// strategiesFile is the JSON format of the sampling strategies file, the same one
+// used by the static strategy store of the Jaeger collector.
+type strategiesFile struct {
+	DefaultStrategy   *defaultStrategy   `json:"default_strategy"`
+	ServiceStrategies []*serviceStrategy `json:"service_strategies"`
+}
+
+type strategy struct {
+	Type  string  `json:"type"`
+	Param float64 `json:"param"`
+}
+
+type operationStrategy struct {
+	strategy
+	Operation string `json:"operation"`
+}
+
+type defaultStrategy struct {
+	strategy
+	OperationStrategies []*operationStrategy `json:"operation_strategies"`
+}
+
+type serviceStrategy struct {
+	defaultStrategy
+	Service string `json:"service"`
+}
+
+// baggageRestrictionsFile is the JSON format of the baggage restrictions file. The
+// default restrictions are returned for the services without restrictions of their own.
+type baggageRestrictionsFile struct {
+	DefaultRestrictions []*baggageRestriction  `json:"default_restrictions"`
+	ServiceRestrictions []*serviceRestrictions `json:"service_restrictions"`
+}
+
+type baggageRestriction struct {
+	BaggageKey     string `json:"baggage_key"`
+	MaxValueLength int32  `json:"max_value_length"`
+}
+
+type serviceRestrictions struct {
+	Service      string                `json:"service"`
+	Restrictions []*baggageRestriction `json:"restrictions"`
+}
+
+// strategyStore serves the sampling strategies and baggage restrictions read from
+// their files, which are read again whenever they change.
+type strategyStore struct {
+	strategiesFile   string
+	restrictionsFile string
+
+	mu                  sync.RWMutex
+	defaultStrategy     *sampling.SamplingStrategyResponse
+	serviceStrategies   map[string]*sampling.SamplingStrategyResponse
+	defaultRestrictions []*baggage.BaggageRestriction
+	serviceRestrictions map[string][]*baggage.BaggageRestriction
+
+	strategiesModTime   time.Time
+	restrictionsModTime time.Time
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// newStrategyStore creates a store that reads the given files, any of them can be
+// empty in which case the store returns empty responses for it. It returns an error
+// if any of the files cannot be read or is invalid.
+func newStrategyStore(strategiesFile, restrictionsFile string) (*strategyStore, error) {
+	s := &strategyStore{
+		strategiesFile:   strategiesFile,
+		restrictionsFile: restrictionsFile,
+		done:             make(chan struct{}),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// start reads the files again on every interval, until stop is called. Files that
+// fail to be read keep the previous strategies or restrictions until fixed.
+func (s *strategyStore) start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStrategiesReloadInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = s.reload()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *strategyStore) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// getSamplingStrategy returns the strategy of the given service or the default one.
+func (s *strategyStore) getSamplingStrategy(serviceName string) *sampling.SamplingStrategyResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if strategy, ok := s.serviceStrategies[serviceName]; ok {
+		return strategy
+	}
+	if s.defaultStrategy != nil {
+		return s.defaultStrategy
+	}
+	return &sampling.SamplingStrategyResponse{}
+}
+
+// getBaggageRestrictions returns the restrictions of the given service or the default ones.
+func (s *strategyStore) getBaggageRestrictions(serviceName string) []*baggage.BaggageRestriction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if restrictions, ok := s.serviceRestrictions[serviceName]; ok {
+		return restrictions
+	}
+	return s.defaultRestrictions
+}
+
+// reload reads again the files that were modified since they were last read. A file
+// that fails to be read does not prevent the other one from being read.
+func (s *strategyStore) reload() error {
+	serr := s.reloadStrategies()
+	rerr := s.reloadRestrictions()
+	if serr != nil {
+		return serr
+	}
+	return rerr
+}
+
+func (s *strategyStore) reloadStrategies() error {
+	modTime, changed, err := fileChanged(s.strategiesFile, s.strategiesModTime)
+	if err != nil || !changed {
+		return err
+	}
+	def, services, err := loadStrategies(s.strategiesFile)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.defaultStrategy, s.serviceStrategies, s.strategiesModTime = def, services, modTime
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *strategyStore) reloadRestrictions() error {
+	modTime, changed, err := fileChanged(s.restrictionsFile, s.restrictionsModTime)
+	if err != nil || !changed {
+		return err
+	}
+	def, services, err := loadBaggageRestrictions(s.restrictionsFile)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.defaultRestrictions, s.serviceRestrictions, s.restrictionsModTime = def, services, modTime
+	s.mu.Unlock()
+	return nil
+}
+
+func fileChanged(path string, lastModTime time.Time) (time.Time, bool, error) {
+	if path == "" {
+		return time.Time{}, false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return info.ModTime(), !info.ModTime().Equal(lastModTime), nil
+}
+
+func loadStrategies(path string) (*sampling.SamplingStrategyResponse, map[string]*sampling.SamplingStrategyResponse, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file strategiesFile
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse sampling strategies file %q: %v", path, err)
+	}
+
+	def := &sampling.SamplingStrategyResponse{
+		StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+		ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: defaultSamplingProbability},
+	}
+	if file.DefaultStrategy != nil {
+		if def, err = toSamplingStrategyResponse(file.DefaultStrategy, nil); err != nil {
+			return nil, nil, fmt.Errorf("invalid default strategy in %q: %v", path, err)
+		}
+	}
+
+	services := make(map[string]*sampling.SamplingStrategyResponse, len(file.ServiceStrategies))
+	for _, service := range file.ServiceStrategies {
+		if service.Service == "" {
+			return nil, nil, fmt.Errorf("service strategy without service name in %q", path)
+		}
+		resp, err := toSamplingStrategyResponse(&service.defaultStrategy, def)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid strategy of service %q in %q: %v", service.Service, path, err)
+		}
+		services[service.Service] = resp
+	}
+	return def, services, nil
+}
+
+// toSamplingStrategyResponse converts a strategy of the file, the operation strategies
+// are only returned for probabilistic strategies or, for rate limiting strategies, if
+// the default strategy is probabilistic, in which case its probability is the default
+// one of the operations.
+func toSamplingStrategyResponse(s *defaultStrategy, def *sampling.SamplingStrategyResponse) (*sampling.SamplingStrategyResponse, error) {
+	resp := &sampling.SamplingStrategyResponse{}
+	switch s.Type {
+	case samplingTypeProbabilistic:
+		if s.Param < 0 || s.Param > 1 {
+			return nil, fmt.Errorf("probabilistic param must be between 0 and 1, got %v", s.Param)
+		}
+		resp.StrategyType = sampling.SamplingStrategyType_PROBABILISTIC
+		resp.ProbabilisticSampling = &sampling.ProbabilisticSamplingStrategy{SamplingRate: s.Param}
+	case samplingTypeRateLimiting:
+		if s.Param < 0 || s.Param > math.MaxInt16 {
+			return nil, fmt.Errorf("ratelimiting param must be between 0 and %d, got %v", math.MaxInt16, s.Param)
+		}
+		resp.StrategyType = sampling.SamplingStrategyType_RATE_LIMITING
+		resp.RateLimitingSampling = &sampling.RateLimitingSamplingStrategy{MaxTracesPerSecond: int16(s.Param)}
+	default:
+		return nil, fmt.Errorf("unknown strategy type %q", s.Type)
+	}
+
+	if len(s.OperationStrategies) == 0 {
+		return resp, nil
+	}
+
+	var defaultProbability float64
+	switch {
+	case resp.ProbabilisticSampling != nil:
+		defaultProbability = resp.ProbabilisticSampling.SamplingRate
+	case def != nil && def.ProbabilisticSampling != nil:
+		defaultProbability = def.ProbabilisticSampling.SamplingRate
+	default:
+		return resp, nil
+	}
+
+	operations := &sampling.PerOperationSamplingStrategies{
+		DefaultSamplingProbability: defaultProbability,
+	}
+	for _, op := range s.OperationStrategies {
+		// Per operation strategies can only be probabilistic.
+		if op.Type != samplingTypeProbabilistic {
+			continue
+		}
+		if op.Param < 0 || op.Param > 1 {
+			return nil, fmt.Errorf("probabilistic param of operation %q must be between 0 and 1, got %v", op.Operation, op.Param)
+		}
+		operations.PerOperationStrategies = append(operations.PerOperationStrategies, &sampling.OperationSamplingStrategy{
+			Operation:             op.Operation,
+			ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: op.Param},
+		})
+	}
+	resp.OperationSampling = operations
+	return resp, nil
+}
+
+func loadBaggageRestrictions(path string) ([]*baggage.BaggageRestriction, map[string][]*baggage.BaggageRestriction, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file baggageRestrictionsFile
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse baggage restrictions file %q: %v", path, err)
+	}
+
+	def, err := toBaggageRestrictions(file.DefaultRestrictions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid default restrictions in %q: %v", path, err)
+	}
+	services := make(map[string][]*baggage.BaggageRestriction, len(file.ServiceRestrictions))
+	for _, service := range file.ServiceRestrictions {
+		if service.Service == "" {
+			return nil, nil, fmt.Errorf("service restrictions without service name in %q", path)
+		}
+		restrictions, err := toBaggageRestrictions(service.Restrictions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid restrictions of service %q in %q: %v", service.Service, path, err)
+		}
+		services[service.Service] = restrictions
+	}
+	return def, services, nil
+}
+
+func toBaggageRestrictions(restrictions []*baggageRestriction) ([]*baggage.BaggageRestriction, error) {
+	res := make([]*baggage.BaggageRestriction, 0, len(restrictions))
+	for _, r := range restrictions {
+		if r.BaggageKey == "" {
+			return nil, fmt.Errorf("baggage restriction without baggage key")
+		}
+		if r.MaxValueLength < 0 {
+			return nil, fmt.Errorf("max value length of baggage key %q must not be negative", r.BaggageKey)
+		}
+		res = append(res, &baggage.BaggageRestriction{
+			BaggageKey:     r.BaggageKey,
+			MaxValueLength: r.MaxValueLength,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaegerreceiver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jaegertracing/jaeger/thrift-gen/baggage"
+	"github.com/jaegertracing/jaeger/thrift-gen/sampling"
+)
+
+func TestStrategyStore_SamplingStrategies(t *testing.T) {
+	store, err := newStrategyStore("./testdata/strategies.json", "")
+	if err != nil {
+		t.Fatalf("newStrategyStore() = %v", err)
+	}
+
+	tests := []struct {
+		service string
+		want    *sampling.SamplingStrategyResponse
+	}{
+		{
+			service: "foo",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+				ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0.8},
+				OperationSampling: &sampling.PerOperationSamplingStrategies{
+					DefaultSamplingProbability: 0.8,
+					PerOperationStrategies: []*sampling.OperationSamplingStrategy{
+						{Operation: "op1", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0.2}},
+					},
+				},
+			},
+		},
+		{
+			service: "bar",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:         sampling.SamplingStrategyType_RATE_LIMITING,
+				RateLimitingSampling: &sampling.RateLimitingSamplingStrategy{MaxTracesPerSecond: 5},
+			},
+		},
+		{
+			service: "unknown",
+			want: &sampling.SamplingStrategyResponse{
+				StrategyType:          sampling.SamplingStrategyType_PROBABILISTIC,
+				ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0.5},
+				OperationSampling: &sampling.PerOperationSamplingStrategies{
+					DefaultSamplingProbability: 0.5,
+					PerOperationStrategies: []*sampling.OperationSamplingStrategy{
+						{Operation: "/health", ProbabilisticSampling: &sampling.ProbabilisticSamplingStrategy{SamplingRate: 0}},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			if got := store.getSamplingStrategy(tt.service); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getSamplingStrategy(%q) = %+v, want %+v", tt.service, got, tt.want)
+			}
+		})
+	}
+
+	if got := store.getBaggageRestrictions("foo"); got != nil {
+		t.Errorf("getBaggageRestrictions() without file = %v, want nil", got)
+	}
+}
+
+func TestStrategyStore_BaggageRestrictions(t *testing.T) {
+	store, err := newStrategyStore("", "./testdata/baggage_restrictions.json")
+	if err != nil {
+		t.Fatalf("newStrategyStore() = %v", err)
+	}
+
+	want := []*baggage.BaggageRestriction{{BaggageKey: "user", MaxValueLength: 16}}
+	if got := store.getBaggageRestrictions("foo"); !reflect.DeepEqual(got, want) {
+		t.Errorf("getBaggageRestrictions(foo) = %v, want %v", got, want)
+	}
+	want = []*baggage.BaggageRestriction{{BaggageKey: "tenant", MaxValueLength: 32}}
+	if got := store.getBaggageRestrictions("bar"); !reflect.DeepEqual(got, want) {
+		t.Errorf("getBaggageRestrictions(bar) = %v, want %v", got, want)
+	}
+
+	if got := store.getSamplingStrategy("foo"); !reflect.DeepEqual(got, &sampling.SamplingStrategyResponse{}) {
+		t.Errorf("getSamplingStrategy() without file = %v, want empty response", got)
+	}
+}
+
+func TestStrategyStore_InvalidFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		strategies   string
+		restrictions string
+	}{
+		{name: "missing_file", strategies: "./testdata/missing.json"},
+		{name: "not_json", strategies: `{"default_strategy": `},
+		{name: "unknown_type", strategies: `{"default_strategy": {"type": "always", "param": 1}}`},
+		{name: "probability_out_of_range", strategies: `{"service_strategies": [{"service": "foo", "type": "probabilistic", "param": 1.5}]}`},
+		{name: "rate_out_of_range", strategies: `{"service_strategies": [{"service": "foo", "type": "ratelimiting", "param": 100000}]}`},
+		{name: "no_service", strategies: `{"service_strategies": [{"type": "probabilistic", "param": 0.5}]}`},
+		{name: "no_baggage_key", restrictions: `{"default_restrictions": [{"max_value_length": 10}]}`},
+		{name: "negative_length", restrictions: `{"default_restrictions": [{"baggage_key": "k", "max_value_length": -1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategies, restrictions := tt.strategies, tt.restrictions
+			if strategies != "" && strategies[0] == '{' {
+				strategies = writeTempFile(t, strategies)
+				defer os.Remove(strategies)
+			}
+			if restrictions != "" {
+				restrictions = writeTempFile(t, restrictions)
+				defer os.Remove(restrictions)
+			}
+			if _, err := newStrategyStore(strategies, restrictions); err == nil {
+				t.Error("newStrategyStore() = nil, want error")
+			}
+		})
+	}
+}
+
+func TestStrategyStore_Reload(t *testing.T) {
+	path := writeTempFile(t, `{"default_strategy": {"type": "probabilistic", "param": 0.1}}`)
+	defer os.Remove(path)
+
+	store, err := newStrategyStore(path, "")
+	if err != nil {
+		t.Fatalf("newStrategyStore() = %v", err)
+	}
+	store.start(10 * time.Millisecond)
+	defer store.stop()
+
+	// An invalid file keeps the previous strategies.
+	writeFile(t, path, `{"default_strategy": {"type": "probabilistic", "param": 2}}`, time.Now().Add(time.Second))
+	time.Sleep(50 * time.Millisecond)
+	if got := store.getSamplingStrategy("foo").ProbabilisticSampling.SamplingRate; got != 0.1 {
+		t.Fatalf("SamplingRate after invalid change = %v, want 0.1", got)
+	}
+
+	writeFile(t, path, `{"default_strategy": {"type": "probabilistic", "param": 0.2}}`, time.Now().Add(2*time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for store.getSamplingStrategy("foo").ProbabilisticSampling.SamplingRate != 0.2 {
+		if time.Now().After(deadline) {
+			t.Fatal("strategies were not reloaded after the file changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeSamplingStrategy(t *testing.T) {
+	jr, err := New(context.Background(), &Configuration{SamplingStrategiesFile: "./testdata/strategies.json"}, nil)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	jr.(*jReceiver).serveSamplingStrategy(rec, httptest.NewRequest(http.MethodGet, "/api/sampling?service=bar", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got sampling.SamplingStrategyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal response %q: %v", rec.Body.String(), err)
+	}
+	if got.StrategyType != sampling.SamplingStrategyType_RATE_LIMITING || got.RateLimitingSampling.MaxTracesPerSecond != 5 {
+		t.Errorf("response = %+v, want rate limiting of 5 traces per second", got)
+	}
+
+	rec = httptest.NewRecorder()
+	jr.(*jReceiver).serveSamplingStrategy(rec, httptest.NewRequest(http.MethodGet, "/api/sampling", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status without service = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "jaeger-strategies")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	f.Close()
+	writeFile(t, f.Name(), content, time.Now())
+	return f.Name()
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+	// Set the modification time explicitly since the resolution of the file system
+	// may not tell apart quick successive writes.
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to set modification time of %q: %v", path, err)
+	}
+}
@@ -22,12 +22,12 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	agentapp "github.com/jaegertracing/jaeger/cmd/agent/app"
 	"github.com/jaegertracing/jaeger/cmd/agent/app/configmanager"
 	"github.com/jaegertracing/jaeger/cmd/agent/app/reporter"
-	"github.com/jaegertracing/jaeger/cmd/collector/app"
 	"github.com/jaegertracing/jaeger/thrift-gen/baggage"
 	"github.com/jaegertracing/jaeger/thrift-gen/jaeger"
 	"github.com/jaegertracing/jaeger/thrift-gen/sampling"
@@ -38,6 +38,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/observability"
 	"github.com/census-instrumentation/opencensus-service/receiver"
 	jaegertranslator "github.com/census-instrumentation/opencensus-service/translator/trace/jaeger"
@@ -52,6 +53,16 @@ type Configuration struct {
 	AgentPort              int `mapstructure:"agent_port"`
 	AgentCompactThriftPort int `mapstructure:"agent_compact_thrift_port"`
 	AgentBinaryThriftPort  int `mapstructure:"agent_binary_thrift_port"`
+
+	// SamplingStrategiesFile is the JSON file with the sampling strategies served to
+	// the Jaeger clients, in the format of the Jaeger collector strategies file.
+	SamplingStrategiesFile string `mapstructure:"sampling_strategies_file"`
+	// BaggageRestrictionsFile is the JSON file with the baggage restrictions served
+	// to the Jaeger clients.
+	BaggageRestrictionsFile string `mapstructure:"baggage_restrictions_file"`
+	// StrategiesReloadInterval is how often the files above are checked for changes,
+	// by default every 10 seconds.
+	StrategiesReloadInterval time.Duration `mapstructure:"strategies_reload_interval"`
 }
 
 // Receiver type is used to receive spans that were originally intended to be sent to Jaeger.
@@ -73,6 +84,8 @@ type jReceiver struct {
 	tchannel        *tchannel.Channel
 	collectorServer *http.Server
 
+	strategies *strategyStore
+
 	defaultAgentCtx context.Context
 }
 
@@ -95,12 +108,22 @@ const (
 )
 
 // New creates a TraceReceiver that receives traffic as a collector with both Thrift and HTTP transports.
+// The sampling strategies and baggage restrictions files of the configuration, if any,
+// are read at creation and an error is returned if they are invalid.
 func New(ctx context.Context, config *Configuration, nextConsumer consumer.TraceConsumer) (receiver.TraceReceiver, error) {
-	return &jReceiver{
+	jr := &jReceiver{
 		config:          config,
 		defaultAgentCtx: observability.ContextWithReceiverName(context.Background(), "jaeger-agent"),
 		nextConsumer:    nextConsumer,
-	}, nil
+	}
+	if config != nil && (config.SamplingStrategiesFile != "" || config.BaggageRestrictionsFile != "") {
+		strategies, err := newStrategyStore(config.SamplingStrategiesFile, config.BaggageRestrictionsFile)
+		if err != nil {
+			return nil, err
+		}
+		jr.strategies = strategies
+	}
+	return jr, nil
 }
 
 var _ receiver.TraceReceiver = (*jReceiver)(nil)
@@ -187,6 +210,10 @@ func (jr *jReceiver) StartTraceReception(ctx context.Context, asyncErrorChan cha
 			return
 		}
 
+		if jr.strategies != nil {
+			jr.strategies.start(jr.config.StrategiesReloadInterval)
+		}
+
 		err = nil
 	})
 	return err
@@ -219,6 +246,9 @@ func (jr *jReceiver) stopTraceReceptionLocked(ctx context.Context) error {
 			jr.tchannel.Close()
 			jr.tchannel = nil
 		}
+		if jr.strategies != nil {
+			jr.strategies.stop()
+		}
 		if len(errs) == 0 {
 			err = nil
 			return
@@ -238,28 +268,39 @@ const collectorReceiverTagValue = "jaeger-collector"
 
 func (jr *jReceiver) SubmitBatches(ctx thrift.Context, batches []*jaeger.Batch) ([]*jaeger.BatchSubmitResponse, error) {
 	jbsr := make([]*jaeger.BatchSubmitResponse, 0, len(batches))
-	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, collectorReceiverTagValue)
 
 	for _, batch := range batches {
-		td, err := jaegertranslator.ThriftBatchToOCProto(batch)
-		// TODO: (@odeke-em) add this error for Jaeger observability
-		ok := false
-
-		if err == nil {
-			ok = true
-			td.SourceFormat = "jaeger"
-			jr.nextConsumer.ConsumeTraceData(ctx, td)
-			// We MUST unconditionally record metrics from this reception.
-			observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(batch.Spans), len(batch.Spans)-len(td.Spans))
-		}
-
+		// TODO: (@odeke-em) add the translation errors for Jaeger observability
+		err := jr.consumeBatch(ctx, batch)
+		// Refused batches are not Ok so that the client sends them again.
 		jbsr = append(jbsr, &jaeger.BatchSubmitResponse{
-			Ok: ok,
+			Ok: err == nil,
 		})
 	}
 	return jbsr, nil
 }
 
+// consumeBatch sends a batch received by the collector to the next consumer. It returns
+// an error if the batch could not be translated, or the consumererror.ResourceExhausted
+// error with which the next consumer refused it.
+func (jr *jReceiver) consumeBatch(ctx context.Context, batch *jaeger.Batch) error {
+	td, err := jaegertranslator.ThriftBatchToOCProto(batch)
+	if err != nil {
+		return err
+	}
+
+	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, collectorReceiverTagValue)
+	td.SourceFormat = "jaeger"
+	err = jr.nextConsumer.ConsumeTraceData(ctx, td)
+	if consumererror.IsResourceExhausted(err) {
+		observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(batch.Spans), len(batch.Spans))
+		return err
+	}
+	// We MUST unconditionally record metrics from this reception.
+	observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(batch.Spans), len(batch.Spans)-len(td.Spans))
+	return nil
+}
+
 var _ reporter.Reporter = (*jReceiver)(nil)
 var _ agentapp.CollectorProxy = (*jReceiver)(nil)
 
@@ -292,12 +333,39 @@ func (jr *jReceiver) GetManager() configmanager.ClientConfigManager {
 	return jr
 }
 
+// GetSamplingStrategy implements cmd/agent/configmanager.ClientConfigManager and it
+// returns the strategy of the service from the sampling strategies file, if any.
 func (jr *jReceiver) GetSamplingStrategy(serviceName string) (*sampling.SamplingStrategyResponse, error) {
-	return &sampling.SamplingStrategyResponse{}, nil
+	if jr.strategies == nil {
+		return &sampling.SamplingStrategyResponse{}, nil
+	}
+	return jr.strategies.getSamplingStrategy(serviceName), nil
 }
 
+// GetBaggageRestrictions implements cmd/agent/configmanager.ClientConfigManager and it
+// returns the restrictions of the service from the baggage restrictions file, if any.
 func (jr *jReceiver) GetBaggageRestrictions(serviceName string) ([]*baggage.BaggageRestriction, error) {
-	return nil, nil
+	if jr.strategies == nil {
+		return nil, nil
+	}
+	return jr.strategies.getBaggageRestrictions(serviceName), nil
+}
+
+// collectorConfigManager serves the sampling strategies and baggage restrictions
+// of the receiver over the TChannel endpoint of the collector.
+type collectorConfigManager struct {
+	jr *jReceiver
+}
+
+var _ sampling.TChanSamplingManager = (*collectorConfigManager)(nil)
+var _ baggage.TChanBaggageRestrictionManager = (*collectorConfigManager)(nil)
+
+func (m *collectorConfigManager) GetSamplingStrategy(ctx thrift.Context, serviceName string) (*sampling.SamplingStrategyResponse, error) {
+	return m.jr.GetSamplingStrategy(serviceName)
+}
+
+func (m *collectorConfigManager) GetBaggageRestrictions(ctx thrift.Context, serviceName string) ([]*baggage.BaggageRestriction, error) {
+	return m.jr.GetBaggageRestrictions(serviceName)
 }
 
 func (jr *jReceiver) startAgent() error {
@@ -350,6 +418,8 @@ func (jr *jReceiver) startCollector() error {
 
 	server := thrift.NewServer(tch)
 	server.Register(jaeger.NewTChanCollectorServer(jr))
+	server.Register(sampling.NewTChanSamplingManagerServer(&collectorConfigManager{jr}))
+	server.Register(baggage.NewTChanBaggageRestrictionManagerServer(&collectorConfigManager{jr}))
 
 	taddr := jr.tchannelAddr()
 	tln, terr := net.Listen("tcp", taddr)
@@ -369,8 +439,8 @@ func (jr *jReceiver) startCollector() error {
 	}
 
 	nr := mux.NewRouter()
-	apiHandler := app.NewAPIHandler(jr)
-	apiHandler.RegisterRoutes(nr)
+	nr.HandleFunc("/api/traces", jr.serveTraces).Methods(http.MethodPost)
+	nr.HandleFunc("/api/sampling", jr.serveSamplingStrategy).Methods(http.MethodGet)
 	jr.collectorServer = &http.Server{Handler: nr}
 	go func() {
 		_ = jr.collectorServer.Serve(cln)
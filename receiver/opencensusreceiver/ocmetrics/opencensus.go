@@ -28,8 +28,10 @@ import (
 	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
 // Receiver is the type used to handle metrics from OpenCensus exporters.
@@ -63,8 +65,17 @@ func (ocr *Receiver) Export(mes agentmetricspb.MetricsService_ExportServer) erro
 	// The bundler will receive batches of metrics i.e. []*metricspb.Metric
 	// We need to ensure that it propagates the receiver name as a tag
 	ctxWithReceiverName := observability.ContextWithReceiverName(mes.Context(), receiverTagValue)
+	// refused receives the error if the next consumer refuses the metrics because it is
+	// overloaded, so that the client can be told to retry.
+	refused := make(chan error, 1)
 	metricsBundler := bundler.NewBundler((*data.MetricsData)(nil), func(payload interface{}) {
-		ocr.batchMetricExporting(ctxWithReceiverName, payload)
+		if err := ocr.batchMetricExporting(ctxWithReceiverName, payload); err != nil {
+			select {
+			case refused <- err:
+			default:
+				// The stream already has a refusal to report.
+			}
+		}
 	})
 
 	metricBufferPeriod := ocr.metricBufferPeriod
@@ -95,6 +106,14 @@ func (ocr *Receiver) Export(mes agentmetricspb.MetricsService_ExportServer) erro
 	var resource *resourcepb.Resource
 	// Now that we've got the first message with a Node, we can start to receive streamed up metrics.
 	for {
+		select {
+		case err := <-refused:
+			// The metrics are exported asynchronously, so the client is told at its next
+			// message and must send again the metrics since the refused ones.
+			return receiver.GRPCRetryLater(err)
+		default:
+		}
+
 		// If a Node has been sent from downstream, save and use it.
 		if recv.Node != nil {
 			lastNonNilNode = recv.Node
@@ -123,10 +142,12 @@ func processReceivedMetrics(ni *commonpb.Node, resource *resourcepb.Resource, me
 	}
 }
 
-func (ocr *Receiver) batchMetricExporting(longLivedRPCCtx context.Context, payload interface{}) {
+// batchMetricExporting sends the bundled metrics to the next consumer. It returns the
+// consumererror.ResourceExhausted error with which the next consumer refused them, if any.
+func (ocr *Receiver) batchMetricExporting(longLivedRPCCtx context.Context, payload interface{}) error {
 	mds := payload.([]*data.MetricsData)
 	if len(mds) == 0 {
-		return
+		return nil
 	}
 
 	// Trace this method
@@ -141,12 +162,18 @@ func (ocr *Receiver) batchMetricExporting(longLivedRPCCtx context.Context, paylo
 	observability.SetParentLink(longLivedRPCCtx, span)
 
 	nMetrics := int64(0)
+	var refusedErr error
 	for _, md := range mds {
-		ocr.nextConsumer.ConsumeMetricsData(ctx, *md)
+		err := ocr.nextConsumer.ConsumeMetricsData(ctx, *md)
+		if consumererror.IsResourceExhausted(err) && refusedErr == nil {
+			refusedErr = err
+			span.SetStatus(trace.Status{Code: trace.StatusCodeResourceExhausted, Message: err.Error()})
+		}
 		nMetrics += int64(len(md.Metrics))
 	}
 
 	span.Annotate([]trace.Attribute{
 		trace.Int64Attribute("num_metrics", nMetrics),
 	}, "")
+	return refusedErr
 }
@@ -0,0 +1,144 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package octrace
+
+import (
+	"errors"
+	"io"
+	"path"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// TraceConfigRule selects the TraceConfig sent to the libraries of the services whose
+// name matches ServiceName, a pattern with the syntax of path.Match, e.g.: "frontend-*".
+type TraceConfigRule struct {
+	ServiceName string
+	Config      *tracepb.TraceConfig
+}
+
+// traceConfigs holds the TraceConfig sent to the libraries connected to the Config
+// stream. The changed channel is closed, and replaced, whenever they are updated.
+type traceConfigs struct {
+	defaultConfig *tracepb.TraceConfig
+	rules         []TraceConfigRule
+	changed       chan struct{}
+}
+
+// SetTraceConfig replaces the TraceConfig sent to the libraries connected to the
+// Config stream, the ones whose config changes get the new one right away. The first
+// rule matching the service name of a library is used, or defaultConfig if none
+// matches. A nil TraceConfig sends nothing, so the libraries keep their current one.
+func (ocr *Receiver) SetTraceConfig(defaultConfig *tracepb.TraceConfig, rules []TraceConfigRule) {
+	ocr.configMu.Lock()
+	defer ocr.configMu.Unlock()
+
+	close(ocr.configs.changed)
+	ocr.configs = &traceConfigs{
+		defaultConfig: defaultConfig,
+		rules:         rules,
+		changed:       make(chan struct{}),
+	}
+}
+
+// traceConfigFor returns the TraceConfig for the given Node, and a channel closed
+// when it may have changed.
+func (ocr *Receiver) traceConfigFor(node *commonpb.Node) (*tracepb.TraceConfig, <-chan struct{}) {
+	ocr.configMu.Lock()
+	configs := ocr.configs
+	ocr.configMu.Unlock()
+
+	serviceName := node.GetServiceInfo().GetName()
+	for _, rule := range configs.rules {
+		if matched, _ := path.Match(rule.ServiceName, serviceName); matched {
+			return rule.Config, configs.changed
+		}
+	}
+	return configs.defaultConfig, configs.changed
+}
+
+var (
+	errConfigProtocolViolation = errors.New("protocol violation: Config's first message must have a Node")
+	// errConfigStopped tells the libraries to connect again, to the receiver that
+	// replaced this one if any.
+	errConfigStopped = status.Error(codes.Unavailable, "the receiver was stopped")
+)
+
+// Config is the gRPC method that keeps the libraries updated with the TraceConfig
+// of their service. It sends the TraceConfig once the library identifies itself,
+// and again each time it changes, until the library closes the stream.
+func (ocr *Receiver) Config(tcs agenttracepb.TraceService_ConfigServer) error {
+	recv, err := tcs.Recv()
+	if err != nil {
+		return err
+	}
+	if recv.Node == nil {
+		return errConfigProtocolViolation
+	}
+	node := recv.Node
+
+	// The library sends its current config after applying each update, a Node sent
+	// then replaces the previous one.
+	recvs := make(chan *agenttracepb.CurrentLibraryConfig)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			recv, err := tcs.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case recvs <- recv:
+			case <-tcs.Context().Done():
+				return
+			}
+		}
+	}()
+
+	var sent *tracepb.TraceConfig
+	for {
+		cfg, changed := ocr.traceConfigFor(node)
+		if cfg != nil && !proto.Equal(cfg, sent) {
+			if err := tcs.Send(&agenttracepb.UpdatedLibraryConfig{Config: cfg}); err != nil {
+				return err
+			}
+			sent = cfg
+		}
+
+		select {
+		case <-changed:
+		case recv := <-recvs:
+			if recv.Node != nil {
+				node = recv.Node
+			}
+		case err := <-recvErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-tcs.Context().Done():
+			return tcs.Context().Err()
+		case <-ocr.stopped:
+			return errConfigStopped
+		}
+	}
+}
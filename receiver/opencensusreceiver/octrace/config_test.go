@@ -0,0 +1,156 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package octrace
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+func TestConfig_pushesTraceConfigOfService(t *testing.T) {
+	defaultConfig := probabilityConfig(0.1)
+	rules := []TraceConfigRule{{ServiceName: "frontend-*", Config: probabilityConfig(1)}}
+	oci, port, doneFn := ocReceiverOnGRPCServer(t, newSpanAppender(), WithTraceConfig(defaultConfig, rules))
+	defer doneFn()
+
+	frontend, frontendDoneFn := makeConfigClient(t, port, "frontend-web")
+	defer frontendDoneFn()
+	backend, backendDoneFn := makeConfigClient(t, port, "backend")
+	defer backendDoneFn()
+
+	expectConfig(t, frontend, probabilityConfig(1))
+	expectConfig(t, backend, defaultConfig)
+
+	// Only the libraries whose config changes get an update.
+	oci.SetTraceConfig(defaultConfig, []TraceConfigRule{{ServiceName: "frontend-*", Config: probabilityConfig(0.5)}})
+	expectConfig(t, frontend, probabilityConfig(0.5))
+
+	oci.SetTraceConfig(probabilityConfig(0.2), nil)
+	expectConfig(t, backend, probabilityConfig(0.2))
+	expectConfig(t, frontend, probabilityConfig(0.2))
+}
+
+func TestConfig_noTraceConfig(t *testing.T) {
+	oci, port, doneFn := ocReceiverOnGRPCServer(t, newSpanAppender())
+	defer doneFn()
+
+	client, clientDoneFn := makeConfigClient(t, port, "frontend")
+	defer clientDoneFn()
+
+	// Nothing is sent until there is a config for the library.
+	oci.SetTraceConfig(nil, []TraceConfigRule{{ServiceName: "backend", Config: probabilityConfig(1)}})
+	oci.SetTraceConfig(nil, []TraceConfigRule{{ServiceName: "frontend", Config: probabilityConfig(0.3)}})
+	expectConfig(t, client, probabilityConfig(0.3))
+}
+
+func TestConfig_nodelessFirstMessage(t *testing.T) {
+	_, port, doneFn := ocReceiverOnGRPCServer(t, newSpanAppender())
+	defer doneFn()
+
+	client, clientDoneFn := makeConfigClient(t, port, "")
+	defer clientDoneFn()
+
+	_, err := client.Recv()
+	wantSubStr := "protocol violation: Config's first message must have a Node"
+	if err == nil || !strings.Contains(err.Error(), wantSubStr) {
+		t.Errorf("Recv() = %v, want error containing %q", err, wantSubStr)
+	}
+}
+
+func TestConfig_stop(t *testing.T) {
+	oci, port, doneFn := ocReceiverOnGRPCServer(t, newSpanAppender())
+	defer doneFn()
+
+	client, clientDoneFn := makeConfigClient(t, port, "frontend")
+	defer clientDoneFn()
+
+	oci.Stop()
+	if _, err := client.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv() after Stop = %v, want code %v", err, codes.Unavailable)
+	}
+}
+
+// makeConfigClient opens a Config stream whose first message has a Node of the given
+// service, or no Node if serviceName is empty.
+func makeConfigClient(t *testing.T, port int, serviceName string) (agenttracepb.TraceService_ConfigClient, func()) {
+	cc, err := grpc.Dial(fmt.Sprintf(":%d", port), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	client, err := agenttracepb.NewTraceServiceClient(cc).Config(context.Background())
+	if err != nil {
+		_ = cc.Close()
+		t.Fatalf("Failed to open the Config stream: %v", err)
+	}
+
+	first := &agenttracepb.CurrentLibraryConfig{}
+	if serviceName != "" {
+		first.Node = &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: serviceName}}
+	}
+	if err := client.Send(first); err != nil {
+		_ = cc.Close()
+		t.Fatalf("Failed to send the first message: %v", err)
+	}
+	return client, func() { _ = cc.Close() }
+}
+
+func expectConfig(t *testing.T, client agenttracepb.TraceService_ConfigClient, want *tracepb.TraceConfig) {
+	t.Helper()
+	type result struct {
+		resp *agenttracepb.UpdatedLibraryConfig
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := client.Recv()
+		done <- result{resp, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("Recv() = %v", r.err)
+		}
+		if !proto.Equal(r.resp.Config, want) {
+			t.Fatalf("Recv() config = %v, want %v", r.resp.Config, want)
+		}
+		// Acknowledge it like the libraries do.
+		if err := client.Send(&agenttracepb.CurrentLibraryConfig{Config: r.resp.Config}); err != nil {
+			t.Fatalf("Send() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for config %v", want)
+	}
+}
+
+func probabilityConfig(probability float64) *tracepb.TraceConfig {
+	return &tracepb.TraceConfig{
+		Sampler: &tracepb.TraceConfig_ProbabilitySampler{
+			ProbabilitySampler: &tracepb.ProbabilitySampler{SamplingProbability: probability},
+		},
+	}
+}
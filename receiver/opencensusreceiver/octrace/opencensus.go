@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"io"
+	"sync"
+	"sync/atomic"
 
 	"go.opencensus.io/trace"
 
@@ -25,8 +27,10 @@ import (
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
 	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
 const (
@@ -41,11 +45,27 @@ type Receiver struct {
 	numWorkers   int
 	workers      []*receiverWorker
 	messageChan  chan *traceDataWithCtx
+
+	// configMu guards configs, the TraceConfig sent on the Config stream.
+	configMu sync.Mutex
+	configs  *traceConfigs
+	// stopped is closed by Stop to end the Config streams.
+	stopped  chan struct{}
+	stopOnce sync.Once
 }
 
 type traceDataWithCtx struct {
 	data *data.TraceData
 	ctx  context.Context
+	// refused receives the error if the next consumer refuses the data because it is
+	// overloaded, so that Export can tell the client to retry.
+	refused chan<- error
+	// refusedSpans counts the spans of the stream refused by the next consumer, including
+	// those refused while an earlier refusal is still to be reported.
+	refusedSpans *int64
+	// pending is done once the data is exported, so Export can report a refusal of the
+	// last messages of a stream ended by the client.
+	pending *sync.WaitGroup
 }
 
 // New creates a new opencensus.Receiver reference.
@@ -59,6 +79,8 @@ func New(nextConsumer consumer.TraceConsumer, opts ...Option) (*Receiver, error)
 		nextConsumer: nextConsumer,
 		numWorkers:   defaultNumWorkers,
 		messageChan:  messageChan,
+		configs:      &traceConfigs{changed: make(chan struct{})},
+		stopped:      make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(ocr)
@@ -78,14 +100,6 @@ func New(nextConsumer consumer.TraceConsumer, opts ...Option) (*Receiver, error)
 
 var _ agenttracepb.TraceServiceServer = (*Receiver)(nil)
 
-var errUnimplemented = errors.New("unimplemented")
-
-// Config handles configuration messages.
-func (ocr *Receiver) Config(tcs agenttracepb.TraceService_ConfigServer) error {
-	// TODO: Implement when we define the config receiver/sender.
-	return errUnimplemented
-}
-
 var errTraceExportProtocolViolation = errors.New("protocol violation: Export's first message must have a Node")
 
 const receiverTagValue = "oc_trace"
@@ -109,8 +123,18 @@ func (ocr *Receiver) Export(tes agenttracepb.TraceService_ExportServer) error {
 
 	var lastNonNilNode *commonpb.Node
 	var resource *resourcepb.Resource
+	refused := make(chan error, 1)
+	var refusedSpans int64
+	var pending sync.WaitGroup
 	// Now that we've got the first message with a Node, we can start to receive streamed up spans.
 	for {
+		// The spans are exported asynchronously, so the client is told at its next
+		// message and must send again the spans since the refused ones, this message's
+		// included.
+		if err := ocr.reportRefusal(ctxWithReceiverName, refused, &refusedSpans, &pending, len(recv.Spans)); err != nil {
+			return err
+		}
+
 		// If a Node has been sent from downstream, save and use it.
 		if recv.Node != nil {
 			lastNonNilNode = recv.Node
@@ -129,12 +153,27 @@ func (ocr *Receiver) Export(tes agenttracepb.TraceService_ExportServer) error {
 			SourceFormat: "oc_trace",
 		}
 
-		ocr.messageChan <- &traceDataWithCtx{data: td, ctx: ctxWithReceiverName}
+		pending.Add(1)
+		ocr.messageChan <- &traceDataWithCtx{
+			data:         td,
+			ctx:          ctxWithReceiverName,
+			refused:      refused,
+			refusedSpans: &refusedSpans,
+			pending:      &pending,
+		}
 
 		observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(td.Spans), 0)
 
 		recv, err = tes.Recv()
 		if err != nil {
+			if err == io.EOF {
+				// The client ended the stream and waits for the response, which reports
+				// a refusal of its last messages too.
+				ocr.waitExported(&pending)
+			}
+			if rerr := ocr.reportRefusal(ctxWithReceiverName, refused, &refusedSpans, &pending, 0); rerr != nil {
+				return rerr
+			}
 			if err == io.EOF {
 				// Do not return EOF as an error so that grpc-gateway calls get an empty
 				// response with HTTP status code 200 rather than a 500 error with EOF.
@@ -145,13 +184,45 @@ func (ocr *Receiver) Export(tes agenttracepb.TraceService_ExportServer) error {
 	}
 }
 
-// Stop the receiver and its workers
-func (ocr *Receiver) Stop() {
-	for _, worker := range ocr.workers {
-		worker.stopListening()
+// reportRefusal returns the error telling the client to retry if the next consumer
+// refused spans of the stream, nil otherwise. The stream ends with the refusal, so the
+// spans refused by the next consumer, once the pending messages are exported, and the
+// unsent spans already received but not passed to it are all recorded as dropped.
+func (ocr *Receiver) reportRefusal(ctx context.Context, refused <-chan error, refusedSpans *int64, pending *sync.WaitGroup, unsent int) error {
+	select {
+	case err := <-refused:
+		ocr.waitExported(pending)
+		dropped := int(atomic.LoadInt64(refusedSpans)) + unsent
+		observability.RecordTraceReceiverMetrics(ctx, unsent, dropped)
+		return receiver.GRPCRetryLater(err)
+	default:
+		return nil
 	}
 }
 
+// waitExported waits until the messages of a stream are exported, or the receiver stops.
+func (ocr *Receiver) waitExported(pending *sync.WaitGroup) {
+	exported := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(exported)
+	}()
+	select {
+	case <-exported:
+	case <-ocr.stopped:
+	}
+}
+
+// Stop the receiver and its workers, it can be called more than once.
+func (ocr *Receiver) Stop() {
+	ocr.stopOnce.Do(func() {
+		close(ocr.stopped)
+		for _, worker := range ocr.workers {
+			worker.stopListening()
+		}
+	})
+}
+
 type receiverWorker struct {
 	receiver *Receiver
 	tes      agenttracepb.TraceService_ExportServer
@@ -169,7 +240,7 @@ func (rw *receiverWorker) listenOn(cn <-chan *traceDataWithCtx) {
 	for {
 		select {
 		case tdWithCtx := <-cn:
-			rw.export(tdWithCtx.ctx, tdWithCtx.data)
+			rw.export(tdWithCtx)
 		case <-rw.cancel:
 			return
 		}
@@ -180,7 +251,11 @@ func (rw *receiverWorker) stopListening() {
 	close(rw.cancel)
 }
 
-func (rw *receiverWorker) export(longLivedCtx context.Context, tracedata *data.TraceData) {
+func (rw *receiverWorker) export(tdWithCtx *traceDataWithCtx) {
+	if tdWithCtx.pending != nil {
+		defer tdWithCtx.pending.Done()
+	}
+	longLivedCtx, tracedata := tdWithCtx.ctx, tdWithCtx.data
 	if tracedata == nil {
 		return
 	}
@@ -200,7 +275,16 @@ func (rw *receiverWorker) export(longLivedCtx context.Context, tracedata *data.T
 	// If the starting RPC has a parent span, then add it as a parent link.
 	observability.SetParentLink(longLivedCtx, span)
 
-	rw.receiver.nextConsumer.ConsumeTraceData(ctx, *tracedata)
+	err := rw.receiver.nextConsumer.ConsumeTraceData(ctx, *tracedata)
+	if consumererror.IsResourceExhausted(err) {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeResourceExhausted, Message: err.Error()})
+		atomic.AddInt64(tdWithCtx.refusedSpans, int64(len(tracedata.Spans)))
+		select {
+		case tdWithCtx.refused <- err:
+		default:
+			// The stream already has a refusal to report, the spans are counted with it.
+		}
+	}
 
 	span.Annotate([]trace.Attribute{
 		trace.Int64Attribute("num_spans", int64(len(tracedata.Spans))),
@@ -31,15 +31,19 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"contrib.go.opencensus.io/exporter/ocagent"
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/internal"
 	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/observability/observabilitytest"
 	"go.opencensus.io/trace"
 	"go.opencensus.io/trace/tracestate"
 )
@@ -370,6 +374,129 @@ func TestExportProtocolViolations_nodelessFirstMessage(t *testing.T) {
 	close(testDone)
 }
 
+func TestExportRefusedData_resourceExhausted(t *testing.T) {
+	_, port, doneFn := ocReceiverOnGRPCServer(t, refusingTraceConsumer{})
+	defer doneFn()
+
+	traceClient, traceClientDoneFn, err := makeTraceServiceClient(port)
+	if err != nil {
+		t.Fatalf("Failed to create the gRPC TraceService_ExportClient: %v", err)
+	}
+	defer traceClientDoneFn()
+
+	req := &agenttracepb.ExportTraceServiceRequest{
+		Node:  &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{Pid: 1}},
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "refused"}}},
+	}
+	// The spans are exported asynchronously, so the refusal is reported on one of the
+	// following messages, after which the stream is torn down.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := traceClient.Send(req); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = traceClient.Recv()
+	if g, w := status.Code(err), codes.ResourceExhausted; g != w {
+		t.Errorf("Got code %v (%v), want %v", g, err, w)
+	}
+}
+
+func TestExportRefusedData_reportedWhenClientEndsStream(t *testing.T) {
+	_, port, doneFn := ocReceiverOnGRPCServer(t, refusingTraceConsumer{})
+	defer doneFn()
+
+	traceClient, traceClientDoneFn, err := makeTraceServiceClient(port)
+	if err != nil {
+		t.Fatalf("Failed to create the gRPC TraceService_ExportClient: %v", err)
+	}
+	defer traceClientDoneFn()
+
+	req := &agenttracepb.ExportTraceServiceRequest{
+		Node:  &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{Pid: 1}},
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "refused"}}},
+	}
+	if err := traceClient.Send(req); err != nil {
+		t.Fatalf("Failed to send the message: %v", err)
+	}
+	// There is no next message to report the refusal on, the response has to.
+	if err := traceClient.CloseSend(); err != nil {
+		t.Fatalf("Failed to end the stream: %v", err)
+	}
+
+	_, err = traceClient.Recv()
+	if g, w := status.Code(err), codes.ResourceExhausted; g != w {
+		t.Errorf("Got code %v (%v), want %v", g, err, w)
+	}
+}
+
+func TestExportRefusedData_allRefusedSpansDropped(t *testing.T) {
+	doneFn := observabilitytest.SetupRecordedMetricsTest()
+	defer doneFn()
+
+	const numMessages = 3
+	gated := &gatedRefusingTraceConsumer{called: make(chan struct{}, numMessages), release: make(chan struct{})}
+	_, port, doneReceiverFn := ocReceiverOnGRPCServer(t, gated)
+	defer doneReceiverFn()
+
+	traceClient, traceClientDoneFn, err := makeTraceServiceClient(port)
+	if err != nil {
+		t.Fatalf("Failed to create the gRPC TraceService_ExportClient: %v", err)
+	}
+	defer traceClientDoneFn()
+
+	req := &agenttracepb.ExportTraceServiceRequest{
+		Node:  &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{Pid: 1}},
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "refused"}}},
+	}
+	for i := 0; i < numMessages; i++ {
+		if err := traceClient.Send(req); err != nil {
+			t.Fatalf("Failed to send message #%d: %v", i, err)
+		}
+	}
+	if err := traceClient.CloseSend(); err != nil {
+		t.Fatalf("Failed to end the stream: %v", err)
+	}
+	// Refuse the messages only once all of them are being exported, so that the later
+	// refusals find the first one still to be reported.
+	for i := 0; i < numMessages; i++ {
+		<-gated.called
+	}
+	close(gated.release)
+
+	_, err = traceClient.Recv()
+	if g, w := status.Code(err), codes.ResourceExhausted; g != w {
+		t.Fatalf("Got code %v (%v), want %v", g, err, w)
+	}
+	if err := observabilitytest.CheckValueViewReceiverReceivedSpans(receiverTagValue, numMessages); err != nil {
+		t.Errorf("Received spans: %v", err)
+	}
+	if err := observabilitytest.CheckValueViewReceiverDroppedSpans(receiverTagValue, numMessages); err != nil {
+		t.Errorf("Dropped spans: %v", err)
+	}
+}
+
+// gatedRefusingTraceConsumer refuses all the data once released.
+type gatedRefusingTraceConsumer struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (gc *gatedRefusingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	gc.called <- struct{}{}
+	<-gc.release
+	return consumererror.ResourceExhausted(errors.New("queue is full"), 0)
+}
+
+// refusingTraceConsumer refuses all the data as an overloaded consumer would.
+type refusingTraceConsumer struct{}
+
+func (refusingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	return consumererror.ResourceExhausted(errors.New("queue is full"), 0)
+}
+
 // If the first message is valid (has a non-nil Node) and has spans, those
 // spans should be received and NEVER discarded.
 // See https://github.com/census-instrumentation/opencensus-service/issues/51
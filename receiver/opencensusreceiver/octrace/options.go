@@ -14,6 +14,10 @@
 
 package octrace
 
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
 // Option interface defines for configuration settings to be applied to receivers.
 //
 // WithReceiver applies the configuration to the given receiver.
@@ -26,3 +30,15 @@ func WithWorkerCount(workerCount int) Option {
 		r.numWorkers = workerCount
 	}
 }
+
+// WithTraceConfig sets the TraceConfig sent to the libraries connected to the Config
+// stream, see Receiver.SetTraceConfig.
+func WithTraceConfig(defaultConfig *tracepb.TraceConfig, rules []TraceConfigRule) Option {
+	return func(r *Receiver) {
+		r.configs = &traceConfigs{
+			defaultConfig: defaultConfig,
+			rules:         rules,
+			changed:       make(chan struct{}),
+		}
+	}
+}
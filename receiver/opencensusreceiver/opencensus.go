@@ -35,6 +35,7 @@ import (
 
 	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 )
 
 // Receiver is the type that exposes Trace and Metrics reception.
@@ -115,16 +116,34 @@ func (ocr *Receiver) registerTraceConsumer() error {
 	var err = errAlreadyStarted
 
 	ocr.startTraceReceiverOnce.Do(func() {
-		ocr.traceReceiver, err = octrace.New(ocr.traceConsumer, ocr.traceReceiverOpts...)
+		var traceReceiver *octrace.Receiver
+		ocr.mu.Lock()
+		traceReceiver, err = octrace.New(ocr.traceConsumer, ocr.traceReceiverOpts...)
+		ocr.traceReceiver = traceReceiver
+		ocr.mu.Unlock()
 		if err == nil {
 			srv := ocr.grpcServer()
-			agenttracepb.RegisterTraceServiceServer(srv, ocr.traceReceiver)
+			agenttracepb.RegisterTraceServiceServer(srv, traceReceiver)
 		}
 	})
 
 	return err
 }
 
+// SetTraceConfig replaces the TraceConfig sent to the libraries connected to the Config
+// stream of the trace receiver, see octrace.Receiver.SetTraceConfig. If the trace
+// receiver is not started yet it is used once started.
+func (ocr *Receiver) SetTraceConfig(defaultConfig *tracepb.TraceConfig, rules []octrace.TraceConfigRule) {
+	ocr.mu.Lock()
+	defer ocr.mu.Unlock()
+
+	if ocr.traceReceiver != nil {
+		ocr.traceReceiver.SetTraceConfig(defaultConfig, rules)
+		return
+	}
+	ocr.traceReceiverOpts = append(ocr.traceReceiverOpts, octrace.WithTraceConfig(defaultConfig, rules))
+}
+
 // MetricsSource returns the name of the metrics data source.
 func (ocr *Receiver) MetricsSource() string {
 	return source
@@ -210,6 +229,12 @@ func (ocr *Receiver) Stop() error {
 
 	var err = errAlreadyStopped
 	ocr.stopOnce.Do(func() {
+		// The Config streams of the trace receiver outlive the listener, they are ended
+		// so the clients reconnect, e.g.: to the receiver replacing this one on a reload.
+		if ocr.traceReceiver != nil {
+			ocr.traceReceiver.Stop()
+		}
+
 		if ocr.serverHTTP != nil {
 			_ = ocr.serverHTTP.Close()
 		}
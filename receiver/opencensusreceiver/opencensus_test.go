@@ -25,7 +25,9 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
@@ -320,3 +322,47 @@ func TestStopWithoutStartNeverCrashes(t *testing.T) {
 	// Stop it before ever invoking Start*.
 	ocr.Stop()
 }
+
+// The Config streams are ended by Stop, so the clients reconnect to the receiver that
+// replaces this one on a reload.
+func TestStopEndsConfigStreams(t *testing.T) {
+	addr := ":55446"
+	ocr, err := New(addr, new(exportertest.SinkTraceExporter), nil)
+	if err != nil {
+		t.Fatalf("Failed to create an OpenCensus receiver: %v", err)
+	}
+	if err := ocr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start trace receiver: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer cc.Close()
+	client, err := agenttracepb.NewTraceServiceClient(cc).Config(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open the Config stream: %v", err)
+	}
+	first := &agenttracepb.CurrentLibraryConfig{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"}},
+	}
+	if err := client.Send(first); err != nil {
+		t.Fatalf("Failed to send the first message: %v", err)
+	}
+
+	ocr.Stop()
+	for {
+		_, err := client.Recv()
+		if err == nil {
+			// The TraceConfig pushed when the stream was opened.
+			continue
+		}
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("Recv() after Stop = %v, want code %v", err, codes.Unavailable)
+		}
+		return
+	}
+}
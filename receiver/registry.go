@@ -0,0 +1,110 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The registries hold the factories of all receivers linked in the binary. Receiver
+// packages are expected to register their factories from an init function, so a
+// binary only needs to import a receiver package to make it available.
+var (
+	registryMu               sync.RWMutex
+	traceReceiverFactories   = make(map[string]TraceReceiverFactory)
+	metricsReceiverFactories = make(map[string]MetricsReceiverFactory)
+)
+
+// RegisterTraceReceiverFactory makes a TraceReceiverFactory available by its type.
+// If RegisterTraceReceiverFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterTraceReceiverFactory(factory TraceReceiverFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("receiver: RegisterTraceReceiverFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := traceReceiverFactories[typeStr]; dup {
+		panic(fmt.Sprintf("receiver: RegisterTraceReceiverFactory called twice for type %q", typeStr))
+	}
+	traceReceiverFactories[typeStr] = factory
+}
+
+// RegisterMetricsReceiverFactory makes a MetricsReceiverFactory available by its type.
+// If RegisterMetricsReceiverFactory is called twice with the same type or if factory
+// is nil, it panics.
+func RegisterMetricsReceiverFactory(factory MetricsReceiverFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("receiver: RegisterMetricsReceiverFactory factory is nil")
+	}
+	typeStr := factory.Type()
+	if _, dup := metricsReceiverFactories[typeStr]; dup {
+		panic(fmt.Sprintf("receiver: RegisterMetricsReceiverFactory called twice for type %q", typeStr))
+	}
+	metricsReceiverFactories[typeStr] = factory
+}
+
+// GetTraceReceiverFactory returns the registered TraceReceiverFactory for the given
+// type, or nil if there is none.
+func GetTraceReceiverFactory(typeStr string) TraceReceiverFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return traceReceiverFactories[typeStr]
+}
+
+// GetMetricsReceiverFactory returns the registered MetricsReceiverFactory for the given
+// type, or nil if there is none.
+func GetMetricsReceiverFactory(typeStr string) MetricsReceiverFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return metricsReceiverFactories[typeStr]
+}
+
+// TraceReceiverFactories returns all registered TraceReceiverFactory sorted by type.
+func TraceReceiverFactories() []TraceReceiverFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]TraceReceiverFactory, 0, len(traceReceiverFactories))
+	for _, factory := range traceReceiverFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
+
+// MetricsReceiverFactories returns all registered MetricsReceiverFactory sorted by type.
+func MetricsReceiverFactories() []MetricsReceiverFactory {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factories := make([]MetricsReceiverFactory, 0, len(metricsReceiverFactories))
+	for _, factory := range metricsReceiverFactories {
+		factories = append(factories, factory)
+	}
+	sort.Slice(factories, func(i, j int) bool {
+		return factories[i].Type() < factories[j].Type()
+	})
+	return factories
+}
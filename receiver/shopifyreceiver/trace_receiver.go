@@ -16,6 +16,7 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/observability"
 	"github.com/census-instrumentation/opencensus-service/receiver"
@@ -273,10 +274,19 @@ func (sr *ShopifyReceiver) ingest(w http.ResponseWriter, r *http.Request) {
 
 	// Process.
 	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, "shopify")
-	sr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, data.TraceData{
+	err = sr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, data.TraceData{
 		Node:  &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: proxiedApplication}},
 		Spans: ocSpans,
 	})
+	if consumererror.IsResourceExhausted(err) {
+		observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(ocSpans), len(ocSpans)+invalidSpans)
+		span.SetStatus(trace.Status{
+			Code:    trace.StatusCodeResourceExhausted,
+			Message: err.Error(),
+		})
+		receiver.WriteRetryLater(w, err)
+		return
+	}
 
 	observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(ocSpans), invalidSpans)
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/receiver"
 )
 
 var (
@@ -76,6 +77,15 @@ func New(v *viper.Viper, consumer consumer.MetricsConsumer) (*Receiver, error) {
 	return vmr, nil
 }
 
+var _ receiver.MetricsReceiver = (*Receiver)(nil)
+
+const metricsSource string = "VMMetrics"
+
+// MetricsSource returns the name of the metrics data source.
+func (vmr *Receiver) MetricsSource() string {
+	return metricsSource
+}
+
 // StartMetricsReception scrapes VM metrics based on the OS platform.
 func (vmr *Receiver) StartMetricsReception(ctx context.Context, asyncErrorChan chan<- error) error {
 	vmr.mu.Lock()
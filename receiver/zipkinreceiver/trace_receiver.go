@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
@@ -38,6 +39,7 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/internal"
 	"github.com/census-instrumentation/opencensus-service/observability"
@@ -284,6 +286,13 @@ const (
 	zipkinV2TagValue = "zipkinV2"
 )
 
+const (
+	// maxRefusedRetries and maxRefusedRetryTime bound how long a request is held open
+	// to send again the batches refused after part of its payload was accepted.
+	maxRefusedRetries   = 3
+	maxRefusedRetryTime = 5 * time.Second
+)
+
 // The ZipkinReceiver receives spans from endpoint /api/v2 as JSON,
 // unmarshals them and sends them along to the nextConsumer.
 func (zr *ZipkinReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -329,19 +338,67 @@ func (zr *ZipkinReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, receiverTagValue)
 	tdsSize := 0
 	for _, td := range tds {
-		td.SourceFormat = "zipkin"
-		zr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, td)
 		tdsSize += len(td.Spans)
 	}
+	droppedSize := 0
+	var refusedErr error
+	// The client sends the whole payload again, so the request is refused only while
+	// none of it is accepted. Past the first batch, the refused ones are sent again a
+	// few times, within maxRefusedRetryTime, and then dropped.
+	retryCtx, cancelRetries := context.WithTimeout(parentCtx, maxRefusedRetryTime)
+	defer cancelRetries()
+	for i, td := range tds {
+		td.SourceFormat = "zipkin"
+		err := zr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, td)
+		for retries := 0; i > 0 && retries < maxRefusedRetries &&
+			consumererror.IsResourceExhausted(err) && waitRetryAfter(retryCtx, err); retries++ {
+			err = zr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, td)
+		}
+		if consumererror.IsResourceExhausted(err) {
+			if i == 0 {
+				refusedErr = err
+				droppedSize = tdsSize
+				break
+			}
+			droppedSize += len(td.Spans)
+		}
+	}
 
 	// TODO: Get the number of dropped spans from the conversion failure.
-	observability.RecordTraceReceiverMetrics(ctxWithReceiverName, tdsSize, 0)
+	observability.RecordTraceReceiverMetrics(ctxWithReceiverName, tdsSize, droppedSize)
+
+	if refusedErr != nil {
+		span.SetStatus(trace.Status{
+			Code:    trace.StatusCodeResourceExhausted,
+			Message: refusedErr.Error(),
+		})
+		receiver.WriteRetryLater(w, refusedErr)
+		return
+	}
+	if droppedSize > 0 {
+		span.Annotate([]trace.Attribute{
+			trace.Int64Attribute("dropped_spans", int64(droppedSize)),
+		}, "Dropped the spans refused after part of the payload was accepted")
+	}
 
 	// Finally send back the response "Accepted" as
 	// required at https://zipkin.io/zipkin-api/#/default/post_spans
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// waitRetryAfter waits for the delay after which the data refused with err can be sent
+// again. It returns false if ctx is done first.
+func waitRetryAfter(ctx context.Context, err error) bool {
+	timer := time.NewTimer(receiver.RetryAfter(err))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 var (
 	errNilZipkinSpan = errors.New("non-nil Zipkin span expected")
 	errZeroTraceID   = errors.New("trace id is zero")
@@ -17,6 +17,7 @@ package zipkinreceiver
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -35,6 +36,7 @@ import (
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
 	"github.com/census-instrumentation/opencensus-service/internal"
@@ -520,3 +522,73 @@ func TestConversionRoundtrip(t *testing.T) {
 		t.Errorf("The roundtrip JSON doesn't match the JSON that we want\nGot:\n%s\nWant:\n%s", gj, wj)
 	}
 }
+
+func TestZipkinReceiverRefusal_acceptedAsAUnit(t *testing.T) {
+	// The spans of two services are consumed as two batches.
+	payload := `[
+		{"traceId": "4d1e00c0db9010db86154a4ba6e91385", "id": "86154a4ba6e91385", "name": "get",
+		 "timestamp": 1472470996199000, "duration": 207000, "localEndpoint": {"serviceName": "frontend"}},
+		{"traceId": "4d1e00c0db9010db86154a4ba6e91385", "id": "4d1e00c0db9010db", "name": "get",
+		 "parentId": "86154a4ba6e91385", "timestamp": 1472470996250000, "duration": 100000,
+		 "localEndpoint": {"serviceName": "backend"}}
+	]`
+
+	tests := []struct {
+		name        string
+		refusals    []int
+		wantCode    int
+		wantBatches int
+	}{
+		{name: "first batch refused", refusals: []int{0}, wantCode: http.StatusServiceUnavailable, wantBatches: 0},
+		{name: "second batch refused once", refusals: []int{1}, wantCode: http.StatusAccepted, wantBatches: 2},
+		// The second batch is dropped after its retries instead of holding the request.
+		{name: "second batch always refused", refusals: []int{1, 2, 3, 4}, wantCode: http.StatusAccepted, wantBatches: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &refusingOnceTraceConsumer{refusals: tt.refusals}
+			zr := &ZipkinReceiver{nextConsumer: rc}
+
+			req := httptest.NewRequest("POST", "/api/v2/spans", bytes.NewBufferString(payload))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			zr.ServeHTTP(rec, req)
+
+			if g, w := rec.Code, tt.wantCode; g != w {
+				t.Errorf("Got status %d, want %d", g, w)
+			}
+			// Every accepted batch is consumed exactly once.
+			if g, w := len(rc.accepted), tt.wantBatches; g != w {
+				t.Errorf("Got %d accepted batches, want %d", g, w)
+			}
+			seen := make(map[string]bool)
+			for _, td := range rc.accepted {
+				name := td.Node.GetServiceInfo().GetName()
+				if seen[name] {
+					t.Errorf("Batch of %q accepted twice", name)
+				}
+				seen[name] = true
+			}
+		})
+	}
+}
+
+// refusingOnceTraceConsumer refuses the batches at the given call indexes once, and
+// accepts all the others.
+type refusingOnceTraceConsumer struct {
+	calls    int
+	refusals []int
+	accepted []data.TraceData
+}
+
+func (rc *refusingOnceTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	call := rc.calls
+	rc.calls++
+	for _, refusal := range rc.refusals {
+		if call == refusal {
+			return consumererror.ResourceExhausted(errors.New("queue is full"), time.Millisecond)
+		}
+	}
+	rc.accepted = append(rc.accepted, td)
+	return nil
+}
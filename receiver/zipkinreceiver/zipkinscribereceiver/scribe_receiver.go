@@ -27,6 +27,7 @@ import (
 	"github.com/omnition/scribe-go/if/scribe/gen-go/scribe"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/consumer/consumererror"
 	"github.com/census-instrumentation/opencensus-service/observability"
 	"github.com/census-instrumentation/opencensus-service/receiver"
 	zipkintranslator "github.com/census-instrumentation/opencensus-service/translator/trace/zipkin"
@@ -176,7 +177,12 @@ func (sc *scribeCollector) Log(messages []*scribe.LogEntry) (r scribe.ResultCode
 	tdsSize := 0
 	for _, td := range tds {
 		td.SourceFormat = "zipkin-scribe"
-		sc.nextConsumer.ConsumeTraceData(sc.defaultCtx, td)
+		err := sc.nextConsumer.ConsumeTraceData(sc.defaultCtx, td)
+		if consumererror.IsResourceExhausted(err) {
+			// Scribe clients send the whole message list again later.
+			observability.RecordTraceReceiverMetrics(sc.defaultCtx, len(zSpans), len(zSpans)-tdsSize)
+			return scribe.ResultCode_TRY_LATER, nil
+		}
 		tdsSize += len(td.Spans)
 	}
 